@@ -0,0 +1,91 @@
+package simla
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nyambati/simla/internal/openapi"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	apiExportOutput string
+	apiExportFormat string
+)
+
+var apiCmd = &cobra.Command{
+	Use:   "api",
+	Short: "Work with the API Gateway definition",
+	Long:  `Commands for the API Gateway stage defined in .simla.yaml.`,
+}
+
+// ---------------------------------------------------------------------------
+// api export
+// ---------------------------------------------------------------------------
+
+var apiExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the API Gateway stage as an OpenAPI 3.1 document",
+	Long: `Print an OpenAPI 3.1 document describing the API Gateway stage, routes,
+authorizers, request models and CORS settings in .simla.yaml.
+
+Each operation carries an x-amazon-apigateway-integration whose URI names the
+route's service as the Lambda function, so the document can be fed back in
+through apiGateway.openapi. Examples:
+
+  simla api export
+  simla api export -o openapi.json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		doc, err := openapi.Export(cfg)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to export api")
+		}
+
+		format := strings.ToLower(apiExportFormat)
+		if format == "" {
+			format = "yaml"
+			if strings.EqualFold(filepath.Ext(apiExportOutput), ".json") {
+				format = "json"
+			}
+		}
+
+		var data []byte
+		switch format {
+		case "json":
+			data, err = json.MarshalIndent(doc, "", "  ")
+			data = append(data, '\n')
+		case "yaml":
+			var buf bytes.Buffer
+			encoder := yaml.NewEncoder(&buf)
+			encoder.SetIndent(2)
+			err = encoder.Encode(doc)
+			data = buf.Bytes()
+		default:
+			logger.Fatalf("unsupported format %q: use yaml or json", apiExportFormat)
+		}
+		if err != nil {
+			logger.WithError(err).Fatal("failed to encode document")
+		}
+
+		if apiExportOutput == "" {
+			_, _ = os.Stdout.Write(data)
+			return
+		}
+		if err := os.WriteFile(apiExportOutput, data, 0o644); err != nil {
+			logger.WithError(err).Fatal("failed to write document")
+		}
+		logger.Infof("wrote %s", apiExportOutput)
+	},
+}
+
+func init() {
+	apiExportCmd.Flags().StringVarP(&apiExportOutput, "output", "o", "", "File to write (default: stdout)")
+	apiExportCmd.Flags().StringVarP(&apiExportFormat, "format", "f", "", "yaml or json (default: from the output extension, else yaml)")
+	apiCmd.AddCommand(apiExportCmd)
+	rootCmd.AddCommand(apiCmd)
+}
@@ -0,0 +1,80 @@
+package simla
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nyambati/simla/internal/build"
+	"github.com/spf13/cobra"
+)
+
+var buildCmd = &cobra.Command{
+	Use:   "build [service-name...]",
+	Short: "Build services that have a build step",
+	Long: `Build the services that define a build section, or only the named ones.
+
+Go services are cross-compiled for their architecture with cgo disabled,
+Python services are packaged with the dependencies of their requirements.txt,
+and services with a build command run it. The output is written to
+~/.simla/build/<service> and mounted at /var/task when the service starts.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		names := args
+		if len(names) == 0 {
+			names = buildableServices()
+			if len(names) == 0 {
+				fmt.Println("No services define a build step.")
+				return
+			}
+		}
+		for _, name := range names {
+			svc, ok := cfg.GetService(ctx, name)
+			if !ok {
+				logger.Fatalf("service %s not found", name)
+			}
+			if svc.Build == nil {
+				logger.Fatalf("service %s does not define a build step", name)
+			}
+		}
+		if err := buildServices(names); err != nil {
+			logger.Fatal(err)
+		}
+	},
+}
+
+// buildableServices returns the names of the services with a build step,
+// sorted.
+func buildableServices() []string {
+	var names []string
+	for name, svc := range cfg.Services {
+		if svc.Build != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildServices builds the named services, logging each failure with the
+// output of its build tool. It returns an error if any build failed.
+func buildServices(names []string) error {
+	builder, err := build.NewDefaultBuilder(logger.WithField("component", "build"))
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, name := range names {
+		svc, _ := cfg.GetService(ctx, name)
+		if _, err := builder.Build(ctx, name, svc); err != nil {
+			logger.WithField("service", name).Error(err.Error())
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d builds failed", failed, len(names))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(buildCmd)
+}
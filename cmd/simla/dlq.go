@@ -0,0 +1,148 @@
+package simla
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nyambati/simla/internal/async"
+	"github.com/nyambati/simla/internal/scheduler"
+	"github.com/spf13/cobra"
+)
+
+var dlqListJSON bool
+
+var dlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "Inspect and replay failed asynchronous invocations",
+	Long: `Commands for the dead-letter store, which keeps asynchronous invocations that
+ran out of retries or exceeded their maximum event age.`,
+}
+
+// ---------------------------------------------------------------------------
+// dlq list
+// ---------------------------------------------------------------------------
+
+var dlqListCmd = &cobra.Command{
+	Use:   "list [service-name]",
+	Short: "List dead letters, optionally for one service",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		service := ""
+		if len(args) == 1 {
+			service = args[0]
+		}
+
+		letters := listDeadLetters(service)
+
+		if dlqListJSON {
+			data, err := json.MarshalIndent(letters, "", "  ")
+			if err != nil {
+				logger.WithError(err).Fatal("failed to encode dead letters")
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		if len(letters) == 0 {
+			fmt.Println("No dead letters.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "ID\tSERVICE\tCONDITION\tATTEMPTS\tFAILED AT\tERROR")
+		fmt.Fprintln(w, "--\t-------\t---------\t--------\t---------\t-----")
+
+		for _, letter := range letters {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+				letter.ID,
+				letter.Service,
+				letter.Condition,
+				letter.Attempts,
+				letter.FailedAt.Local().Format("2006-01-02 15:04:05"),
+				truncate(letter.Error, 60),
+			)
+		}
+		w.Flush()
+	},
+}
+
+// ---------------------------------------------------------------------------
+// dlq replay
+// ---------------------------------------------------------------------------
+
+var dlqReplayCmd = &cobra.Command{
+	Use:   "replay <service-name> [id...]",
+	Short: "Invoke a service again with its dead letters",
+	Long: `Invoke a service synchronously with the payload of each of its dead letters,
+or only of the given IDs. Dead letters that succeed are removed from the store;
+the others are kept with the new error.
+
+Example:
+  simla dlq replay orders
+  simla dlq replay orders 3f1c2a8e-...`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		service, ids := args[0], args[1:]
+
+		store := deadLetterStore()
+		letters := listDeadLetters(service)
+		sched := scheduler.NewScheduler(cfg, svcRegistry, logger.WithField("component", "scheduler"))
+
+		replayed, failed := 0, 0
+		for _, letter := range letters {
+			if len(ids) > 0 && !slices.Contains(ids, letter.ID) {
+				continue
+			}
+			replayed++
+			if err := async.Replay(ctx, sched, store, letter); err != nil {
+				failed++
+				logger.WithError(err).Errorf("replay of %s failed", letter.ID)
+				continue
+			}
+			logger.Infof("replayed %s", letter.ID)
+		}
+
+		if replayed == 0 {
+			logger.Fatalf("no matching dead letters for service %s", service)
+		}
+		if failed > 0 {
+			logger.Fatalf("%d of %d replays failed", failed, replayed)
+		}
+	},
+}
+
+func deadLetterStore() *async.DeadLetterStore {
+	store, err := async.DefaultDeadLetterStore()
+	if err != nil {
+		logger.WithError(err).Fatal("failed to open dead-letter store")
+	}
+	return store
+}
+
+func listDeadLetters(service string) []*async.DeadLetter {
+	letters, err := deadLetterStore().List(service)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to read dead letters")
+	}
+	return letters
+}
+
+// truncate shortens s to n runes for table output, on a single line.
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-3]) + "..."
+	}
+	return s
+}
+
+func init() {
+	dlqListCmd.Flags().BoolVar(&dlqListJSON, "json", false, "Print dead letters, including payloads, as JSON")
+	dlqCmd.AddCommand(dlqListCmd)
+	dlqCmd.AddCommand(dlqReplayCmd)
+	rootCmd.AddCommand(dlqCmd)
+}
@@ -2,9 +2,11 @@ package simla
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/nyambati/simla/internal/config"
 	"github.com/nyambati/simla/internal/runtime"
 	"github.com/spf13/cobra"
 )
@@ -30,7 +32,11 @@ Use --follow / -f to keep the stream open until the container stops or Ctrl+C is
 			logger.Fatalf("service %q has no associated container (has it been started?)", serviceName)
 		}
 
-		rt, err := runtime.NewRuntime(svcRegistry, logger.WithField("component", "runtime"))
+		backend := config.BackendDocker
+		if runtime.IsProcess(svc.ID) {
+			backend = config.BackendProcess
+		}
+		rt, err := runtime.New(backend, svcRegistry, logger.WithField("component", "runtime"))
 		if err != nil {
 			logger.WithError(err).Fatal("failed to create runtime client")
 		}
@@ -48,8 +54,13 @@ Use --follow / -f to keep the stream open until the container stops or Ctrl+C is
 		defer reader.Close()
 
 		// Docker multiplexes stdout and stderr into a single stream with an
-		// 8-byte header per frame. stdcopy.StdCopy demultiplexes it.
-		_, err = stdcopy.StdCopy(os.Stdout, os.Stderr, reader)
+		// 8-byte header per frame. stdcopy.StdCopy demultiplexes it. Process
+		// output is plain.
+		if backend == config.BackendProcess {
+			_, err = io.Copy(os.Stdout, reader)
+		} else {
+			_, err = stdcopy.StdCopy(os.Stdout, os.Stderr, reader)
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error reading log stream: %v\n", err)
 		}
@@ -10,6 +10,7 @@ import (
 	"syscall"
 
 	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/openapi"
 	"github.com/nyambati/simla/internal/registry"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -69,9 +70,13 @@ func loadConfig() error {
 	viper.SetConfigName(".simla")
 	viper.SetConfigType("yaml")
 
-	if err := viper.ReadInConfig(); err != nil {
+	if err := cfg.Load(viper.GetViper()); err != nil {
 		return err
 	}
 
-	return viper.Unmarshal(cfg)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	return openapi.Import(&cfg.APIGateway, cfg.Services, logger.WithField("component", "openapi"))
 }
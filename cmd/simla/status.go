@@ -21,7 +21,11 @@ Columns:
   INVOCATIONS Total number of invocations recorded
   ERRORS      Number of invocations that returned an error
   ERROR RATE  Fraction of invocations that errored (0.00–1.00)
+  THROTTLES   Invocations rejected at the service's reserved concurrency
   AVG LATENCY Mean invocation round-trip time
+  COLD STARTS Invocations that had to start a container first
+  WARM        Invocations served by a running container
+  AVG INIT    Mean time a cold start took to become healthy
   LAST INVOKED Wall-clock time of the most recent invocation`,
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -40,8 +44,8 @@ Columns:
 		sort.Strings(names)
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-		fmt.Fprintln(w, "SERVICE\tINVOCATIONS\tERRORS\tERROR RATE\tAVG LATENCY\tLAST INVOKED")
-		fmt.Fprintln(w, "-------\t-----------\t------\t----------\t-----------\t------------")
+		fmt.Fprintln(w, "SERVICE\tINVOCATIONS\tERRORS\tERROR RATE\tTHROTTLES\tAVG LATENCY\tCOLD STARTS\tWARM\tAVG INIT\tLAST INVOKED")
+		fmt.Fprintln(w, "-------\t-----------\t------\t----------\t---------\t-----------\t-----------\t----\t--------\t------------")
 
 		for _, name := range names {
 			m := all[name]
@@ -51,12 +55,16 @@ Columns:
 				lastInvoked = m.LastInvoked.Format(time.RFC3339)
 			}
 
-			fmt.Fprintf(w, "%s\t%d\t%d\t%.2f\t%s\t%s\n",
+			fmt.Fprintf(w, "%s\t%d\t%d\t%.2f\t%d\t%s\t%d\t%d\t%s\t%s\n",
 				name,
 				m.Invocations,
 				m.Errors,
 				m.ErrorRate(),
+				m.Throttles,
 				m.AvgLatency().Round(time.Millisecond),
+				m.ColdStarts,
+				m.WarmInvocations(),
+				m.AvgInitDuration().Round(time.Millisecond),
 				lastInvoked,
 			)
 		}
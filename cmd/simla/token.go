@@ -0,0 +1,104 @@
+package simla
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/token"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tokenAuthorizer string
+	tokenIssuer     string
+	tokenAudience   []string
+	tokenSubject    string
+	tokenScopes     []string
+	tokenClaims     []string
+	tokenExpiresIn  time.Duration
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Sign a test JWT for routes protected by a JWT authorizer",
+	Long: `Sign a JWT with simla's local signing key and print it.
+
+The key is generated in ~/.simla/jwt on first use, alongside the jwks.json that
+JWT authorizers without a jwksFile or jwksUrl verify against. Use --authorizer
+to take the issuer and audience from an authorizer in .simla.yaml:
+
+  curl -H "Authorization: Bearer $(simla token -a my-jwt --scope orders:read)" \
+    http://localhost:8080/v1/orders`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		issuer, audience := tokenIssuer, tokenAudience
+		if tokenAuthorizer != "" {
+			authorizer, exists := cfg.APIGateway.GetAuthorizer(context.Background(), tokenAuthorizer)
+			if !exists {
+				logger.Fatalf("authorizer %q not found in config", tokenAuthorizer)
+			}
+			if authorizer.Type != config.AuthorizerTypeJWT {
+				logger.Fatalf("authorizer %q is not a jwt authorizer", tokenAuthorizer)
+			}
+			if authorizer.JWKSFile != "" || authorizer.JWKSURL != "" {
+				logger.Warnf("authorizer %q verifies against its own key set; tokens signed by simla will be rejected", tokenAuthorizer)
+			}
+			if issuer == "" {
+				issuer = authorizer.Issuer
+			}
+			if len(audience) == 0 {
+				audience = authorizer.Audience
+			}
+		}
+		if issuer == "" {
+			issuer = token.DefaultIssuer
+		}
+
+		claims := map[string]any{
+			"iss": issuer,
+			"sub": tokenSubject,
+		}
+		if len(audience) > 0 {
+			claims["aud"] = audience
+		}
+		if len(tokenScopes) > 0 {
+			claims["scope"] = strings.Join(tokenScopes, " ")
+		}
+		for _, claim := range tokenClaims {
+			key, value, ok := strings.Cut(claim, "=")
+			if !ok || key == "" {
+				logger.Fatalf("invalid claim %q: expected key=value", claim)
+			}
+			claims[key] = value
+		}
+
+		dir, err := token.DefaultDir()
+		if err != nil {
+			logger.WithError(err).Fatal("failed to resolve signing key directory")
+		}
+		issuerKey, err := token.LoadOrCreateIssuer(dir)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to load signing key")
+		}
+
+		signed, err := issuerKey.Sign(claims, tokenExpiresIn)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to sign token")
+		}
+		fmt.Println(signed)
+	},
+}
+
+func init() {
+	tokenCmd.Flags().StringVarP(&tokenAuthorizer, "authorizer", "a", "", "JWT authorizer whose issuer and audience to use")
+	tokenCmd.Flags().StringVar(&tokenIssuer, "issuer", "", "Issuer (iss) claim (default: the authorizer's issuer or "+token.DefaultIssuer+")")
+	tokenCmd.Flags().StringSliceVar(&tokenAudience, "audience", nil, "Audience (aud) claim; may be repeated")
+	tokenCmd.Flags().StringVarP(&tokenSubject, "subject", "s", "simla-user", "Subject (sub) claim")
+	tokenCmd.Flags().StringSliceVar(&tokenScopes, "scope", nil, "Scope to grant; may be repeated")
+	tokenCmd.Flags().StringArrayVarP(&tokenClaims, "claim", "c", nil, "Extra string claim as key=value; may be repeated")
+	tokenCmd.Flags().DurationVarP(&tokenExpiresIn, "expires-in", "e", time.Hour, "Token lifetime")
+	rootCmd.AddCommand(tokenCmd)
+}
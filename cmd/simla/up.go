@@ -3,7 +3,9 @@ package simla
 import (
 	"context"
 
+	"github.com/nyambati/simla/internal/async"
 	"github.com/nyambati/simla/internal/gateway"
+	"github.com/nyambati/simla/internal/lambdaapi"
 	"github.com/nyambati/simla/internal/scheduler"
 	"github.com/nyambati/simla/internal/trigger"
 	"github.com/nyambati/simla/internal/watcher"
@@ -17,9 +19,37 @@ var upCmd = &cobra.Command{
 	Short: "Start simla server",
 	Long:  `Start the simla local Lambda development server.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := buildServices(buildableServices()); err != nil {
+			logger.WithError(err).Fatal("failed to build services")
+		}
+
 		sched := scheduler.NewScheduler(cfg, svcRegistry, logger.WithField("component", "scheduler"))
 		gw := gateway.NewAPIGateway(cfg, svcRegistry, logger)
 
+		store, err := async.DefaultDeadLetterStore()
+		if err != nil {
+			logger.WithError(err).Fatal("failed to open dead-letter store")
+		}
+		queue, err := async.NewQueue(cfg, sched, store, logger.WithField("component", "async"))
+		if err != nil {
+			logger.WithError(err).Fatal("invalid event invoke configuration")
+		}
+		go func() {
+			if err := queue.Start(ctx); err != nil {
+				logger.WithError(err).Error("async queue exited with error")
+			}
+		}()
+
+		reaper, err := scheduler.NewReaper(cfg, svcRegistry, logger.WithField("component", "reaper"))
+		if err != nil {
+			logger.WithError(err).Fatal("invalid idle timeout configuration")
+		}
+		go func() {
+			if err := reaper.Start(ctx); err != nil {
+				logger.WithError(err).Error("reaper exited with error")
+			}
+		}()
+
 		if watchMode {
 			w := watcher.New(cfg, sched, logger.WithField("component", "watcher"), 0)
 			go func() {
@@ -31,7 +61,25 @@ var upCmd = &cobra.Command{
 		}
 
 		// Start all configured triggers in the background.
-		startTriggers(sched)
+		startTriggers(sched, queue)
+
+		if cfg.WebSocketAPI.Port != "" {
+			ws := gateway.NewWebSocketGateway(cfg, svcRegistry, logger)
+			go func() {
+				if err := ws.Start(ctx); err != nil {
+					logger.WithError(err).Error("websocket api exited with error")
+				}
+			}()
+		}
+
+		if cfg.LambdaAPI.Port != "" {
+			api := lambdaapi.NewServer(cfg, svcRegistry, queue, logger)
+			go func() {
+				if err := api.Start(ctx); err != nil {
+					logger.WithError(err).Error("lambda api exited with error")
+				}
+			}()
+		}
 
 		if err := gw.Start(ctx); err != nil {
 			logger.WithError(err).Error("gateway exited with error")
@@ -48,13 +96,14 @@ var upCmd = &cobra.Command{
 
 // startTriggers iterates all services in the config, constructs a trigger
 // Source for each Trigger definition, and runs them as background goroutines.
-func startTriggers(sched scheduler.SchedulerInterface) {
+func startTriggers(sched scheduler.SchedulerInterface, queue async.QueueInterface) {
 	for serviceName, svc := range cfg.Services {
 		for _, trig := range svc.Triggers {
 			src, err := trigger.New(
 				trig,
 				serviceName,
 				sched,
+				queue,
 				logger.WithFields(map[string]interface{}{
 					"component": "trigger",
 					"service":   serviceName,
@@ -0,0 +1,147 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nyambati/simla/internal/scheduler"
+)
+
+// DeadLetter is an event that was discarded, with the reason.
+type DeadLetter struct {
+	Event
+	Condition string    `json:"condition"`
+	Error     string    `json:"error"`
+	FailedAt  time.Time `json:"failedAt"`
+}
+
+// DeadLetterStoreInterface persists discarded events.
+type DeadLetterStoreInterface interface {
+	Put(letter *DeadLetter) error
+	List(service string) ([]*DeadLetter, error)
+	Delete(service, id string) error
+}
+
+// DeadLetterStore keeps one JSON file per dead letter under
+// <dir>/<service>/<id>.json, so that `simla dlq` can read what a running
+// `simla up` discarded.
+type DeadLetterStore struct {
+	dir string
+}
+
+var _ DeadLetterStoreInterface = (*DeadLetterStore)(nil)
+
+// NewDeadLetterStore returns a store that keeps dead letters under dir.
+func NewDeadLetterStore(dir string) *DeadLetterStore {
+	return &DeadLetterStore{dir: dir}
+}
+
+// DefaultDeadLetterStore returns the store under ~/.simla/dlq.
+func DefaultDeadLetterStore() (*DeadLetterStore, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return NewDeadLetterStore(filepath.Join(home, ".simla", "dlq")), nil
+}
+
+func (s *DeadLetterStore) Put(letter *DeadLetter) error {
+	dir := filepath.Join(s.dir, letter.Service)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+	data, err := json.MarshalIndent(letter, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write then rename so `simla dlq` never reads a partial file.
+	tmp := filepath.Join(dir, "."+letter.ID+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, letter.ID+".json"))
+}
+
+// List returns the dead letters of service, or of every service when
+// service is empty, oldest failure first.
+func (s *DeadLetterStore) List(service string) ([]*DeadLetter, error) {
+	services := []string{service}
+	if service == "" {
+		entries, err := os.ReadDir(s.dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		services = services[:0]
+		for _, entry := range entries {
+			if entry.IsDir() {
+				services = append(services, entry.Name())
+			}
+		}
+	}
+
+	var letters []*DeadLetter
+	for _, name := range services {
+		entries, err := os.ReadDir(filepath.Join(s.dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(s.dir, name, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			var letter DeadLetter
+			if err := json.Unmarshal(data, &letter); err != nil {
+				return nil, fmt.Errorf("dead letter %s/%s: %w", name, entry.Name(), err)
+			}
+			letters = append(letters, &letter)
+		}
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+	return letters, nil
+}
+
+func (s *DeadLetterStore) Delete(service, id string) error {
+	err := os.Remove(filepath.Join(s.dir, service, id+".json"))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("dead letter %s not found for service %s", id, service)
+	}
+	return err
+}
+
+// Replay invokes the service of letter synchronously with the original
+// payload. A successful replay removes the letter from store; a failed one
+// records the new attempt and error and keeps it.
+func Replay(ctx context.Context, sched scheduler.SchedulerInterface, store DeadLetterStoreInterface, letter *DeadLetter) error {
+	ctx = context.WithValue(ctx, "service", letter.Service)
+	response, err := sched.Invoke(ctx, letter.Service, letter.Payload)
+	if err == nil && scheduler.IsFunctionError(response) {
+		err = errors.New(string(response))
+	}
+	if err == nil {
+		return store.Delete(letter.Service, letter.ID)
+	}
+
+	letter.Attempts++
+	letter.Error = err.Error()
+	letter.FailedAt = time.Now().UTC()
+	if putErr := store.Put(letter); putErr != nil {
+		return errors.Join(err, putErr)
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package async
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nyambati/simla/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestDeadLetterStore(t *testing.T) {
+	store := NewDeadLetterStore(t.TempDir())
+
+	letters, err := store.List("")
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+
+	now := time.Now().UTC()
+	require.NoError(t, store.Put(&DeadLetter{Event: Event{ID: "b", Service: "orders", Payload: []byte(`{}`)}, FailedAt: now}))
+	require.NoError(t, store.Put(&DeadLetter{Event: Event{ID: "a", Service: "orders", Payload: []byte(`{}`)}, FailedAt: now.Add(-time.Minute)}))
+	require.NoError(t, store.Put(&DeadLetter{Event: Event{ID: "c", Service: "audit", Payload: []byte(`{}`)}, FailedAt: now.Add(time.Minute)}))
+
+	letters, err = store.List("orders")
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, "a", letters[0].ID, "oldest failure first")
+	assert.Equal(t, "b", letters[1].ID)
+
+	letters, err = store.List("")
+	require.NoError(t, err)
+	assert.Len(t, letters, 3)
+
+	require.NoError(t, store.Delete("orders", "a"))
+	assert.Error(t, store.Delete("orders", "a"))
+	letters, err = store.List("orders")
+	require.NoError(t, err)
+	assert.Len(t, letters, 1)
+}
+
+func TestReplay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	store := NewDeadLetterStore(t.TempDir())
+
+	ok := &DeadLetter{Event: Event{ID: "ok", Service: "orders", Payload: []byte(`{"id":1}`), Attempts: 3}}
+	bad := &DeadLetter{Event: Event{ID: "bad", Service: "orders", Payload: []byte(`{"id":2}`), Attempts: 3}}
+	require.NoError(t, store.Put(ok))
+	require.NoError(t, store.Put(bad))
+
+	sched.EXPECT().Invoke(gomock.Any(), "orders", []byte(`{"id":1}`)).Return([]byte(`{}`), nil)
+	sched.EXPECT().Invoke(gomock.Any(), "orders", []byte(`{"id":2}`)).
+		Return([]byte(`{"errorMessage":"still failing","errorType":"Error"}`), nil)
+
+	require.NoError(t, Replay(context.Background(), sched, store, ok))
+	assert.Error(t, Replay(context.Background(), sched, store, bad))
+
+	letters, err := store.List("orders")
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "bad", letters[0].ID)
+	assert.Equal(t, 4, letters[0].Attempts)
+	assert.Contains(t, letters[0].Error, "still failing")
+}
@@ -0,0 +1,335 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nyambati/simla/internal/config"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
+	"github.com/nyambati/simla/internal/scheduler"
+	"github.com/nyambati/simla/internal/workflow"
+	"github.com/sirupsen/logrus"
+)
+
+var _ QueueInterface = (*Queue)(nil)
+
+// NewQueue validates the event invoke configuration of every service and
+// returns a queue that invokes them through sched. Events that are
+// discarded are written to store.
+func NewQueue(cfg *config.Config, sched scheduler.SchedulerInterface, store DeadLetterStoreInterface, logger *logrus.Entry) (*Queue, error) {
+	policies := make(map[string]policy, len(cfg.Services))
+	for name, svc := range cfg.Services {
+		p, err := newPolicy(cfg, svc.EventInvoke)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: eventInvoke: %w", name, err)
+		}
+		policies[name] = p
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		config:    cfg,
+		scheduler: sched,
+		executor:  workflow.NewExecutor(cfg, sched, logger.WithField("component", "workflow")),
+		store:     store,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		logger:    logger,
+		ctx:       ctx,
+		cancel:    cancel,
+		services:  make(map[string]chan *Event),
+		policies:  policies,
+	}, nil
+}
+
+// newPolicy applies Lambda's defaults to an event invoke configuration and
+// checks its limits and destinations.
+func newPolicy(cfg *config.Config, eic *config.EventInvokeConfig) (policy, error) {
+	p := policy{
+		maxAge:  config.DefaultMaximumEventAgeInSeconds * time.Second,
+		retries: config.DefaultMaximumRetryAttempts,
+	}
+	retryDelay := config.DefaultRetryDelay
+	if eic == nil {
+		p.retryDelay, _ = time.ParseDuration(retryDelay)
+		return p, nil
+	}
+
+	if age := eic.MaximumEventAgeInSeconds; age != 0 {
+		if age < config.MinMaximumEventAgeInSeconds || age > config.DefaultMaximumEventAgeInSeconds {
+			return p, fmt.Errorf("maximumEventAgeInSeconds must be between %d and %d, got %d",
+				config.MinMaximumEventAgeInSeconds, config.DefaultMaximumEventAgeInSeconds, age)
+		}
+		p.maxAge = time.Duration(age) * time.Second
+	}
+	if eic.MaximumRetryAttempts != nil {
+		if *eic.MaximumRetryAttempts < 0 || *eic.MaximumRetryAttempts > config.DefaultMaximumRetryAttempts {
+			return p, fmt.Errorf("maximumRetryAttempts must be between 0 and %d, got %d",
+				config.DefaultMaximumRetryAttempts, *eic.MaximumRetryAttempts)
+		}
+		p.retries = *eic.MaximumRetryAttempts
+	}
+	if eic.RetryDelay != "" {
+		retryDelay = eic.RetryDelay
+	}
+	delay, err := time.ParseDuration(retryDelay)
+	if err != nil || delay < 0 {
+		return p, fmt.Errorf("invalid retryDelay %q", eic.RetryDelay)
+	}
+	p.retryDelay = delay
+
+	if p.onSuccess, err = resolveDestination(cfg, eic.OnSuccess); err != nil {
+		return p, fmt.Errorf("onSuccess: %w", err)
+	}
+	if p.onFailure, err = resolveDestination(cfg, eic.OnFailure); err != nil {
+		return p, fmt.Errorf("onFailure: %w", err)
+	}
+	return p, nil
+}
+
+// resolveDestination checks that a destination names a configured target,
+// and returns a copy naming it as it appears in cfg.
+func resolveDestination(cfg *config.Config, dest *config.Destination) (*config.Destination, error) {
+	if dest == nil {
+		return nil, nil
+	}
+	resolved := *dest
+	switch dest.Type {
+	case config.DestinationTypeService:
+		name, ok := cfg.ServiceName(dest.Service)
+		if !ok {
+			return nil, fmt.Errorf("unknown service %q", dest.Service)
+		}
+		resolved.Service = name
+	case config.DestinationTypeWorkflow:
+		name, ok := cfg.WorkflowName(dest.Workflow)
+		if !ok {
+			return nil, fmt.Errorf("unknown workflow %q", dest.Workflow)
+		}
+		resolved.Workflow = name
+	case config.DestinationTypeQueue:
+		if dest.QueueURL == "" {
+			return nil, fmt.Errorf("queueUrl is required")
+		}
+	default:
+		return nil, fmt.Errorf("unknown destination type %q: expected service, workflow or queue", dest.Type)
+	}
+	return &resolved, nil
+}
+
+// Start blocks until ctx is cancelled and then stops the workers. Events
+// still queued or waiting for a retry are dropped.
+func (q *Queue) Start(ctx context.Context) error {
+	<-ctx.Done()
+	q.logger.Info("stopping async invocation queue")
+	q.cancel()
+	return nil
+}
+
+func (q *Queue) Enqueue(ctx context.Context, service string, payload []byte) (string, error) {
+	if _, ok := q.policies[service]; !ok {
+		return "", simlaerrors.NewServiceNotFoundError(service)
+	}
+	event := &Event{
+		ID:         uuid.NewString(),
+		Service:    service,
+		Payload:    append(json.RawMessage{}, payload...),
+		EnqueuedAt: time.Now().UTC(),
+	}
+	select {
+	case q.queue(service) <- event:
+	default:
+		return "", fmt.Errorf("async queue for service %s is full (%d events)", service, queueCapacity)
+	}
+	q.logger.WithFields(logrus.Fields{"service": service, "request_id": event.ID}).Debug("event queued")
+	return event.ID, nil
+}
+
+// queue returns the event channel of service, starting its worker on first
+// use.
+func (q *Queue) queue(service string) chan *Event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ch, ok := q.services[service]
+	if !ok {
+		ch = make(chan *Event, queueCapacity)
+		q.services[service] = ch
+		go q.work(ch)
+	}
+	return ch
+}
+
+func (q *Queue) work(ch chan *Event) {
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case event := <-ch:
+			q.process(event)
+		}
+	}
+}
+
+// process invokes the service of event once and decides what happens next:
+// a destination record on success, a retry after backoff, or discarding the
+// event once it is out of retries or too old.
+func (q *Queue) process(event *Event) {
+	p := q.policies[event.Service]
+	logger := q.logger.WithFields(logrus.Fields{"service": event.Service, "request_id": event.ID})
+
+	if age := time.Since(event.EnqueuedAt); age > p.maxAge {
+		q.discard(event, nil, ConditionEventAgeExceeded, fmt.Errorf("event age %s exceeds the maximum of %s", age.Round(time.Second), p.maxAge))
+		return
+	}
+
+	event.Attempts++
+	ctx := context.WithValue(q.ctx, "service", event.Service)
+	response, err := q.scheduler.Invoke(ctx, event.Service, event.Payload)
+	result := &invocationResult{response: response, err: err}
+	if err == nil && !scheduler.IsFunctionError(response) {
+		logger.Debug("asynchronous invocation succeeded")
+		q.deliver(p.onSuccess, q.record(event, result, ConditionSuccess), logger)
+		return
+	}
+
+	// Throttled events never reached the function, so like Lambda they are
+	// retried until they are too old without using up retry attempts.
+	var throttled *simlaerrors.TooManyRequestsError
+	if errors.As(err, &throttled) {
+		event.Attempts--
+		logger.Debug("asynchronous invocation throttled")
+		q.retry(event, result, throttleRetryDelay)
+		return
+	}
+	logger.WithError(result.failure()).Warnf("asynchronous invocation failed (attempt %d of %d)", event.Attempts, p.retries+1)
+
+	if event.Attempts > p.retries {
+		q.discard(event, result, ConditionRetriesExhausted, result.failure())
+		return
+	}
+	q.retry(event, result, p.retryDelay<<(event.Attempts-1))
+}
+
+// retry queues event again after delay, or discards it when it would exceed
+// its maximum age by then.
+func (q *Queue) retry(event *Event, result *invocationResult, delay time.Duration) {
+	if time.Since(event.EnqueuedAt)+delay > q.policies[event.Service].maxAge {
+		q.discard(event, result, ConditionEventAgeExceeded, result.failure())
+		return
+	}
+
+	ch := q.queue(event.Service)
+	time.AfterFunc(delay, func() {
+		select {
+		case ch <- event:
+		case <-q.ctx.Done():
+		}
+	})
+}
+
+// discard moves event to the dead-letter store and notifies the OnFailure
+// destination. result is nil when the event was never invoked.
+func (q *Queue) discard(event *Event, result *invocationResult, condition string, cause error) {
+	logger := q.logger.WithFields(logrus.Fields{"service": event.Service, "request_id": event.ID, "condition": condition})
+	logger.WithError(cause).Error("discarding asynchronous invocation")
+
+	letter := &DeadLetter{Event: *event, Condition: condition, Error: cause.Error(), FailedAt: time.Now().UTC()}
+	if err := q.store.Put(letter); err != nil {
+		logger.WithError(err).Error("failed to write dead letter")
+	}
+	q.deliver(q.policies[event.Service].onFailure, q.record(event, result, condition), logger)
+}
+
+// invocationResult is the outcome of one invocation of an event.
+type invocationResult struct {
+	response []byte
+	err      error
+}
+
+// failure describes a failed invocation.
+func (r *invocationResult) failure() error {
+	if r.err != nil {
+		return r.err
+	}
+	return errors.New(string(r.response))
+}
+
+// record builds the destination record of event. result is nil when the
+// event was never invoked.
+func (q *Queue) record(event *Event, result *invocationResult, condition string) *DestinationRecord {
+	record := &DestinationRecord{
+		Version:   destinationRecordVersion,
+		Timestamp: time.Now().UTC(),
+		RequestContext: RequestContext{
+			RequestID:              event.ID,
+			FunctionARN:            fmt.Sprintf("arn:aws:lambda:%s:%s:function:%s:$LATEST", q.config.AWS.RegionOrDefault(), q.config.AWS.AccountIDOrDefault(), event.Service),
+			Condition:              condition,
+			ApproximateInvokeCount: event.Attempts,
+		},
+		RequestPayload:  event.Payload,
+		ResponseContext: ResponseContext{ExecutedVersion: "$LATEST"},
+	}
+	if !json.Valid(event.Payload) {
+		record.RequestPayload, _ = json.Marshal(string(event.Payload))
+	}
+	switch {
+	case result == nil:
+	case result.err != nil:
+		record.ResponseContext.StatusCode = http.StatusInternalServerError
+		var invocationErr *simlaerrors.ServiceInvocationError
+		if errors.As(result.err, &invocationErr) && invocationErr.StatusCode >= http.StatusBadRequest {
+			record.ResponseContext.StatusCode = invocationErr.StatusCode
+		}
+		record.ResponsePayload, _ = json.Marshal(map[string]string{
+			"errorMessage": result.err.Error(),
+			"errorType":    "ServiceException",
+		})
+	default:
+		record.ResponseContext.StatusCode = http.StatusOK
+		if scheduler.IsFunctionError(result.response) {
+			record.ResponseContext.FunctionError = "Unhandled"
+		}
+		if len(result.response) > 0 {
+			record.ResponsePayload = result.response
+			if !json.Valid(result.response) {
+				record.ResponsePayload, _ = json.Marshal(string(result.response))
+			}
+		}
+	}
+	return record
+}
+
+// deliver sends record to dest. Delivery is best-effort: failures are
+// logged and the record is dropped.
+func (q *Queue) deliver(dest *config.Destination, record *DestinationRecord, logger *logrus.Entry) {
+	if dest == nil {
+		return
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		logger.WithError(err).Error("failed to encode destination record")
+		return
+	}
+
+	logger = logger.WithField("destination", dest.Type)
+	switch dest.Type {
+	case config.DestinationTypeService:
+		if _, err := q.Enqueue(q.ctx, dest.Service, data); err != nil {
+			logger.WithError(err).Errorf("failed to send record to service %s", dest.Service)
+		}
+	case config.DestinationTypeWorkflow:
+		go func() {
+			if _, err := q.executor.Execute(q.ctx, dest.Workflow, data); err != nil {
+				logger.WithError(err).Errorf("destination workflow %s failed", dest.Workflow)
+			}
+		}()
+	case config.DestinationTypeQueue:
+		if err := q.sendMessage(dest.QueueURL, data); err != nil {
+			logger.WithError(err).Errorf("failed to send record to queue %s", dest.QueueURL)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nyambati/simla/internal/config"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
+	"github.com/nyambati/simla/internal/mocks"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func intPtr(v int) *int { return &v }
+
+func newTestQueue(t *testing.T, cfg *config.Config, sched *mocks.MockSchedulerInterface) (*Queue, *DeadLetterStore) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	store := NewDeadLetterStore(t.TempDir())
+	q, err := NewQueue(cfg, sched, store, logrus.NewEntry(logger))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { _ = q.Start(ctx) }()
+	t.Cleanup(cancel)
+	return q, store
+}
+
+// waitForLetters polls store until it holds n dead letters for service.
+func waitForLetters(t *testing.T, store *DeadLetterStore, service string, n int) []*DeadLetter {
+	t.Helper()
+	var letters []*DeadLetter
+	require.Eventually(t, func() bool {
+		var err error
+		letters, err = store.List(service)
+		return err == nil && len(letters) == n
+	}, 2*time.Second, 10*time.Millisecond)
+	return letters
+}
+
+func TestQueue_SuccessDestination(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	cfg := &config.Config{Services: map[string]config.Service{
+		"orders": {EventInvoke: &config.EventInvokeConfig{
+			OnSuccess: &config.Destination{Type: config.DestinationTypeService, Service: "Audit"},
+		}},
+		"audit": {},
+	}}
+	q, _ := newTestQueue(t, cfg, sched)
+
+	records := make(chan DestinationRecord, 1)
+	sched.EXPECT().Invoke(gomock.Any(), "orders", []byte(`{"id":1}`)).
+		DoAndReturn(func(ctx context.Context, _ string, _ []byte) ([]byte, error) {
+			assert.Equal(t, "orders", ctx.Value("service"))
+			return []byte(`{"ok":true}`), nil
+		})
+	sched.EXPECT().Invoke(gomock.Any(), "audit", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, payload []byte) ([]byte, error) {
+			var record DestinationRecord
+			assert.NoError(t, json.Unmarshal(payload, &record))
+			records <- record
+			return nil, nil
+		})
+
+	id, err := q.Enqueue(context.Background(), "orders", []byte(`{"id":1}`))
+	require.NoError(t, err)
+
+	select {
+	case record := <-records:
+		assert.Equal(t, "1.0", record.Version)
+		assert.Equal(t, id, record.RequestContext.RequestID)
+		assert.Equal(t, ConditionSuccess, record.RequestContext.Condition)
+		assert.Equal(t, "arn:aws:lambda:us-east-1:012345678901:function:orders:$LATEST", record.RequestContext.FunctionARN)
+		assert.Equal(t, 1, record.RequestContext.ApproximateInvokeCount)
+		assert.JSONEq(t, `{"id":1}`, string(record.RequestPayload))
+		assert.Equal(t, 200, record.ResponseContext.StatusCode)
+		assert.Equal(t, "$LATEST", record.ResponseContext.ExecutedVersion)
+		assert.Empty(t, record.ResponseContext.FunctionError)
+		assert.JSONEq(t, `{"ok":true}`, string(record.ResponsePayload))
+	case <-time.After(2 * time.Second):
+		t.Fatal("success destination was not invoked")
+	}
+}
+
+func TestQueue_RetriesExhausted(t *testing.T) {
+	messages := make(chan url.Values, 1)
+	sqs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		messages <- r.PostForm
+	}))
+	defer sqs.Close()
+
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	cfg := &config.Config{Services: map[string]config.Service{
+		"orders": {EventInvoke: &config.EventInvokeConfig{
+			RetryDelay: "1ms",
+			OnFailure:  &config.Destination{Type: config.DestinationTypeQueue, QueueURL: sqs.URL},
+		}},
+	}}
+	q, store := newTestQueue(t, cfg, sched)
+
+	sched.EXPECT().Invoke(gomock.Any(), "orders", gomock.Any()).
+		Return([]byte(`{"errorMessage":"boom","errorType":"Error"}`), nil).
+		Times(3)
+
+	id, err := q.Enqueue(context.Background(), "orders", []byte(`{"id":2}`))
+	require.NoError(t, err)
+
+	select {
+	case form := <-messages:
+		assert.Equal(t, "SendMessage", form.Get("Action"))
+		var record DestinationRecord
+		require.NoError(t, json.Unmarshal([]byte(form.Get("MessageBody")), &record))
+		assert.Equal(t, ConditionRetriesExhausted, record.RequestContext.Condition)
+		assert.Equal(t, 3, record.RequestContext.ApproximateInvokeCount)
+		assert.Equal(t, "Unhandled", record.ResponseContext.FunctionError)
+		assert.JSONEq(t, `{"errorMessage":"boom","errorType":"Error"}`, string(record.ResponsePayload))
+	case <-time.After(2 * time.Second):
+		t.Fatal("failure destination did not receive a record")
+	}
+
+	letters := waitForLetters(t, store, "orders", 1)
+	assert.Equal(t, id, letters[0].ID)
+	assert.Equal(t, ConditionRetriesExhausted, letters[0].Condition)
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.JSONEq(t, `{"id":2}`, string(letters[0].Payload))
+}
+
+func TestQueue_NoRetries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	cfg := &config.Config{Services: map[string]config.Service{
+		"orders": {EventInvoke: &config.EventInvokeConfig{MaximumRetryAttempts: intPtr(0)}},
+	}}
+	q, store := newTestQueue(t, cfg, sched)
+
+	sched.EXPECT().Invoke(gomock.Any(), "orders", gomock.Any()).Return(nil, errors.New("container failed")).Times(1)
+
+	_, err := q.Enqueue(context.Background(), "orders", []byte(`{}`))
+	require.NoError(t, err)
+
+	letters := waitForLetters(t, store, "orders", 1)
+	assert.Equal(t, ConditionRetriesExhausted, letters[0].Condition)
+	assert.Equal(t, "container failed", letters[0].Error)
+}
+
+func TestQueue_ThrottledEventsKeepTheirAttempts(t *testing.T) {
+	defer func(d time.Duration) { throttleRetryDelay = d }(throttleRetryDelay)
+	throttleRetryDelay = time.Millisecond
+
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	cfg := &config.Config{Services: map[string]config.Service{
+		"orders": {EventInvoke: &config.EventInvokeConfig{MaximumRetryAttempts: intPtr(0)}},
+	}}
+	q, store := newTestQueue(t, cfg, sched)
+
+	gomock.InOrder(
+		sched.EXPECT().Invoke(gomock.Any(), "orders", gomock.Any()).
+			Return(nil, simlaerrors.NewTooManyRequestsError("orders", 1)).Times(2),
+		sched.EXPECT().Invoke(gomock.Any(), "orders", gomock.Any()).
+			Return(nil, errors.New("container failed")),
+	)
+
+	_, err := q.Enqueue(context.Background(), "orders", []byte(`{}`))
+	require.NoError(t, err)
+
+	// With no retries, the event is discarded on its first real failure.
+	letters := waitForLetters(t, store, "orders", 1)
+	assert.Equal(t, ConditionRetriesExhausted, letters[0].Condition)
+	assert.Equal(t, 1, letters[0].Attempts)
+}
+
+func TestQueue_EventAgeExceeded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	cfg := &config.Config{Services: map[string]config.Service{
+		"orders": {EventInvoke: &config.EventInvokeConfig{MaximumEventAgeInSeconds: 60}},
+	}}
+	q, store := newTestQueue(t, cfg, sched)
+
+	// The event is too old, so it is discarded without being invoked.
+	q.process(&Event{ID: "old", Service: "orders", Payload: []byte(`{}`), EnqueuedAt: time.Now().Add(-2 * time.Minute)})
+
+	letters := waitForLetters(t, store, "orders", 1)
+	assert.Equal(t, ConditionEventAgeExceeded, letters[0].Condition)
+	assert.Equal(t, 0, letters[0].Attempts)
+}
+
+func TestQueue_UnknownService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	q, _ := newTestQueue(t, &config.Config{}, mocks.NewMockSchedulerInterface(ctrl))
+
+	_, err := q.Enqueue(context.Background(), "missing", []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestNewQueue_InvalidConfig(t *testing.T) {
+	tests := map[string]*config.EventInvokeConfig{
+		"event age too low":   {MaximumEventAgeInSeconds: 30},
+		"event age too high":  {MaximumEventAgeInSeconds: 21601},
+		"too many retries":    {MaximumRetryAttempts: intPtr(3)},
+		"invalid retry delay": {RetryDelay: "soon"},
+		"unknown service":     {OnSuccess: &config.Destination{Type: config.DestinationTypeService, Service: "missing"}},
+		"unknown workflow":    {OnFailure: &config.Destination{Type: config.DestinationTypeWorkflow, Workflow: "missing"}},
+		"missing queue url":   {OnFailure: &config.Destination{Type: config.DestinationTypeQueue}},
+		"unknown destination": {OnFailure: &config.Destination{Type: "topic"}},
+	}
+	for name, eic := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{Services: map[string]config.Service{"orders": {EventInvoke: eic}}}
+			_, err := NewQueue(cfg, nil, nil, logrus.NewEntry(logrus.New()))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package async
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// sendMessage sends body to an SQS-compatible queue with the SendMessage
+// action of the SQS query API.
+func (q *Queue) sendMessage(queueURL string, body []byte) error {
+	params := url.Values{
+		"Action":      {"SendMessage"},
+		"MessageBody": {string(body)},
+		"Version":     {"2012-11-05"},
+	}
+	req, err := http.NewRequestWithContext(q.ctx, http.MethodPost, queueURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("SendMessage returned %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+//go:generate mockgen -source=$GOFILE -destination=../mocks/mock_async.go -package=mocks QueueInterface
+
+// Package async queues asynchronous (Event) invocations of services and
+// processes them the way Lambda does: events are retried with backoff until
+// they succeed, run out of retries or exceed their maximum age, and the
+// outcome is sent to the service's destinations. Discarded events are kept in
+// a dead-letter store from which they can be replayed.
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/scheduler"
+	"github.com/nyambati/simla/internal/workflow"
+	"github.com/sirupsen/logrus"
+)
+
+// Conditions reported in destination records and dead letters.
+const (
+	ConditionSuccess          = "Success"
+	ConditionRetriesExhausted = "RetriesExhausted"
+	ConditionEventAgeExceeded = "EventAgeExceeded"
+)
+
+const (
+	// destinationRecordVersion is the version of the destination record
+	// envelope.
+	destinationRecordVersion = "1.0"
+	// queueCapacity is how many events a service's queue holds before
+	// Enqueue rejects new ones.
+	queueCapacity = 1000
+)
+
+// throttleRetryDelay is the wait before a throttled event is invoked again.
+// It is a variable so tests can shorten it.
+var throttleRetryDelay = time.Second
+
+// QueueInterface accepts asynchronous invocations.
+type QueueInterface interface {
+	// Enqueue queues payload for an asynchronous invocation of service and
+	// returns the request ID of the event.
+	Enqueue(ctx context.Context, service string, payload []byte) (string, error)
+}
+
+// Queue holds an event queue per service and the workers that drain them.
+type Queue struct {
+	config    *config.Config
+	scheduler scheduler.SchedulerInterface
+	executor  workflow.ExecutorInterface
+	store     DeadLetterStoreInterface
+	client    *http.Client
+	logger    *logrus.Entry
+
+	// ctx bounds the workers; cancel stops them when Start returns.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	services map[string]chan *Event
+	policies map[string]policy
+}
+
+// policy is the validated event invoke configuration of a service.
+type policy struct {
+	maxAge     time.Duration
+	retries    int
+	retryDelay time.Duration
+	onSuccess  *config.Destination
+	onFailure  *config.Destination
+}
+
+// Event is one queued asynchronous invocation.
+type Event struct {
+	// ID is the request ID returned to the caller.
+	ID         string          `json:"id"`
+	Service    string          `json:"service"`
+	Payload    json.RawMessage `json:"payload"`
+	EnqueuedAt time.Time       `json:"enqueuedAt"`
+	// Attempts counts the invocations made so far.
+	Attempts int `json:"attempts"`
+}
+
+// DestinationRecord is the envelope Lambda sends to destinations.
+type DestinationRecord struct {
+	Version         string          `json:"version"`
+	Timestamp       time.Time       `json:"timestamp"`
+	RequestContext  RequestContext  `json:"requestContext"`
+	RequestPayload  json.RawMessage `json:"requestPayload"`
+	ResponseContext ResponseContext `json:"responseContext"`
+	ResponsePayload json.RawMessage `json:"responsePayload,omitempty"`
+}
+
+type RequestContext struct {
+	RequestID              string `json:"requestId"`
+	FunctionARN            string `json:"functionArn"`
+	Condition              string `json:"condition"`
+	ApproximateInvokeCount int    `json:"approximateInvokeCount"`
+}
+
+type ResponseContext struct {
+	StatusCode      int    `json:"statusCode"`
+	ExecutedVersion string `json:"executedVersion"`
+	FunctionError   string `json:"functionError,omitempty"`
+}
@@ -0,0 +1,244 @@
+// Package build compiles or packages a service's code before its containers
+// start, so CodePath can hold sources instead of a prebuilt Linux artifact.
+//
+// Go services are cross-compiled for the service's architecture with cgo
+// disabled, Python services are copied along with the packages of their
+// requirements.txt, and any service can run a custom command instead. The
+// output is written to a directory per service that the runtime mounts at
+// /var/task in place of CodePath. Services run by the process backend are
+// built for the host instead of Linux.
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nyambati/simla/internal/config"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
+	"github.com/nyambati/simla/internal/runtime"
+	"github.com/sirupsen/logrus"
+)
+
+// OutputDirVar is the variable that tells a command build where to write
+// its output.
+const OutputDirVar = "SIMLA_BUILD_DIR"
+
+// defaultBinary is the name of a Go service's executable when the service
+// sets no cmd, as for the provided runtimes.
+const defaultBinary = "bootstrap"
+
+// locks serialises builds of the same output directory. Every scheduler in
+// the process may build a service on demand.
+var locks sync.Map
+
+// DefaultDir returns the directory holding build output, ~/.simla/build.
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".simla", "build"), nil
+}
+
+// Builder builds services into a directory per service below its dir.
+type Builder struct {
+	dir    string
+	logger *logrus.Entry
+}
+
+// NewBuilder returns a Builder writing to dir.
+func NewBuilder(dir string, logger *logrus.Entry) *Builder {
+	return &Builder{dir: dir, logger: logger}
+}
+
+// NewDefaultBuilder returns a Builder writing to DefaultDir.
+func NewDefaultBuilder(logger *logrus.Entry) (*Builder, error) {
+	dir, err := DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewBuilder(dir, logger), nil
+}
+
+// OutputDir returns the directory the build of serviceName is written to.
+func (b *Builder) OutputDir(serviceName string) string {
+	return filepath.Join(b.dir, serviceName)
+}
+
+// Ensure returns the build output of serviceName, building it first if it
+// has not been built yet.
+func (b *Builder) Ensure(ctx context.Context, serviceName string, svc *config.Service) (string, error) {
+	out := b.OutputDir(serviceName)
+	if _, err := os.Stat(out); err == nil {
+		return out, nil
+	}
+	return b.Build(ctx, serviceName, svc)
+}
+
+// Build builds serviceName and returns its output directory. The previous
+// output is replaced only when the build succeeds. Failures are reported as
+// a BuildError carrying the build tool's output.
+func (b *Builder) Build(ctx context.Context, serviceName string, svc *config.Service) (string, error) {
+	out := b.OutputDir(serviceName)
+	mu, _ := locks.LoadOrStore(out, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	logger := b.logger.WithFields(logrus.Fields{"service": serviceName, "type": svc.BuildType()})
+	logger.Info("building service")
+	start := time.Now()
+
+	if err := os.MkdirAll(b.dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create build directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(b.dir, "."+serviceName+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create build directory: %w", err)
+	}
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", err
+	}
+
+	if err := b.run(ctx, serviceName, svc, tmp); err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", err
+	}
+
+	if err := os.RemoveAll(out); err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", fmt.Errorf("failed to replace build output: %w", err)
+	}
+	if err := os.Rename(tmp, out); err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", fmt.Errorf("failed to replace build output: %w", err)
+	}
+
+	logger.WithField("duration", time.Since(start).Round(time.Millisecond)).Info("service built")
+	return out, nil
+}
+
+// run builds svc into out.
+func (b *Builder) run(ctx context.Context, serviceName string, svc *config.Service, out string) error {
+	source, err := filepath.Abs(svc.CodePath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve code path: %w", err)
+	}
+	// Services run by the process backend are built for the host.
+	goos, arch := runtime.OS, runtime.HostArch()
+	if svc.Backend == config.BackendProcess {
+		goos = goruntime.GOOS
+	} else if svc.Architecture != "" {
+		arch = runtime.NormArch(svc.Architecture)
+	}
+
+	switch svc.BuildType() {
+	case config.BuildTypeGo:
+		binary := defaultBinary
+		if len(svc.Cmd) > 0 {
+			binary = svc.Cmd[0]
+		}
+		cmd := exec.CommandContext(ctx, "go", "build", "-trimpath", "-o", filepath.Join(out, binary), ".")
+		cmd.Dir = source
+		cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH="+arch, "CGO_ENABLED=0")
+		return execute(serviceName, cmd)
+
+	case config.BuildTypePython:
+		if err := copySources(source, out); err != nil {
+			return simlaerrors.NewBuildError(serviceName, "", err)
+		}
+		requirements := filepath.Join(source, "requirements.txt")
+		if _, err := os.Stat(requirements); err != nil {
+			return nil
+		}
+		platform := arch
+		if svc.Backend == config.BackendProcess {
+			platform = ""
+		}
+		cmd := exec.CommandContext(ctx, "python3", pipArgs(requirements, out, platform, svc.Runtime)...)
+		cmd.Dir = source
+		return execute(serviceName, cmd)
+
+	case config.BuildTypeCommand:
+		cmd := exec.CommandContext(ctx, "sh", "-c", svc.Build.Command)
+		cmd.Dir = source
+		cmd.Env = append(os.Environ(), OutputDirVar+"="+out, "SIMLA_ARCHITECTURE="+arch)
+		return execute(serviceName, cmd)
+	}
+	return simlaerrors.NewBuildError(serviceName, "", fmt.Errorf("unsupported build type %q", svc.BuildType()))
+}
+
+// pipArgs returns the arguments of python3 that vendor the packages of
+// requirements into out, choosing wheels built for Lambda's platform on
+// arch. An empty arch installs packages for the host instead.
+func pipArgs(requirements, out, arch, rt string) []string {
+	args := []string{
+		"-m", "pip", "install",
+		"--requirement", requirements,
+		"--target", out,
+		"--upgrade",
+		"--quiet",
+	}
+	if arch == "" {
+		return args
+	}
+	platform := "manylinux2014_x86_64"
+	if arch == "arm64" {
+		platform = "manylinux2014_aarch64"
+	}
+	args = append(args, "--platform", platform, "--only-binary=:all:", "--implementation", "cp")
+	if version := strings.TrimPrefix(rt, "python"); version != rt && version != "" {
+		args = append(args, "--python-version", version)
+	}
+	return args
+}
+
+// execute runs cmd and reports a failure with its combined output.
+func execute(serviceName string, cmd *exec.Cmd) error {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return simlaerrors.NewBuildError(serviceName, strings.TrimSpace(output.String()), err)
+	}
+	return nil
+}
+
+// copySources copies the Python sources in src to dest, leaving out
+// bytecode caches and virtual environments.
+func copySources(src, dest string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		if d.IsDir() {
+			switch d.Name() {
+			case "__pycache__", ".venv", "venv", ".git":
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dest, rel), 0o755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dest, rel), data, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,103 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nyambati/simla/internal/config"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBuilder(t *testing.T) *Builder {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewBuilder(filepath.Join(t.TempDir(), "build"), logrus.NewEntry(logger))
+}
+
+func TestBuild_Command(t *testing.T) {
+	b := newTestBuilder(t)
+	source := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(source, "handler.sh"), []byte("echo hi"), 0o755))
+
+	svc := &config.Service{
+		CodePath:     source,
+		Architecture: "x86_64",
+		Build:        &config.Build{Command: `cp handler.sh "$SIMLA_BUILD_DIR/" && echo "$SIMLA_ARCHITECTURE" > "$SIMLA_BUILD_DIR/arch"`},
+	}
+	out, err := b.Build(context.Background(), "orders", svc)
+	require.NoError(t, err)
+	assert.Equal(t, b.OutputDir("orders"), out)
+
+	data, err := os.ReadFile(filepath.Join(out, "handler.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo hi", string(data))
+	data, err = os.ReadFile(filepath.Join(out, "arch"))
+	require.NoError(t, err)
+	assert.Equal(t, "amd64\n", string(data))
+}
+
+func TestBuild_FailureKeepsPreviousOutput(t *testing.T) {
+	b := newTestBuilder(t)
+	svc := &config.Service{CodePath: t.TempDir(), Build: &config.Build{Command: `touch "$SIMLA_BUILD_DIR/ok"`}}
+	out, err := b.Build(context.Background(), "orders", svc)
+	require.NoError(t, err)
+
+	svc.Build.Command = `echo "syntax error on line 3" >&2; exit 2`
+	_, err = b.Build(context.Background(), "orders", svc)
+	var buildErr *simlaerrors.BuildError
+	require.True(t, errors.As(err, &buildErr))
+	assert.Equal(t, "orders", buildErr.ServiceName)
+	assert.Equal(t, "syntax error on line 3", buildErr.Output)
+
+	assert.FileExists(t, filepath.Join(out, "ok"), "a failed build leaves the last good output in place")
+}
+
+func TestEnsure_BuildsOnlyOnce(t *testing.T) {
+	b := newTestBuilder(t)
+	counter := filepath.Join(t.TempDir(), "count")
+	svc := &config.Service{CodePath: t.TempDir(), Build: &config.Build{Command: "echo x >> " + counter}}
+
+	_, err := b.Ensure(context.Background(), "orders", svc)
+	require.NoError(t, err)
+	_, err = b.Ensure(context.Background(), "orders", svc)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(counter)
+	require.NoError(t, err)
+	assert.Equal(t, "x\n", string(data))
+}
+
+func TestCopySources(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "pkg", "__pycache__"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "main.py"), []byte("main"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "pkg", "util.py"), []byte("util"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "pkg", "__pycache__", "util.pyc"), []byte("x"), 0o644))
+
+	dest := t.TempDir()
+	require.NoError(t, copySources(src, dest))
+	assert.FileExists(t, filepath.Join(dest, "main.py"))
+	assert.FileExists(t, filepath.Join(dest, "pkg", "util.py"))
+	assert.NoDirExists(t, filepath.Join(dest, "pkg", "__pycache__"))
+}
+
+func TestPipArgs(t *testing.T) {
+	args := pipArgs("requirements.txt", "/out", "arm64", "python3.12")
+	assert.Contains(t, args, "manylinux2014_aarch64")
+	assert.Equal(t, []string{"--python-version", "3.12"}, args[len(args)-2:])
+
+	args = pipArgs("requirements.txt", "/out", "amd64", "python")
+	assert.Contains(t, args, "manylinux2014_x86_64")
+	assert.NotContains(t, args, "--python-version")
+
+	args = pipArgs("requirements.txt", "/out", "", "python3.12")
+	assert.NotContains(t, args, "--platform", "host builds use the host's wheels")
+}
@@ -2,11 +2,300 @@ package config
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
+// GetService returns a copy of the named service, with its Backend set to
+// the global one when it does not choose its own.
 func (c *Config) GetService(ctx context.Context, serviceName string) (*Service, bool) {
 	if service, exists := c.Services[serviceName]; exists {
+		if service.Backend == "" {
+			service.Backend = c.BackendOrDefault()
+		}
 		return &service, true
 	}
 	return nil, false
 }
+
+// BackendOrDefault returns the global backend, applying BackendDocker.
+func (c *Config) BackendOrDefault() string {
+	if c.Backend == "" {
+		return BackendDocker
+	}
+	return c.Backend
+}
+
+// Key returns the key of m that name refers to: name itself or, since viper
+// lowercases map keys when loading .simla.yaml, a key equal to it under
+// case folding.
+func Key[V any](m map[string]V, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	for key := range m {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// ServiceName returns the name of the service that name refers to, as it
+// appears in c.Services.
+func (c *Config) ServiceName(name string) (string, bool) {
+	return Key(c.Services, name)
+}
+
+// WorkflowName returns the name of the workflow that name refers to, as it
+// appears in c.Workflows.
+func (c *Config) WorkflowName(name string) (string, bool) {
+	return Key(c.Workflows, name)
+}
+
+// GetAuthorizer returns the named authorizer.
+func (a *APIGateway) GetAuthorizer(ctx context.Context, name string) (*Authorizer, bool) {
+	if key, ok := Key(a.Authorizers, name); ok {
+		authorizer := a.Authorizers[key]
+		return &authorizer, true
+	}
+	return nil, false
+}
+
+// Load reads the config file found by v into c. Viper lowercases map keys
+// and splits them on ".", which suits setting names but not keys that are
+// data, such as build args, stage variables and workflow payload templates;
+// those are decoded again from the file as written.
+func (c *Config) Load(v *viper.Viper) error {
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+	if err := v.Unmarshal(c); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(v.ConfigFileUsed())
+	if err != nil {
+		return err
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", v.ConfigFileUsed(), err)
+	}
+
+	c.APIGateway.StageVariables, _ = stringMap(field(field(raw, "apiGateway"), "stageVariables"))
+	services, _ := field(raw, "services").(map[string]any)
+	for name, def := range services {
+		key, ok := c.ServiceName(name)
+		if !ok {
+			continue
+		}
+		svc := c.Services[key]
+		svc.BuildArgs, _ = stringMap(field(def, "buildArgs"))
+		c.Services[key] = svc
+	}
+	c.decodeWorkflows(raw)
+	return nil
+}
+
+// stringMap returns the YAML mapping raw with its values as strings.
+func stringMap(raw any) (map[string]string, bool) {
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	values := make(map[string]string, len(m))
+	for key, value := range m {
+		if value != nil {
+			values[key] = fmt.Sprint(value)
+		} else {
+			values[key] = ""
+		}
+	}
+	return values, true
+}
+
+// Validate checks the settings of every service against Lambda's limits.
+func (c *Config) Validate() error {
+	if err := validateBackend(c.Backend); err != nil {
+		return err
+	}
+	for name, layer := range c.Layers {
+		if layer.Path == "" {
+			return fmt.Errorf("layer %s: path is required", name)
+		}
+	}
+	for name, svc := range c.Services {
+		if err := svc.validate(); err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		if _, err := c.LayerPaths(&svc); err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		if svc.Backend == "" {
+			svc.Backend = c.BackendOrDefault()
+		}
+		if svc.Backend == BackendProcess && (svc.Image != "" || svc.ImageBuild()) {
+			return fmt.Errorf("service %s: image, dockerfile and buildContext need the %s backend", name, BackendDocker)
+		}
+	}
+	return nil
+}
+
+// LayerPaths returns the directories and .zip files of svc's layers, in
+// order, with named layers replaced by their path.
+func (c *Config) LayerPaths(svc *Service) ([]string, error) {
+	if len(svc.Layers) > MaxLayers {
+		return nil, fmt.Errorf("layers: at most %d layers are allowed, got %d", MaxLayers, len(svc.Layers))
+	}
+	paths := make([]string, 0, len(svc.Layers))
+	for _, ref := range svc.Layers {
+		if key, ok := Key(c.Layers, ref); ok {
+			paths = append(paths, c.Layers[key].Path)
+			continue
+		}
+		if ref == "" {
+			return nil, fmt.Errorf("layers: empty layer reference")
+		}
+		paths = append(paths, ref)
+	}
+	return paths, nil
+}
+
+func (s *Service) validate() error {
+	if s.MemorySize != 0 && (s.MemorySize < DefaultMemorySize || s.MemorySize > MaxMemorySize) {
+		return fmt.Errorf("memorySize must be between %d and %d MB, got %d", DefaultMemorySize, MaxMemorySize, s.MemorySize)
+	}
+	if s.Timeout < 0 || s.Timeout > MaxTimeout {
+		return fmt.Errorf("timeout must be between 1 and %d seconds, got %d", MaxTimeout, s.Timeout)
+	}
+	if s.EphemeralStorage != 0 && (s.EphemeralStorage < DefaultEphemeralStorage || s.EphemeralStorage > MaxEphemeralStorage) {
+		return fmt.Errorf("ephemeralStorage must be between %d and %d MB, got %d", DefaultEphemeralStorage, MaxEphemeralStorage, s.EphemeralStorage)
+	}
+
+	if s.Build != nil {
+		switch s.BuildType() {
+		case BuildTypeGo, BuildTypePython:
+		case BuildTypeCommand:
+			if s.Build.Command == "" {
+				return fmt.Errorf("build: command is required for command builds")
+			}
+		case "":
+			return fmt.Errorf("build: type is required for runtime %q", s.Runtime)
+		default:
+			return fmt.Errorf("build: type must be %s, %s or %s, got %q", BuildTypeGo, BuildTypePython, BuildTypeCommand, s.Build.Type)
+		}
+	}
+
+	if err := validateBackend(s.Backend); err != nil {
+		return err
+	}
+
+	if s.ImageBuild() {
+		if s.Image != "" {
+			return fmt.Errorf("image cannot be set together with dockerfile or buildContext")
+		}
+		if s.Build != nil {
+			return fmt.Errorf("build cannot be set together with dockerfile or buildContext")
+		}
+		if len(s.Layers) > 0 {
+			return fmt.Errorf("layers cannot be set together with dockerfile or buildContext; copy them into the image instead")
+		}
+	}
+
+	var reserved []string
+	for key := range s.Environment {
+		if slices.Contains(ReservedEnvironment, strings.ToUpper(key)) {
+			reserved = append(reserved, key)
+		}
+	}
+	if len(reserved) > 0 {
+		sort.Strings(reserved)
+		return fmt.Errorf("environment: reserved keys that are currently not supported for modification: %s", strings.Join(reserved, ","))
+	}
+	return nil
+}
+
+// validateBackend checks that backend is empty or a known backend.
+func validateBackend(backend string) error {
+	switch backend {
+	case "", BackendDocker, BackendProcess:
+		return nil
+	}
+	return fmt.Errorf("backend must be %s or %s, got %q", BackendDocker, BackendProcess, backend)
+}
+
+// AccountIDOrDefault returns the configured account ID, applying
+// DefaultAccountID.
+func (a *AWS) AccountIDOrDefault() string {
+	if a.AccountID == "" {
+		return DefaultAccountID
+	}
+	return a.AccountID
+}
+
+// RegionOrDefault returns the configured region, applying DefaultRegion.
+func (a *AWS) RegionOrDefault() string {
+	if a.Region == "" {
+		return DefaultRegion
+	}
+	return a.Region
+}
+
+// BuildType returns the type of the service's build, inferring it from
+// Build.Command or Runtime when Build.Type is not set. It is "" when the
+// service has no build or the type cannot be inferred.
+func (s *Service) BuildType() string {
+	switch {
+	case s.Build == nil:
+		return ""
+	case s.Build.Type != "":
+		return s.Build.Type
+	case s.Build.Command != "":
+		return BuildTypeCommand
+	case s.Runtime == "go" || strings.HasPrefix(s.Runtime, "provided"):
+		return BuildTypeGo
+	case strings.HasPrefix(s.Runtime, "python"):
+		return BuildTypePython
+	}
+	return ""
+}
+
+// ImageBuild reports whether the service runs from an image built from a
+// Dockerfile.
+func (s *Service) ImageBuild() bool {
+	return s.Dockerfile != "" || s.BuildContext != ""
+}
+
+// MemorySizeOrDefault returns the service's memory in MB, applying
+// DefaultMemorySize.
+func (s *Service) MemorySizeOrDefault() int {
+	if s.MemorySize == 0 {
+		return DefaultMemorySize
+	}
+	return s.MemorySize
+}
+
+// TimeoutOrDefault returns how long an invocation of the service may run,
+// applying DefaultTimeout.
+func (s *Service) TimeoutOrDefault() time.Duration {
+	if s.Timeout == 0 {
+		return DefaultTimeout * time.Second
+	}
+	return time.Duration(s.Timeout) * time.Second
+}
+
+// EphemeralStorageOrDefault returns the size of the service's /tmp in MB,
+// applying DefaultEphemeralStorage.
+func (s *Service) EphemeralStorageOrDefault() int {
+	if s.EphemeralStorage == 0 {
+		return DefaultEphemeralStorage
+	}
+	return s.EphemeralStorage
+}
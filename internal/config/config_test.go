@@ -2,8 +2,12 @@ package config
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -166,3 +170,256 @@ func TestGetService_EmptyServicesMap(t *testing.T) {
 	assert.False(t, ok)
 	assert.Nil(t, svc)
 }
+
+// ── Validate ──────────────────────────────────────────────────────────────────
+
+func TestValidate_DefaultsAreValid(t *testing.T) {
+	assert.NoError(t, makeConfig().Validate())
+}
+
+func TestValidate_ResourceLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		svc  Service
+		want string
+	}{
+		{"memory below minimum", Service{MemorySize: 64}, "memorySize must be between 128 and 10240 MB"},
+		{"memory above maximum", Service{MemorySize: 20000}, "memorySize"},
+		{"negative timeout", Service{Timeout: -1}, "timeout must be between 1 and 900 seconds"},
+		{"timeout above maximum", Service{Timeout: 901}, "timeout"},
+		{"storage below minimum", Service{EphemeralStorage: 256}, "ephemeralStorage must be between 512 and 10240 MB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Services: map[string]Service{"orders": tt.svc}}
+			err := cfg.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "service orders: "+tt.want)
+		})
+	}
+}
+
+func TestService_LimitDefaults(t *testing.T) {
+	svc := &Service{}
+	assert.Equal(t, 128, svc.MemorySizeOrDefault())
+	assert.Equal(t, 3*time.Second, svc.TimeoutOrDefault())
+	assert.Equal(t, 512, svc.EphemeralStorageOrDefault())
+
+	svc = &Service{MemorySize: 1024, Timeout: 60, EphemeralStorage: 2048}
+	assert.Equal(t, 1024, svc.MemorySizeOrDefault())
+	assert.Equal(t, time.Minute, svc.TimeoutOrDefault())
+	assert.Equal(t, 2048, svc.EphemeralStorageOrDefault())
+}
+
+func TestValidate_ReservedEnvironment(t *testing.T) {
+	cfg := &Config{Services: map[string]Service{
+		"orders": {Environment: map[string]string{"TABLE": "orders", "aws_region": "eu-west-1", "_HANDLER": "main"}},
+	}}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reserved keys that are currently not supported for modification: _HANDLER,aws_region")
+
+	cfg.Services["orders"] = Service{Environment: map[string]string{"AWS_ACCESS_KEY_ID": "test"}}
+	assert.NoError(t, cfg.Validate(), "credentials are passed by the service")
+
+	cfg.Services["orders"] = Service{Environment: map[string]string{"TZ": "Europe/Berlin"}}
+	assert.NoError(t, cfg.Validate(), "TZ is not reserved")
+}
+
+func TestAWS_Defaults(t *testing.T) {
+	aws := &AWS{}
+	assert.Equal(t, DefaultAccountID, aws.AccountIDOrDefault())
+	assert.Equal(t, DefaultRegion, aws.RegionOrDefault())
+
+	aws = &AWS{AccountID: "111122223333", Region: "eu-west-1"}
+	assert.Equal(t, "111122223333", aws.AccountIDOrDefault())
+	assert.Equal(t, "eu-west-1", aws.RegionOrDefault())
+}
+
+// ── Layers ────────────────────────────────────────────────────────────────────
+
+func TestLayerPaths(t *testing.T) {
+	cfg := &Config{Layers: map[string]Layer{
+		"shared": {Path: "./layers/shared"},
+		"otel":   {Path: "./layers/otel.zip"},
+	}}
+
+	paths, err := cfg.LayerPaths(&Service{Layers: []string{"shared", "./vendor", "OTEL"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"./layers/shared", "./vendor", "./layers/otel.zip"}, paths)
+
+	_, err = cfg.LayerPaths(&Service{Layers: []string{"a", "b", "c", "d", "e", "f"}})
+	assert.ErrorContains(t, err, "at most 5 layers")
+}
+
+func TestValidate_Layers(t *testing.T) {
+	cfg := &Config{Layers: map[string]Layer{"shared": {}}}
+	assert.ErrorContains(t, cfg.Validate(), "layer shared: path is required")
+
+	cfg = &Config{Services: map[string]Service{"orders": {Layers: []string{""}}}}
+	assert.ErrorContains(t, cfg.Validate(), "service orders: layers: empty layer reference")
+}
+
+// ── Build ─────────────────────────────────────────────────────────────────────
+
+func TestService_BuildType(t *testing.T) {
+	tests := []struct {
+		svc  Service
+		want string
+	}{
+		{Service{Runtime: "go"}, ""},
+		{Service{Runtime: "go", Build: &Build{}}, BuildTypeGo},
+		{Service{Runtime: "provided.al2023", Build: &Build{}}, BuildTypeGo},
+		{Service{Runtime: "python3.12", Build: &Build{}}, BuildTypePython},
+		{Service{Runtime: "python3.12", Build: &Build{Command: "make"}}, BuildTypeCommand},
+		{Service{Runtime: "nodejs20.x", Build: &Build{Type: BuildTypeCommand}}, BuildTypeCommand},
+		{Service{Runtime: "nodejs20.x", Build: &Build{}}, ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.svc.BuildType(), "runtime %s", tt.svc.Runtime)
+	}
+}
+
+func TestValidate_Build(t *testing.T) {
+	cfg := &Config{Services: map[string]Service{"web": {Runtime: "nodejs20.x", Build: &Build{}}}}
+	assert.ErrorContains(t, cfg.Validate(), `build: type is required for runtime "nodejs20.x"`)
+
+	cfg.Services["web"] = Service{Build: &Build{Type: BuildTypeCommand}}
+	assert.ErrorContains(t, cfg.Validate(), "build: command is required")
+
+	cfg.Services["web"] = Service{Build: &Build{Type: "rust"}}
+	assert.ErrorContains(t, cfg.Validate(), `build: type must be go, python or command, got "rust"`)
+
+	cfg.Services["web"] = Service{Runtime: "go", Build: &Build{}}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidate_ImageBuild(t *testing.T) {
+	cfg := &Config{Services: map[string]Service{"web": {Dockerfile: "Dockerfile", Image: "node:20"}}}
+	assert.ErrorContains(t, cfg.Validate(), "image cannot be set together with dockerfile")
+
+	cfg.Services["web"] = Service{BuildContext: "./web", Runtime: "go", Build: &Build{}}
+	assert.ErrorContains(t, cfg.Validate(), "build cannot be set together with dockerfile")
+
+	cfg.Services["web"] = Service{BuildContext: "./web", BuildArgs: map[string]string{"VERSION": "1"}}
+	assert.NoError(t, cfg.Validate())
+	svc := cfg.Services["web"]
+	assert.True(t, svc.ImageBuild())
+}
+
+func TestServiceName(t *testing.T) {
+	cfg := makeConfig()
+	cfg.Services["OrderAPI"] = Service{}
+
+	name, ok := cfg.ServiceName("payments")
+	require.True(t, ok)
+	assert.Equal(t, "payments", name)
+
+	// Viper lowercases map keys, so callers may name a service differently.
+	name, ok = cfg.ServiceName("Payments")
+	require.True(t, ok)
+	assert.Equal(t, "payments", name)
+	name, ok = cfg.ServiceName("OrderAPI")
+	require.True(t, ok)
+	assert.Equal(t, "OrderAPI", name, "exact matches win")
+
+	_, ok = cfg.ServiceName("refunds")
+	assert.False(t, ok)
+	_, ok = cfg.WorkflowName("payments")
+	assert.False(t, ok)
+}
+
+// ── Load ──────────────────────────────────────────────────────────────────────
+
+// loadConfig loads doc as .simla.yaml the way the CLI does.
+func loadConfig(t *testing.T, doc string) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".simla.yaml"), []byte(doc), 0o644))
+	v := viper.New()
+	v.AddConfigPath(dir)
+	v.SetConfigName(".simla")
+	v.SetConfigType("yaml")
+	cfg := &Config{}
+	require.NoError(t, cfg.Load(v))
+	return cfg
+}
+
+func TestLoad_BuildArgs(t *testing.T) {
+	cfg := loadConfig(t, `
+services:
+  Web:
+    dockerfile: Dockerfile
+    buildArgs:
+      NODE_VERSION: "20"
+      app.env: prod
+      WORKERS: 4
+`)
+	svc, ok := cfg.GetService(context.Background(), "web")
+	require.True(t, ok)
+	assert.Equal(t, "Dockerfile", svc.Dockerfile)
+	assert.Equal(t, map[string]string{"NODE_VERSION": "20", "app.env": "prod", "WORKERS": "4"}, svc.BuildArgs,
+		"build-arg names must match ARG exactly")
+}
+
+func TestLoad_StageVariables(t *testing.T) {
+	cfg := loadConfig(t, `
+apiGateway:
+  stage: v1
+  stageVariables:
+    functionName: orders
+    lambda.alias: live
+`)
+	assert.Equal(t, "v1", cfg.APIGateway.Stage)
+	assert.Equal(t, map[string]string{"functionName": "orders", "lambda.alias": "live"}, cfg.APIGateway.StageVariables)
+}
+
+// ── Workflows ─────────────────────────────────────────────────────────────────
+
+func TestDecodeWorkflowData(t *testing.T) {
+	data := []byte(`
+workflows:
+  Orders:
+    States:
+      Fan:
+        Type: Parallel
+        Branches:
+          - States:
+              Charge:
+                Type: Task
+                Parameters:
+                  orderId.$: "$.order.id"
+      Each:
+        Type: Map
+        Iterator:
+          States:
+            Ship:
+              Type: Pass
+              Result:
+                carrierName: UPS
+        ItemBatcher:
+          BatchInput:
+            runId.$: "$$.Execution.Id"
+`)
+	// These are the values viper decodes the document to.
+	cfg := &Config{Workflows: map[string]StateMachine{
+		"orders": {States: map[string]State{
+			"fan": {Type: "Parallel", Branches: []StateMachine{{States: map[string]State{
+				"charge": {Type: "Task", Parameters: map[string]any{"orderid": map[string]any{"$": "$.order.id"}}},
+			}}}},
+			"each": {
+				Type:        "Map",
+				Iterator:    &ItemProcessor{StateMachine: StateMachine{States: map[string]State{"ship": {Type: "Pass", Result: map[string]any{"carriername": "UPS"}}}}},
+				ItemBatcher: &ItemBatcher{BatchInput: map[string]any{"runid": map[string]any{"$": "$$.Execution.Id"}}},
+			},
+		}},
+	}}
+	require.NoError(t, cfg.DecodeWorkflowData(data))
+
+	sm := cfg.Workflows["orders"]
+	assert.Equal(t, map[string]any{"orderId.$": "$.order.id"}, sm.States["fan"].Branches[0].States["charge"].Parameters)
+	assert.Equal(t, map[string]any{"carrierName": "UPS"}, sm.States["each"].Iterator.States["ship"].Result)
+	assert.Equal(t, map[string]any{"runId.$": "$$.Execution.Id"}, sm.States["each"].ItemBatcher.BatchInput)
+
+	assert.Error(t, cfg.DecodeWorkflowData([]byte("workflows: [")))
+}
@@ -71,14 +71,328 @@ type Service struct {
 	// the host environment.
 	EnvFile  string    `yaml:"envFile"`
 	Triggers []Trigger `yaml:"triggers"`
+	// FunctionURL exposes the service directly over HTTP, like a Lambda
+	// Function URL. Nil disables it.
+	FunctionURL *FunctionURL `yaml:"functionUrl"`
+	// EventInvoke configures asynchronous (Event) invocations: retries,
+	// event age and destinations. Nil uses Lambda's defaults.
+	EventInvoke *EventInvokeConfig `yaml:"eventInvoke"`
+	// ReservedConcurrency caps how many containers of the service may serve
+	// invocations at the same time. Nil uses DefaultConcurrency; 0 throttles
+	// every invocation, as in Lambda.
+	ReservedConcurrency *int `yaml:"reservedConcurrency"`
+	// IdleTimeout is how long a container may go without invocations before
+	// it is stopped, e.g. "5m". The next invocation cold starts it again.
+	// Defaults to DefaultIdleTimeout; "0" keeps containers until simla down.
+	IdleTimeout string `yaml:"idleTimeout"`
+	// MemorySize is the memory available to the function in MB
+	// (128-10240, default 128). CPU is allocated in proportion to it, as in
+	// Lambda.
+	MemorySize int `yaml:"memorySize"`
+	// Timeout is how many seconds an invocation may run before it is killed
+	// (1-900, default 3).
+	Timeout int `yaml:"timeout"`
+	// EphemeralStorage is the size of /tmp in MB (512-10240, default 512).
+	EphemeralStorage int `yaml:"ephemeralStorage"`
+	// Layers are merged in order into /opt; files of later layers replace
+	// those of earlier ones. Each entry is the name of a layer in
+	// Config.Layers, or the path of a directory or .zip file. At most
+	// MaxLayers.
+	Layers []string `yaml:"layers"`
+	// Build compiles or packages CodePath before the service's containers
+	// start; the output is mounted at /var/task instead of CodePath. Nil
+	// mounts CodePath as it is.
+	Build *Build `yaml:"build"`
+	// Dockerfile is the path of a Dockerfile, relative to BuildContext, the
+	// service's image is built from (default "Dockerfile"). Setting it or
+	// BuildContext runs the service from the built image instead of Image
+	// or Runtime, with its code baked in rather than mounted from CodePath.
+	Dockerfile string `yaml:"dockerfile"`
+	// BuildContext is the directory sent to Docker as the image's build
+	// context (default ".").
+	BuildContext string `yaml:"buildContext"`
+	// BuildArgs are passed to the image build as --build-arg values. Load
+	// decodes them itself, as their names may hold dots.
+	BuildArgs map[string]string `yaml:"buildArgs" mapstructure:"-"`
+	// Backend runs the service in a Docker container (docker) or as a
+	// process on the host (process). Defaults to Config.Backend.
+	Backend string `yaml:"backend"`
 }
 
+// Backends accepted by Service.Backend and Config.Backend.
+const (
+	BackendDocker  = "docker"
+	BackendProcess = "process"
+)
+
+// Build types accepted by Build.Type.
+const (
+	BuildTypeGo      = "go"
+	BuildTypePython  = "python"
+	BuildTypeCommand = "command"
+)
+
+// Build describes how to build a service from the sources in its CodePath.
+type Build struct {
+	// Type is go, python or command. It defaults to command when Command
+	// is set and otherwise to the one matching Runtime.
+	Type string `yaml:"type"`
+	// Command is run with sh -c in CodePath for command builds. It writes
+	// its output to the directory in $SIMLA_BUILD_DIR.
+	Command string `yaml:"command"`
+}
+
+// MaxLayers is the number of layers a service may use, as in Lambda.
+const MaxLayers = 5
+
+// Layer is a layer shared by several services, which reference it by name.
+type Layer struct {
+	// Path is a directory or .zip file laid out as the layer's content
+	// should appear under /opt, e.g. python/, nodejs/node_modules/ or
+	// extensions/.
+	Path string `yaml:"path"`
+}
+
+// DefaultConcurrency is the number of concurrent containers a service may
+// scale out to when it does not set ReservedConcurrency.
+const DefaultConcurrency = 10
+
+// DefaultIdleTimeout is the IdleTimeout of services that do not set one.
+const DefaultIdleTimeout = "10m"
+
+// Limits and defaults of a service's resources, as in Lambda.
+const (
+	DefaultMemorySize       = 128
+	MaxMemorySize           = 10240
+	DefaultTimeout          = 3
+	MaxTimeout              = 900
+	DefaultEphemeralStorage = 512
+	MaxEphemeralStorage     = 10240
+)
+
+// Limits and defaults of EventInvokeConfig, as in Lambda.
+const (
+	DefaultMaximumEventAgeInSeconds = 21600
+	MinMaximumEventAgeInSeconds     = 60
+	DefaultMaximumRetryAttempts     = 2
+	DefaultRetryDelay               = "1m"
+)
+
+// EventInvokeConfig mirrors a Lambda function's event invoke configuration.
+type EventInvokeConfig struct {
+	// MaximumEventAgeInSeconds is how long an event may wait in the queue,
+	// including retries, before it is discarded (60-21600, default 21600).
+	MaximumEventAgeInSeconds int `yaml:"maximumEventAgeInSeconds"`
+	// MaximumRetryAttempts is how many times a failed event is retried
+	// (0-2, default 2).
+	MaximumRetryAttempts *int `yaml:"maximumRetryAttempts"`
+	// RetryDelay is the wait before the first retry; it doubles for the
+	// second. Defaults to "1m", as in Lambda; shorten it for local testing.
+	RetryDelay string `yaml:"retryDelay"`
+	// OnSuccess receives a destination record for every successful event.
+	OnSuccess *Destination `yaml:"onSuccess"`
+	// OnFailure receives a destination record for every event that is
+	// discarded. Discarded events are also kept in the dead-letter store.
+	OnFailure *Destination `yaml:"onFailure"`
+}
+
+// Destination types accepted by Destination.Type.
+const (
+	DestinationTypeService  = "service"
+	DestinationTypeWorkflow = "workflow"
+	DestinationTypeQueue    = "queue"
+)
+
+// Destination is where the record of an asynchronous invocation is sent.
+// Only the field matching Type needs to be set.
+type Destination struct {
+	// Type is service, workflow or queue.
+	Type string `yaml:"type"`
+	// Service is invoked asynchronously with the record.
+	Service string `yaml:"service"`
+	// Workflow is executed with the record as input.
+	Workflow string `yaml:"workflow"`
+	// QueueURL is an SQS-compatible queue the record is sent to, e.g.
+	// "http://localhost:9324/queue/failed-orders".
+	QueueURL string `yaml:"queueUrl"`
+}
+
+// Function URL auth types.
+const (
+	// FunctionURLAuthNone accepts unauthenticated requests.
+	FunctionURLAuthNone = "NONE"
+	// FunctionURLAuthIAM requires requests signed with AWS Signature V4.
+	FunctionURLAuthIAM = "AWS_IAM"
+)
+
+// FunctionURL configures a service's Function URL. Set Port to give it its own
+// listener, or Host and/or PathPrefix to mount it on the API gateway listener.
+type FunctionURL struct {
+	// Port is the port of a dedicated listener for this function URL.
+	Port string `yaml:"port"`
+	// Host matches requests on the API gateway listener by Host header, e.g.
+	// "orders.lambda-url.localhost".
+	Host string `yaml:"host"`
+	// PathPrefix matches requests on the API gateway listener by path prefix,
+	// e.g. "/fn/orders". The prefix is stripped from the path the Lambda sees.
+	PathPrefix string `yaml:"pathPrefix"`
+	// AuthType is NONE (the default) or AWS_IAM.
+	AuthType string `yaml:"authType"`
+	// InvokeMode is BUFFERED (the default) or RESPONSE_STREAM.
+	InvokeMode string `yaml:"invokeMode"`
+	// CORS configures the CORS headers added to responses. Nil leaves
+	// responses untouched.
+	CORS *FunctionURLCORS `yaml:"cors"`
+}
+
+// FunctionURLCORS mirrors the CORS settings of a Lambda Function URL.
+type FunctionURLCORS struct {
+	AllowOrigins     []string `yaml:"allowOrigins"`
+	AllowMethods     []string `yaml:"allowMethods"`
+	AllowHeaders     []string `yaml:"allowHeaders"`
+	ExposeHeaders    []string `yaml:"exposeHeaders"`
+	AllowCredentials bool     `yaml:"allowCredentials"`
+	// MaxAge is the Access-Control-Max-Age of preflight responses in seconds.
+	MaxAge int `yaml:"maxAge"`
+}
+
+// Payload format versions accepted by APIGateway.PayloadFormatVersion and
+// Route.PayloadFormatVersion.
+const (
+	// PayloadFormatV1 sends REST API events (events.APIGatewayProxyRequest).
+	PayloadFormatV1 = "1.0"
+	// PayloadFormatV2 sends HTTP API events (events.APIGatewayV2HTTPRequest).
+	PayloadFormatV2 = "2.0"
+)
+
+// Invoke modes accepted by Route.InvokeMode and FunctionURL.InvokeMode.
+const (
+	// InvokeModeBuffered returns the response once the invocation completes.
+	InvokeModeBuffered = "BUFFERED"
+	// InvokeModeResponseStream relays the response to the client as the
+	// function writes it.
+	InvokeModeResponseStream = "RESPONSE_STREAM"
+)
+
+// AuthorizerType identifies how an API Gateway authorizer validates requests.
+type AuthorizerType string
+
+const (
+	// AuthorizerTypeToken invokes a Lambda with a single bearer token taken
+	// from a request header.
+	AuthorizerTypeToken AuthorizerType = "token"
+	// AuthorizerTypeRequest invokes a Lambda with the request's headers,
+	// query string, path parameters and stage variables.
+	AuthorizerTypeRequest AuthorizerType = "request"
+	// AuthorizerTypeJWT validates a bearer JWT against a JSON Web Key Set
+	// without invoking a Lambda.
+	AuthorizerTypeJWT AuthorizerType = "jwt"
+)
+
+// Authorizer describes an API Gateway authorizer: a Lambda authorizer backed
+// by a simla service, or a JWT authorizer.
+type Authorizer struct {
+	// Type selects the authorizer implementation.
+	Type AuthorizerType `yaml:"type"`
+	// Service is the simla service invoked to authorize requests. It is not
+	// used by JWT authorizers.
+	Service string `yaml:"service"`
+	// IdentitySource lists where the caller's identity is read from, e.g.
+	// "$request.header.Authorization" or "method.request.querystring.key".
+	// TOKEN and JWT authorizers use the first entry and default to the
+	// Authorization header. Requests missing any identity source are rejected
+	// with 401.
+	IdentitySource []string `yaml:"identitySource"`
+	// TokenValidation is an optional regular expression a TOKEN authorizer's
+	// token must match before the Lambda is invoked.
+	TokenValidation string `yaml:"tokenValidation"`
+	// ResultTTLSeconds caches the authorizer response per identity source for
+	// this many seconds. 0 disables caching.
+	ResultTTLSeconds int `yaml:"resultTtlSeconds"`
+	// EnableSimpleResponses lets a REQUEST authorizer using payload format 2.0
+	// return {"isAuthorized": bool, "context": {...}} instead of an IAM policy.
+	EnableSimpleResponses bool `yaml:"enableSimpleResponses"`
+	// PayloadFormatVersion selects the REQUEST authorizer event shape. It
+	// defaults to the payload format of the route being authorized.
+	PayloadFormatVersion string `yaml:"payloadFormatVersion"`
+	// Issuer is the "iss" claim a JWT authorizer requires.
+	Issuer string `yaml:"issuer"`
+	// Audience lists the accepted "aud" (or "client_id") claim values of a JWT
+	// authorizer; a token must match at least one.
+	Audience []string `yaml:"audience"`
+	// JWKSFile and JWKSURL locate the JSON Web Key Set a JWT authorizer
+	// verifies signatures with. When neither is set, the key set of the local
+	// `simla token` issuer is used.
+	JWKSFile string `yaml:"jwksFile"`
+	JWKSURL  string `yaml:"jwksUrl"`
+}
+
+// Route maps an API Gateway route key to a service.
 type Route struct {
+	// Path is relative to the stage and may contain {name} path variables and
+	// a trailing greedy {proxy+} variable, e.g. "users/{id}" or "api/{proxy+}".
+	// The special path "$default" catches every request no other route matches.
 	Path    string `yaml:"path"`
 	Service string `yaml:"service"`
-	Method  string `yaml:"method"`
+	// Method is an HTTP method or ANY to match every method.
+	Method string `yaml:"method"`
+	// PayloadFormatVersion overrides APIGateway.PayloadFormatVersion for this
+	// route. Leave empty to inherit the gateway setting.
+	PayloadFormatVersion string `yaml:"payloadFormatVersion"`
+	// Authorizer names an entry in APIGateway.Authorizers that must allow the
+	// request before the service is invoked.
+	Authorizer string `yaml:"authorizer"`
+	// AuthorizationScopes, for routes using a JWT authorizer, lists scopes of
+	// which the token must carry at least one.
+	AuthorizationScopes []string `yaml:"authorizationScopes"`
+	// InvokeMode is BUFFERED (the default) or RESPONSE_STREAM.
+	InvokeMode string `yaml:"invokeMode"`
+	// RequestModel is the path of a JSON Schema (JSON or YAML) the request
+	// body must satisfy. Invalid bodies are rejected with 400 before the
+	// service is invoked.
+	RequestModel string `yaml:"requestModel"`
+	// RequiredParameters lists request parameters that must be present, in
+	// API Gateway notation: method.request.querystring.<name>,
+	// method.request.header.<name> or method.request.path.<name>.
+	RequiredParameters []string `yaml:"requiredParameters"`
 }
 
+// AWS identifiers used in ARNs and event contexts wherever a real account
+// and region would appear.
+const (
+	DefaultAccountID = "012345678901"
+	DefaultRegion    = "us-east-1"
+)
+
+// ReservedEnvironment lists the environment variables Lambda sets itself.
+// Services may not set them in Environment. Credentials are not reserved:
+// simla has no execution role, so services pass their own.
+var ReservedEnvironment = []string{
+	"_HANDLER",
+	"_X_AMZN_TRACE_ID",
+	"AWS_DEFAULT_REGION",
+	"AWS_REGION",
+	"AWS_EXECUTION_ENV",
+	"AWS_LAMBDA_FUNCTION_NAME",
+	"AWS_LAMBDA_FUNCTION_MEMORY_SIZE",
+	"AWS_LAMBDA_FUNCTION_VERSION",
+	"AWS_LAMBDA_FUNCTION_TIMEOUT",
+	"AWS_LAMBDA_INITIALIZATION_TYPE",
+	"AWS_LAMBDA_LOG_GROUP_NAME",
+	"AWS_LAMBDA_LOG_STREAM_NAME",
+	"AWS_LAMBDA_RUNTIME_API",
+	"LAMBDA_TASK_ROOT",
+	"LAMBDA_RUNTIME_DIR",
+}
+
+// Values the gateway uses for CORSConfig fields that are left unset.
+const (
+	DefaultCORSAllowOrigins = "*"
+	DefaultCORSAllowMethods = "GET,POST,PUT,PATCH,DELETE,OPTIONS"
+	DefaultCORSAllowHeaders = "Content-Type,Authorization,X-Request-ID"
+	DefaultCORSMaxAge       = 86400
+)
+
 // CORSConfig controls cross-origin resource sharing headers added by the
 // gateway. Set Enabled: true to activate; all other fields have sensible
 // defaults.
@@ -106,11 +420,89 @@ type APIGateway struct {
 	Routes []Route    `yaml:"routes"`
 	Stage  string     `yaml:"stage"`
 	CORS   CORSConfig `yaml:"cors"`
+	// PayloadFormatVersion selects the event shape sent to Lambdas: "2.0"
+	// (HTTP API, the default) or "1.0" (REST API).
+	PayloadFormatVersion string `yaml:"payloadFormatVersion"`
+	// StageVariables are passed to every Lambda in the event's stageVariables.
+	// Config.Load decodes them itself, as their names are case-sensitive.
+	StageVariables map[string]string `yaml:"stageVariables" mapstructure:"-"`
+	// Authorizers are the named authorizers routes can reference.
+	Authorizers map[string]Authorizer `yaml:"authorizers"`
+	// OpenAPI imports additional routes from an OpenAPI 3 document.
+	OpenAPI *OpenAPIImport `yaml:"openapi"`
+}
+
+// OpenAPIImport derives gateway routes from the Lambda proxy integrations
+// (x-amazon-apigateway-integration) of an OpenAPI 3 document. Routes in
+// .simla.yaml take precedence over imported routes with the same method and
+// path.
+type OpenAPIImport struct {
+	// File is the path of the YAML or JSON document.
+	File string `yaml:"file"`
+	// Services maps the Lambda function names or CloudFormation logical IDs
+	// found in integration URIs to service names. Integrations that are not
+	// listed resolve to the service of the same name.
+	Services map[string]string `yaml:"services"`
+}
+
+// WebSocket route keys with a special meaning. Every other route key is
+// matched against the value selected by RouteSelectionExpression.
+const (
+	WebSocketRouteConnect    = "$connect"
+	WebSocketRouteDisconnect = "$disconnect"
+	WebSocketRouteDefault    = "$default"
+)
+
+// WebSocketAPI configures an API Gateway WebSocket API served on its own port.
+type WebSocketAPI struct {
+	// Port the WebSocket API listens on. The API is disabled when empty.
+	Port string `yaml:"port"`
+	// Stage is the path clients connect to and the prefix of the
+	// @connections API. Defaults to the HTTP API stage.
+	Stage string `yaml:"stage"`
+	// RouteSelectionExpression picks the route key of each message, e.g.
+	// "$request.body.action" (the default) reads the action field of a JSON
+	// message.
+	RouteSelectionExpression string `yaml:"routeSelectionExpression"`
+	// Routes map route keys to services.
+	Routes []WebSocketRoute `yaml:"routes"`
+}
+
+// WebSocketRoute maps a WebSocket route key to a service.
+type WebSocketRoute struct {
+	// RouteKey is $connect, $disconnect, $default or a custom key selected
+	// by the API's RouteSelectionExpression.
+	RouteKey string `yaml:"routeKey"`
+	Service  string `yaml:"service"`
+}
+
+// LambdaAPI configures the Lambda Invoke API simla serves so that AWS SDKs
+// and CLIs, and other functions, can call lambda:Invoke against services.
+type LambdaAPI struct {
+	// Port the Lambda API listens on. The API is disabled when empty.
+	Port string `yaml:"port"`
+}
+
+// AWS is the account and region simla reports to functions in their
+// reserved environment variables.
+type AWS struct {
+	// AccountID defaults to DefaultAccountID.
+	AccountID string `yaml:"accountId"`
+	// Region sets AWS_REGION and AWS_DEFAULT_REGION. Defaults to
+	// DefaultRegion.
+	Region string `yaml:"region"`
 }
 
 type Config struct {
-	APIGateway APIGateway              `yaml:"apiGateway"`
-	Services   map[string]Service      `yaml:"services"`
-	Workflows  map[string]StateMachine `yaml:"workflows"`
-	Host       string                  `yaml:"-"`
+	AWS          AWS                     `yaml:"aws"`
+	APIGateway   APIGateway              `yaml:"apiGateway"`
+	WebSocketAPI WebSocketAPI            `yaml:"webSocketApi"`
+	LambdaAPI    LambdaAPI               `yaml:"lambdaApi"`
+	Services     map[string]Service      `yaml:"services"`
+	Layers       map[string]Layer        `yaml:"layers"`
+	Workflows    map[string]StateMachine `yaml:"workflows"`
+	// Backend is the backend of services that do not set their own,
+	// docker (the default) or process.
+	Backend string `yaml:"backend"`
+	Host    string `yaml:"-"`
 }
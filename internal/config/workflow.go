@@ -2,6 +2,10 @@ package config
 
 import (
 	"context"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -9,6 +13,7 @@ const (
 	StateTypePass     StateType = "Pass"
 	StateTypeChoice   StateType = "Choice"
 	StateTypeParallel StateType = "Parallel"
+	StateTypeMap      StateType = "Map"
 	StateTypeWait     StateType = "Wait"
 	StateTypeSucceed  StateType = "Succeed"
 	StateTypeFail     StateType = "Fail"
@@ -38,6 +43,13 @@ type State struct {
 	ResultPath string `yaml:"resultPath" mapstructure:"resultpath"`
 	Result     any    `yaml:"result"     mapstructure:"result"`
 
+	// Parameters builds the effective input of a Task, Pass, Map or Parallel
+	// state, and ResultSelector the result of a Task, Map or Parallel state.
+	// Keys ending in ".$" take their value from a path or an intrinsic
+	// function such as States.Format.
+	Parameters     map[string]any `yaml:"parameters"     mapstructure:"parameters"`
+	ResultSelector map[string]any `yaml:"resultSelector" mapstructure:"resultselector"`
+
 	TimeoutSeconds   int `yaml:"timeoutSeconds"   mapstructure:"timeoutseconds"`
 	HeartbeatSeconds int `yaml:"heartbeatSeconds" mapstructure:"heartbeatseconds"`
 
@@ -46,6 +58,31 @@ type State struct {
 
 	Branches []StateMachine `yaml:"branches" mapstructure:"branches"`
 
+	// ItemProcessor is the state machine a Map state runs for every item.
+	// Iterator is its name in older definitions.
+	ItemProcessor *ItemProcessor `yaml:"itemProcessor" mapstructure:"itemprocessor"`
+	Iterator      *ItemProcessor `yaml:"iterator"      mapstructure:"iterator"`
+	// ItemsPath selects the array a Map state iterates over from its
+	// effective input; it defaults to "$".
+	ItemsPath string `yaml:"itemsPath" mapstructure:"itemspath"`
+	// ItemSelector builds the input of each item. Keys ending in ".$" take
+	// their value from a path of the effective input or, with "$$.Map.Item",
+	// of the item.
+	ItemSelector map[string]any `yaml:"itemSelector" mapstructure:"itemselector"`
+	// MaxConcurrency bounds the items processed at once; 0 runs them all at
+	// once.
+	MaxConcurrency int `yaml:"maxConcurrency" mapstructure:"maxconcurrency"`
+	// ToleratedFailureCount and ToleratedFailurePercentage let a Map state
+	// succeed although some of its items failed.
+	ToleratedFailureCount      int     `yaml:"toleratedFailureCount"      mapstructure:"toleratedfailurecount"`
+	ToleratedFailurePercentage float64 `yaml:"toleratedFailurePercentage" mapstructure:"toleratedfailurepercentage"`
+	// ItemReader, ItemBatcher and ResultWriter configure a distributed Map
+	// state: where its items come from, how they are grouped into child
+	// executions and where their results are written.
+	ItemReader   *ItemReader   `yaml:"itemReader"   mapstructure:"itemreader"`
+	ItemBatcher  *ItemBatcher  `yaml:"itemBatcher"  mapstructure:"itembatcher"`
+	ResultWriter *ResultWriter `yaml:"resultWriter" mapstructure:"resultwriter"`
+
 	Choices       []ChoiceRule `yaml:"choices" mapstructure:"choices"`
 	DefaultChoice string       `yaml:"default" mapstructure:"default"`
 
@@ -59,6 +96,67 @@ type State struct {
 	CausePath string `yaml:"causePath"  mapstructure:"causepath"`
 }
 
+// ItemProcessor is the state machine of a Map state, with its processor
+// configuration.
+type ItemProcessor struct {
+	ProcessorConfig ProcessorConfig `yaml:"processorConfig" mapstructure:"processorconfig"`
+	StateMachine    `yaml:",inline" mapstructure:",squash"`
+}
+
+// ProcessorConfig configures how a Map state processes its items.
+type ProcessorConfig struct {
+	Mode          string `yaml:"mode"          mapstructure:"mode"`
+	ExecutionType string `yaml:"executionType" mapstructure:"executiontype"`
+}
+
+// ItemReader reads the items of a distributed Map state from an object or
+// a listing of a bucket. Locally, the Bucket parameter names a directory
+// that stands in for the bucket, or a file holding the items.
+type ItemReader struct {
+	// Resource is arn:aws:states:::s3:getObject or
+	// arn:aws:states:::s3:listObjectsV2.
+	Resource     string         `yaml:"resource"     mapstructure:"resource"`
+	ReaderConfig ReaderConfig   `yaml:"readerConfig" mapstructure:"readerconfig"`
+	Parameters   map[string]any `yaml:"parameters"   mapstructure:"parameters"`
+}
+
+// ReaderConfig describes the object an ItemReader reads.
+type ReaderConfig struct {
+	// InputType is JSON, JSONL or CSV.
+	InputType string `yaml:"inputType" mapstructure:"inputtype"`
+	// CSVHeaderLocation is FIRST_ROW, the default, or GIVEN to use
+	// CSVHeaders.
+	CSVHeaderLocation string   `yaml:"csvHeaderLocation" mapstructure:"csvheaderlocation"`
+	CSVHeaders        []string `yaml:"csvHeaders"        mapstructure:"csvheaders"`
+	// MaxItems limits the items read; 0 reads them all.
+	MaxItems int `yaml:"maxItems" mapstructure:"maxitems"`
+}
+
+// ItemBatcher groups the items of a distributed Map state into batches,
+// each processed by one child execution.
+type ItemBatcher struct {
+	MaxItemsPerBatch      int `yaml:"maxItemsPerBatch"      mapstructure:"maxitemsperbatch"`
+	MaxInputBytesPerBatch int `yaml:"maxInputBytesPerBatch" mapstructure:"maxinputbytesperbatch"`
+	// BatchInput is passed to every batch alongside its items.
+	BatchInput map[string]any `yaml:"batchInput" mapstructure:"batchinput"`
+}
+
+// ResultWriter writes the results of the child executions of a
+// distributed Map state under the Prefix of the directory named by Bucket.
+type ResultWriter struct {
+	Resource   string         `yaml:"resource"   mapstructure:"resource"`
+	Parameters map[string]any `yaml:"parameters" mapstructure:"parameters"`
+}
+
+// Processor returns the item processor of a Map state, from ItemProcessor
+// or the older Iterator field, or nil.
+func (s *State) Processor() *ItemProcessor {
+	if s.ItemProcessor != nil {
+		return s.ItemProcessor
+	}
+	return s.Iterator
+}
+
 type RetryConfig struct {
 	Errors          []string `yaml:"errors"`
 	IntervalSeconds int      `yaml:"intervalSeconds"`
@@ -107,3 +205,100 @@ func (c *Config) GetWorkflow(ctx context.Context, workflowName string) (*StateMa
 	}
 	return nil, false
 }
+
+// DecodeWorkflowData replaces the payload templates and literal data of the
+// workflows, such as Parameters and Result, with their values in the YAML
+// document data. Viper lowercases map keys and splits them on ".", so it
+// turns "userId.$" into "userid" holding "$"; decoding these fields again
+// keeps their keys as written.
+func (c *Config) DecodeWorkflowData(data []byte) error {
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to parse workflows: %w", err)
+	}
+	c.decodeWorkflows(raw)
+	return nil
+}
+
+// decodeWorkflows decodes the payload fields of the workflows of c from
+// raw, the parsed config document.
+func (c *Config) decodeWorkflows(raw map[string]any) {
+	workflows, _ := field(raw, "workflows").(map[string]any)
+	for name, def := range workflows {
+		key, ok := c.WorkflowName(name)
+		if !ok {
+			continue
+		}
+		sm := c.Workflows[key]
+		sm.decodeData(def)
+		c.Workflows[key] = sm
+	}
+}
+
+// decodeData decodes the payload fields of the states of sm from raw.
+func (sm *StateMachine) decodeData(raw any) {
+	states, _ := field(raw, "states").(map[string]any)
+	for name, def := range states {
+		key, ok := Key(sm.States, name)
+		if !ok {
+			continue
+		}
+		state := sm.States[key]
+		state.decodeData(def)
+		sm.States[key] = state
+	}
+}
+
+// decodeData decodes the payload fields of s, and of the states it runs,
+// from raw.
+func (s *State) decodeData(raw any) {
+	if v, ok := field(raw, "parameters").(map[string]any); ok {
+		s.Parameters = v
+	}
+	if v, ok := field(raw, "resultSelector").(map[string]any); ok {
+		s.ResultSelector = v
+	}
+	if v, ok := field(raw, "itemSelector").(map[string]any); ok {
+		s.ItemSelector = v
+	}
+	if v := field(raw, "result"); v != nil {
+		s.Result = v
+	}
+	if s.ItemReader != nil {
+		if v, ok := field(field(raw, "itemReader"), "parameters").(map[string]any); ok {
+			s.ItemReader.Parameters = v
+		}
+	}
+	if s.ItemBatcher != nil {
+		if v, ok := field(field(raw, "itemBatcher"), "batchInput").(map[string]any); ok {
+			s.ItemBatcher.BatchInput = v
+		}
+	}
+	if s.ResultWriter != nil {
+		if v, ok := field(field(raw, "resultWriter"), "parameters").(map[string]any); ok {
+			s.ResultWriter.Parameters = v
+		}
+	}
+	branches, _ := field(raw, "branches").([]any)
+	for i := range min(len(branches), len(s.Branches)) {
+		s.Branches[i].decodeData(branches[i])
+	}
+	if s.ItemProcessor != nil {
+		s.ItemProcessor.decodeData(field(raw, "itemProcessor"))
+	}
+	if s.Iterator != nil {
+		s.Iterator.decodeData(field(raw, "iterator"))
+	}
+}
+
+// field returns the value of the key name of the YAML mapping raw,
+// matching it case-insensitively as viper does, or nil.
+func field(raw any, name string) any {
+	m, _ := raw.(map[string]any)
+	for key, value := range m {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return nil
+}
@@ -64,6 +64,44 @@ func (e *ServiceInvocationError) Error() string {
 	return fmt.Sprintf("service %s returned %d: %s", e.ServiceName, e.StatusCode, e.Body)
 }
 
+// TooManyRequestsError reports an invocation throttled because every
+// container the service may run is already busy.
+type TooManyRequestsError struct {
+	ServiceName string
+	Limit       int
+}
+
+func NewTooManyRequestsError(name string, limit int) error {
+	return &TooManyRequestsError{ServiceName: name, Limit: limit}
+}
+
+func (e *TooManyRequestsError) Error() string {
+	return fmt.Sprintf("rate exceeded: service %s is at its reserved concurrency of %d", e.ServiceName, e.Limit)
+}
+
+// BuildError reports a failed build of a service, with the output of the
+// build tool.
+type BuildError struct {
+	ServiceName string
+	Output      string
+	Err         error
+}
+
+func NewBuildError(name, output string, err error) error {
+	return &BuildError{ServiceName: name, Output: output, Err: err}
+}
+
+func (e *BuildError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("build failed for service %s: %v", e.ServiceName, e.Err)
+	}
+	return fmt.Sprintf("build failed for service %s: %v\n%s", e.ServiceName, e.Err, e.Output)
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
 // Health check error
 type HealthCheckFailedError struct {
 	ServiceName string
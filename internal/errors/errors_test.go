@@ -69,6 +69,25 @@ func TestServiceInvocationError_ZeroStatus(t *testing.T) {
 	assert.Equal(t, "service svc returned 0: ", err.Error())
 }
 
+func TestTooManyRequestsError(t *testing.T) {
+	err := NewTooManyRequestsError("orders", 2)
+	assertError[*TooManyRequestsError](t, err, "rate exceeded: service orders is at its reserved concurrency of 2")
+
+	var typed *TooManyRequestsError
+	require.True(t, errors.As(err, &typed))
+	assert.Equal(t, 2, typed.Limit)
+}
+
+func TestBuildError(t *testing.T) {
+	cause := errors.New("exit status 1")
+	err := NewBuildError("orders", "main.go:3: undefined: foo", cause)
+	assertError[*BuildError](t, err, "build failed for service orders: exit status 1\nmain.go:3: undefined: foo")
+	assert.ErrorIs(t, err, cause)
+
+	err = NewBuildError("orders", "", cause)
+	assert.Equal(t, "build failed for service orders: exit status 1", err.Error())
+}
+
 func TestHealthCheckFailedError(t *testing.T) {
 	err := NewHeathCheckFailedError("worker", "connection refused")
 	assertError[*HealthCheckFailedError](t, err,
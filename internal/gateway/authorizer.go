@@ -0,0 +1,491 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultTokenSource is the identity source TOKEN authorizers read when none
+// is configured.
+const defaultTokenSource = "method.request.header.Authorization"
+
+// Identity source prefixes in HTTP API ($request.…) and REST API (method.request.…)
+// notation.
+var identitySourcePrefixes = []struct {
+	prefix string
+	kind   string
+}{
+	{"$request.header.", "header"},
+	{"method.request.header.", "header"},
+	{"$request.querystring.", "querystring"},
+	{"method.request.querystring.", "querystring"},
+	{"$stageVariables.", "stageVariables"},
+	{"stageVariables.", "stageVariables"},
+}
+
+// authorizerResult is what an allowing authorizer contributes to the Lambda
+// event's requestContext.authorizer. jwt is set only by JWT authorizers.
+type authorizerResult struct {
+	principalID string
+	context     map[string]any
+	jwt         *jwtAuthorization
+}
+
+// authorizerError is returned by authorize when the gateway must answer the
+// request itself instead of invoking the route's service.
+type authorizerError struct {
+	statusCode int
+	reason     string
+}
+
+func (e *authorizerError) Error() string {
+	return fmt.Sprintf("authorizer rejected request with %d: %s", e.statusCode, e.reason)
+}
+
+// message returns the body API Gateway sends for the rejection.
+func (e *authorizerError) message() any {
+	switch e.statusCode {
+	case http.StatusUnauthorized:
+		return "Unauthorized"
+	case http.StatusForbidden:
+		return "Forbidden"
+	default:
+		// API Gateway answers authorizer failures with {"message": null}.
+		return nil
+	}
+}
+
+func unauthorized(reason string) error {
+	return &authorizerError{statusCode: http.StatusUnauthorized, reason: reason}
+}
+
+func forbidden(reason string) error {
+	return &authorizerError{statusCode: http.StatusForbidden, reason: reason}
+}
+
+func authorizerFailure(reason string) error {
+	return &authorizerError{statusCode: http.StatusInternalServerError, reason: reason}
+}
+
+// authorizerResponse covers every response shape a Lambda authorizer may
+// return: an IAM policy, an HTTP API simple response, or a function error such
+// as the conventional "Unauthorized" error.
+type authorizerResponse struct {
+	PrincipalID    string          `json:"principalId"`
+	PolicyDocument *policyDocument `json:"policyDocument"`
+	IsAuthorized   *bool           `json:"isAuthorized"`
+	Context        map[string]any  `json:"context"`
+	ErrorMessage   string          `json:"errorMessage"`
+}
+
+// policyDocument is an IAM policy returned by an authorizer. It is decoded
+// separately from events.IAMPolicyDocument because authorizers commonly
+// return Action and Resource as single strings rather than arrays.
+type policyDocument struct {
+	Version   string
+	Statement []policyStatement
+}
+
+type policyStatement struct {
+	Action   stringList
+	Effect   string
+	Resource stringList
+}
+
+// stringList decodes a JSON string or array of strings.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = []string{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*l = many
+	return nil
+}
+
+// allows evaluates the policy for an execute-api:Invoke on methodArn. As in
+// IAM, an explicit Deny wins over any Allow and the default is to deny.
+func (p *policyDocument) allows(methodArn string) bool {
+	allowed := false
+	for _, stmt := range p.Statement {
+		if !matchesAny(stmt.Action, "execute-api:Invoke") || !matchesAny(stmt.Resource, methodArn) {
+			continue
+		}
+		switch strings.ToLower(stmt.Effect) {
+		case "deny":
+			return false
+		case "allow":
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// matchesAny reports whether value matches any of the IAM wildcard patterns,
+// where * matches any run of characters and ? matches exactly one.
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+		if matched, _ := regexp.MatchString("^"+expr+"$", value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizerCache stores authorizer responses per identity source. A nil
+// cache never holds entries, so gateways built without one skip caching.
+type authorizerCache struct {
+	mu      sync.Mutex
+	entries map[string]authorizerCacheEntry
+}
+
+type authorizerCacheEntry struct {
+	response *authorizerResponse
+	expires  time.Time
+}
+
+func newAuthorizerCache() *authorizerCache {
+	return &authorizerCache{entries: make(map[string]authorizerCacheEntry)}
+}
+
+func (c *authorizerCache) get(key string) (*authorizerResponse, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.response, true
+}
+
+func (c *authorizerCache) put(key string, resp *authorizerResponse, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = authorizerCacheEntry{response: resp, expires: time.Now().Add(ttl)}
+}
+
+// validateAuthorizer checks the authorizer referenced by route, if any, so
+// configuration mistakes surface when the gateway starts rather than on the
+// first request.
+func (g *APIGateway) validateAuthorizer(route config.Route) error {
+	if route.Authorizer == "" {
+		return nil
+	}
+	authorizer, ok := g.config.GetAuthorizer(context.Background(), route.Authorizer)
+	if !ok {
+		return fmt.Errorf("route %q: authorizer %q is not defined", route.Path, route.Authorizer)
+	}
+
+	switch authorizer.Type {
+	case config.AuthorizerTypeToken, config.AuthorizerTypeRequest:
+	case config.AuthorizerTypeJWT:
+		if err := validateJWTAuthorizer(route.Authorizer, authorizer); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("authorizer %q: unknown type %q", route.Authorizer, authorizer.Type)
+	}
+	if authorizer.Service == "" && authorizer.Type != config.AuthorizerTypeJWT {
+		return fmt.Errorf("authorizer %q: service is required", route.Authorizer)
+	}
+	if authorizer.TokenValidation != "" {
+		if _, err := regexp.Compile(authorizer.TokenValidation); err != nil {
+			return fmt.Errorf("authorizer %q: invalid tokenValidation: %w", route.Authorizer, err)
+		}
+	}
+	for _, source := range authorizer.IdentitySource {
+		if _, _, ok := parseIdentitySource(source); !ok {
+			return fmt.Errorf("authorizer %q: unsupported identity source %q", route.Authorizer, source)
+		}
+	}
+	if authorizer.EnableSimpleResponses && g.authorizerPayloadFormat(authorizer, route) != config.PayloadFormatV2 {
+		return fmt.Errorf("authorizer %q: enableSimpleResponses requires payload format %s", route.Authorizer, config.PayloadFormatV2)
+	}
+	return nil
+}
+
+// authorize runs the route's authorizer, if it has one. It returns the result
+// to inject into the Lambda event, or an *authorizerError when the gateway
+// must reject the request.
+func (g *APIGateway) authorize(ctx context.Context, r *http.Request, route config.Route, requestID string, logger *logrus.Entry) (*authorizerResult, error) {
+	if route.Authorizer == "" {
+		return nil, nil
+	}
+	authorizer, ok := g.config.GetAuthorizer(ctx, route.Authorizer)
+	if !ok {
+		return nil, authorizerFailure(fmt.Sprintf("authorizer %q is not defined", route.Authorizer))
+	}
+	logger = logger.WithField("authorizer", route.Authorizer)
+
+	if authorizer.Type == config.AuthorizerTypeJWT {
+		return g.authorizeJWT(ctx, r, route, authorizer)
+	}
+
+	sources := authorizer.IdentitySource
+	if authorizer.Type == config.AuthorizerTypeToken {
+		if len(sources) == 0 {
+			sources = []string{defaultTokenSource}
+		}
+		sources = sources[:1]
+	}
+
+	identity := make([]string, 0, len(sources))
+	for _, source := range sources {
+		value, ok := g.identityValue(r, source)
+		if !ok || value == "" {
+			return nil, unauthorized(fmt.Sprintf("identity source %s is missing", source))
+		}
+		identity = append(identity, value)
+	}
+
+	if authorizer.Type == config.AuthorizerTypeToken && authorizer.TokenValidation != "" {
+		if matched, _ := regexp.MatchString(authorizer.TokenValidation, identity[0]); !matched {
+			return nil, unauthorized("token does not match tokenValidation")
+		}
+	}
+
+	methodArn := g.methodArn(r)
+	cacheKey := ""
+	if authorizer.ResultTTLSeconds > 0 && len(identity) > 0 {
+		cacheKey = route.Authorizer + "\x00" + strings.Join(identity, "\x00")
+	}
+
+	resp, cached := g.authCache.get(cacheKey)
+	if cached {
+		logger.Debug("using cached authorizer response")
+	} else {
+		payload, err := g.buildAuthorizerEvent(r, route, authorizer, identity, methodArn, requestID)
+		if err != nil {
+			return nil, authorizerFailure(fmt.Sprintf("failed to build authorizer event: %v", err))
+		}
+
+		invokeCtx := context.WithValue(ctx, "service", authorizer.Service)
+		raw, err := g.scheduler.Invoke(invokeCtx, authorizer.Service, payload)
+		if err != nil {
+			return nil, authorizerFailure(fmt.Sprintf("authorizer invocation failed: %v", err))
+		}
+
+		resp = &authorizerResponse{}
+		if err := json.Unmarshal(raw, resp); err != nil {
+			return nil, authorizerFailure(fmt.Sprintf("invalid authorizer response: %v", err))
+		}
+		if resp.ErrorMessage == "Unauthorized" {
+			return nil, unauthorized("authorizer returned Unauthorized")
+		}
+		if resp.ErrorMessage != "" {
+			return nil, authorizerFailure(fmt.Sprintf("authorizer failed: %s", resp.ErrorMessage))
+		}
+		if cacheKey != "" {
+			g.authCache.put(cacheKey, resp, time.Duration(authorizer.ResultTTLSeconds)*time.Second)
+		}
+	}
+
+	switch {
+	case authorizer.EnableSimpleResponses:
+		if resp.IsAuthorized == nil {
+			return nil, authorizerFailure("simple response has no isAuthorized field")
+		}
+		if !*resp.IsAuthorized {
+			return nil, forbidden("authorizer returned isAuthorized=false")
+		}
+	case resp.PolicyDocument == nil:
+		return nil, authorizerFailure("authorizer response has no policyDocument")
+	case !resp.PolicyDocument.allows(methodArn):
+		return nil, forbidden(fmt.Sprintf("policy does not allow %s", methodArn))
+	}
+
+	logger.WithField("principal_id", resp.PrincipalID).Debug("request authorized")
+	return &authorizerResult{principalID: resp.PrincipalID, context: resp.Context}, nil
+}
+
+// authorizerPayloadFormat returns the event format for a REQUEST authorizer,
+// defaulting to the payload format of the route being authorized.
+func (g *APIGateway) authorizerPayloadFormat(authorizer *config.Authorizer, route config.Route) string {
+	if authorizer.PayloadFormatVersion != "" {
+		return authorizer.PayloadFormatVersion
+	}
+	return g.payloadFormat(route)
+}
+
+// buildAuthorizerEvent builds the event sent to the authorizer Lambda.
+func (g *APIGateway) buildAuthorizerEvent(
+	r *http.Request,
+	route config.Route,
+	authorizer *config.Authorizer,
+	identity []string,
+	methodArn, requestID string,
+) ([]byte, error) {
+	if authorizer.Type == config.AuthorizerTypeToken {
+		return json.Marshal(&events.APIGatewayCustomAuthorizerRequest{
+			Type:               "TOKEN",
+			AuthorizationToken: identity[0],
+			MethodArn:          methodArn,
+		})
+	}
+
+	if g.authorizerPayloadFormat(authorizer, route) == config.PayloadFormatV2 {
+		query, _ := extractQueryParameters(r)
+		return json.Marshal(&events.APIGatewayV2CustomAuthorizerV2Request{
+			Version:               config.PayloadFormatV2,
+			Type:                  "REQUEST",
+			RouteArn:              methodArn,
+			IdentitySource:        identity,
+			RouteKey:              g.routeKey(route),
+			RawPath:               r.URL.Path,
+			RawQueryString:        r.URL.RawQuery,
+			Cookies:               extractCookies(r),
+			Headers:               extractHeaders(r, requestID),
+			QueryStringParameters: query,
+			RequestContext:        g.httpRequestContext(r, route, requestID),
+			PathParameters:        extractPathParameters(r),
+			StageVariables:        g.config.StageVariables,
+		})
+	}
+
+	resource, pathParameters := g.restResource(r, route)
+	headers, multiValueHeaders := extractMultiValueHeaders(r, requestID)
+	query, multiValueQuery := extractQueryParameters(r)
+	return json.Marshal(&events.APIGatewayCustomAuthorizerRequestTypeRequest{
+		Type:                            "REQUEST",
+		MethodArn:                       methodArn,
+		Resource:                        resource,
+		Path:                            g.stageRelativePath(r.URL.Path),
+		HTTPMethod:                      r.Method,
+		Headers:                         headers,
+		MultiValueHeaders:               multiValueHeaders,
+		QueryStringParameters:           query,
+		MultiValueQueryStringParameters: multiValueQuery,
+		PathParameters:                  pathParameters,
+		StageVariables:                  g.config.StageVariables,
+		RequestContext: events.APIGatewayCustomAuthorizerRequestTypeRequestContext{
+			Path:         r.URL.Path,
+			AccountID:    localAccountID,
+			Stage:        g.config.Stage,
+			RequestID:    requestID,
+			ResourcePath: resource,
+			HTTPMethod:   r.Method,
+			APIID:        localAPIID,
+			Identity: events.APIGatewayCustomAuthorizerRequestTypeRequestIdentity{
+				SourceIP: r.RemoteAddr,
+			},
+		},
+	})
+}
+
+// methodArn returns the execute-api ARN of the request, which authorizer
+// policies are evaluated against.
+func (g *APIGateway) methodArn(r *http.Request) string {
+	return fmt.Sprintf("arn:aws:execute-api:%s:%s:%s/%s/%s%s",
+		localRegion, localAccountID, localAPIID, g.config.Stage, r.Method, g.stageRelativePath(r.URL.Path))
+}
+
+// identityValue resolves one identity source against the request.
+func (g *APIGateway) identityValue(r *http.Request, source string) (string, bool) {
+	kind, name, ok := parseIdentitySource(source)
+	if !ok {
+		return "", false
+	}
+	switch kind {
+	case "header":
+		values := r.Header.Values(name)
+		if len(values) == 0 {
+			return "", false
+		}
+		return strings.Join(values, ","), true
+	case "querystring":
+		values, exists := r.URL.Query()[name]
+		if !exists {
+			return "", false
+		}
+		return strings.Join(values, ","), true
+	default:
+		value, exists := g.config.StageVariables[name]
+		return value, exists
+	}
+}
+
+// parseIdentitySource splits an identity source expression into its kind
+// (header, querystring or stageVariables) and name. A bare name is treated as
+// a header, which keeps TOKEN authorizer configuration short.
+func parseIdentitySource(source string) (kind, name string, ok bool) {
+	for _, p := range identitySourcePrefixes {
+		if strings.HasPrefix(source, p.prefix) {
+			name = strings.TrimPrefix(source, p.prefix)
+			return p.kind, name, name != ""
+		}
+	}
+	if source == "" || strings.ContainsAny(source, "$.") {
+		return "", "", false
+	}
+	return "header", source, true
+}
+
+// httpAuthorizerDescription returns requestContext.authorizer for an HTTP API
+// event.
+func httpAuthorizerDescription(auth *authorizerResult) *events.APIGatewayV2HTTPRequestContextAuthorizerDescription {
+	if auth == nil {
+		return &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{}
+	}
+	if auth.jwt != nil {
+		return &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+			JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{
+				Claims: auth.jwt.claims,
+				Scopes: auth.jwt.scopes,
+			},
+		}
+	}
+	lambda := make(map[string]any, len(auth.context))
+	for k, v := range auth.context {
+		lambda[k] = v
+	}
+	return &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{Lambda: lambda}
+}
+
+// restAuthorizerContext returns requestContext.authorizer for a REST API
+// event: the authorizer context flattened alongside its principalId, or the
+// claims and scopes of a JWT.
+func restAuthorizerContext(auth *authorizerResult) map[string]any {
+	if auth == nil {
+		return nil
+	}
+	if auth.jwt != nil {
+		return map[string]any{"claims": auth.jwt.claims, "scopes": auth.jwt.scopes}
+	}
+	out := make(map[string]any, len(auth.context)+1)
+	for k, v := range auth.context {
+		out[k] = v
+	}
+	if auth.principalID != "" {
+		out["principalId"] = auth.principalID
+	}
+	return out
+}
@@ -0,0 +1,226 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/gorilla/mux"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/mocks"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func allowPolicy(effect, resource string) []byte {
+	resp := map[string]any{
+		"principalId": "user-1",
+		"policyDocument": map[string]any{
+			"Version": "2012-10-17",
+			"Statement": []map[string]any{
+				{"Action": "execute-api:Invoke", "Effect": effect, "Resource": resource},
+			},
+		},
+		"context": map[string]any{"tenant": "acme"},
+	}
+	b, _ := json.Marshal(resp)
+	return b
+}
+
+func TestAuthorizer_TokenAllow_InjectsContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t,
+		[]config.Route{{Path: "orders/{id}", Method: http.MethodGet, Service: "orders", Authorizer: "auth"}},
+		sched,
+		withAuthorizers(map[string]config.Authorizer{"auth": {Type: config.AuthorizerTypeToken, Service: "authfn"}}))
+
+	var authEvent events.APIGatewayCustomAuthorizerRequest
+	sched.EXPECT().
+		Invoke(gomock.Any(), "authfn", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ string, payload []byte) ([]byte, error) {
+			assert.Equal(t, "authfn", ctx.Value("service"))
+			require.NoError(t, json.Unmarshal(payload, &authEvent))
+			return allowPolicy("Allow", "arn:aws:execute-api:*:*:*/v1/GET/orders/*"), nil
+		})
+	event := captureEvent[events.APIGatewayV2HTTPRequest](sched, "orders", []byte(`"ok"`))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders/42", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "TOKEN", authEvent.Type)
+	assert.Equal(t, "Bearer abc", authEvent.AuthorizationToken)
+	assert.Equal(t, "arn:aws:execute-api:us-east-1:012345678901:simla/v1/GET/orders/42", authEvent.MethodArn)
+	require.NotNil(t, event.RequestContext.Authorizer)
+	assert.Equal(t, map[string]any{"tenant": "acme"}, event.RequestContext.Authorizer.Lambda)
+}
+
+func TestAuthorizer_PolicyDeny_Returns403(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t,
+		[]config.Route{{Path: "orders", Method: http.MethodGet, Service: "orders", Authorizer: "auth"}},
+		sched,
+		withAuthorizers(map[string]config.Authorizer{"auth": {Type: config.AuthorizerTypeToken, Service: "authfn"}}))
+
+	sched.EXPECT().Invoke(gomock.Any(), "authfn", gomock.Any()).Return(allowPolicy("Deny", "*"), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders", nil)
+	req.Header.Set("Authorization", "token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"message":"Forbidden"}`, w.Body.String())
+}
+
+func TestAuthorizer_MissingToken_Returns401(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t,
+		[]config.Route{{Path: "orders", Method: http.MethodGet, Service: "orders", Authorizer: "auth"}},
+		sched,
+		withAuthorizers(map[string]config.Authorizer{"auth": {Type: config.AuthorizerTypeToken, Service: "authfn"}}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/orders", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"message":"Unauthorized"}`, w.Body.String())
+}
+
+func TestAuthorizer_UnauthorizedError_Returns401(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t,
+		[]config.Route{{Path: "orders", Method: http.MethodGet, Service: "orders", Authorizer: "auth"}},
+		sched,
+		withAuthorizers(map[string]config.Authorizer{"auth": {Type: config.AuthorizerTypeToken, Service: "authfn"}}))
+
+	sched.EXPECT().Invoke(gomock.Any(), "authfn", gomock.Any()).
+		Return([]byte(`{"errorMessage":"Unauthorized","errorType":"Error"}`), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders", nil)
+	req.Header.Set("Authorization", "expired")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthorizer_CachesByIdentitySource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t,
+		[]config.Route{{Path: "orders", Method: http.MethodGet, Service: "orders", Authorizer: "auth"}},
+		sched,
+		withAuthorizers(map[string]config.Authorizer{"auth": {Type: config.AuthorizerTypeToken, Service: "authfn", ResultTTLSeconds: 300}}))
+
+	sched.EXPECT().Invoke(gomock.Any(), "authfn", gomock.Any()).Return(allowPolicy("Allow", "*"), nil).Times(1)
+	sched.EXPECT().Invoke(gomock.Any(), "orders", gomock.Any()).Return([]byte(`"ok"`), nil).Times(2)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/v1/orders", nil)
+		req.Header.Set("Authorization", "same-token")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
+
+func TestAuthorizer_RequestSimpleResponse_Denied(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t,
+		[]config.Route{{Path: "orders", Method: http.MethodGet, Service: "orders", Authorizer: "auth"}},
+		sched,
+		withAuthorizers(map[string]config.Authorizer{"auth": {
+			Type:                  config.AuthorizerTypeRequest,
+			Service:               "authfn",
+			IdentitySource:        []string{"$request.header.X-Api-Key", "$request.querystring.tenant"},
+			EnableSimpleResponses: true,
+		}}))
+
+	var authEvent events.APIGatewayV2CustomAuthorizerV2Request
+	sched.EXPECT().
+		Invoke(gomock.Any(), "authfn", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, payload []byte) ([]byte, error) {
+			require.NoError(t, json.Unmarshal(payload, &authEvent))
+			return []byte(`{"isAuthorized": false}`), nil
+		})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders?tenant=acme", nil)
+	req.Header.Set("X-Api-Key", "k1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "REQUEST", authEvent.Type)
+	assert.Equal(t, []string{"k1", "acme"}, authEvent.IdentitySource)
+	assert.Equal(t, "GET /v1/orders", authEvent.RouteKey)
+}
+
+func TestAuthorizer_RESTAPI_InjectsPrincipalID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t,
+		[]config.Route{{
+			Path: "orders", Method: http.MethodGet, Service: "orders",
+			Authorizer: "auth", PayloadFormatVersion: config.PayloadFormatV1,
+		}},
+		sched,
+		withAuthorizers(map[string]config.Authorizer{"auth": {Type: config.AuthorizerTypeToken, Service: "authfn"}}))
+
+	sched.EXPECT().Invoke(gomock.Any(), "authfn", gomock.Any()).Return(allowPolicy("Allow", "*"), nil)
+	var event events.APIGatewayProxyRequest
+	sched.EXPECT().
+		Invoke(gomock.Any(), "orders", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, payload []byte) ([]byte, error) {
+			require.NoError(t, json.Unmarshal(payload, &event))
+			return mustMarshal(t, events.APIGatewayProxyResponse{StatusCode: http.StatusOK}), nil
+		})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders", nil)
+	req.Header.Set("Authorization", "token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "user-1", event.RequestContext.Authorizer["principalId"])
+	assert.Equal(t, "acme", event.RequestContext.Authorizer["tenant"])
+}
+
+func TestRegisterRoutes_UnknownAuthorizer(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	gw := &APIGateway{
+		config: &config.APIGateway{
+			Stage:  "v1",
+			Routes: []config.Route{{Path: "x", Method: http.MethodGet, Authorizer: "missing"}},
+		},
+		logger: logger.WithField("component", "gateway"),
+		router: mux.NewRouter(),
+	}
+	err := gw.registerRoutes()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not defined")
+}
+
+func TestPolicyDocument_ExplicitDenyWins(t *testing.T) {
+	arn := "arn:aws:execute-api:us-east-1:012345678901:simla/v1/GET/orders"
+	policy := policyDocument{Statement: []policyStatement{
+		{Action: stringList{"execute-api:*"}, Effect: "Allow", Resource: stringList{"*"}},
+		{Action: stringList{"execute-api:Invoke"}, Effect: "Deny", Resource: stringList{"arn:aws:execute-api:*:*:*/v1/GET/*"}},
+	}}
+	assert.False(t, policy.allows(arn))
+	assert.True(t, policy.allows("arn:aws:execute-api:us-east-1:012345678901:simla/v1/POST/orders"))
+}
@@ -0,0 +1,389 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// sigV4Algorithm prefixes the Authorization header of SigV4-signed requests.
+const sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+// functionURLs collects the function URLs configured on services.
+func functionURLs(services map[string]config.Service) map[string]config.FunctionURL {
+	urls := make(map[string]config.FunctionURL)
+	for name, svc := range services {
+		if svc.FunctionURL != nil {
+			urls[name] = *svc.FunctionURL
+		}
+	}
+	return urls
+}
+
+// registerFunctionURLs validates every service's function URL and mounts
+// those with a Host or PathPrefix on the gateway router. It returns the
+// function URLs that need a dedicated listener, keyed by service.
+func (g *APIGateway) registerFunctionURLs() (map[string]config.FunctionURL, error) {
+	names := make([]string, 0, len(g.functionURLs))
+	for name := range g.functionURLs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dedicated := make(map[string]config.FunctionURL)
+	for _, name := range names {
+		url := g.functionURLs[name]
+		if err := g.validateFunctionURL(name, url); err != nil {
+			return nil, err
+		}
+		if url.Port != "" {
+			dedicated[name] = url
+			continue
+		}
+
+		g.logger.WithFields(logrus.Fields{
+			"service": name,
+			"host":    url.Host,
+			"prefix":  url.PathPrefix,
+		}).Info("registering function url")
+
+		handler := g.handleFunctionURL(name, url)
+		prefix := strings.TrimSuffix(url.PathPrefix, "/")
+		if prefix == "" {
+			g.functionURLRoute(url).HandlerFunc(handler)
+			continue
+		}
+		// A prefix matches itself and everything below it, but not sibling
+		// paths that merely share its leading characters.
+		g.functionURLRoute(url).Path(prefix).HandlerFunc(handler)
+		g.functionURLRoute(url).PathPrefix(prefix + "/").HandlerFunc(handler)
+	}
+	return dedicated, nil
+}
+
+// functionURLRoute returns a new gateway route matching the function URL's
+// Host, if it has one.
+func (g *APIGateway) functionURLRoute(url config.FunctionURL) *mux.Route {
+	r := g.router.NewRoute()
+	if url.Host != "" {
+		r = r.Host(url.Host)
+	}
+	return r
+}
+
+func (g *APIGateway) validateFunctionURL(service string, url config.FunctionURL) error {
+	switch url.AuthType {
+	case "", config.FunctionURLAuthNone, config.FunctionURLAuthIAM:
+	default:
+		return fmt.Errorf("service %q: unsupported functionUrl authType %q", service, url.AuthType)
+	}
+	if err := validateInvokeMode(url.InvokeMode); err != nil {
+		return fmt.Errorf("service %q: functionUrl %w", service, err)
+	}
+	switch {
+	case url.Port == "" && url.Host == "" && url.PathPrefix == "":
+		return fmt.Errorf("service %q: functionUrl needs a port, host or pathPrefix", service)
+	case url.Port != "" && (url.Host != "" || url.PathPrefix != ""):
+		return fmt.Errorf("service %q: functionUrl port cannot be combined with host or pathPrefix", service)
+	case url.Port != "" && url.Port == g.config.Port:
+		return fmt.Errorf("service %q: functionUrl port %s is already used by the gateway", service, url.Port)
+	case url.PathPrefix != "" && !strings.HasPrefix(url.PathPrefix, "/"):
+		return fmt.Errorf("service %q: functionUrl pathPrefix must start with /", service)
+	}
+	return nil
+}
+
+// startFunctionURLListeners serves each function URL on its own port until
+// ctx is cancelled.
+func (g *APIGateway) startFunctionURLListeners(ctx context.Context, urls map[string]config.FunctionURL) {
+	for name, url := range urls {
+		server := &http.Server{
+			Addr:    ":" + url.Port,
+			Handler: g.loggingMiddleware(g.handleFunctionURL(name, url)),
+		}
+		logger := g.logger.WithFields(logrus.Fields{"service": name, "port": url.Port})
+		logger.Info("starting function url listener")
+
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.WithError(err).Error("function url listener failed")
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(shutdown)
+		}()
+	}
+}
+
+// handleFunctionURL forwards every method and path to service with the
+// Function URL event shape.
+func (g *APIGateway) handleFunctionURL(service string, url config.FunctionURL) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := uuid.NewString()
+		logger := g.logger.WithFields(logrus.Fields{
+			"path":       r.URL.Path,
+			"method":     r.Method,
+			"request_id": requestID,
+			"service":    service,
+		})
+		defer r.Body.Close()
+
+		// Function URLs apply their own CORS configuration, never the
+		// gateway's.
+		for key := range w.Header() {
+			if strings.HasPrefix(key, "Access-Control-") {
+				w.Header().Del(key)
+			}
+		}
+		if url.CORS != nil && isPreflight(r) {
+			writeFunctionURLPreflight(w, r, url.CORS)
+			return
+		}
+
+		var iam *events.LambdaFunctionURLRequestContextAuthorizerIAMDescription
+		if url.AuthType == config.FunctionURLAuthIAM {
+			accessKey, ok := sigV4AccessKey(r)
+			if !ok {
+				logger.Warn("function url request is not SigV4 signed")
+				writeFunctionURLError(w, http.StatusForbidden, "Forbidden", requestID)
+				return
+			}
+			iam = &events.LambdaFunctionURLRequestContextAuthorizerIAMDescription{
+				AccessKey: accessKey,
+				AccountID: localAccountID,
+				CallerID:  accessKey,
+				UserARN:   fmt.Sprintf("arn:aws:iam::%s:user/simla", localAccountID),
+				UserID:    accessKey,
+			}
+		}
+
+		payload, err := g.buildFunctionURLEvent(r, service, url, requestID, iam)
+		if err != nil {
+			logger.WithError(err).Error("failed to build function url event")
+			http.Error(w, "failed to build function url request", http.StatusBadRequest)
+			return
+		}
+
+		if url.CORS != nil {
+			w = &corsResponseWriter{ResponseWriter: w, headers: functionURLCORSHeaders(r, url.CORS)}
+		}
+
+		if url.InvokeMode == config.InvokeModeResponseStream {
+			g.streamInvocation(r.Context(), w, service, payload, requestID, logger)
+			logger.WithField("duration", time.Since(start)).Info("successfully streamed function url request")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+		defer cancel()
+		ctx = context.WithValue(ctx, "service", service)
+
+		response, err := g.scheduler.Invoke(ctx, service, payload)
+		if err != nil {
+			logger.WithError(err).Error("failed to invoke service")
+			writeInvokeError(w, err, requestID)
+			return
+		}
+
+		writeHTTPAPIResponse(w, response, requestID, logger)
+		logger.WithField("duration", time.Since(start)).Info("successfully served function url request")
+	}
+}
+
+// buildFunctionURLEvent converts r into an events.LambdaFunctionURLRequest.
+func (g *APIGateway) buildFunctionURLEvent(
+	r *http.Request,
+	service string,
+	url config.FunctionURL,
+	requestID string,
+	iam *events.LambdaFunctionURLRequestContextAuthorizerIAMDescription,
+) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	path := r.URL.Path
+	if prefix := strings.TrimSuffix(url.PathPrefix, "/"); prefix != "" {
+		path = strings.TrimPrefix(path, prefix)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+
+	// Unauthenticated function URL requests report an anonymous account.
+	accountID := "anonymous"
+	var authorizer *events.LambdaFunctionURLRequestContextAuthorizerDescription
+	if iam != nil {
+		accountID = iam.AccountID
+		authorizer = &events.LambdaFunctionURLRequestContextAuthorizerDescription{IAM: iam}
+	}
+
+	query, _ := extractQueryParameters(r)
+	now := time.Now()
+	event := &events.LambdaFunctionURLRequest{
+		Version:               config.PayloadFormatV2,
+		RawPath:               path,
+		RawQueryString:        r.URL.RawQuery,
+		Cookies:               extractCookies(r),
+		Headers:               extractHeaders(r, requestID),
+		QueryStringParameters: query,
+		RequestContext: events.LambdaFunctionURLRequestContext{
+			AccountID:    accountID,
+			RequestID:    requestID,
+			Authorizer:   authorizer,
+			APIID:        service,
+			DomainName:   r.Host,
+			DomainPrefix: service,
+			Time:         now.Format(restRequestTimeFormat),
+			TimeEpoch:    now.UnixMilli(),
+			HTTP: events.LambdaFunctionURLRequestContextHTTPDescription{
+				Method:    r.Method,
+				Path:      path,
+				Protocol:  r.Proto,
+				SourceIP:  r.RemoteAddr,
+				UserAgent: r.UserAgent(),
+			},
+		},
+		Body:            bodyString(body),
+		IsBase64Encoded: !utf8.Valid(body),
+	}
+	return json.Marshal(event)
+}
+
+// sigV4AccessKey returns the access key ID of a request signed with AWS
+// Signature V4, either in the Authorization header or as a presigned URL.
+// The signature itself is not verified: simla has no credentials to check it
+// against, so any well-formed signature is accepted.
+func sigV4AccessKey(r *http.Request) (string, bool) {
+	credential := r.URL.Query().Get("X-Amz-Credential")
+	if auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), sigV4Algorithm+" "); ok {
+		credential = ""
+		for _, part := range strings.Split(auth, ",") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(part), "Credential="); ok {
+				credential = value
+			}
+		}
+	}
+	accessKey, scope, _ := strings.Cut(credential, "/")
+	return accessKey, accessKey != "" && scope != ""
+}
+
+func writeFunctionURLError(w http.ResponseWriter, statusCode int, message, requestID string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-ID", requestID)
+	w.WriteHeader(statusCode)
+	body, _ := json.Marshal(map[string]string{"Message": message})
+	_, _ = w.Write(body)
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// writeFunctionURLPreflight answers a CORS preflight without invoking the
+// function, as Lambda does for function URLs with CORS configured.
+func writeFunctionURLPreflight(w http.ResponseWriter, r *http.Request, cors *config.FunctionURLCORS) {
+	for key, values := range functionURLCORSHeaders(r, cors) {
+		w.Header()[key] = values
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		if len(cors.AllowMethods) > 0 {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(cors.AllowMethods, ","))
+		}
+		if len(cors.AllowHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(cors.AllowHeaders, ","))
+		}
+		if cors.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// functionURLCORSHeaders returns the CORS headers for a request whose Origin
+// is allowed. Requests without an allowed Origin get none.
+func functionURLCORSHeaders(r *http.Request, cors *config.FunctionURLCORS) http.Header {
+	headers := http.Header{}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return headers
+	}
+
+	wildcard, allowed := false, false
+	for _, o := range cors.AllowOrigins {
+		if o == "*" {
+			wildcard = true
+		}
+		if o == "*" || strings.EqualFold(o, origin) {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return headers
+	}
+
+	if wildcard && !cors.AllowCredentials {
+		headers.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		headers.Set("Access-Control-Allow-Origin", origin)
+		headers.Set("Vary", "Origin")
+	}
+	if cors.AllowCredentials {
+		headers.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if len(cors.ExposeHeaders) > 0 {
+		headers.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposeHeaders, ","))
+	}
+	return headers
+}
+
+// corsResponseWriter applies CORS headers when the response status is
+// written, so they replace any CORS headers the function returned.
+type corsResponseWriter struct {
+	http.ResponseWriter
+	headers     http.Header
+	wroteHeader bool
+}
+
+func (w *corsResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		for key, values := range w.headers {
+			w.ResponseWriter.Header()[key] = values
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *corsResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses through the
+// wrapper.
+func (w *corsResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,162 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestFunctionURL_PathPrefix_EventShape(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, []config.Route{{Path: defaultRouteKey, Service: "fallback"}}, sched, withFunctionURLs(map[string]config.FunctionURL{
+		"orders": {PathPrefix: "/fn/orders"},
+	}))
+
+	event := captureEvent[events.LambdaFunctionURLRequest](sched, "orders", mustMarshal(t, events.LambdaFunctionURLResponse{
+		StatusCode: http.StatusCreated,
+		Headers:    map[string]string{"X-Custom": "yes"},
+		Body:       "created",
+	}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/fn/orders/42/items?x=1", strings.NewReader(`{"a":1}`)))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "yes", w.Header().Get("X-Custom"))
+	assert.Equal(t, "created", w.Body.String())
+
+	assert.Equal(t, "2.0", event.Version)
+	assert.Equal(t, "/42/items", event.RawPath)
+	assert.Equal(t, "x=1", event.RawQueryString)
+	assert.Equal(t, http.MethodPatch, event.RequestContext.HTTP.Method)
+	assert.Equal(t, "anonymous", event.RequestContext.AccountID)
+	assert.Nil(t, event.RequestContext.Authorizer)
+	assert.JSONEq(t, `{"a":1}`, event.Body)
+}
+
+func TestFunctionURL_PrefixDoesNotMatchSiblings(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, nil, sched, withFunctionURLs(map[string]config.FunctionURL{
+		"orders": {PathPrefix: "/fn/orders"},
+	}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fn/orders-old", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFunctionURL_HostMatch_RawResponse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, nil, sched, withFunctionURLs(map[string]config.FunctionURL{
+		"orders": {Host: "orders.lambda-url.localhost"},
+	}))
+
+	event := captureEvent[events.LambdaFunctionURLRequest](sched, "orders", []byte(`{"ok":true}`))
+
+	req := httptest.NewRequest(http.MethodGet, "/anything", nil)
+	req.Host = "orders.lambda-url.localhost"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"ok":true}`, w.Body.String())
+	assert.Equal(t, "/anything", event.RawPath)
+	assert.Equal(t, "orders.lambda-url.localhost", event.RequestContext.DomainName)
+}
+
+func TestFunctionURL_IAMAuth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, nil, sched, withFunctionURLs(map[string]config.FunctionURL{
+		"orders": {PathPrefix: "/fn/orders", AuthType: config.FunctionURLAuthIAM},
+	}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fn/orders", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"Message":"Forbidden"}`, w.Body.String())
+
+	event := captureEvent[events.LambdaFunctionURLRequest](sched, "orders", []byte(`"ok"`))
+	req := httptest.NewRequest(http.MethodGet, "/fn/orders", nil)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20250101/us-east-1/lambda/aws4_request, SignedHeaders=host;x-amz-date, Signature=abc")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, event.RequestContext.Authorizer)
+	require.NotNil(t, event.RequestContext.Authorizer.IAM)
+	assert.Equal(t, "AKIDEXAMPLE", event.RequestContext.Authorizer.IAM.AccessKey)
+	assert.Equal(t, localAccountID, event.RequestContext.AccountID)
+}
+
+func TestFunctionURL_CORS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, nil, sched, withFunctionURLs(map[string]config.FunctionURL{
+		"orders": {
+			PathPrefix: "/fn/orders",
+			CORS: &config.FunctionURLCORS{
+				AllowOrigins:  []string{"https://app.example"},
+				AllowMethods:  []string{"GET", "POST"},
+				AllowHeaders:  []string{"content-type"},
+				ExposeHeaders: []string{"x-total"},
+				MaxAge:        600,
+			},
+		},
+	}))
+
+	// Preflight is answered without invoking the function.
+	req := httptest.NewRequest(http.MethodOptions, "/fn/orders", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://app.example", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET,POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	// CORS headers on responses replace those returned by the function.
+	captureEvent[events.LambdaFunctionURLRequest](sched, "orders", mustMarshal(t, events.LambdaFunctionURLResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Access-Control-Allow-Origin": "*"},
+	}))
+	req = httptest.NewRequest(http.MethodGet, "/fn/orders", nil)
+	req.Header.Set("Origin", "https://app.example")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "https://app.example", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "x-total", w.Header().Get("Access-Control-Expose-Headers"))
+}
+
+func TestFunctionURL_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		url  config.FunctionURL
+		want string
+	}{
+		{"no binding", config.FunctionURL{}, "needs a port, host or pathPrefix"},
+		{"port and prefix", config.FunctionURL{Port: "9500", PathPrefix: "/fn"}, "cannot be combined"},
+		{"gateway port", config.FunctionURL{Port: "8080"}, "already used by the gateway"},
+		{"bad auth", config.FunctionURL{Port: "9500", AuthType: "COGNITO"}, "unsupported functionUrl authType"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := &APIGateway{config: &config.APIGateway{Port: "8080"}}
+			err := gw.validateFunctionURL("orders", tt.url)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
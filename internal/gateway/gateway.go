@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,6 +18,7 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/nyambati/simla/internal/config"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
 	"github.com/nyambati/simla/internal/registry"
 	"github.com/nyambati/simla/internal/scheduler"
 	"github.com/sirupsen/logrus"
@@ -25,10 +27,13 @@ import (
 func NewAPIGateway(config *config.Config, registry registry.ServiceRegistryInterface, logger *logrus.Logger) GatewayInterface {
 	scheduler := scheduler.NewScheduler(config, registry, logger.WithField("component", "scheduler"))
 	return &APIGateway{
-		config:    &config.APIGateway,
-		scheduler: scheduler,
-		logger:    logger.WithField("component", "gateway"),
-		router:    mux.NewRouter(),
+		config:       &config.APIGateway,
+		functionURLs: functionURLs(config.Services),
+		scheduler:    scheduler,
+		logger:       logger.WithField("component", "gateway"),
+		router:       mux.NewRouter(),
+		authCache:    newAuthorizerCache(),
+		jwks:         newJWKSCache(),
 	}
 }
 
@@ -42,24 +47,22 @@ func (g *APIGateway) Start(ctx context.Context) error {
 		g.router.Use(g.corsMiddleware)
 	}
 
-	for _, route := range g.config.Routes {
-		rPath := filepath.Join("/", g.config.Stage, route.Path)
-		fields := logrus.Fields{
-			"method":  route.Method,
-			"path":    rPath,
-			"service": route.Service,
-		}
-		g.logger.WithFields(fields).Info("registering route")
-		g.router.Methods(route.Method).Path(rPath).HandlerFunc(g.handleRequest(route))
-		// Register the OPTIONS preflight handler for every route when CORS is on.
-		if g.config.CORS.Enabled {
-			g.router.Methods(http.MethodOptions).Path(rPath).HandlerFunc(g.handlePreflight())
-		}
+	// Function URLs mounted on this listener are registered before the API
+	// routes so that a $default route does not shadow them.
+	dedicated, err := g.registerFunctionURLs()
+	if err != nil {
+		return err
 	}
+	if err := g.registerRoutes(); err != nil {
+		return err
+	}
+	g.startFunctionURLListeners(ctx, dedicated)
 	return g.createHttpServer(ctx)
 }
 
-func (g *APIGateway) handleRequest(route config.Route) http.HandlerFunc {
+// handleRequest serves route. validator, when non-nil, checks requests after
+// authorization and before the service is invoked.
+func (g *APIGateway) handleRequest(route config.Route, validator *requestValidator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
@@ -72,7 +75,7 @@ func (g *APIGateway) handleRequest(route config.Route) http.HandlerFunc {
 			"request_id": requestID,
 		})
 
-		if r.Method != route.Method {
+		if !methodAllowed(route, r.Method) {
 			logger.Warnf("unsupported method: expected=%s, got=%s", route.Method, r.Method)
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -82,42 +85,94 @@ func (g *APIGateway) handleRequest(route config.Route) http.HandlerFunc {
 
 		defer r.Body.Close()
 
-		body, err := g.buildAPIGatewayEvent(r, requestID)
+		// Prepare context with timeout and service name for downstream tracing.
+		// 5 minutes to accommodate cold-start container pulls on first invocation.
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+		defer cancel()
+
+		auth, err := g.authorize(ctx, r, route, requestID, logger)
+		if err != nil {
+			var authErr *authorizerError
+			if !errors.As(err, &authErr) {
+				authErr = &authorizerError{statusCode: http.StatusInternalServerError, reason: err.Error()}
+			}
+			logger.WithError(err).Warn("request rejected by authorizer")
+			writeGatewayError(w, authErr.statusCode, authErr.message(), requestID)
+			return
+		}
+
+		if validator != nil {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				logger.WithError(err).Error("failed to read request body")
+				http.Error(w, "failed to read request body", http.StatusBadRequest)
+				return
+			}
+			if err := validator.validate(r, body); err != nil {
+				logger.WithError(err).Warn("request failed validation")
+				writeBadRequest(w, err, requestID)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		restAPI := g.payloadFormat(route) == config.PayloadFormatV1
+
+		var body []byte
+		if restAPI {
+			body, err = g.buildRESTAPIEvent(r, route, requestID, auth)
+		} else {
+			body, err = g.buildAPIGatewayEvent(r, route, requestID, auth)
+		}
 		if err != nil {
 			logger.WithError(err).Error("failed to build api gateway event")
 			http.Error(w, "failed to build api gateway request", http.StatusBadRequest)
 			return
 		}
 
-		// Prepare context with timeout and service name for downstream tracing.
-		// 5 minutes to accommodate cold-start container pulls on first invocation.
-		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
-		defer cancel()
+		if route.InvokeMode == config.InvokeModeResponseStream {
+			// Streamed responses are bounded by the scheduler rather than the
+			// buffered request timeout above.
+			g.streamInvocation(r.Context(), w, route.Service, body, requestID, logger)
+			logger.WithField("duration", time.Since(start)).Info("successfully streamed request")
+			return
+		}
+
 		ctx = context.WithValue(ctx, "service", route.Service)
 
 		// Invoke the Lambda through the scheduler.
 		response, err := g.scheduler.Invoke(ctx, route.Service, body)
 		if err != nil {
 			logger.WithError(err).Error("failed to invoke service")
-			writeJSONError(w, http.StatusBadGateway, err.Error(), requestID)
+			writeInvokeError(w, err, requestID)
 			return
 		}
 
-		// Attempt to interpret the response as a full APIGatewayV2HTTPResponse.
-		// If the Lambda returned one, honour its status code, headers, and body.
-		// Otherwise fall back to a plain 200 with the raw bytes as the body.
-		if err := writePassthroughResponse(w, response, requestID, logger); err != nil {
-			// Not an APIGatewayV2HTTPResponse — write raw bytes at 200.
-			w.Header().Set("Content-Type", "application/json")
-			w.Header().Set("X-Request-ID", requestID)
-			w.WriteHeader(http.StatusOK)
-			_, _ = io.Copy(w, bytes.NewReader(response))
+		if restAPI {
+			writeRESTResponse(w, response, requestID, logger)
+			logger.WithField("duration", time.Since(start)).Info("successfully routed request")
+			return
 		}
 
+		writeHTTPAPIResponse(w, response, requestID, logger)
 		logger.WithField("duration", time.Since(start)).Info("successfully routed request")
 	}
 }
 
+// writeHTTPAPIResponse writes a Lambda response the way HTTP APIs and
+// function URLs do. If the Lambda returned an APIGatewayV2HTTPResponse, its
+// status code, headers, and body are honoured; otherwise the raw bytes are
+// written as the body of a 200.
+func writeHTTPAPIResponse(w http.ResponseWriter, response []byte, requestID string, logger *logrus.Entry) {
+	if err := writePassthroughResponse(w, response, requestID, logger); err != nil {
+		// Not an APIGatewayV2HTTPResponse — write raw bytes at 200.
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-ID", requestID)
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.Copy(w, bytes.NewReader(response))
+	}
+}
+
 // writePassthroughResponse tries to unmarshal body as events.APIGatewayV2HTTPResponse.
 // It returns an error if the body is not a valid response structure (so the
 // caller can fall back to a raw write). A valid response must have a non-zero
@@ -130,7 +185,13 @@ func writePassthroughResponse(w http.ResponseWriter, body []byte, requestID stri
 	if resp.StatusCode == 0 {
 		return fmt.Errorf("not an APIGatewayV2HTTPResponse: StatusCode is 0")
 	}
+	writeLambdaResponse(w, &resp, requestID, logger)
+	return nil
+}
 
+// writeLambdaResponse writes the status code, headers, cookies and body of a
+// Lambda proxy response to w.
+func writeLambdaResponse(w http.ResponseWriter, resp *events.APIGatewayV2HTTPResponse, requestID string, logger *logrus.Entry) {
 	// Copy single-value headers from the Lambda response.
 	for k, v := range resp.Headers {
 		w.Header().Set(k, v)
@@ -166,15 +227,13 @@ func writePassthroughResponse(w http.ResponseWriter, body []byte, requestID stri
 				// observable, but do not surface it as an error to the caller
 				// (WriteHeader has already been called, so no fallback is possible).
 				logger.WithError(err).Error("failed to base64-decode Lambda response body")
-				return nil
+				return
 			}
 		} else {
 			bodyBytes = []byte(resp.Body)
 		}
 		_, _ = w.Write(bodyBytes)
 	}
-
-	return nil
 }
 
 // writeJSONError writes a structured JSON error body with the given status code.
@@ -186,6 +245,28 @@ func writeJSONError(w http.ResponseWriter, statusCode int, message string, reque
 	_, _ = w.Write(body)
 }
 
+// writeInvokeError reports a failed invocation: throttles as API Gateway's
+// 429, anything else as a 502.
+func writeInvokeError(w http.ResponseWriter, err error, requestID string) {
+	var throttled *simlaerrors.TooManyRequestsError
+	if errors.As(err, &throttled) {
+		w.Header().Set("X-Amzn-ErrorType", "TooManyRequestsException")
+		writeGatewayError(w, http.StatusTooManyRequests, "Too Many Requests", requestID)
+		return
+	}
+	writeJSONError(w, http.StatusBadGateway, err.Error(), requestID)
+}
+
+// writeGatewayError writes an error the way API Gateway itself reports one,
+// as {"message": ...}. A nil message is written as JSON null.
+func writeGatewayError(w http.ResponseWriter, statusCode int, message any, requestID string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-ID", requestID)
+	w.WriteHeader(statusCode)
+	body, _ := json.Marshal(map[string]any{"message": message})
+	_, _ = w.Write(body)
+}
+
 func (g *APIGateway) createHttpServer(ctx context.Context) error {
 	server := &http.Server{
 		Addr:    ":" + g.config.Port,
@@ -205,36 +286,29 @@ func (g *APIGateway) createHttpServer(ctx context.Context) error {
 	return server.Shutdown(shutdown)
 }
 
-func (g *APIGateway) buildAPIGatewayEvent(r *http.Request, requestID string) ([]byte, error) {
-	routeKey := fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+// buildAPIGatewayEvent converts r into an APIGatewayV2HTTPRequest for route.
+// Path variables captured by the router, including greedy {proxy+} segments,
+// are passed to the Lambda in PathParameters. auth, when non-nil, is the
+// result of the route's authorizer.
+func (g *APIGateway) buildAPIGatewayEvent(r *http.Request, route config.Route, requestID string, auth *authorizerResult) ([]byte, error) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		return nil, err
 	}
 
+	requestContext := g.httpRequestContext(r, route, requestID)
+	requestContext.Authorizer = httpAuthorizerDescription(auth)
+
 	event := &events.APIGatewayV2HTTPRequest{
-		Version:        "2.0",
-		RouteKey:       routeKey,
-		RawPath:        r.URL.Path,
-		RawQueryString: r.URL.RawQuery,
-		Headers:        extractHeaders(r, requestID),
-		Cookies:        extractCookies(r),
-		RequestContext: events.APIGatewayV2HTTPRequestContext{
-			RouteKey:   routeKey,
-			AccountID:  "012345678901",
-			Stage:      g.config.Stage,
-			RequestID:  requestID,
-			Time:       time.Now().Format(time.RFC3339),
-			Authorizer: &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{},
-			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
-				Method:    r.Method,
-				Path:      r.URL.Path,
-				Protocol:  r.Proto,
-				SourceIP:  r.RemoteAddr,
-				UserAgent: r.UserAgent(),
-			},
-			Authentication: events.APIGatewayV2HTTPRequestContextAuthentication{},
-		},
+		Version:         "2.0",
+		RouteKey:        requestContext.RouteKey,
+		RawPath:         r.URL.Path,
+		RawQueryString:  r.URL.RawQuery,
+		Headers:         extractHeaders(r, requestID),
+		Cookies:         extractCookies(r),
+		PathParameters:  extractPathParameters(r),
+		StageVariables:  g.config.StageVariables,
+		RequestContext:  requestContext,
 		Body:            bodyString(body),
 		IsBase64Encoded: !utf8.Valid(body),
 	}
@@ -242,6 +316,29 @@ func (g *APIGateway) buildAPIGatewayEvent(r *http.Request, requestID string) ([]
 	return json.Marshal(event)
 }
 
+// httpRequestContext builds the HTTP API requestContext shared by route and
+// REQUEST authorizer events.
+func (g *APIGateway) httpRequestContext(r *http.Request, route config.Route, requestID string) events.APIGatewayV2HTTPRequestContext {
+	return events.APIGatewayV2HTTPRequestContext{
+		RouteKey:   g.routeKey(route),
+		AccountID:  localAccountID,
+		APIID:      localAPIID,
+		Stage:      g.config.Stage,
+		RequestID:  requestID,
+		Time:       time.Now().Format(time.RFC3339),
+		TimeEpoch:  time.Now().UnixMilli(),
+		Authorizer: &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{},
+		HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Protocol:  r.Proto,
+			SourceIP:  r.RemoteAddr,
+			UserAgent: r.UserAgent(),
+		},
+		Authentication: events.APIGatewayV2HTTPRequestContextAuthentication{},
+	}
+}
+
 // extractHeaders collects the first value of every request header and injects
 // the X-Request-ID so Lambdas can read it from event.Headers.
 func extractHeaders(r *http.Request, requestID string) map[string]string {
@@ -255,6 +352,20 @@ func extractHeaders(r *http.Request, requestID string) map[string]string {
 	return headers
 }
 
+// extractPathParameters returns the path variables matched by the router, or
+// nil when the route has none so the field is omitted from the event.
+func extractPathParameters(r *http.Request) map[string]string {
+	vars := mux.Vars(r)
+	if len(vars) == 0 {
+		return nil
+	}
+	params := make(map[string]string, len(vars))
+	for k, v := range vars {
+		params[k] = v
+	}
+	return params
+}
+
 func extractCookies(r *http.Request) []string {
 	cookies := []string{}
 	for _, cookie := range r.Cookies() {
@@ -274,19 +385,19 @@ func (g *APIGateway) handleHealthCheck() http.HandlerFunc {
 func (g *APIGateway) corsDefaults() (origins, methods, headers string, maxAge int) {
 	origins = g.config.CORS.AllowOrigins
 	if origins == "" {
-		origins = "*"
+		origins = config.DefaultCORSAllowOrigins
 	}
 	methods = g.config.CORS.AllowMethods
 	if methods == "" {
-		methods = "GET,POST,PUT,PATCH,DELETE,OPTIONS"
+		methods = config.DefaultCORSAllowMethods
 	}
 	headers = g.config.CORS.AllowHeaders
 	if headers == "" {
-		headers = "Content-Type,Authorization,X-Request-ID"
+		headers = config.DefaultCORSAllowHeaders
 	}
 	maxAge = g.config.CORS.MaxAge
 	if maxAge == 0 {
-		maxAge = 86400
+		maxAge = config.DefaultCORSMaxAge
 	}
 	return
 }
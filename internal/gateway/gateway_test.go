@@ -14,6 +14,7 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/gorilla/mux"
 	"github.com/nyambati/simla/internal/config"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
 	"github.com/nyambati/simla/internal/mocks"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -47,7 +48,7 @@ func newTestGateway(t *testing.T, routes []config.Route, sched *mocks.MockSchedu
 	// Register each route.
 	for _, route := range routes {
 		r := route
-		gw.router.Methods(r.Method).Path("/v1/" + r.Path).HandlerFunc(gw.handleRequest(r))
+		gw.router.Methods(r.Method).Path("/v1/" + r.Path).HandlerFunc(gw.handleRequest(r, nil))
 	}
 
 	return gw, gw.router
@@ -111,6 +112,26 @@ func TestRequestID_PresentOnErrorResponse(t *testing.T) {
 	assert.Equal(t, body["requestId"], w.Header().Get("X-Request-ID"))
 }
 
+func TestHandler_ThrottledInvocation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+
+	route := config.Route{Path: "payments", Method: http.MethodGet, Service: "payments"}
+	_, router := newTestGateway(t, []config.Route{route}, sched)
+
+	sched.EXPECT().
+		Invoke(gomock.Any(), "payments", gomock.Any()).
+		Return(nil, simlaerrors.NewTooManyRequestsError("payments", 1))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/payments", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "TooManyRequestsException", w.Header().Get("X-Amzn-ErrorType"))
+	assert.JSONEq(t, `{"message":"Too Many Requests"}`, w.Body.String())
+}
+
 func TestRequestID_InjectedIntoEventHeaders(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	sched := mocks.NewMockSchedulerInterface(ctrl)
@@ -331,7 +352,8 @@ func TestBuildAPIGatewayEvent_Fields(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	req.AddCookie(&http.Cookie{Name: "tok", Value: "xyz"})
 
-	rawEvent, err := gw.buildAPIGatewayEvent(req, "test-request-id")
+	route := config.Route{Path: "greet", Method: http.MethodPost, Service: "greet"}
+	rawEvent, err := gw.buildAPIGatewayEvent(req, route, "test-request-id", nil)
 	require.NoError(t, err)
 
 	var event events.APIGatewayV2HTTPRequest
@@ -362,7 +384,8 @@ func TestBuildAPIGatewayEvent_BinaryBody_IsBase64(t *testing.T) {
 	binaryBody := []byte{0x89, 0x50, 0x4e, 0x47}
 	req := httptest.NewRequest(http.MethodPost, "/v1/upload", strings.NewReader(string(binaryBody)))
 
-	rawEvent, err := gw.buildAPIGatewayEvent(req, "rid")
+	route := config.Route{Path: "upload", Method: http.MethodPost, Service: "upload"}
+	rawEvent, err := gw.buildAPIGatewayEvent(req, route, "rid", nil)
 	require.NoError(t, err)
 
 	var event events.APIGatewayV2HTTPRequest
@@ -373,3 +396,287 @@ func TestBuildAPIGatewayEvent_BinaryBody_IsBase64(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, binaryBody, decoded)
 }
+
+// ------- path parameters and route selection --------------------------------
+
+// gatewayOption adjusts the APIGateway built by newRoutedGateway before its
+// routes are registered.
+type gatewayOption func(*APIGateway)
+
+// withAuthorizers configures the authorizers that routes can reference.
+func withAuthorizers(authorizers map[string]config.Authorizer) gatewayOption {
+	return func(gw *APIGateway) { gw.config.Authorizers = authorizers }
+}
+
+// withFunctionURLs serves the given function URLs next to the API routes.
+func withFunctionURLs(urls map[string]config.FunctionURL) gatewayOption {
+	return func(gw *APIGateway) { gw.functionURLs = urls }
+}
+
+// newRoutedGateway builds an APIGateway whose function URLs and routes are
+// registered in the same order as Start, so route precedence matches what
+// Start would produce.
+func newRoutedGateway(t *testing.T, routes []config.Route, sched *mocks.MockSchedulerInterface, opts ...gatewayOption) *mux.Router {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	gw := &APIGateway{
+		config:    &config.APIGateway{Port: "8080", Stage: "v1", Routes: routes},
+		scheduler: sched,
+		logger:    logger.WithField("component", "gateway"),
+		router:    mux.NewRouter(),
+		authCache: newAuthorizerCache(),
+	}
+	for _, opt := range opts {
+		opt(gw)
+	}
+	dedicated, err := gw.registerFunctionURLs()
+	require.NoError(t, err)
+	require.Empty(t, dedicated)
+	require.NoError(t, gw.registerRoutes())
+	return gw.router
+}
+
+// captureEvent expects one invocation of service answered with response and
+// returns a pointer that is filled with the decoded event once the request has
+// been served.
+func captureEvent[E any](sched *mocks.MockSchedulerInterface, service string, response []byte) *E {
+	event := new(E)
+	sched.EXPECT().
+		Invoke(gomock.Any(), service, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, payload []byte) ([]byte, error) {
+			_ = json.Unmarshal(payload, event)
+			return response, nil
+		})
+	return event
+}
+
+func TestRoute_PathParameters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, []config.Route{
+		{Path: "users/{id}/orders/{orderId}", Method: http.MethodGet, Service: "users"},
+	}, sched)
+
+	event := captureEvent[events.APIGatewayV2HTTPRequest](sched, "users", []byte(`"ok"`))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users/42/orders/abc", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]string{"id": "42", "orderId": "abc"}, event.PathParameters)
+	assert.Equal(t, "GET /v1/users/{id}/orders/{orderId}", event.RouteKey)
+	assert.Equal(t, "GET /v1/users/{id}/orders/{orderId}", event.RequestContext.RouteKey)
+	assert.Equal(t, "/v1/users/42/orders/abc", event.RawPath)
+}
+
+func TestRoute_GreedyProxy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, []config.Route{
+		{Path: "api/{proxy+}", Method: "ANY", Service: "api"},
+	}, sched)
+
+	event := captureEvent[events.APIGatewayV2HTTPRequest](sched, "api", []byte(`"ok"`))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/v1/api/a/b/c", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]string{"proxy": "a/b/c"}, event.PathParameters)
+	assert.Equal(t, "ANY /v1/api/{proxy+}", event.RouteKey)
+}
+
+func TestRoute_Precedence(t *testing.T) {
+	// Registered in the reverse of AWS precedence on purpose.
+	routes := []config.Route{
+		{Path: defaultRouteKey, Service: "fallback"},
+		{Path: "items/{proxy+}", Method: "ANY", Service: "greedy"},
+		{Path: "items/{id}", Method: "ANY", Service: "any-var"},
+		{Path: "items/{id}", Method: http.MethodGet, Service: "get-var"},
+		{Path: "items/special", Method: http.MethodGet, Service: "literal"},
+	}
+
+	tests := []struct {
+		method  string
+		path    string
+		service string
+	}{
+		{http.MethodGet, "/v1/items/special", "literal"},
+		{http.MethodGet, "/v1/items/7", "get-var"},
+		{http.MethodPost, "/v1/items/7", "any-var"},
+		{http.MethodGet, "/v1/items/7/reviews", "greedy"},
+		{http.MethodGet, "/v1/other", "fallback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			sched := mocks.NewMockSchedulerInterface(ctrl)
+			router := newRoutedGateway(t, routes, sched)
+
+			sched.EXPECT().Invoke(gomock.Any(), tt.service, gomock.Any()).Return([]byte(`"ok"`), nil)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
+
+func TestRoute_DefaultRouteKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, []config.Route{
+		{Path: defaultRouteKey, Service: "fallback"},
+	}, sched)
+
+	event := captureEvent[events.APIGatewayV2HTTPRequest](sched, "fallback", []byte(`"ok"`))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/v1/anything/here", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "$default", event.RouteKey)
+	assert.Nil(t, event.PathParameters)
+}
+
+func TestRoute_MethodMismatchWithoutDefault(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, []config.Route{
+		{Path: "users/{id}", Method: http.MethodGet, Service: "users"},
+	}, sched)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/users/1", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestSortRoutes_InvalidGreedyPosition(t *testing.T) {
+	_, err := sortRoutes([]config.Route{{Path: "a/{proxy+}/b", Method: http.MethodGet}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must be the last segment")
+}
+
+func TestMuxPathTemplate(t *testing.T) {
+	assert.Equal(t, "/v1/users/{id}", muxPathTemplate("/v1/users/{id}"))
+	assert.Equal(t, "/v1/files/{proxy:.+}", muxPathTemplate("/v1/files/{proxy+}"))
+}
+
+// ------- REST API (payload format 1.0) --------------------------------------
+
+func TestRESTAPI_EventShape(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, []config.Route{
+		{Path: "users/{id}", Method: http.MethodPost, Service: "users", PayloadFormatVersion: config.PayloadFormatV1},
+	}, sched)
+
+	var event events.APIGatewayProxyRequest
+	sched.EXPECT().
+		Invoke(gomock.Any(), "users", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, payload []byte) ([]byte, error) {
+			require.NoError(t, json.Unmarshal(payload, &event))
+			return mustMarshal(t, events.APIGatewayProxyResponse{StatusCode: http.StatusAccepted, Body: "queued"}), nil
+		})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/users/42?tag=a&tag=b&q=x", strings.NewReader(`{}`))
+	req.Header.Add("X-Multi", "one")
+	req.Header.Add("X-Multi", "two")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "queued", w.Body.String())
+
+	assert.Equal(t, "/users/{id}", event.Resource)
+	assert.Equal(t, "/users/42", event.Path)
+	assert.Equal(t, http.MethodPost, event.HTTPMethod)
+	assert.Equal(t, map[string]string{"id": "42"}, event.PathParameters)
+	assert.Equal(t, "two", event.Headers["X-Multi"])
+	assert.Equal(t, []string{"one", "two"}, event.MultiValueHeaders["X-Multi"])
+	assert.Equal(t, "b", event.QueryStringParameters["tag"])
+	assert.Equal(t, []string{"a", "b"}, event.MultiValueQueryStringParameters["tag"])
+	assert.Equal(t, "/v1/users/42", event.RequestContext.Path)
+	assert.Equal(t, "/users/{id}", event.RequestContext.ResourcePath)
+	assert.Equal(t, "v1", event.RequestContext.Stage)
+	assert.Equal(t, w.Header().Get("X-Request-ID"), event.RequestContext.RequestID)
+}
+
+func TestRESTAPI_GatewayDefaultAndStageVariables(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	gw := &APIGateway{
+		config: &config.APIGateway{
+			Stage:                "v1",
+			PayloadFormatVersion: config.PayloadFormatV1,
+			StageVariables:       map[string]string{"env": "local"},
+			Routes:               []config.Route{{Path: defaultRouteKey, Service: "fallback"}},
+		},
+		scheduler: sched,
+		logger:    logger.WithField("component", "gateway"),
+		router:    mux.NewRouter(),
+	}
+	require.NoError(t, gw.registerRoutes())
+
+	var event events.APIGatewayProxyRequest
+	sched.EXPECT().
+		Invoke(gomock.Any(), "fallback", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, payload []byte) ([]byte, error) {
+			require.NoError(t, json.Unmarshal(payload, &event))
+			return mustMarshal(t, events.APIGatewayProxyResponse{StatusCode: http.StatusOK}), nil
+		})
+
+	w := httptest.NewRecorder()
+	gw.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/a/b", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/{proxy+}", event.Resource)
+	assert.Equal(t, map[string]string{"proxy": "a/b"}, event.PathParameters)
+	assert.Equal(t, map[string]string{"env": "local"}, event.StageVariables)
+	assert.Nil(t, event.QueryStringParameters)
+}
+
+func TestRESTAPI_MalformedResponse_Returns502(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, []config.Route{
+		{Path: "greet", Method: http.MethodGet, Service: "greet", PayloadFormatVersion: config.PayloadFormatV1},
+	}, sched)
+
+	sched.EXPECT().Invoke(gomock.Any(), "greet", gomock.Any()).Return([]byte(`"Hello"`), nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/greet", nil))
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.JSONEq(t, `{"message":"Internal server error"}`, w.Body.String())
+}
+
+func TestRegisterRoutes_InvalidPayloadFormat(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	gw := &APIGateway{
+		config: &config.APIGateway{
+			Stage:  "v1",
+			Routes: []config.Route{{Path: "x", Method: http.MethodGet, PayloadFormatVersion: "3.0"}},
+		},
+		logger: logger.WithField("component", "gateway"),
+		router: mux.NewRouter(),
+	}
+	err := gw.registerRoutes()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported payloadFormatVersion")
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return b
+}
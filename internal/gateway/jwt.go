@@ -0,0 +1,270 @@
+package gateway
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/token"
+)
+
+// jwksRefreshInterval is how long a loaded key set is trusted before it is
+// read again. A token signed with an unknown kid forces an earlier reload, so
+// rotated keys are picked up immediately.
+const jwksRefreshInterval = 5 * time.Minute
+
+// jwtSigningMethods are the algorithms accepted by JWT authorizers. "none"
+// and the HMAC algorithms are never accepted.
+var jwtSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// jwtAuthorization is what a JWT authorizer contributes to the Lambda event.
+type jwtAuthorization struct {
+	claims map[string]string
+	scopes []string
+}
+
+// jwksCache holds key sets by location. A nil cache loads the key set on
+// every request.
+type jwksCache struct {
+	mu     sync.Mutex
+	client *http.Client
+	sets   map[string]jwksEntry
+}
+
+type jwksEntry struct {
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+func newJWKSCache() *jwksCache {
+	return &jwksCache{
+		client: &http.Client{Timeout: 10 * time.Second},
+		sets:   make(map[string]jwksEntry),
+	}
+}
+
+// keys returns the key set at location, a file path or http(s) URL. refresh
+// bypasses the cached copy.
+func (c *jwksCache) keys(ctx context.Context, location string, refresh bool) (map[string]crypto.PublicKey, error) {
+	if c == nil {
+		return loadJWKS(ctx, http.DefaultClient, location)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.sets[location]; ok && !refresh && time.Since(entry.fetched) < jwksRefreshInterval {
+		return entry.keys, nil
+	}
+	keys, err := loadJWKS(ctx, c.client, location)
+	if err != nil {
+		return nil, err
+	}
+	c.sets[location] = jwksEntry{keys: keys, fetched: time.Now()}
+	return keys, nil
+}
+
+func loadJWKS(ctx context.Context, client *http.Client, location string) (map[string]crypto.PublicKey, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		data, err := os.ReadFile(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwks: %w", err)
+		}
+		return token.ParseJWKS(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch jwks: %s returned %d", location, resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	return token.ParseJWKS(data)
+}
+
+// jwksLocation returns where a JWT authorizer's key set is read from.
+func jwksLocation(authorizer *config.Authorizer) (string, error) {
+	switch {
+	case authorizer.JWKSURL != "":
+		return authorizer.JWKSURL, nil
+	case authorizer.JWKSFile != "":
+		return authorizer.JWKSFile, nil
+	default:
+		return token.DefaultJWKSPath()
+	}
+}
+
+// validateJWTAuthorizer checks the settings specific to JWT authorizers.
+func validateJWTAuthorizer(name string, authorizer *config.Authorizer) error {
+	if authorizer.Issuer == "" {
+		return fmt.Errorf("authorizer %q: issuer is required", name)
+	}
+	if len(authorizer.Audience) == 0 {
+		return fmt.Errorf("authorizer %q: audience is required", name)
+	}
+	if authorizer.JWKSFile != "" && authorizer.JWKSURL != "" {
+		return fmt.Errorf("authorizer %q: set only one of jwksFile and jwksUrl", name)
+	}
+	return nil
+}
+
+// authorizeJWT validates the bearer token in the request the way API Gateway
+// JWT authorizers do: signature, issuer, audience (or client_id) and expiry
+// failures are 401, a token lacking every scope the route requires is 403.
+func (g *APIGateway) authorizeJWT(ctx context.Context, r *http.Request, route config.Route, authorizer *config.Authorizer) (*authorizerResult, error) {
+	source := defaultTokenSource
+	if len(authorizer.IdentitySource) > 0 {
+		source = authorizer.IdentitySource[0]
+	}
+	raw, ok := g.identityValue(r, source)
+	raw = strings.TrimSpace(raw)
+	if len(raw) > len("Bearer ") && strings.EqualFold(raw[:len("Bearer ")], "Bearer ") {
+		raw = strings.TrimSpace(raw[len("Bearer "):])
+	}
+	if !ok || raw == "" {
+		return nil, unauthorized(fmt.Sprintf("identity source %s is missing", source))
+	}
+
+	location, err := jwksLocation(authorizer)
+	if err != nil {
+		return nil, authorizerFailure(err.Error())
+	}
+
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(jwtSigningMethods),
+		jwt.WithIssuer(authorizer.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithJSONNumber(),
+	)
+	_, err = parser.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		return g.jwtKey(ctx, location, t)
+	})
+	if err != nil {
+		return nil, unauthorized(fmt.Sprintf("invalid token: %v", err))
+	}
+	if !audienceMatches(claims, authorizer.Audience) {
+		return nil, unauthorized("token audience does not match")
+	}
+
+	scopes := tokenScopes(claims)
+	if len(route.AuthorizationScopes) > 0 && !anyScope(scopes, route.AuthorizationScopes) {
+		return nil, forbidden("token has none of the route's authorization scopes")
+	}
+
+	return &authorizerResult{jwt: &jwtAuthorization{claims: stringClaims(claims), scopes: scopes}}, nil
+}
+
+// jwtKey selects the verification key for t by its kid header. An unknown kid
+// reloads the key set once in case the keys were rotated.
+func (g *APIGateway) jwtKey(ctx context.Context, location string, t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	for _, refresh := range []bool{false, true} {
+		keys, err := g.jwks.keys(ctx, location, refresh)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := keys[kid]; ok {
+			return key, nil
+		}
+		if kid == "" && len(keys) == 1 {
+			for _, key := range keys {
+				return key, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no key with kid %q in %s", kid, location)
+}
+
+// audienceMatches reports whether the token's aud claim, or its client_id
+// claim when it has no aud, contains one of the accepted audiences.
+func audienceMatches(claims jwt.MapClaims, accepted []string) bool {
+	audiences, _ := claims.GetAudience()
+	if len(audiences) == 0 {
+		if clientID, ok := claims["client_id"].(string); ok {
+			audiences = []string{clientID}
+		}
+	}
+	for _, aud := range audiences {
+		for _, want := range accepted {
+			if aud == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tokenScopes reads the space-delimited scope claim, falling back to scp,
+// which some issuers send as an array.
+func tokenScopes(claims jwt.MapClaims) []string {
+	for _, name := range []string{"scope", "scp"} {
+		switch v := claims[name].(type) {
+		case string:
+			return strings.Fields(v)
+		case []any:
+			scopes := make([]string, 0, len(v))
+			for _, s := range v {
+				scopes = append(scopes, fmt.Sprint(s))
+			}
+			return scopes
+		}
+	}
+	return nil
+}
+
+func anyScope(have, want []string) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stringClaims flattens claims to strings, as API Gateway does in
+// requestContext.authorizer.jwt.claims. Arrays are rendered as "[a b]" and
+// objects as JSON.
+func stringClaims(claims jwt.MapClaims) map[string]string {
+	out := make(map[string]string, len(claims))
+	for k, v := range claims {
+		switch val := v.(type) {
+		case string:
+			out[k] = val
+		case json.Number:
+			out[k] = val.String()
+		case []any:
+			parts := make([]string, 0, len(val))
+			for _, item := range val {
+				parts = append(parts, fmt.Sprint(item))
+			}
+			out[k] = "[" + strings.Join(parts, " ") + "]"
+		case map[string]any:
+			b, _ := json.Marshal(val)
+			out[k] = string(b)
+		default:
+			out[k] = fmt.Sprint(val)
+		}
+	}
+	return out
+}
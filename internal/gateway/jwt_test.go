@@ -0,0 +1,172 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/mocks"
+	"github.com/nyambati/simla/internal/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// newJWTIssuer creates a signing key in a temp dir and returns it with the
+// path of its JWKS.
+func newJWTIssuer(t *testing.T) (*token.Issuer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	issuer, err := token.LoadOrCreateIssuer(dir)
+	require.NoError(t, err)
+	return issuer, filepath.Join(dir, "jwks.json")
+}
+
+func jwtAuthorizers(jwksFile string) map[string]config.Authorizer {
+	return map[string]config.Authorizer{"jwt": {
+		Type:     config.AuthorizerTypeJWT,
+		Issuer:   "https://issuer.test",
+		Audience: []string{"orders-api"},
+		JWKSFile: jwksFile,
+	}}
+}
+
+func TestJWTAuthorizer_ValidToken_InjectsClaims(t *testing.T) {
+	issuer, jwks := newJWTIssuer(t)
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, []config.Route{{
+		Path: "orders", Method: http.MethodGet, Service: "orders",
+		Authorizer: "jwt", AuthorizationScopes: []string{"orders:read"},
+	}}, sched, withAuthorizers(jwtAuthorizers(jwks)))
+
+	event := captureEvent[events.APIGatewayV2HTTPRequest](sched, "orders", []byte(`"ok"`))
+
+	signed, err := issuer.Sign(map[string]any{
+		"iss":   "https://issuer.test",
+		"aud":   "orders-api",
+		"sub":   "alice",
+		"scope": "orders:read orders:write",
+		"roles": []string{"admin", "dev"},
+	}, time.Hour)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, event.RequestContext.Authorizer.JWT)
+	claims := event.RequestContext.Authorizer.JWT.Claims
+	assert.Equal(t, "alice", claims["sub"])
+	assert.Equal(t, "[admin dev]", claims["roles"])
+	assert.NotContains(t, claims["exp"], "e+")
+	assert.Equal(t, []string{"orders:read", "orders:write"}, event.RequestContext.Authorizer.JWT.Scopes)
+}
+
+func TestJWTAuthorizer_Rejections(t *testing.T) {
+	issuer, jwks := newJWTIssuer(t)
+	other, _ := newJWTIssuer(t)
+
+	valid := map[string]any{"iss": "https://issuer.test", "aud": "orders-api", "scope": "orders:read"}
+	with := func(overrides map[string]any) map[string]any {
+		claims := map[string]any{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		for k, v := range overrides {
+			claims[k] = v
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		signer *token.Issuer
+		claims map[string]any
+		ttl    time.Duration
+		want   int
+	}{
+		{"wrong issuer", issuer, with(map[string]any{"iss": "https://evil.test"}), time.Hour, http.StatusUnauthorized},
+		{"wrong audience", issuer, with(map[string]any{"aud": "other"}), time.Hour, http.StatusUnauthorized},
+		{"client_id audience", issuer, with(map[string]any{"aud": nil, "client_id": "orders-api"}), time.Hour, http.StatusOK},
+		{"expired", issuer, with(map[string]any{"exp": time.Now().Add(-time.Minute).Unix()}), 0, http.StatusUnauthorized},
+		{"unknown key", other, valid, time.Hour, http.StatusUnauthorized},
+		{"missing scope", issuer, with(map[string]any{"scope": "orders:write"}), time.Hour, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			sched := mocks.NewMockSchedulerInterface(ctrl)
+			router := newRoutedGateway(t, []config.Route{{
+				Path: "orders", Method: http.MethodGet, Service: "orders",
+				Authorizer: "jwt", AuthorizationScopes: []string{"orders:read"},
+			}}, sched, withAuthorizers(jwtAuthorizers(jwks)))
+			if tt.want == http.StatusOK {
+				sched.EXPECT().Invoke(gomock.Any(), "orders", gomock.Any()).Return([]byte(`"ok"`), nil)
+			}
+
+			claims := tt.claims
+			if claims["aud"] == nil {
+				delete(claims, "aud")
+			}
+			signed, err := tt.signer.Sign(claims, tt.ttl)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/orders", nil)
+			req.Header.Set("Authorization", "Bearer "+signed)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestJWTAuthorizer_RESTAPI_Claims(t *testing.T) {
+	issuer, jwks := newJWTIssuer(t)
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, []config.Route{{
+		Path: "orders", Method: http.MethodGet, Service: "orders",
+		Authorizer: "jwt", PayloadFormatVersion: config.PayloadFormatV1,
+	}}, sched, withAuthorizers(jwtAuthorizers(jwks)))
+
+	var event events.APIGatewayProxyRequest
+	sched.EXPECT().
+		Invoke(gomock.Any(), "orders", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, payload []byte) ([]byte, error) {
+			require.NoError(t, json.Unmarshal(payload, &event))
+			return mustMarshal(t, events.APIGatewayProxyResponse{StatusCode: http.StatusOK}), nil
+		})
+
+	signed, err := issuer.Sign(map[string]any{"iss": "https://issuer.test", "aud": "orders-api", "sub": "bob"}, time.Hour)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders", nil)
+	req.Header.Set("Authorization", signed)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	claims, ok := event.RequestContext.Authorizer["claims"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "bob", claims["sub"])
+}
+
+func TestValidateAuthorizer_JWTRequiresIssuerAndAudience(t *testing.T) {
+	gw := &APIGateway{config: &config.APIGateway{
+		Authorizers: map[string]config.Authorizer{"jwt": {Type: config.AuthorizerTypeJWT, Issuer: "https://issuer.test"}},
+	}}
+	err := gw.validateAuthorizer(config.Route{Path: "orders", Authorizer: "jwt"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "audience is required")
+}
@@ -0,0 +1,179 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// restRequestTimeFormat is the layout API Gateway uses for
+// requestContext.requestTime in REST API events.
+const restRequestTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// payloadFormat returns the payload format version for route. A route-level
+// setting wins over the gateway-wide one, and 2.0 is used when neither is set.
+func (g *APIGateway) payloadFormat(route config.Route) string {
+	if route.PayloadFormatVersion != "" {
+		return route.PayloadFormatVersion
+	}
+	if g.config.PayloadFormatVersion != "" {
+		return g.config.PayloadFormatVersion
+	}
+	return config.PayloadFormatV2
+}
+
+// validatePayloadFormat rejects payload format versions API Gateway does not
+// define.
+func (g *APIGateway) validatePayloadFormat(route config.Route) error {
+	switch format := g.payloadFormat(route); format {
+	case config.PayloadFormatV1, config.PayloadFormatV2:
+		return nil
+	default:
+		return fmt.Errorf("route %q: unsupported payloadFormatVersion %q (expected %q or %q)",
+			route.Path, format, config.PayloadFormatV1, config.PayloadFormatV2)
+	}
+}
+
+// buildRESTAPIEvent converts r into an events.APIGatewayProxyRequest, the
+// payload format 1.0 event REST APIs send to Lambda proxy integrations.
+// auth, when non-nil, is the result of the route's authorizer.
+func (g *APIGateway) buildRESTAPIEvent(r *http.Request, route config.Route, requestID string, auth *authorizerResult) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// REST APIs report the path relative to the stage in event.path and the
+	// full path in requestContext.path.
+	stagePath := g.stageRelativePath(r.URL.Path)
+	resource, pathParameters := g.restResource(r, route)
+
+	headers, multiValueHeaders := extractMultiValueHeaders(r, requestID)
+	query, multiValueQuery := extractQueryParameters(r)
+	now := time.Now()
+
+	event := &events.APIGatewayProxyRequest{
+		Resource:                        resource,
+		Path:                            stagePath,
+		HTTPMethod:                      r.Method,
+		Headers:                         headers,
+		MultiValueHeaders:               multiValueHeaders,
+		QueryStringParameters:           query,
+		MultiValueQueryStringParameters: multiValueQuery,
+		PathParameters:                  pathParameters,
+		StageVariables:                  g.config.StageVariables,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:        localAccountID,
+			Stage:            g.config.Stage,
+			RequestID:        requestID,
+			Protocol:         r.Proto,
+			ResourcePath:     resource,
+			Path:             r.URL.Path,
+			HTTPMethod:       r.Method,
+			RequestTime:      now.Format(restRequestTimeFormat),
+			RequestTimeEpoch: now.UnixMilli(),
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  r.RemoteAddr,
+				UserAgent: r.UserAgent(),
+			},
+			Authorizer: restAuthorizerContext(auth),
+		},
+		Body:            bodyString(body),
+		IsBase64Encoded: !utf8.Valid(body),
+	}
+
+	return json.Marshal(event)
+}
+
+// restResource returns the REST API resource path and path parameters for a
+// request matched by route.
+func (g *APIGateway) restResource(r *http.Request, route config.Route) (string, map[string]string) {
+	if !isDefaultRoute(route) {
+		return filepath.Join("/", route.Path), extractPathParameters(r)
+	}
+	// REST APIs have no $default route; the equivalent is a root-level greedy
+	// proxy resource.
+	var pathParameters map[string]string
+	if proxy := strings.TrimPrefix(g.stageRelativePath(r.URL.Path), "/"); proxy != "" {
+		pathParameters = map[string]string{"proxy": proxy}
+	}
+	return "/{proxy+}", pathParameters
+}
+
+// stageRelativePath strips the stage prefix from a request path, always
+// returning a path with a leading slash.
+func (g *APIGateway) stageRelativePath(path string) string {
+	rel := strings.TrimPrefix(path, strings.TrimSuffix(g.stagePrefix(), "/"))
+	if !strings.HasPrefix(rel, "/") {
+		rel = "/" + rel
+	}
+	return rel
+}
+
+// writeRESTResponse maps an events.APIGatewayProxyResponse returned by the
+// Lambda onto w. Unlike HTTP APIs, REST APIs do not infer a response from
+// arbitrary JSON: anything that is not a proxy response with a status code is
+// a malformed Lambda proxy response and becomes a 502.
+func writeRESTResponse(w http.ResponseWriter, body []byte, requestID string, logger *logrus.Entry) {
+	var resp events.APIGatewayProxyResponse
+	if err := json.Unmarshal(body, &resp); err != nil || resp.StatusCode == 0 {
+		logger.Error("malformed Lambda proxy response")
+		writeGatewayError(w, http.StatusBadGateway, "Internal server error", requestID)
+		return
+	}
+
+	writeLambdaResponse(w, &events.APIGatewayV2HTTPResponse{
+		StatusCode:        resp.StatusCode,
+		Headers:           resp.Headers,
+		MultiValueHeaders: resp.MultiValueHeaders,
+		Body:              resp.Body,
+		IsBase64Encoded:   resp.IsBase64Encoded,
+	}, requestID, logger)
+}
+
+// extractMultiValueHeaders returns the request headers in both REST API
+// shapes: headers holds the last value of each header, multiValueHeaders all
+// of them. The X-Request-ID is injected into both.
+func extractMultiValueHeaders(r *http.Request, requestID string) (map[string]string, map[string][]string) {
+	headers := make(map[string]string, len(r.Header)+1)
+	multi := make(map[string][]string, len(r.Header)+1)
+	for key, values := range r.Header {
+		if len(values) == 0 {
+			continue
+		}
+		headers[key] = values[len(values)-1]
+		multi[key] = append([]string(nil), values...)
+	}
+	headers["X-Request-ID"] = requestID
+	multi["X-Request-ID"] = []string{requestID}
+	return headers, multi
+}
+
+// extractQueryParameters returns the query string in both REST API shapes.
+// Both maps are nil when the request has no query string, matching the null
+// API Gateway sends.
+func extractQueryParameters(r *http.Request) (map[string]string, map[string][]string) {
+	values := r.URL.Query()
+	if len(values) == 0 {
+		return nil, nil
+	}
+	single := make(map[string]string, len(values))
+	multi := make(map[string][]string, len(values))
+	for key, vs := range values {
+		if len(vs) == 0 {
+			continue
+		}
+		single[key] = vs[len(vs)-1]
+		multi[key] = vs
+	}
+	return single, multi
+}
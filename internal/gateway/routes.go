@@ -0,0 +1,224 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultRouteKey is the API Gateway catch-all route. It matches any
+	// request under the stage that no other route matches.
+	defaultRouteKey = "$default"
+	// methodAny matches every HTTP method, like API Gateway's ANY route.
+	methodAny = "ANY"
+)
+
+// Segment kinds, ordered from most to least specific. API Gateway prefers
+// literal segments over path variables, and path variables over greedy
+// {proxy+} variables, when more than one route matches a request.
+const (
+	segmentLiteral = iota
+	segmentVariable
+	segmentGreedy
+)
+
+// registerRoutes adds every configured route to the mux router in API Gateway
+// precedence order. gorilla/mux dispatches to the first matching route, so
+// registering the most specific routes first gives AWS route selection.
+func (g *APIGateway) registerRoutes() error {
+	routes, err := sortRoutes(g.config.Routes)
+	if err != nil {
+		return err
+	}
+	validators := make([]*requestValidator, len(routes))
+	for i, route := range routes {
+		if err := g.validatePayloadFormat(route); err != nil {
+			return err
+		}
+		if err := g.validateAuthorizer(route); err != nil {
+			return err
+		}
+		if err := validateInvokeMode(route.InvokeMode); err != nil {
+			return fmt.Errorf("route %q: %w", route.Path, err)
+		}
+		if validators[i], err = newRequestValidator(route); err != nil {
+			return err
+		}
+	}
+
+	// Preflight handlers go first so that ANY and $default routes do not
+	// swallow OPTIONS requests when CORS is enabled.
+	if g.config.CORS.Enabled {
+		for _, route := range routes {
+			if isDefaultRoute(route) {
+				g.router.Methods(http.MethodOptions).PathPrefix(g.stagePrefix()).HandlerFunc(g.handlePreflight())
+				continue
+			}
+			rPath := muxPathTemplate(filepath.Join("/", g.config.Stage, route.Path))
+			g.router.Methods(http.MethodOptions).Path(rPath).HandlerFunc(g.handlePreflight())
+		}
+	}
+
+	for i, route := range routes {
+		fields := logrus.Fields{
+			"method":  route.Method,
+			"path":    route.Path,
+			"service": route.Service,
+		}
+
+		var r *mux.Route
+		if isDefaultRoute(route) {
+			r = g.router.PathPrefix(g.stagePrefix())
+		} else {
+			rPath := filepath.Join("/", g.config.Stage, route.Path)
+			fields["path"] = rPath
+			r = g.router.Path(muxPathTemplate(rPath))
+			if !isAnyMethod(route.Method) {
+				r = r.Methods(route.Method)
+			}
+		}
+
+		g.logger.WithFields(fields).Info("registering route")
+		r.HandlerFunc(g.handleRequest(route, validators[i]))
+	}
+	return nil
+}
+
+// stagePrefix returns the path prefix shared by every route in the stage.
+func (g *APIGateway) stagePrefix() string {
+	prefix := filepath.Join("/", g.config.Stage)
+	if prefix == "/" {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+// routeKey returns the API Gateway route key for route, e.g. "GET /v1/users/{id}"
+// or "$default".
+func (g *APIGateway) routeKey(route config.Route) string {
+	if isDefaultRoute(route) {
+		return defaultRouteKey
+	}
+	method := strings.ToUpper(route.Method)
+	if method == "" {
+		method = methodAny
+	}
+	return fmt.Sprintf("%s %s", method, filepath.Join("/", g.config.Stage, route.Path))
+}
+
+// sortRoutes validates the route paths and returns a copy of routes ordered by
+// API Gateway precedence: more specific paths first, a concrete method before
+// ANY on the same path, and $default last.
+func sortRoutes(routes []config.Route) ([]config.Route, error) {
+	sorted := make([]config.Route, len(routes))
+	copy(sorted, routes)
+
+	for _, route := range sorted {
+		if isDefaultRoute(route) {
+			continue
+		}
+		if _, err := segmentKinds(route.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if isDefaultRoute(a) != isDefaultRoute(b) {
+			return isDefaultRoute(b)
+		}
+		if isDefaultRoute(a) {
+			return false
+		}
+		if c := compareSpecificity(a.Path, b.Path); c != 0 {
+			return c < 0
+		}
+		return !isAnyMethod(a.Method) && isAnyMethod(b.Method)
+	})
+	return sorted, nil
+}
+
+// compareSpecificity returns a negative number when path a is more specific
+// than path b, a positive number when it is less specific, and zero when the
+// two are equally specific. Segments are compared left to right; at the first
+// segment whose kind differs, the more specific kind wins. When one path is a
+// prefix of the other the longer path wins, since it constrains more of the URL.
+func compareSpecificity(a, b string) int {
+	ka, _ := segmentKinds(a)
+	kb, _ := segmentKinds(b)
+	for i := 0; i < len(ka) && i < len(kb); i++ {
+		if ka[i] != kb[i] {
+			return ka[i] - kb[i]
+		}
+	}
+	return len(kb) - len(ka)
+}
+
+// segmentKinds classifies each segment of a route path. It returns an error
+// when a greedy {name+} variable is not the final segment or a variable is
+// malformed, mirroring the validation API Gateway performs on route keys.
+func segmentKinds(path string) ([]int, error) {
+	segments := splitPath(path)
+	kinds := make([]int, len(segments))
+	for i, seg := range segments {
+		switch {
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "+}"):
+			if i != len(segments)-1 {
+				return nil, fmt.Errorf("route %q: greedy path variable %s must be the last segment", path, seg)
+			}
+			kinds[i] = segmentGreedy
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}"):
+			if len(seg) == 2 {
+				return nil, fmt.Errorf("route %q: path variable has no name", path)
+			}
+			kinds[i] = segmentVariable
+		case strings.ContainsAny(seg, "{}"):
+			return nil, fmt.Errorf("route %q: segment %q must be a literal or a whole {variable}", path, seg)
+		default:
+			kinds[i] = segmentLiteral
+		}
+	}
+	return kinds, nil
+}
+
+// muxPathTemplate rewrites an API Gateway path template into a gorilla/mux
+// template. Plain {name} variables are already compatible; a greedy {name+}
+// variable becomes {name:.+} so it matches the rest of the path, slashes
+// included.
+func muxPathTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "+}") {
+			segments[i] = "{" + strings.TrimSuffix(seg[1:], "+}") + ":.+}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func splitPath(path string) []string {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+	return strings.Split(trimmed, "/")
+}
+
+func isDefaultRoute(route config.Route) bool {
+	return route.Path == defaultRouteKey
+}
+
+func isAnyMethod(method string) bool {
+	return method == "" || strings.EqualFold(method, methodAny)
+}
+
+// methodAllowed reports whether the request method satisfies the route.
+func methodAllowed(route config.Route, method string) bool {
+	return isDefaultRoute(route) || isAnyMethod(route.Method) || strings.EqualFold(route.Method, method)
+}
@@ -0,0 +1,146 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nyambati/simla/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// streamPreludeDelimiter separates the JSON prelude of a streamed
+	// response, which carries the status code and headers, from its body.
+	streamPreludeDelimiter = "\x00\x00\x00\x00\x00\x00\x00\x00"
+	// maxStreamPreludeSize bounds how much of a stream is buffered while
+	// looking for the prelude delimiter.
+	maxStreamPreludeSize = 64 << 10
+	streamChunkSize      = 32 << 10
+)
+
+// streamPrelude is the metadata a streaming function writes ahead of its
+// body, as produced by awslambda.HttpResponseStream.from in Node.js.
+type streamPrelude struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers"`
+	Cookies    []string          `json:"cookies"`
+}
+
+// validateInvokeMode checks an invokeMode setting.
+func validateInvokeMode(mode string) error {
+	switch mode {
+	case "", config.InvokeModeBuffered, config.InvokeModeResponseStream:
+		return nil
+	}
+	return fmt.Errorf("unsupported invokeMode %q: expected %s or %s", mode, config.InvokeModeBuffered, config.InvokeModeResponseStream)
+}
+
+// streamInvocation invokes service in RESPONSE_STREAM mode and relays the
+// response to w. Invocation failures before the first byte are reported as
+// a 502; later failures can only end the response early.
+func (g *APIGateway) streamInvocation(ctx context.Context, w http.ResponseWriter, service string, payload []byte, requestID string, logger *logrus.Entry) {
+	ctx = context.WithValue(ctx, "service", service)
+	stream, err := g.scheduler.InvokeStream(ctx, service, payload)
+	if err != nil {
+		logger.WithError(err).Error("failed to invoke service")
+		writeInvokeError(w, err, requestID)
+		return
+	}
+	defer stream.Close()
+
+	writeStreamingResponse(w, stream, requestID, logger)
+}
+
+// writeStreamingResponse writes a streamed Lambda response to w, flushing
+// after every chunk so clients see data as the function produces it. A
+// leading prelude sets the status code, headers and cookies; without one
+// the stream is the body of a 200.
+func writeStreamingResponse(w http.ResponseWriter, stream io.Reader, requestID string, logger *logrus.Entry) {
+	prelude, body, err := splitStreamPrelude(stream)
+	if err != nil {
+		logger.WithError(err).Error("failed to read response stream")
+		writeJSONError(w, http.StatusBadGateway, err.Error(), requestID)
+		return
+	}
+
+	statusCode := http.StatusOK
+	if prelude != nil {
+		for k, v := range prelude.Headers {
+			w.Header().Set(k, v)
+		}
+		for _, c := range prelude.Cookies {
+			w.Header().Add("Set-Cookie", c)
+		}
+		if prelude.StatusCode != 0 {
+			statusCode = prelude.StatusCode
+		}
+	}
+	w.Header().Set("X-Request-ID", requestID)
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	w.WriteHeader(statusCode)
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		logger.WithError(err).Debug("response writer does not support flushing")
+	}
+
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				logger.WithError(werr).Warn("client went away while streaming response")
+				return
+			}
+			_ = rc.Flush()
+		}
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			logger.WithError(err).Error("response stream ended early")
+			return
+		}
+	}
+}
+
+// splitStreamPrelude separates an optional prelude from stream and returns
+// the remaining body. A stream is only treated as having a prelude when it
+// starts with a JSON object terminated by streamPreludeDelimiter within
+// maxStreamPreludeSize bytes; anything else is returned untouched.
+func splitStreamPrelude(stream io.Reader) (*streamPrelude, io.Reader, error) {
+	br := bufio.NewReader(stream)
+	first, err := br.Peek(1)
+	if err != nil || first[0] != '{' {
+		return nil, br, nil
+	}
+
+	var buf []byte
+	chunk := make([]byte, 4096)
+	for len(buf) < maxStreamPreludeSize {
+		n, err := br.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if i := bytes.Index(buf, []byte(streamPreludeDelimiter)); i >= 0 {
+			var prelude streamPrelude
+			if json.Unmarshal(buf[:i], &prelude) == nil {
+				return &prelude, io.MultiReader(bytes.NewReader(buf[i+len(streamPreludeDelimiter):]), br), nil
+			}
+			break
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return nil, io.MultiReader(bytes.NewReader(buf), br), nil
+}
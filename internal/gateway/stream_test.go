@@ -0,0 +1,119 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func streamRoutes() []config.Route {
+	return []config.Route{{Path: "events", Method: http.MethodGet, Service: "events", InvokeMode: config.InvokeModeResponseStream}}
+}
+
+func TestStreaming_PreludeSetsStatusAndHeaders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, streamRoutes(), sched)
+
+	stream := `{"statusCode":201,"headers":{"Content-Type":"text/event-stream"},"cookies":["a=1"]}` +
+		streamPreludeDelimiter + "data: one\n\ndata: two\n\n"
+	sched.EXPECT().InvokeStream(gomock.Any(), "events", gomock.Any()).
+		Return(io.NopCloser(strings.NewReader(stream)), nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/events", nil))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "a=1", w.Header().Get("Set-Cookie"))
+	assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
+	assert.Equal(t, "data: one\n\ndata: two\n\n", w.Body.String())
+	assert.True(t, w.Flushed)
+}
+
+func TestStreaming_WithoutPrelude(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+	}{
+		{"plain text", "hello world"},
+		{"json without delimiter", `{"message":"hello"}`},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			sched := mocks.NewMockSchedulerInterface(ctrl)
+			router := newRoutedGateway(t, streamRoutes(), sched)
+			sched.EXPECT().InvokeStream(gomock.Any(), "events", gomock.Any()).
+				Return(io.NopCloser(strings.NewReader(tt.stream)), nil)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/events", nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.stream, w.Body.String())
+		})
+	}
+}
+
+func TestStreaming_ChunksReachClientBeforeStreamEnds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	router := newRoutedGateway(t, nil, sched, withFunctionURLs(map[string]config.FunctionURL{
+		"events": {
+			PathPrefix: "/fn/events",
+			InvokeMode: config.InvokeModeResponseStream,
+			CORS:       &config.FunctionURLCORS{AllowOrigins: []string{"*"}},
+		},
+	}))
+
+	pr, pw := io.Pipe()
+	sched.EXPECT().InvokeStream(gomock.Any(), "events", gomock.Any()).Return(pr, nil)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	go func() {
+		_, _ = io.WriteString(pw, `{"statusCode":200}`+streamPreludeDelimiter+"first")
+	}()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/fn/events", nil)
+	req.Header.Set("Origin", "https://app.example")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "*", resp.Header.Get("Access-Control-Allow-Origin"))
+
+	// The first chunk arrives while the function is still writing.
+	buf := make([]byte, len("first"))
+	_, err = io.ReadFull(resp.Body, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(buf))
+
+	go func() {
+		_, _ = io.WriteString(pw, " second")
+		_ = pw.Close()
+	}()
+	rest, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, " second", string(rest))
+}
+
+func TestRegisterRoutes_InvalidInvokeMode(t *testing.T) {
+	gw := &APIGateway{
+		config: &config.APIGateway{Routes: []config.Route{{Path: "events", Service: "events", InvokeMode: "STREAMING"}}},
+	}
+	err := gw.registerRoutes()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported invokeMode")
+}
@@ -4,13 +4,23 @@ package gateway
 
 import (
 	"context"
+	"sync"
 
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 	"github.com/nyambati/simla/internal/config"
 	"github.com/nyambati/simla/internal/scheduler"
 	"github.com/sirupsen/logrus"
 )
 
+// Identifiers reported in event request contexts and execute-api ARNs. They
+// are fixed so that Lambdas and authorizer policies see stable values locally.
+const (
+	localAccountID = config.DefaultAccountID
+	localRegion    = config.DefaultRegion
+	localAPIID     = "simla"
+)
+
 type GatewayInterface interface {
 	Start(ctx context.Context) error
 }
@@ -20,4 +30,23 @@ type APIGateway struct {
 	scheduler scheduler.SchedulerInterface
 	logger    *logrus.Entry
 	router    *mux.Router
+	authCache *authorizerCache
+	jwks      *jwksCache
+	// functionURLs holds the function URLs of services, keyed by service.
+	functionURLs map[string]config.FunctionURL
+}
+
+// WebSocketGateway emulates an API Gateway WebSocket API: it upgrades client
+// connections, routes their lifecycle events and messages to services, and
+// serves the @connections management API.
+type WebSocketGateway struct {
+	config    *config.WebSocketAPI
+	stage     string
+	scheduler scheduler.SchedulerInterface
+	logger    *logrus.Entry
+	router    *mux.Router
+	upgrader  websocket.Upgrader
+
+	mu          sync.RWMutex
+	connections map[string]*wsConnection
 }
@@ -0,0 +1,140 @@
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/openapi"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// API Gateway's messages for requests rejected by a request validator.
+const (
+	invalidBodyMessage       = "Invalid request body"
+	missingParametersMessage = "Missing required request parameters: [%s]"
+)
+
+// requestParameter is one required parameter of a route.
+type requestParameter struct {
+	location string
+	name     string
+}
+
+// requestValidator enforces a route's request model and required
+// parameters, like an API Gateway request validator.
+type requestValidator struct {
+	schema     *jsonschema.Schema
+	parameters []requestParameter
+}
+
+// badRequestError is a request rejected by a requestValidator. message is
+// what API Gateway returns to the client; err is the detail for the logs.
+type badRequestError struct {
+	message string
+	err     error
+}
+
+func (e *badRequestError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%s: %v", e.message, e.err)
+	}
+	return e.message
+}
+
+// newRequestValidator compiles the request model and parses the required
+// parameters of route. It returns nil when the route validates nothing.
+func newRequestValidator(route config.Route) (*requestValidator, error) {
+	if route.RequestModel == "" && len(route.RequiredParameters) == 0 {
+		return nil, nil
+	}
+	v := &requestValidator{}
+	for _, expr := range route.RequiredParameters {
+		location, name, err := openapi.ParseRequestParameter(expr)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", route.Path, err)
+		}
+		v.parameters = append(v.parameters, requestParameter{location: location, name: name})
+	}
+	if route.RequestModel != "" {
+		schema, err := compileRequestModel(route.RequestModel)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: requestModel: %w", route.Path, err)
+		}
+		v.schema = schema
+	}
+	return v, nil
+}
+
+func compileRequestModel(path string) (*jsonschema.Schema, error) {
+	location, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := openapi.LoadSchema(location)
+	if err != nil {
+		return nil, err
+	}
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(location, doc); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(location)
+}
+
+// validate checks r and its body. Required parameters are checked before
+// the body, the order in which API Gateway reports failures.
+func (v *requestValidator) validate(r *http.Request, body []byte) error {
+	if v == nil {
+		return nil
+	}
+
+	var missing []string
+	for _, param := range v.parameters {
+		if !hasRequestParameter(r, param) {
+			missing = append(missing, param.name)
+		}
+	}
+	if len(missing) > 0 {
+		return &badRequestError{message: fmt.Sprintf(missingParametersMessage, strings.Join(missing, ", "))}
+	}
+
+	if v.schema == nil {
+		return nil
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return &badRequestError{message: invalidBodyMessage, err: err}
+	}
+	if err := v.schema.Validate(instance); err != nil {
+		return &badRequestError{message: invalidBodyMessage, err: err}
+	}
+	return nil
+}
+
+func hasRequestParameter(r *http.Request, param requestParameter) bool {
+	switch param.location {
+	case openapi.ParameterQueryString:
+		return r.URL.Query().Has(param.name)
+	case openapi.ParameterHeader:
+		return r.Header.Get(param.name) != ""
+	default:
+		return mux.Vars(r)[param.name] != ""
+	}
+}
+
+// writeBadRequest writes a validation failure the way API Gateway does.
+func writeBadRequest(w http.ResponseWriter, err error, requestID string) {
+	message := invalidBodyMessage
+	var badRequest *badRequestError
+	if errors.As(err, &badRequest) {
+		message = badRequest.message
+	}
+	w.Header().Set("X-Amzn-ErrorType", "BadRequestException")
+	writeGatewayError(w, http.StatusBadRequest, message, requestID)
+}
@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func writeModel(t *testing.T, name, schema string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(schema), 0o644))
+	return path
+}
+
+func TestRequestValidation(t *testing.T) {
+	model := writeModel(t, "order.json", `{
+		"type": "object",
+		"required": ["sku"],
+		"properties": {"sku": {"type": "string"}, "quantity": {"type": "integer", "minimum": 1}}
+	}`)
+	route := config.Route{
+		Path: "orders/{id}", Method: http.MethodPut, Service: "orders",
+		RequestModel:       model,
+		RequiredParameters: []string{"method.request.querystring.version", "method.request.header.Idempotency-Key"},
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		headers map[string]string
+		body    string
+		want    int
+		message string
+	}{
+		{"valid", "/v1/orders/1?version=2", map[string]string{"Idempotency-Key": "k"}, `{"sku":"a","quantity":2}`, http.StatusOK, ""},
+		{"missing parameters", "/v1/orders/1", nil, `{"sku":"a"}`, http.StatusBadRequest, "Missing required request parameters: [version, Idempotency-Key]"},
+		{"missing header", "/v1/orders/1?version=2", nil, `{"sku":"a"}`, http.StatusBadRequest, "Missing required request parameters: [Idempotency-Key]"},
+		{"schema violation", "/v1/orders/1?version=2", map[string]string{"Idempotency-Key": "k"}, `{"quantity":0}`, http.StatusBadRequest, "Invalid request body"},
+		{"not json", "/v1/orders/1?version=2", map[string]string{"Idempotency-Key": "k"}, `sku=a`, http.StatusBadRequest, "Invalid request body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			sched := mocks.NewMockSchedulerInterface(ctrl)
+			router := newRoutedGateway(t, []config.Route{route}, sched)
+
+			var event *events.APIGatewayV2HTTPRequest
+			if tt.want == http.StatusOK {
+				event = captureEvent[events.APIGatewayV2HTTPRequest](sched, "orders", []byte(`"ok"`))
+			}
+
+			req := httptest.NewRequest(http.MethodPut, tt.target, strings.NewReader(tt.body))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want == http.StatusBadRequest {
+				assert.JSONEq(t, `{"message":"`+tt.message+`"}`, w.Body.String())
+				assert.Equal(t, "BadRequestException", w.Header().Get("X-Amzn-ErrorType"))
+				return
+			}
+			// The body is still delivered to the service after validation.
+			assert.JSONEq(t, tt.body, event.Body)
+		})
+	}
+}
+
+func TestNewRequestValidator_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		route config.Route
+		want  string
+	}{
+		{"bad parameter", config.Route{Path: "a", RequiredParameters: []string{"querystring.page"}}, "invalid required parameter"},
+		{"bad location", config.Route{Path: "a", RequiredParameters: []string{"method.request.body.page"}}, "unknown location"},
+		{"missing model", config.Route{Path: "a", RequestModel: "does-not-exist.json"}, "requestModel"},
+		{"invalid schema", config.Route{Path: "a", RequestModel: writeModel(t, "bad.json", `{"type": 5}`)}, "requestModel"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newRequestValidator(tt.route)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
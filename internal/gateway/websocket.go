@@ -0,0 +1,447 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/registry"
+	"github.com/nyambati/simla/internal/scheduler"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultRouteSelectionExpression is the expression API Gateway suggests
+	// for new WebSocket APIs.
+	defaultRouteSelectionExpression = "$request.body.action"
+	routeSelectionBodyPrefix        = "$request.body."
+
+	wsEventConnect    = "CONNECT"
+	wsEventMessage    = "MESSAGE"
+	wsEventDisconnect = "DISCONNECT"
+)
+
+// wsConnection is one connected WebSocket client.
+type wsConnection struct {
+	id          string
+	conn        *websocket.Conn
+	connectedAt time.Time
+	sourceIP    string
+	userAgent   string
+
+	// writeMu serialises writes: gorilla/websocket allows one concurrent
+	// writer, and both the @connections API and the gateway write frames.
+	writeMu    sync.Mutex
+	lastActive time.Time
+}
+
+func (c *wsConnection) write(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// connectionInfo is the GetConnection response body.
+type connectionInfo struct {
+	ConnectedAt  time.Time          `json:"connectedAt"`
+	Identity     connectionIdentity `json:"identity"`
+	LastActiveAt time.Time          `json:"lastActiveAt"`
+}
+
+type connectionIdentity struct {
+	SourceIP  string `json:"sourceIp"`
+	UserAgent string `json:"userAgent"`
+}
+
+func NewWebSocketGateway(config *config.Config, registry registry.ServiceRegistryInterface, logger *logrus.Logger) GatewayInterface {
+	stage := config.WebSocketAPI.Stage
+	if stage == "" {
+		stage = config.APIGateway.Stage
+	}
+	return &WebSocketGateway{
+		config:      &config.WebSocketAPI,
+		stage:       stage,
+		scheduler:   scheduler.NewScheduler(config, registry, logger.WithField("component", "scheduler")),
+		logger:      logger.WithField("component", "websocket"),
+		router:      mux.NewRouter(),
+		upgrader:    websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+		connections: make(map[string]*wsConnection),
+	}
+}
+
+func (g *WebSocketGateway) Start(ctx context.Context) error {
+	if err := g.registerRoutes(); err != nil {
+		return err
+	}
+	g.logger.Infof("starting websocket api on port %s", g.config.Port)
+
+	server := &http.Server{
+		Addr:    ":" + g.config.Port,
+		Handler: g.router,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			g.logger.WithError(err).Fatal("failed to start websocket api")
+		}
+	}()
+
+	<-ctx.Done()
+	g.logger.Info("shutting down websocket api")
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := server.Shutdown(shutdown)
+	// Hijacked connections are not closed by Shutdown.
+	g.closeAll()
+	return err
+}
+
+// registerRoutes validates the configuration and registers the connect
+// endpoint and the @connections API.
+func (g *WebSocketGateway) registerRoutes() error {
+	if _, err := g.selectionPath(); err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(g.config.Routes))
+	for _, route := range g.config.Routes {
+		if route.RouteKey == "" || route.Service == "" {
+			return fmt.Errorf("websocket route %q: routeKey and service are required", route.RouteKey)
+		}
+		if seen[route.RouteKey] {
+			return fmt.Errorf("websocket route %q is defined more than once", route.RouteKey)
+		}
+		seen[route.RouteKey] = true
+	}
+
+	stagePath := filepath.Join("/", g.stage)
+	connections := filepath.Join(stagePath, "@connections", "{connectionId}")
+	g.router.HandleFunc(connections, g.handlePostToConnection()).Methods(http.MethodPost)
+	g.router.HandleFunc(connections, g.handleGetConnection()).Methods(http.MethodGet)
+	g.router.HandleFunc(connections, g.handleDeleteConnection()).Methods(http.MethodDelete)
+	g.router.HandleFunc(stagePath, g.handleConnect())
+	if stagePath != "/" {
+		g.router.HandleFunc(stagePath+"/", g.handleConnect())
+	}
+	return nil
+}
+
+// selectionPath returns the JSON field path of the route selection
+// expression. Only $request.body.* expressions are supported.
+func (g *WebSocketGateway) selectionPath() ([]string, error) {
+	expr := g.config.RouteSelectionExpression
+	if expr == "" {
+		expr = defaultRouteSelectionExpression
+	}
+	if !strings.HasPrefix(expr, routeSelectionBodyPrefix) || expr == routeSelectionBodyPrefix {
+		return nil, fmt.Errorf("unsupported routeSelectionExpression %q: expected %s<field>", expr, routeSelectionBodyPrefix)
+	}
+	return strings.Split(strings.TrimPrefix(expr, routeSelectionBodyPrefix), "."), nil
+}
+
+// route returns the route configured for routeKey.
+func (g *WebSocketGateway) route(routeKey string) (config.WebSocketRoute, bool) {
+	for _, route := range g.config.Routes {
+		if route.RouteKey == routeKey {
+			return route, true
+		}
+	}
+	return config.WebSocketRoute{}, false
+}
+
+// selectRoute evaluates the route selection expression against a message,
+// falling back to $default when the message is not JSON, the selected value
+// is missing, or no route has that key.
+func (g *WebSocketGateway) selectRoute(body []byte) (config.WebSocketRoute, bool) {
+	path, _ := g.selectionPath()
+	var value any
+	if err := json.Unmarshal(body, &value); err == nil {
+		for _, field := range path {
+			obj, ok := value.(map[string]any)
+			if !ok {
+				value = nil
+				break
+			}
+			value = obj[field]
+		}
+		if key, ok := value.(string); ok && key != "" && !strings.HasPrefix(key, "$") {
+			if route, ok := g.route(key); ok {
+				return route, true
+			}
+		}
+	}
+	return g.route(config.WebSocketRouteDefault)
+}
+
+// handleConnect runs the $connect route, upgrades the connection when it
+// succeeds and then serves messages until the client disconnects.
+func (g *WebSocketGateway) handleConnect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !websocket.IsWebSocketUpgrade(r) {
+			http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+
+		conn := &wsConnection{
+			id:          newConnectionID(),
+			connectedAt: time.Now(),
+			sourceIP:    r.RemoteAddr,
+			userAgent:   r.UserAgent(),
+		}
+		conn.lastActive = conn.connectedAt
+		logger := g.logger.WithField("connection_id", conn.id)
+		domain := r.Host
+
+		if route, ok := g.route(config.WebSocketRouteConnect); ok {
+			requestID := uuid.NewString()
+			event := g.buildEvent(conn, route.RouteKey, wsEventConnect, requestID, domain)
+			headers, multiValueHeaders := extractMultiValueHeaders(r, requestID)
+			event.Headers, event.MultiValueHeaders = headers, multiValueHeaders
+			event.QueryStringParameters, event.MultiValueQueryStringParameters = extractQueryParameters(r)
+
+			response, err := g.invoke(r.Context(), route.Service, event)
+			if err != nil {
+				logger.WithError(err).Error("$connect route failed")
+				writeGatewayError(w, http.StatusInternalServerError, "Internal server error", requestID)
+				return
+			}
+			// A failed integration, such as a thrown error or a timeout,
+			// rejects the connection as API Gateway does.
+			if scheduler.IsFunctionError(response) {
+				logger.WithField("response", string(response)).Error("$connect route failed")
+				writeGatewayError(w, http.StatusInternalServerError, "Internal server error", requestID)
+				return
+			}
+			// A $connect integration rejects the connection by returning a
+			// non-2xx status code; any other response accepts it.
+			var resp events.APIGatewayProxyResponse
+			if json.Unmarshal(response, &resp) == nil && resp.StatusCode >= http.StatusMultipleChoices {
+				logger.Infof("connection rejected by $connect with %d", resp.StatusCode)
+				w.Header().Set("X-Request-ID", requestID)
+				w.WriteHeader(resp.StatusCode)
+				_, _ = io.WriteString(w, resp.Body)
+				return
+			}
+		}
+
+		ws, err := g.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			logger.WithError(err).Warn("websocket upgrade failed")
+			return
+		}
+		conn.conn = ws
+
+		g.mu.Lock()
+		g.connections[conn.id] = conn
+		g.mu.Unlock()
+		logger.Info("client connected")
+
+		g.serveConnection(conn, domain, logger)
+	}
+}
+
+// serveConnection dispatches messages from conn until it closes, then runs
+// the $disconnect route.
+func (g *WebSocketGateway) serveConnection(conn *wsConnection, domain string, logger *logrus.Entry) {
+	closeCode, closeReason := websocket.CloseNoStatusReceived, ""
+	for {
+		messageType, body, err := conn.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				closeCode, closeReason = closeErr.Code, closeErr.Text
+			} else {
+				closeCode, closeReason = websocket.CloseAbnormalClosure, "Going away"
+			}
+			break
+		}
+
+		g.mu.Lock()
+		conn.lastActive = time.Now()
+		g.mu.Unlock()
+
+		g.handleMessage(conn, messageType, body, domain, logger)
+	}
+
+	g.mu.Lock()
+	delete(g.connections, conn.id)
+	g.mu.Unlock()
+	_ = conn.conn.Close()
+	logger.WithField("code", closeCode).Info("client disconnected")
+
+	route, ok := g.route(config.WebSocketRouteDisconnect)
+	if !ok {
+		return
+	}
+	event := g.buildEvent(conn, route.RouteKey, wsEventDisconnect, uuid.NewString(), domain)
+	event.RequestContext.DisconnectStatusCode = int64(closeCode)
+	event.RequestContext.DisconnectReason = &closeReason
+	if _, err := g.invoke(context.Background(), route.Service, event); err != nil {
+		logger.WithError(err).Error("$disconnect route failed")
+	}
+}
+
+// handleMessage routes one client message. Like API Gateway, the client is
+// sent an error frame when no route matches or the integration fails.
+func (g *WebSocketGateway) handleMessage(conn *wsConnection, messageType int, body []byte, domain string, logger *logrus.Entry) {
+	requestID := uuid.NewString()
+	route, ok := g.selectRoute(body)
+	if !ok {
+		g.writeError(conn, "Forbidden", requestID)
+		return
+	}
+
+	event := g.buildEvent(conn, route.RouteKey, wsEventMessage, requestID, domain)
+	event.RequestContext.MessageID = uuid.NewString()
+	event.Body = bodyString(body)
+	event.IsBase64Encoded = messageType == websocket.BinaryMessage || !utf8.Valid(body)
+
+	if _, err := g.invoke(context.Background(), route.Service, event); err != nil {
+		logger.WithError(err).WithField("route_key", route.RouteKey).Error("websocket route failed")
+		g.writeError(conn, "Internal server error", requestID)
+	}
+}
+
+func (g *WebSocketGateway) writeError(conn *wsConnection, message, requestID string) {
+	body, _ := json.Marshal(map[string]string{
+		"message":      message,
+		"connectionId": conn.id,
+		"requestId":    requestID,
+	})
+	_ = conn.write(websocket.TextMessage, body)
+}
+
+func (g *WebSocketGateway) buildEvent(conn *wsConnection, routeKey, eventType, requestID, domain string) *events.APIGatewayWebsocketProxyRequest {
+	now := time.Now()
+	return &events.APIGatewayWebsocketProxyRequest{
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+			AccountID:         localAccountID,
+			APIID:             localAPIID,
+			Stage:             g.stage,
+			RequestID:         requestID,
+			ExtendedRequestID: requestID,
+			ConnectionID:      conn.id,
+			ConnectedAt:       conn.connectedAt.UnixMilli(),
+			DomainName:        domain,
+			EventType:         eventType,
+			MessageDirection:  "IN",
+			RouteKey:          routeKey,
+			RequestTime:       now.Format(restRequestTimeFormat),
+			RequestTimeEpoch:  now.UnixMilli(),
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  conn.sourceIP,
+				UserAgent: conn.userAgent,
+			},
+		},
+	}
+}
+
+func (g *WebSocketGateway) invoke(ctx context.Context, service string, event *events.APIGatewayWebsocketProxyRequest) ([]byte, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	// 5 minutes to accommodate cold-start container pulls on first invocation.
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	ctx = context.WithValue(ctx, "service", service)
+	return g.scheduler.Invoke(ctx, service, payload)
+}
+
+// connection looks up a live connection. Unknown connections are answered
+// with 410, API Gateway's GoneException.
+func (g *WebSocketGateway) connection(w http.ResponseWriter, r *http.Request) (*wsConnection, bool) {
+	id := mux.Vars(r)["connectionId"]
+	g.mu.RLock()
+	conn, ok := g.connections[id]
+	g.mu.RUnlock()
+	if !ok {
+		writeGatewayError(w, http.StatusGone, fmt.Sprintf("connection %s is gone", id), uuid.NewString())
+	}
+	return conn, ok
+}
+
+func (g *WebSocketGateway) handlePostToConnection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, ok := g.connection(w, r)
+		if !ok {
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		messageType := websocket.TextMessage
+		if !utf8.Valid(data) {
+			messageType = websocket.BinaryMessage
+		}
+		if err := conn.write(messageType, data); err != nil {
+			writeGatewayError(w, http.StatusGone, err.Error(), uuid.NewString())
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (g *WebSocketGateway) handleGetConnection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, ok := g.connection(w, r)
+		if !ok {
+			return
+		}
+		g.mu.RLock()
+		info := connectionInfo{
+			ConnectedAt:  conn.connectedAt.UTC(),
+			Identity:     connectionIdentity{SourceIP: conn.sourceIP, UserAgent: conn.userAgent},
+			LastActiveAt: conn.lastActive.UTC(),
+		}
+		g.mu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(info)
+	}
+}
+
+func (g *WebSocketGateway) handleDeleteConnection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, ok := g.connection(w, r)
+		if !ok {
+			return
+		}
+		// Closing the socket ends the read loop, which runs $disconnect.
+		_ = conn.write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		_ = conn.conn.Close()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// closeAll disconnects every client, e.g. on shutdown.
+func (g *WebSocketGateway) closeAll() {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	for _, conn := range g.connections {
+		_ = conn.write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+		_ = conn.conn.Close()
+	}
+}
+
+// newConnectionID returns a random, URL-safe connection ID shaped like the
+// ones API Gateway issues.
+func newConnectionID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,228 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/mocks"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// newTestWebSocketGateway starts a WebSocket API backed by sched on an
+// httptest server and returns it with the ws:// URL of the stage.
+func newTestWebSocketGateway(t *testing.T, routes []config.WebSocketRoute, sched *mocks.MockSchedulerInterface) (*WebSocketGateway, *httptest.Server, string) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	gw := &WebSocketGateway{
+		config:      &config.WebSocketAPI{Routes: routes},
+		stage:       "v1",
+		scheduler:   sched,
+		logger:      logger.WithField("component", "websocket"),
+		router:      mux.NewRouter(),
+		connections: make(map[string]*wsConnection),
+	}
+	require.NoError(t, gw.registerRoutes())
+
+	server := httptest.NewServer(gw.router)
+	t.Cleanup(server.Close)
+	return gw, server, "ws" + strings.TrimPrefix(server.URL, "http") + "/v1"
+}
+
+// recordWebSocketEvents expects invocations of service and sends each decoded
+// event on the returned channel.
+func recordWebSocketEvents(sched *mocks.MockSchedulerInterface, service string, response []byte) chan events.APIGatewayWebsocketProxyRequest {
+	ch := make(chan events.APIGatewayWebsocketProxyRequest, 10)
+	sched.EXPECT().
+		Invoke(gomock.Any(), service, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, payload []byte) ([]byte, error) {
+			var event events.APIGatewayWebsocketProxyRequest
+			_ = json.Unmarshal(payload, &event)
+			ch <- event
+			return response, nil
+		}).
+		AnyTimes()
+	return ch
+}
+
+func receive(t *testing.T, ch chan events.APIGatewayWebsocketProxyRequest) events.APIGatewayWebsocketProxyRequest {
+	t.Helper()
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for websocket event")
+		return events.APIGatewayWebsocketProxyRequest{}
+	}
+}
+
+func TestWebSocket_LifecycleAndRouteSelection(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	_, _, url := newTestWebSocketGateway(t, []config.WebSocketRoute{
+		{RouteKey: "$connect", Service: "connect"},
+		{RouteKey: "$disconnect", Service: "disconnect"},
+		{RouteKey: "$default", Service: "fallback"},
+		{RouteKey: "sendMessage", Service: "chat"},
+	}, sched)
+
+	connects := recordWebSocketEvents(sched, "connect", mustMarshal(t, events.APIGatewayProxyResponse{StatusCode: 200}))
+	chats := recordWebSocketEvents(sched, "chat", nil)
+	fallbacks := recordWebSocketEvents(sched, "fallback", nil)
+	disconnects := recordWebSocketEvents(sched, "disconnect", nil)
+
+	client, _, err := websocket.DefaultDialer.Dial(url+"?room=42", nil)
+	require.NoError(t, err)
+
+	connect := receive(t, connects)
+	assert.Equal(t, "CONNECT", connect.RequestContext.EventType)
+	assert.Equal(t, "$connect", connect.RequestContext.RouteKey)
+	assert.Equal(t, "42", connect.QueryStringParameters["room"])
+	connectionID := connect.RequestContext.ConnectionID
+	require.NotEmpty(t, connectionID)
+
+	require.NoError(t, client.WriteMessage(websocket.TextMessage, []byte(`{"action":"sendMessage","text":"hi"}`)))
+	chat := receive(t, chats)
+	assert.Equal(t, "MESSAGE", chat.RequestContext.EventType)
+	assert.Equal(t, "sendMessage", chat.RequestContext.RouteKey)
+	assert.Equal(t, connectionID, chat.RequestContext.ConnectionID)
+	assert.JSONEq(t, `{"action":"sendMessage","text":"hi"}`, chat.Body)
+
+	require.NoError(t, client.WriteMessage(websocket.TextMessage, []byte(`not json`)))
+	assert.Equal(t, "$default", receive(t, fallbacks).RequestContext.RouteKey)
+
+	require.NoError(t, client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye")))
+	disconnect := receive(t, disconnects)
+	assert.Equal(t, "DISCONNECT", disconnect.RequestContext.EventType)
+	assert.Equal(t, int64(websocket.CloseNormalClosure), disconnect.RequestContext.DisconnectStatusCode)
+	_ = client.Close()
+}
+
+func TestWebSocket_ConnectRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	_, _, url := newTestWebSocketGateway(t, []config.WebSocketRoute{
+		{RouteKey: "$connect", Service: "connect"},
+	}, sched)
+
+	sched.EXPECT().Invoke(gomock.Any(), "connect", gomock.Any()).
+		Return(mustMarshal(t, events.APIGatewayProxyResponse{StatusCode: http.StatusForbidden}), nil)
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestWebSocket_ConnectThrows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	_, _, url := newTestWebSocketGateway(t, []config.WebSocketRoute{
+		{RouteKey: "$connect", Service: "connect"},
+	}, sched)
+
+	sched.EXPECT().Invoke(gomock.Any(), "connect", gomock.Any()).
+		Return([]byte(`{"errorMessage":"boom","errorType":"Error"}`), nil)
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), "Internal server error")
+}
+
+func TestWebSocket_NoMatchingRoute_SendsForbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	_, _, url := newTestWebSocketGateway(t, []config.WebSocketRoute{
+		{RouteKey: "sendMessage", Service: "chat"},
+	}, sched)
+
+	client, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.NoError(t, client.WriteMessage(websocket.TextMessage, []byte(`{"action":"unknown"}`)))
+	_, msg, err := client.ReadMessage()
+	require.NoError(t, err)
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(msg, &body))
+	assert.Equal(t, "Forbidden", body["message"])
+	assert.NotEmpty(t, body["connectionId"])
+}
+
+func TestWebSocket_ConnectionsAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	_, server, url := newTestWebSocketGateway(t, []config.WebSocketRoute{
+		{RouteKey: "$connect", Service: "connect"},
+		{RouteKey: "$disconnect", Service: "disconnect"},
+	}, sched)
+
+	connects := recordWebSocketEvents(sched, "connect", nil)
+	disconnects := recordWebSocketEvents(sched, "disconnect", nil)
+
+	client, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer client.Close()
+	id := receive(t, connects).RequestContext.ConnectionID
+	endpoint := server.URL + "/v1/@connections/" + id
+
+	// PostToConnection delivers the body to the client.
+	resp, err := http.Post(endpoint, "application/json", strings.NewReader(`{"text":"pushed"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_, msg, err := client.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"text":"pushed"}`, string(msg))
+
+	// GetConnection describes it.
+	resp, err = http.Get(endpoint)
+	require.NoError(t, err)
+	var info map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+	resp.Body.Close()
+	assert.Contains(t, info, "connectedAt")
+	assert.Contains(t, info, "identity")
+
+	// DeleteConnection closes it and runs $disconnect.
+	req, _ := http.NewRequest(http.MethodDelete, endpoint, nil)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, id, receive(t, disconnects).RequestContext.ConnectionID)
+
+	// The connection is now gone.
+	resp, err = http.Post(endpoint, "text/plain", strings.NewReader("late"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusGone, resp.StatusCode)
+}
+
+func TestWebSocket_InvalidRouteSelectionExpression(t *testing.T) {
+	gw := &WebSocketGateway{
+		config: &config.WebSocketAPI{RouteSelectionExpression: "$request.header.x"},
+		router: mux.NewRouter(),
+	}
+	err := gw.registerRoutes()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported routeSelectionExpression")
+}
@@ -0,0 +1,254 @@
+package lambdaapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/nyambati/simla/internal/async"
+	"github.com/nyambati/simla/internal/config"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
+	"github.com/nyambati/simla/internal/registry"
+	"github.com/nyambati/simla/internal/runtime"
+	"github.com/nyambati/simla/internal/scheduler"
+	"github.com/sirupsen/logrus"
+)
+
+// invokePath is the route of the Invoke operation. {name} is a function
+// name, a partial ARN or a full ARN, optionally followed by a qualifier.
+const invokePath = "/2015-03-31/functions/{name}/invocations"
+
+func NewServer(config *config.Config, registry registry.ServiceRegistryInterface, queue async.QueueInterface, logger *logrus.Logger) *Server {
+	log := logger.WithField("component", "lambda-api")
+	rt, err := runtime.NewRuntime(registry, logger.WithField("component", "runtime"))
+	if err != nil {
+		log.WithError(err).Warn("docker client unavailable; LogType Tail will not include function output")
+	}
+	return &Server{
+		config:    config,
+		scheduler: scheduler.NewScheduler(config, registry, logger.WithField("component", "scheduler")),
+		queue:     queue,
+		registry:  registry,
+		runtime:   rt,
+		logger:    log,
+		router:    mux.NewRouter(),
+	}
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	s.registerRoutes()
+	s.logger.Infof("starting lambda api on port %s", s.config.LambdaAPI.Port)
+
+	server := &http.Server{
+		Addr:    ":" + s.config.LambdaAPI.Port,
+		Handler: s.router,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.WithError(err).Fatal("failed to start lambda api")
+		}
+	}()
+
+	<-ctx.Done()
+	s.logger.Info("shutting down lambda api")
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdown)
+}
+
+func (s *Server) registerRoutes() {
+	s.router.HandleFunc(invokePath, s.handleInvoke()).Methods(http.MethodPost)
+	s.router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "UnknownOperationException", "Unknown operation "+r.Method+" "+r.URL.Path)
+	})
+}
+
+// handleInvoke serves the Invoke operation.
+func (s *Server) handleInvoke() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := uuid.NewString()
+		w.Header().Set(headerRequestID, requestID)
+
+		function, qualifier := parseFunctionName(mux.Vars(r)["name"])
+		if q := r.URL.Query().Get("Qualifier"); q != "" {
+			qualifier = q
+		}
+		logger := s.logger.WithFields(logrus.Fields{"function": function, "request_id": requestID})
+		if qualifier != "" && qualifier != latestVersion {
+			logger.Debugf("ignoring qualifier %q: simla only runs %s", qualifier, latestVersion)
+		}
+
+		service, ok := s.config.ServiceName(function)
+		if !ok {
+			writeError(w, http.StatusNotFound, "ResourceNotFoundException", "Function not found: "+s.functionARN(function))
+			return
+		}
+
+		invocationType := r.Header.Get(headerInvocationType)
+		if invocationType == "" {
+			invocationType = InvocationTypeRequestResponse
+		}
+		limit := maxSyncPayloadSize
+		switch invocationType {
+		case InvocationTypeRequestResponse, InvocationTypeDryRun:
+		case InvocationTypeEvent:
+			limit = maxAsyncPayloadSize
+		default:
+			writeError(w, http.StatusBadRequest, "InvalidParameterValueException",
+				fmt.Sprintf("Unsupported InvocationType %q: expected RequestResponse, Event or DryRun", invocationType))
+			return
+		}
+
+		payload, err := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequestContentException", "Could not read request body")
+			return
+		}
+		if len(payload) > limit {
+			writeError(w, http.StatusRequestEntityTooLarge, "RequestEntityTooLargeException",
+				fmt.Sprintf("Request must be smaller than %d bytes for the Invoke operation", limit))
+			return
+		}
+		if len(bytes.TrimSpace(payload)) == 0 {
+			payload = []byte("{}")
+		}
+
+		switch invocationType {
+		case InvocationTypeDryRun:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case InvocationTypeEvent:
+			id, err := s.queue.Enqueue(r.Context(), service, payload)
+			if err != nil {
+				logger.WithError(err).Error("failed to queue event")
+				writeError(w, http.StatusTooManyRequests, "TooManyRequestsException", err.Error())
+				return
+			}
+			w.Header().Set(headerRequestID, id)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		start := time.Now()
+		ctx := scheduler.WithRequestID(context.WithValue(r.Context(), "service", service), requestID)
+		response, err := s.scheduler.Invoke(ctx, service, payload)
+		elapsed := time.Since(start)
+		if err != nil {
+			logger.WithError(err).Error("invocation failed")
+			var notFound *simlaerrors.ServiceNotFoundError
+			if errors.As(err, &notFound) {
+				writeError(w, http.StatusNotFound, "ResourceNotFoundException", "Function not found: "+s.functionARN(function))
+				return
+			}
+			var throttled *simlaerrors.TooManyRequestsError
+			if errors.As(err, &throttled) {
+				writeError(w, http.StatusTooManyRequests, "TooManyRequestsException", "Rate Exceeded.")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "ServiceException", err.Error())
+			return
+		}
+
+		if r.Header.Get(headerLogType) == logTypeTail {
+			w.Header().Set(headerLogResult, s.logResult(r.Context(), service, requestID, start, elapsed))
+		}
+		if scheduler.IsFunctionError(response) {
+			w.Header().Set(headerFunctionError, "Unhandled")
+		}
+		w.Header().Set(headerExecutedVersion, latestVersion)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(response)
+	}
+}
+
+// logResult builds the base64 encoded tail of the invocation log: the
+// function's output framed by START, END and REPORT lines. Output that
+// already holds the REPORT line of the Runtime API is used as is.
+func (s *Server) logResult(ctx context.Context, service, requestID string, start time.Time, elapsed time.Duration) string {
+	output := s.functionOutput(ctx, service, start)
+	var buf bytes.Buffer
+	if bytes.Contains(output, []byte("REPORT RequestId: "+requestID)) {
+		buf.Write(output)
+	} else {
+		fmt.Fprintf(&buf, "START RequestId: %s Version: %s\n", requestID, latestVersion)
+		if len(output) > 0 {
+			buf.Write(output)
+			if output[len(output)-1] != '\n' {
+				buf.WriteByte('\n')
+			}
+		}
+		fmt.Fprintf(&buf, "END RequestId: %s\n", requestID)
+		fmt.Fprintf(&buf, "REPORT RequestId: %s\tDuration: %.2f ms\tBilled Duration: %d ms\t\n",
+			requestID, float64(elapsed.Microseconds())/1000, (elapsed+time.Millisecond-1)/time.Millisecond)
+	}
+
+	log := buf.Bytes()
+	if len(log) > maxLogResultSize {
+		log = log[len(log)-maxLogResultSize:]
+	}
+	return base64.StdEncoding.EncodeToString(log)
+}
+
+// functionOutput returns what the service's container or process wrote
+// since start. It is best-effort: a missing container or Docker error yields
+// no output.
+func (s *Server) functionOutput(ctx context.Context, service string, start time.Time) []byte {
+	svc, ok := s.registry.GetService(ctx, service)
+	if !ok || svc.ID == "" {
+		return nil
+	}
+	rt := s.runtime
+	if runtime.IsProcess(svc.ID) {
+		rt = runtime.NewProcessRuntime(s.logger)
+	}
+	if rt == nil {
+		return nil
+	}
+	output, err := rt.LogsSince(ctx, svc.ID, start)
+	if err != nil {
+		s.logger.WithError(err).WithField("service", service).Debug("could not read function output")
+		return nil
+	}
+	return output
+}
+
+// parseFunctionName splits the FunctionName of an Invoke request into the
+// function name and qualifier. It accepts a name ("orders"), a name with a
+// qualifier ("orders:live"), a partial ARN ("012345678901:function:orders")
+// and a full ARN, each optionally qualified.
+func parseFunctionName(value string) (name, qualifier string) {
+	if _, rest, ok := strings.Cut(value, ":function:"); ok {
+		value = rest
+	}
+	name, qualifier, _ = strings.Cut(value, ":")
+	return name, qualifier
+}
+
+// functionARN returns the ARN AWS reports for the function name.
+func (s *Server) functionARN(name string) string {
+	return fmt.Sprintf("arn:aws:lambda:%s:%s:function:%s", s.config.AWS.RegionOrDefault(), s.config.AWS.AccountIDOrDefault(), name)
+}
+
+// writeError writes a Lambda API error the way the AWS SDKs expect it: the
+// error code in X-Amzn-ErrorType and a JSON body with the message.
+func writeError(w http.ResponseWriter, statusCode int, errorType, message string) {
+	fault := "User"
+	if statusCode >= http.StatusInternalServerError {
+		fault = "Service"
+	}
+	w.Header().Set(headerErrorType, errorType)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	body, _ := json.Marshal(errorResponse{Type: fault, Message: message})
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,295 @@
+package lambdaapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nyambati/simla/internal/config"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
+	"github.com/nyambati/simla/internal/mocks"
+	"github.com/nyambati/simla/internal/registry"
+	"github.com/nyambati/simla/internal/scheduler"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type testServer struct {
+	server    *Server
+	scheduler *mocks.MockSchedulerInterface
+	queue     *mocks.MockQueueInterface
+	registry  *mocks.MockServiceRegistryInterface
+	runtime   *mocks.MockRuntimeInterface
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	ts := &testServer{
+		scheduler: mocks.NewMockSchedulerInterface(ctrl),
+		queue:     mocks.NewMockQueueInterface(ctrl),
+		registry:  mocks.NewMockServiceRegistryInterface(ctrl),
+		runtime:   mocks.NewMockRuntimeInterface(ctrl),
+	}
+	ts.server = &Server{
+		config: &config.Config{Services: map[string]config.Service{
+			"orders": {Runtime: "provided.al2023"},
+		}},
+		scheduler: ts.scheduler,
+		queue:     ts.queue,
+		registry:  ts.registry,
+		runtime:   ts.runtime,
+		logger:    logger.WithField("component", "lambda-api"),
+		router:    mux.NewRouter(),
+	}
+	ts.server.registerRoutes()
+	return ts
+}
+
+func (ts *testServer) invoke(function string, headers map[string]string, body string) *httptest.ResponseRecorder {
+	path := "/2015-03-31/functions/" + url.PathEscape(function) + "/invocations"
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	ts.server.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestInvoke_RequestResponse(t *testing.T) {
+	for _, name := range []string{
+		"orders",
+		"Orders",
+		"orders:$LATEST",
+		"012345678901:function:orders",
+		"arn:aws:lambda:us-east-1:012345678901:function:orders:live",
+	} {
+		t.Run(name, func(t *testing.T) {
+			ts := newTestServer(t)
+			ts.scheduler.EXPECT().
+				Invoke(gomock.Any(), "orders", []byte(`{"id":1}`)).
+				DoAndReturn(func(ctx context.Context, _ string, _ []byte) ([]byte, error) {
+					assert.Equal(t, "orders", ctx.Value("service"))
+					return []byte(`{"ok":true}`), nil
+				})
+
+			rec := ts.invoke(name, nil, `{"id":1}`)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, `{"ok":true}`, rec.Body.String())
+			assert.Equal(t, "$LATEST", rec.Header().Get("X-Amz-Executed-Version"))
+			assert.NotEmpty(t, rec.Header().Get("X-Amzn-RequestId"))
+			assert.Empty(t, rec.Header().Get("X-Amz-Function-Error"))
+			assert.Empty(t, rec.Header().Get("X-Amz-Log-Result"))
+		})
+	}
+}
+
+func TestInvoke_FunctionError(t *testing.T) {
+	ts := newTestServer(t)
+	ts.scheduler.EXPECT().Invoke(gomock.Any(), "orders", gomock.Any()).
+		Return([]byte(`{"errorMessage":"boom","errorType":"Error"}`), nil)
+
+	rec := ts.invoke("orders", nil, "")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Unhandled", rec.Header().Get("X-Amz-Function-Error"))
+}
+
+func TestInvoke_LogTail(t *testing.T) {
+	ts := newTestServer(t)
+	ts.scheduler.EXPECT().Invoke(gomock.Any(), "orders", gomock.Any()).Return([]byte(`{}`), nil)
+	ts.registry.EXPECT().GetService(gomock.Any(), "orders").Return(&registry.Service{Name: "orders", ID: "c1"}, true)
+	ts.runtime.EXPECT().LogsSince(gomock.Any(), "c1", gomock.Any()).Return([]byte("processing order\n"), nil)
+
+	rec := ts.invoke("orders", map[string]string{"X-Amz-Log-Type": "Tail"}, `{}`)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	log, err := base64.StdEncoding.DecodeString(rec.Header().Get("X-Amz-Log-Result"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+	require.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "START RequestId: "))
+	assert.Equal(t, "processing order", lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "END RequestId: "))
+	assert.True(t, strings.HasPrefix(lines[3], "REPORT RequestId: "))
+	assert.Contains(t, lines[3], "Billed Duration: ")
+}
+
+func TestInvoke_LogTailFromRuntimeAPI(t *testing.T) {
+	ts := newTestServer(t)
+	var requestID string
+	ts.scheduler.EXPECT().Invoke(gomock.Any(), "orders", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ string, _ []byte) ([]byte, error) {
+			requestID = scheduler.RequestID(ctx)
+			return []byte(`{}`), nil
+		})
+	ts.registry.EXPECT().GetService(gomock.Any(), "orders").Return(&registry.Service{Name: "orders", ID: "c1"}, true)
+	ts.runtime.EXPECT().LogsSince(gomock.Any(), "c1", gomock.Any()).
+		DoAndReturn(func(context.Context, string, time.Time) ([]byte, error) {
+			return []byte("START RequestId: " + requestID + " Version: $LATEST\nprocessing order\nEND RequestId: " + requestID +
+				"\nREPORT RequestId: " + requestID + "\tDuration: 1.00 ms\tBilled Duration: 1 ms\tMemory Size: 128 MB\tInit Duration: 20.00 ms\t\n"), nil
+		})
+
+	rec := ts.invoke("orders", map[string]string{"X-Amz-Log-Type": "Tail"}, `{}`)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, requestID, rec.Header().Get("X-Amzn-RequestId"), "the function runs with the request ID returned")
+	log, err := base64.StdEncoding.DecodeString(rec.Header().Get("X-Amz-Log-Result"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+	require.Len(t, lines, 4, "the Runtime API's lines are not framed again")
+	assert.Contains(t, lines[3], "Init Duration: 20.00 ms")
+}
+
+func TestInvoke_LogTailIsTruncated(t *testing.T) {
+	ts := newTestServer(t)
+	ts.scheduler.EXPECT().Invoke(gomock.Any(), "orders", gomock.Any()).Return([]byte(`{}`), nil)
+	ts.registry.EXPECT().GetService(gomock.Any(), "orders").Return(&registry.Service{Name: "orders", ID: "c1"}, true)
+	ts.runtime.EXPECT().LogsSince(gomock.Any(), "c1", gomock.Any()).Return([]byte(strings.Repeat("x", 10000)), nil)
+
+	rec := ts.invoke("orders", map[string]string{"X-Amz-Log-Type": "Tail"}, `{}`)
+
+	log, err := base64.StdEncoding.DecodeString(rec.Header().Get("X-Amz-Log-Result"))
+	require.NoError(t, err)
+	assert.Len(t, log, maxLogResultSize)
+	assert.Contains(t, string(log), "REPORT RequestId: ")
+}
+
+func TestInvoke_Event(t *testing.T) {
+	ts := newTestServer(t)
+	ts.queue.EXPECT().Enqueue(gomock.Any(), "orders", []byte(`{"id":2}`)).Return("event-1", nil)
+
+	rec := ts.invoke("orders", map[string]string{"X-Amz-Invocation-Type": "Event"}, `{"id":2}`)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, "event-1", rec.Header().Get("X-Amzn-RequestId"))
+}
+
+func TestInvoke_EventQueueFull(t *testing.T) {
+	ts := newTestServer(t)
+	ts.queue.EXPECT().Enqueue(gomock.Any(), "orders", gomock.Any()).Return("", errors.New("async queue for service orders is full"))
+
+	rec := ts.invoke("orders", map[string]string{"X-Amz-Invocation-Type": "Event"}, `{}`)
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "TooManyRequestsException", rec.Header().Get("X-Amzn-ErrorType"))
+}
+
+func TestInvoke_DryRun(t *testing.T) {
+	ts := newTestServer(t)
+
+	rec := ts.invoke("orders", map[string]string{"X-Amz-Invocation-Type": "DryRun"}, `{}`)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestInvoke_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		function   string
+		headers    map[string]string
+		body       string
+		invokeErr  error
+		wantStatus int
+		wantType   string
+	}{
+		{
+			name:       "unknown function",
+			function:   "payments",
+			wantStatus: http.StatusNotFound,
+			wantType:   "ResourceNotFoundException",
+		},
+		{
+			name:       "unknown invocation type",
+			function:   "orders",
+			headers:    map[string]string{"X-Amz-Invocation-Type": "Later"},
+			wantStatus: http.StatusBadRequest,
+			wantType:   "InvalidParameterValueException",
+		},
+		{
+			name:       "event payload too large",
+			function:   "orders",
+			headers:    map[string]string{"X-Amz-Invocation-Type": "Event"},
+			body:       strings.Repeat("x", maxAsyncPayloadSize+1),
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantType:   "RequestEntityTooLargeException",
+		},
+		{
+			name:       "throttled",
+			function:   "orders",
+			invokeErr:  simlaerrors.NewTooManyRequestsError("orders", 1),
+			wantStatus: http.StatusTooManyRequests,
+			wantType:   "TooManyRequestsException",
+		},
+		{
+			name:       "invocation failure",
+			function:   "orders",
+			invokeErr:  simlaerrors.NewServiceInvocationError("orders", 500, "Service is not healthy"),
+			wantStatus: http.StatusInternalServerError,
+			wantType:   "ServiceException",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			if tt.invokeErr != nil {
+				ts.scheduler.EXPECT().Invoke(gomock.Any(), tt.function, gomock.Any()).Return(nil, tt.invokeErr)
+			}
+
+			rec := ts.invoke(tt.function, tt.headers, tt.body)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantType, rec.Header().Get("X-Amzn-ErrorType"))
+			var body errorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.NotEmpty(t, body.Message)
+		})
+	}
+}
+
+func TestInvoke_LogTailWithoutContainerOutput(t *testing.T) {
+	ts := newTestServer(t)
+	ts.scheduler.EXPECT().Invoke(gomock.Any(), "orders", gomock.Any()).Return([]byte(`{}`), nil)
+	ts.registry.EXPECT().GetService(gomock.Any(), "orders").Return(&registry.Service{Name: "orders", ID: "c1"}, true)
+	ts.runtime.EXPECT().LogsSince(gomock.Any(), "c1", gomock.Any()).Return(nil, errors.New("docker unavailable"))
+
+	rec := ts.invoke("orders", map[string]string{"X-Amz-Log-Type": "Tail"}, `{}`)
+
+	log, err := base64.StdEncoding.DecodeString(rec.Header().Get("X-Amz-Log-Result"))
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(log)), "\n"), 3)
+}
+
+func TestParseFunctionName(t *testing.T) {
+	tests := []struct {
+		value, name, qualifier string
+	}{
+		{"orders", "orders", ""},
+		{"orders:1", "orders", "1"},
+		{"012345678901:function:orders", "orders", ""},
+		{"arn:aws:lambda:eu-west-1:012345678901:function:orders", "orders", ""},
+		{"arn:aws:lambda:eu-west-1:012345678901:function:orders:live", "orders", "live"},
+	}
+	for _, tt := range tests {
+		name, qualifier := parseFunctionName(tt.value)
+		assert.Equal(t, tt.name, name, tt.value)
+		assert.Equal(t, tt.qualifier, qualifier, tt.value)
+	}
+}
@@ -0,0 +1,63 @@
+package lambdaapi
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/nyambati/simla/internal/async"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/registry"
+	"github.com/nyambati/simla/internal/runtime"
+	"github.com/nyambati/simla/internal/scheduler"
+	"github.com/sirupsen/logrus"
+)
+
+// Invocation types accepted in the X-Amz-Invocation-Type header.
+const (
+	InvocationTypeRequestResponse = "RequestResponse"
+	InvocationTypeEvent           = "Event"
+	InvocationTypeDryRun          = "DryRun"
+)
+
+// Headers of the Invoke operation.
+const (
+	headerInvocationType  = "X-Amz-Invocation-Type"
+	headerLogType         = "X-Amz-Log-Type"
+	headerFunctionError   = "X-Amz-Function-Error"
+	headerLogResult       = "X-Amz-Log-Result"
+	headerExecutedVersion = "X-Amz-Executed-Version"
+	headerRequestID       = "X-Amzn-RequestId"
+	headerErrorType       = "X-Amzn-ErrorType"
+)
+
+const (
+	// latestVersion is the only function version simla runs.
+	latestVersion = "$LATEST"
+	// logTypeTail asks for the end of the invocation log in X-Amz-Log-Result.
+	logTypeTail = "Tail"
+	// maxLogResultSize is how much of the log X-Amz-Log-Result carries.
+	maxLogResultSize = 4 << 10
+	// Payload quotas of synchronous and asynchronous invocations.
+	maxSyncPayloadSize  = 6 << 20
+	maxAsyncPayloadSize = 256 << 10
+)
+
+// Server serves the Lambda Invoke API
+// (POST /2015-03-31/functions/{name}/invocations) for the configured services.
+type Server struct {
+	config    *config.Config
+	scheduler scheduler.SchedulerInterface
+	// queue runs Event invocations.
+	queue    async.QueueInterface
+	registry registry.ServiceRegistryInterface
+	// runtime reads container logs for LogType Tail. It is nil when the
+	// Docker client could not be created, in which case the log result only
+	// holds the START, END and REPORT lines.
+	runtime runtime.RuntimeInterface
+	logger  *logrus.Entry
+	router  *mux.Router
+}
+
+// errorResponse is the body of a Lambda API error.
+type errorResponse struct {
+	Type    string `json:"Type"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,310 @@
+// Package layer merges Lambda layers into the directory a service's
+// containers mount at /opt.
+//
+// Layers are directories or .zip files laid out as their content should
+// appear under /opt. They are merged in order, so files of later layers
+// replace those of earlier ones, as Lambda does when it extracts them. The
+// merged directory is cached under a hash of the layers' contents and shared
+// by every container using the same layers.
+package layer
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDir returns the directory holding merged layers, ~/.simla/layers.
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".simla", "layers"), nil
+}
+
+// Merge merges the layers at paths, in order, into a directory below
+// cacheDir and returns its absolute path. The directory is reused as long
+// as the layers do not change.
+func Merge(cacheDir string, paths []string) (string, error) {
+	h := sha256.New()
+	for _, path := range paths {
+		if err := hashLayer(h, path); err != nil {
+			return "", err
+		}
+	}
+	cacheDir, err := filepath.Abs(cacheDir)
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(cacheDir, hex.EncodeToString(h.Sum(nil))[:16])
+	if _, err := os.Stat(dest); err == nil {
+		return dest, nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create layer cache: %w", err)
+	}
+	tmp, err := os.MkdirTemp(cacheDir, ".merge-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create layer cache: %w", err)
+	}
+	for _, path := range paths {
+		if err := extract(path, tmp); err != nil {
+			_ = os.RemoveAll(tmp)
+			return "", fmt.Errorf("layer %s: %w", path, err)
+		}
+	}
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", err
+	}
+
+	// Another container of the same layers may have merged them meanwhile;
+	// its copy is as good as ours.
+	if err := os.Rename(tmp, dest); err != nil {
+		_ = os.RemoveAll(tmp)
+		if _, statErr := os.Stat(dest); statErr == nil {
+			return dest, nil
+		}
+		return "", fmt.Errorf("failed to store merged layers: %w", err)
+	}
+	return dest, nil
+}
+
+// hashLayer writes the name, size and modification time of every file of
+// the layer at path to h.
+func hashLayer(h hash.Hash, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("layer %s: %w", path, err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(h, "layer %s\n", abs)
+
+	if !info.IsDir() {
+		if !isZip(path) {
+			return fmt.Errorf("layer %s: must be a directory or a .zip file", path)
+		}
+		fmt.Fprintf(h, "%d %d\n", info.Size(), info.ModTime().UnixNano())
+		return nil
+	}
+
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(path, p)
+		fmt.Fprintf(h, "%s %s %d %d\n", rel, info.Mode(), info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+}
+
+// extract copies the layer at path into dest, replacing existing files.
+func extract(path, dest string) error {
+	if isZip(path) {
+		return unzip(path, dest)
+	}
+	return copyDir(path, dest)
+}
+
+func isZip(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".zip")
+}
+
+// copyDir copies the tree at src into dest, preserving modes and symlinks.
+func copyDir(src, dest string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		target := filepath.Join(dest, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := checkParents(dest, target); err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return mkdir(target, info.Mode().Perm())
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			if err := replace(target); err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return writeFile(target, f, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+// unzip extracts the archive at path into dest.
+func unzip(path, dest string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		target := filepath.Join(dest, f.Name)
+		if !within(dest, target) {
+			return fmt.Errorf("invalid file path in archive: %s", f.Name)
+		}
+		if err := checkParents(dest, target); err != nil {
+			return err
+		}
+
+		mode := f.Mode()
+		if f.FileInfo().IsDir() {
+			if err := mkdir(target, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := mkdir(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		if mode&fs.ModeSymlink != 0 {
+			link, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			if err := checkLink(dest, target, string(link)); err != nil {
+				return fmt.Errorf("invalid symlink in archive: %s: %w", f.Name, err)
+			}
+			if err := replace(target); err != nil {
+				return err
+			}
+			if err := os.Symlink(string(link), target); err != nil {
+				return err
+			}
+			continue
+		}
+		perm := mode.Perm()
+		if perm == 0 {
+			perm = 0o644
+		}
+		err = writeFile(target, rc, perm)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// within reports whether path is dir or below it.
+func within(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+// checkParents returns an error when a directory between dest and target is
+// a symlink, so that nothing is written through a link to outside dest.
+func checkParents(dest, target string) error {
+	rel, err := filepath.Rel(dest, filepath.Dir(target))
+	if err != nil || rel == "." {
+		return err
+	}
+	dir := dest
+	for _, name := range strings.Split(rel, string(os.PathSeparator)) {
+		dir = filepath.Join(dir, name)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			rel, _ := filepath.Rel(dest, dir)
+			return fmt.Errorf("refusing to write through symlink %s", rel)
+		}
+	}
+	return nil
+}
+
+// checkLink returns an error when the symlink target pointing to link is
+// absolute or resolves to outside dest.
+func checkLink(dest, target, link string) error {
+	if filepath.IsAbs(link) {
+		return fmt.Errorf("absolute target %s", link)
+	}
+	if !within(dest, filepath.Join(filepath.Dir(target), link)) {
+		return fmt.Errorf("target %s is outside the layer", link)
+	}
+	return nil
+}
+
+// mkdir creates the directory target, replacing a file an earlier layer put
+// there.
+func mkdir(target string, perm fs.FileMode) error {
+	if info, err := os.Lstat(target); err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(target, perm|0o700)
+}
+
+// replace removes what an earlier layer put at target.
+func replace(target string) error {
+	if err := os.RemoveAll(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// writeFile writes the content of r to target with perm, replacing what an
+// earlier layer put there.
+func writeFile(target string, r io.Reader, perm fs.FileMode) error {
+	if err := replace(target); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
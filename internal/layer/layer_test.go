@@ -0,0 +1,147 @@
+package layer
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+}
+
+// writeLinkZip writes a zip holding a symlink named link to target,
+// followed by the files.
+func writeLinkZip(t *testing.T, path, link, target string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	w := zip.NewWriter(f)
+	header := &zip.FileHeader{Name: link}
+	header.SetMode(os.ModeSymlink | 0o777)
+	fw, err := w.CreateHeader(header)
+	require.NoError(t, err)
+	_, err = fw.Write([]byte(target))
+	require.NoError(t, err)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestMerge_LaterLayersTakePrecedence(t *testing.T) {
+	root := t.TempDir()
+	shared := filepath.Join(root, "shared")
+	writeTree(t, shared, map[string]string{
+		"python/common/__init__.py": "v1",
+		"python/common/util.py":     "util",
+	})
+	deps := filepath.Join(root, "deps.zip")
+	writeZip(t, deps, map[string]string{
+		"python/common/__init__.py": "v2",
+		"extensions/telemetry":      "#!/bin/sh",
+	})
+
+	merged, err := Merge(filepath.Join(root, "cache"), []string{shared, deps})
+	require.NoError(t, err)
+
+	assert.Equal(t, "v2", readFile(t, filepath.Join(merged, "python/common/__init__.py")))
+	assert.Equal(t, "util", readFile(t, filepath.Join(merged, "python/common/util.py")))
+	assert.Equal(t, "#!/bin/sh", readFile(t, filepath.Join(merged, "extensions/telemetry")))
+}
+
+func TestMerge_ReusesCacheUntilLayersChange(t *testing.T) {
+	root := t.TempDir()
+	shared := filepath.Join(root, "shared")
+	writeTree(t, shared, map[string]string{"nodejs/node_modules/lib/index.js": "one"})
+	cache := filepath.Join(root, "cache")
+
+	first, err := Merge(cache, []string{shared})
+	require.NoError(t, err)
+	again, err := Merge(cache, []string{shared})
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+
+	writeTree(t, shared, map[string]string{"nodejs/node_modules/lib/extra.js": "two"})
+	changed, err := Merge(cache, []string{shared})
+	require.NoError(t, err)
+	assert.NotEqual(t, first, changed)
+	assert.Equal(t, "two", readFile(t, filepath.Join(changed, "nodejs/node_modules/lib/extra.js")))
+}
+
+func TestMerge_Errors(t *testing.T) {
+	root := t.TempDir()
+
+	_, err := Merge(filepath.Join(root, "cache"), []string{filepath.Join(root, "missing")})
+	assert.Error(t, err)
+
+	text := filepath.Join(root, "layer.tar")
+	require.NoError(t, os.WriteFile(text, []byte("x"), 0o644))
+	_, err = Merge(filepath.Join(root, "cache"), []string{text})
+	assert.ErrorContains(t, err, "must be a directory or a .zip file")
+
+	evil := filepath.Join(root, "evil.zip")
+	writeZip(t, evil, map[string]string{"../escape": "x"})
+	_, err = Merge(filepath.Join(root, "cache"), []string{evil})
+	assert.ErrorContains(t, err, "invalid file path")
+
+	outside := filepath.Join(root, "outside")
+	require.NoError(t, os.Mkdir(outside, 0o755))
+	pwned := map[string]string{"lib/sub/pwned.txt": "x"}
+
+	absolute := filepath.Join(root, "absolute.zip")
+	writeLinkZip(t, absolute, "lib", outside, pwned)
+	_, err = Merge(filepath.Join(root, "cache"), []string{absolute})
+	assert.ErrorContains(t, err, "invalid symlink in archive: lib: absolute target")
+
+	relative := filepath.Join(root, "relative.zip")
+	writeLinkZip(t, relative, "lib", "../../../outside", pwned)
+	_, err = Merge(filepath.Join(root, "cache"), []string{relative})
+	assert.ErrorContains(t, err, "invalid symlink in archive: lib: target ../../../outside is outside the layer")
+
+	// A symlink of an earlier layer is not written through either.
+	linked := filepath.Join(root, "linked")
+	require.NoError(t, os.Mkdir(linked, 0o755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(linked, "lib")))
+	files := filepath.Join(root, "files.zip")
+	writeZip(t, files, pwned)
+	_, err = Merge(filepath.Join(root, "cache"), []string{linked, files})
+	assert.ErrorContains(t, err, "refusing to write through symlink lib")
+
+	assert.NoDirExists(t, filepath.Join(outside, "sub"))
+}
@@ -1,6 +1,6 @@
 // Package metrics provides lightweight in-process invocation metrics for
-// Lambda services. It tracks invocation count, total/average duration, and
-// error count per service name. All operations are safe for concurrent use.
+// Lambda services. It tracks invocation count, total/average duration, error
+// count, throttles and cold starts per service name. All operations are safe for concurrent use.
 package metrics
 
 import (
@@ -10,10 +10,36 @@ import (
 
 // ServiceMetrics holds the accumulated metrics for a single service.
 type ServiceMetrics struct {
-	Invocations  int64
-	Errors       int64
+	Invocations int64
+	Errors      int64
+	// Throttles counts invocations rejected because the service was at its
+	// reserved concurrency. They are not included in Invocations.
+	Throttles    int64
 	TotalLatency time.Duration
 	LastInvoked  time.Time
+	// ColdStarts counts the invocations that had to start a container
+	// first; the others were warm. TotalInitDuration is the time those
+	// containers took to become ready.
+	ColdStarts        int64
+	TotalInitDuration time.Duration
+}
+
+// WarmInvocations returns the number of invocations served by an already
+// running container.
+func (m *ServiceMetrics) WarmInvocations() int64 {
+	if m.ColdStarts > m.Invocations {
+		return 0
+	}
+	return m.Invocations - m.ColdStarts
+}
+
+// AvgInitDuration returns the mean time a cold start spent starting its
+// container, or zero if there were no cold starts.
+func (m *ServiceMetrics) AvgInitDuration() time.Duration {
+	if m.ColdStarts == 0 {
+		return 0
+	}
+	return m.TotalInitDuration / time.Duration(m.ColdStarts)
 }
 
 // AvgLatency returns the mean invocation duration, or zero if no invocations
@@ -68,6 +94,36 @@ func (r *Recorder) Record(serviceName string, duration time.Duration, errored bo
 	}
 }
 
+// RecordThrottle registers one invocation of serviceName that was rejected
+// before reaching a container.
+func (r *Recorder) RecordThrottle(serviceName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.metrics[serviceName]
+	if !ok {
+		m = &ServiceMetrics{}
+		r.metrics[serviceName] = m
+	}
+	m.Throttles++
+}
+
+// RecordColdStart registers that an invocation of serviceName started a
+// container, which took initDuration to become ready. The invocation itself
+// is recorded with Record.
+func (r *Recorder) RecordColdStart(serviceName string, initDuration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.metrics[serviceName]
+	if !ok {
+		m = &ServiceMetrics{}
+		r.metrics[serviceName] = m
+	}
+	m.ColdStarts++
+	m.TotalInitDuration += initDuration
+}
+
 // Get returns a snapshot of metrics for the named service.
 // The second return value is false when no invocations have been recorded yet.
 func (r *Recorder) Get(serviceName string) (ServiceMetrics, bool) {
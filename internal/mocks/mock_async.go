@@ -0,0 +1,56 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: types.go
+//
+// Generated by this command:
+//
+//	mockgen -source=types.go -destination=../mocks/mock_async.go -package=mocks QueueInterface
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockQueueInterface is a mock of QueueInterface interface.
+type MockQueueInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockQueueInterfaceMockRecorder
+	isgomock struct{}
+}
+
+// MockQueueInterfaceMockRecorder is the mock recorder for MockQueueInterface.
+type MockQueueInterfaceMockRecorder struct {
+	mock *MockQueueInterface
+}
+
+// NewMockQueueInterface creates a new mock instance.
+func NewMockQueueInterface(ctrl *gomock.Controller) *MockQueueInterface {
+	mock := &MockQueueInterface{ctrl: ctrl}
+	mock.recorder = &MockQueueInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQueueInterface) EXPECT() *MockQueueInterfaceMockRecorder {
+	return m.recorder
+}
+
+// Enqueue mocks base method.
+func (m *MockQueueInterface) Enqueue(ctx context.Context, service string, payload []byte) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", ctx, service, payload)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Enqueue indicates an expected call of Enqueue.
+func (mr *MockQueueInterfaceMockRecorder) Enqueue(ctx, service, payload any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockQueueInterface)(nil).Enqueue), ctx, service, payload)
+}
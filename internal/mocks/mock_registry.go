@@ -41,6 +41,21 @@ func (m *MockServiceRegistryInterface) EXPECT() *MockServiceRegistryInterfaceMoc
 	return m.recorder
 }
 
+// AddInstance mocks base method.
+func (m *MockServiceRegistryInterface) AddInstance(ctx context.Context, serviceName string, index int) (*registry.Instance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddInstance", ctx, serviceName, index)
+	ret0, _ := ret[0].(*registry.Instance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddInstance indicates an expected call of AddInstance.
+func (mr *MockServiceRegistryInterfaceMockRecorder) AddInstance(ctx, serviceName, index any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddInstance", reflect.TypeOf((*MockServiceRegistryInterface)(nil).AddInstance), ctx, serviceName, index)
+}
+
 // AddService mocks base method.
 func (m *MockServiceRegistryInterface) AddService(ctx context.Context, serviceName string) (*registry.Service, error) {
 	m.ctrl.T.Helper()
@@ -139,6 +154,20 @@ func (mr *MockServiceRegistryInterfaceMockRecorder) UpdateHealth(ctx, name, heal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateHealth", reflect.TypeOf((*MockServiceRegistryInterface)(nil).UpdateHealth), ctx, name, healthy)
 }
 
+// UpdateInstance mocks base method.
+func (m *MockServiceRegistryInterface) UpdateInstance(ctx context.Context, serviceName string, port int, containerID string, status registry.Status) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateInstance", ctx, serviceName, port, containerID, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateInstance indicates an expected call of UpdateInstance.
+func (mr *MockServiceRegistryInterfaceMockRecorder) UpdateInstance(ctx, serviceName, port, containerID, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateInstance", reflect.TypeOf((*MockServiceRegistryInterface)(nil).UpdateInstance), ctx, serviceName, port, containerID, status)
+}
+
 // UpdateStatus mocks base method.
 func (m *MockServiceRegistryInterface) UpdateStatus(ctx context.Context, name string, status registry.Status) {
 	m.ctrl.T.Helper()
@@ -72,6 +72,21 @@ func (mr *MockRuntimeInterfaceMockRecorder) GetLogs(ctx, containerID, follow any
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogs", reflect.TypeOf((*MockRuntimeInterface)(nil).GetLogs), ctx, containerID, follow)
 }
 
+// LogsSince mocks base method.
+func (m *MockRuntimeInterface) LogsSince(ctx context.Context, containerID string, since time.Time) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LogsSince", ctx, containerID, since)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LogsSince indicates an expected call of LogsSince.
+func (mr *MockRuntimeInterfaceMockRecorder) LogsSince(ctx, containerID, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LogsSince", reflect.TypeOf((*MockRuntimeInterface)(nil).LogsSince), ctx, containerID, since)
+}
+
 // StartContainer mocks base method.
 func (m *MockRuntimeInterface) StartContainer(ctx context.Context, config *runtime.RuntimeConfig) (string, error) {
 	m.ctrl.T.Helper()
@@ -11,6 +11,7 @@ package mocks
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -56,6 +57,22 @@ func (mr *MockRouterInterfaceMockRecorder) SendRequest(ctx, url, headers, payloa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendRequest", reflect.TypeOf((*MockRouterInterface)(nil).SendRequest), ctx, url, headers, payload)
 }
 
+// SendStreamingRequest mocks base method.
+func (m *MockRouterInterface) SendStreamingRequest(ctx context.Context, url string, headers map[string]string, payload []byte) (io.ReadCloser, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendStreamingRequest", ctx, url, headers, payload)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// SendStreamingRequest indicates an expected call of SendStreamingRequest.
+func (mr *MockRouterInterfaceMockRecorder) SendStreamingRequest(ctx, url, headers, payload any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendStreamingRequest", reflect.TypeOf((*MockRouterInterface)(nil).SendStreamingRequest), ctx, url, headers, payload)
+}
+
 // MockSchedulerInterface is a mock of SchedulerInterface interface.
 type MockSchedulerInterface struct {
 	ctrl     *gomock.Controller
@@ -95,6 +112,21 @@ func (mr *MockSchedulerInterfaceMockRecorder) Invoke(ctx, serviceName, payload a
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Invoke", reflect.TypeOf((*MockSchedulerInterface)(nil).Invoke), ctx, serviceName, payload)
 }
 
+// InvokeStream mocks base method.
+func (m *MockSchedulerInterface) InvokeStream(ctx context.Context, serviceName string, payload []byte) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InvokeStream", ctx, serviceName, payload)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InvokeStream indicates an expected call of InvokeStream.
+func (mr *MockSchedulerInterfaceMockRecorder) InvokeStream(ctx, serviceName, payload any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvokeStream", reflect.TypeOf((*MockSchedulerInterface)(nil).InvokeStream), ctx, serviceName, payload)
+}
+
 // StartService mocks base method.
 func (m *MockSchedulerInterface) StartService(ctx context.Context, serviceName string) error {
 	m.ctrl.T.Helper()
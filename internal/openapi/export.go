@@ -0,0 +1,254 @@
+package openapi
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/nyambati/simla/internal/config"
+)
+
+const (
+	// exportVersion is the OpenAPI version Export produces.
+	exportVersion = "3.1.0"
+	// exportValidator names the request validator operations with a request
+	// model or required parameters reference.
+	exportValidator = "simla"
+)
+
+// Export describes the API Gateway stage of cfg, with its routes, authorizers
+// and CORS settings, as an OpenAPI 3.1 document. Integration URIs name each
+// route's service as the Lambda function, so the document can be imported
+// back. Request models are inlined into the operations that use them.
+func Export(cfg *config.Config) (*Document, error) {
+	gw := &cfg.APIGateway
+	stage := filepath.Join("/", gw.Stage)
+	doc := &Document{
+		OpenAPI: exportVersion,
+		Info:    Info{Title: "simla", Version: strings.TrimPrefix(stage, "/")},
+		Servers: []Server{{URL: fmt.Sprintf("http://localhost:%s%s", gw.Port, strings.TrimSuffix(stage, "/"))}},
+		Paths:   make(map[string]PathItem),
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "$default"
+	}
+
+	validated := false
+	for _, route := range gw.Routes {
+		path, key := exportPath(route), operationKey(route)
+		op, err := exportOperation(gw, route)
+		if err != nil {
+			return nil, err
+		}
+		if op.RequestValidator != "" {
+			validated = true
+		}
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		if _, exists := item[key]; exists {
+			return nil, fmt.Errorf("route %s %s is defined more than once", key, path)
+		}
+		item[key] = op
+	}
+
+	components := &Components{}
+	if len(gw.Authorizers) > 0 {
+		components.SecuritySchemes = make(map[string]SecurityScheme, len(gw.Authorizers))
+		for name, authorizer := range gw.Authorizers {
+			components.SecuritySchemes[name] = securityScheme(authorizer)
+		}
+	}
+	if validated {
+		components.RequestValidators = map[string]RequestValidator{
+			exportValidator: {ValidateRequestBody: true, ValidateRequestParameters: true},
+		}
+	}
+	if components.SecuritySchemes != nil || components.RequestValidators != nil {
+		doc.Components = components
+	}
+
+	if gw.CORS.Enabled {
+		doc.CORS = exportCORS(gw.CORS)
+	}
+	return doc, nil
+}
+
+// exportPath returns the OpenAPI path of route. The catch-all route is
+// written as /$default, as API Gateway exports it.
+func exportPath(route config.Route) string {
+	if route.Path == "$default" {
+		return "/$default"
+	}
+	return "/" + strings.Trim(route.Path, "/")
+}
+
+// operationKey returns the path item key of route's method.
+func operationKey(route config.Route) string {
+	method := strings.ToUpper(route.Method)
+	if route.Path == "$default" || method == "" || method == "ANY" {
+		return anyMethodExtension
+	}
+	return strings.ToLower(method)
+}
+
+func exportOperation(gw *config.APIGateway, route config.Route) (*Operation, error) {
+	format := route.PayloadFormatVersion
+	if format == "" {
+		format = gw.PayloadFormatVersion
+	}
+	if format == "" {
+		format = config.PayloadFormatV2
+	}
+
+	op := &Operation{
+		Responses: map[string]Response{
+			"default": {Description: "Response of the " + route.Service + " service"},
+		},
+		Integration: Integration{
+			Type:                 integrationTypeAWSProxy,
+			HTTPMethod:           "POST",
+			URI:                  invocationURI(route.Service),
+			PayloadFormatVersion: format,
+		},
+	}
+
+	declared := make(map[string]bool)
+	for _, segment := range strings.Split(route.Path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			name := segment[1 : len(segment)-1]
+			declared[ParameterPath+"."+name] = true
+			op.Parameters = append(op.Parameters, stringParameter(name, parameterIn[ParameterPath]))
+		}
+	}
+	for _, expr := range route.RequiredParameters {
+		location, name, err := ParseRequestParameter(expr)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", route.Path, err)
+		}
+		if declared[location+"."+name] {
+			continue
+		}
+		declared[location+"."+name] = true
+		op.Parameters = append(op.Parameters, stringParameter(name, parameterIn[location]))
+	}
+
+	if route.RequestModel != "" {
+		schema, err := LoadSchema(route.RequestModel)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: requestModel: %w", route.Path, err)
+		}
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: schema}},
+		}
+	}
+	if route.RequestModel != "" || len(route.RequiredParameters) > 0 {
+		op.RequestValidator = exportValidator
+	}
+
+	if route.Authorizer != "" {
+		scopes := route.AuthorizationScopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		op.Security = []map[string][]string{{route.Authorizer: scopes}}
+	}
+	return op, nil
+}
+
+func stringParameter(name, in string) Parameter {
+	return Parameter{Name: name, In: in, Required: true, Schema: map[string]any{"type": "string"}}
+}
+
+// invocationURI is the API Gateway URI that invokes service as a Lambda
+// function.
+func invocationURI(service string) string {
+	return fmt.Sprintf("arn:aws:apigateway:%s:lambda:path/2015-03-31/functions/arn:aws:lambda:%s:%s:function:%s/invocations",
+		config.DefaultRegion, config.DefaultRegion, config.DefaultAccountID, service)
+}
+
+func securityScheme(authorizer config.Authorizer) SecurityScheme {
+	sources := authorizer.IdentitySource
+	switch authorizer.Type {
+	case config.AuthorizerTypeJWT:
+		source := "$request.header.Authorization"
+		if len(sources) > 0 {
+			source = sources[0]
+		}
+		return SecurityScheme{
+			Type:  "oauth2",
+			Flows: map[string]any{},
+			Authorizer: AuthorizerExtension{
+				Type:             "jwt",
+				IdentitySource:   source,
+				JWTConfiguration: &JWTConfiguration{Issuer: authorizer.Issuer, Audience: authorizer.Audience},
+			},
+		}
+	case config.AuthorizerTypeToken:
+		source := "method.request.header.Authorization"
+		if len(sources) > 0 {
+			source = sources[0]
+		}
+		header := source[strings.LastIndex(source, ".")+1:]
+		return SecurityScheme{
+			Type:     "apiKey",
+			Name:     header,
+			In:       "header",
+			AuthType: "custom",
+			Authorizer: AuthorizerExtension{
+				Type:                         "token",
+				AuthorizerURI:                invocationURI(authorizer.Service),
+				IdentitySource:               source,
+				IdentityValidationExpression: authorizer.TokenValidation,
+				AuthorizerResultTTLInSeconds: authorizer.ResultTTLSeconds,
+			},
+		}
+	default:
+		return SecurityScheme{
+			Type:     "apiKey",
+			Name:     "Unused",
+			In:       "header",
+			AuthType: "custom",
+			Authorizer: AuthorizerExtension{
+				Type:                           "request",
+				AuthorizerURI:                  invocationURI(authorizer.Service),
+				IdentitySource:                 strings.Join(sources, ", "),
+				AuthorizerResultTTLInSeconds:   authorizer.ResultTTLSeconds,
+				AuthorizerPayloadFormatVersion: authorizer.PayloadFormatVersion,
+				EnableSimpleResponses:          authorizer.EnableSimpleResponses,
+			},
+		}
+	}
+}
+
+// exportCORS converts the gateway's CORS settings, applying the same
+// defaults the gateway does.
+func exportCORS(cors config.CORSConfig) *CORS {
+	split := func(value, fallback string) []string {
+		if value == "" {
+			value = fallback
+		}
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+	maxAge := cors.MaxAge
+	if maxAge == 0 {
+		maxAge = config.DefaultCORSMaxAge
+	}
+	return &CORS{
+		AllowOrigins:     split(cors.AllowOrigins, config.DefaultCORSAllowOrigins),
+		AllowMethods:     split(cors.AllowMethods, config.DefaultCORSAllowMethods),
+		AllowHeaders:     split(cors.AllowHeaders, config.DefaultCORSAllowHeaders),
+		AllowCredentials: cors.AllowCredentials,
+		MaxAge:           maxAge,
+	}
+}
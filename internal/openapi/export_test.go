@@ -0,0 +1,104 @@
+package openapi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nyambati/simla/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func exportConfig(t *testing.T) *config.Config {
+	t.Helper()
+	model := filepath.Join(t.TempDir(), "order.yaml")
+	require.NoError(t, os.WriteFile(model, []byte("type: object\nrequired: [sku]\nproperties:\n  sku: {type: string}\n  quantity: {type: integer, minimum: 1}\n"), 0o644))
+
+	return &config.Config{
+		Services: map[string]config.Service{"orders": {}, "fallback": {}, "auth": {}},
+		APIGateway: config.APIGateway{
+			Port:  "8080",
+			Stage: "v1",
+			CORS:  config.CORSConfig{Enabled: true, AllowOrigins: "https://a.example, https://b.example"},
+			Authorizers: map[string]config.Authorizer{
+				"jwt":   {Type: config.AuthorizerTypeJWT, Issuer: "https://issuer.test", Audience: []string{"orders-api"}},
+				"token": {Type: config.AuthorizerTypeToken, Service: "auth", ResultTTLSeconds: 300},
+			},
+			Routes: []config.Route{
+				{Path: "orders", Method: "POST", Service: "orders", RequestModel: model, RequiredParameters: []string{"method.request.header.Idempotency-Key"}},
+				{Path: "orders/{id}", Method: "GET", Service: "orders", Authorizer: "jwt", AuthorizationScopes: []string{"orders:read"}},
+				{Path: "$default", Service: "fallback", PayloadFormatVersion: "1.0"},
+			},
+		},
+	}
+}
+
+func TestExport(t *testing.T) {
+	doc, err := Export(exportConfig(t))
+	require.NoError(t, err)
+
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Equal(t, "http://localhost:8080/v1", doc.Servers[0].URL)
+
+	create := doc.Paths["/orders"]["post"]
+	require.NotNil(t, create)
+	assert.Equal(t, exportValidator, create.RequestValidator)
+	assert.Equal(t, []Parameter{stringParameter("Idempotency-Key", "header")}, create.Parameters)
+	schema := create.RequestBody.Content["application/json"].Schema.(map[string]any)
+	assert.Equal(t, []any{"sku"}, schema["required"])
+	assert.Equal(t, "arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/arn:aws:lambda:us-east-1:012345678901:function:orders/invocations", create.Integration.URI)
+	assert.Equal(t, "2.0", create.Integration.PayloadFormatVersion)
+
+	get := doc.Paths["/orders/{id}"]["get"]
+	require.NotNil(t, get)
+	assert.Equal(t, []Parameter{stringParameter("id", "path")}, get.Parameters)
+	assert.Equal(t, []map[string][]string{{"jwt": {"orders:read"}}}, get.Security)
+	assert.Empty(t, get.RequestValidator)
+
+	fallback := doc.Paths["/$default"][anyMethodExtension]
+	require.NotNil(t, fallback)
+	assert.Equal(t, "1.0", fallback.Integration.PayloadFormatVersion)
+
+	require.NotNil(t, doc.Components)
+	assert.Equal(t, "jwt", doc.Components.SecuritySchemes["jwt"].Authorizer.Type)
+	assert.Equal(t, "Authorization", doc.Components.SecuritySchemes["token"].Name)
+	assert.Contains(t, doc.Components.RequestValidators, exportValidator)
+
+	require.NotNil(t, doc.CORS)
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, doc.CORS.AllowOrigins)
+	assert.Equal(t, config.DefaultCORSMaxAge, doc.CORS.MaxAge)
+}
+
+func TestExport_RoundTripsThroughImport(t *testing.T) {
+	cfg := exportConfig(t)
+	doc, err := Export(cfg)
+	require.NoError(t, err)
+	data, err := yaml.Marshal(doc)
+	require.NoError(t, err)
+
+	gw := &config.APIGateway{Authorizers: cfg.APIGateway.Authorizers, OpenAPI: &config.OpenAPIImport{}}
+	routes, err := importRoutes(data, gw, cfg.Services, testLogger())
+	require.NoError(t, err)
+
+	got := map[string]config.Route{}
+	for _, route := range routes {
+		got[routeKey(route)] = route
+	}
+	assert.Len(t, got, 3)
+	assert.Equal(t, "orders", got["POST /orders"].Service)
+	assert.Equal(t, "jwt", got["GET /orders/{id}"].Authorizer)
+	assert.Equal(t, "fallback", got["$default"].Service)
+	assert.Equal(t, "1.0", got["$default"].PayloadFormatVersion)
+}
+
+func TestExport_DuplicateRoute(t *testing.T) {
+	cfg := &config.Config{APIGateway: config.APIGateway{Routes: []config.Route{
+		{Path: "/a", Method: "GET", Service: "x"},
+		{Path: "a", Method: "get", Service: "y"},
+	}}}
+	_, err := Export(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "defined more than once")
+}
@@ -0,0 +1,263 @@
+package openapi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nyambati/simla/internal/config"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// stageVariableRef matches ${stageVariables.name} references, which API
+// Gateway resolves in integration URIs at request time.
+var stageVariableRef = regexp.MustCompile(`\$\{stageVariables\.([A-Za-z0-9_]+)\}`)
+
+// Import merges the routes defined by the OpenAPI document configured on gw
+// into gw.Routes. Routes already in gw.Routes win over imported routes with
+// the same method and path. It is a no-op when no document is configured.
+func Import(gw *config.APIGateway, services map[string]config.Service, logger *logrus.Entry) error {
+	if gw.OpenAPI == nil || gw.OpenAPI.File == "" {
+		return nil
+	}
+	data, err := os.ReadFile(gw.OpenAPI.File)
+	if err != nil {
+		return fmt.Errorf("openapi: %w", err)
+	}
+	imported, err := importRoutes(data, gw, services, logger)
+	if err != nil {
+		return fmt.Errorf("openapi %s: %w", gw.OpenAPI.File, err)
+	}
+	logger.WithField("file", gw.OpenAPI.File).Infof("imported %d routes from openapi document", len(imported))
+	gw.Routes = mergeRoutes(imported, gw.Routes)
+	return nil
+}
+
+// importRoutes derives a route from every operation of the document that has
+// a Lambda proxy integration.
+func importRoutes(data []byte, gw *config.APIGateway, services map[string]config.Service, logger *logrus.Entry) ([]config.Route, error) {
+	var doc sourceDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse document: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported openapi version %q: expected 3.x", doc.OpenAPI)
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var routes []config.Route
+	for _, path := range paths {
+		item := doc.Paths[path]
+		keys := make([]string, 0, len(item))
+		for key := range item {
+			if _, ok := operationKeys[key]; ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			method := operationKeys[key]
+			log := logger.WithFields(logrus.Fields{"path": path, "method": method})
+
+			var op sourceOperation
+			node := item[key]
+			if err := node.Decode(&op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			integ, err := doc.resolveIntegration(op.Integration)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			if integ == nil {
+				log.Debug("skipping operation without an integration")
+				continue
+			}
+			if !strings.EqualFold(integ.Type, integrationTypeAWSProxy) {
+				log.Warnf("skipping %s integration: only aws_proxy (Lambda proxy) integrations are supported", integ.Type)
+				continue
+			}
+
+			function, err := integrationFunction(integ.URI, gw.StageVariables)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			service, err := resolveService(function, gw.OpenAPI.Services, services)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+
+			route := config.Route{
+				Path:                 routePath(path),
+				Method:               method,
+				Service:              service,
+				PayloadFormatVersion: integ.PayloadFormatVersion,
+			}
+			route.Authorizer, route.AuthorizationScopes = operationAuthorizer(op.Security, gw)
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+// resolveIntegration follows a $ref to a shared integration.
+func (d *sourceDocument) resolveIntegration(integ *sourceIntegration) (*sourceIntegration, error) {
+	if integ == nil || integ.Ref == "" {
+		return integ, nil
+	}
+	name, ok := strings.CutPrefix(integ.Ref, integrationsRefPrefix)
+	if !ok {
+		return nil, fmt.Errorf("unsupported integration $ref %q", integ.Ref)
+	}
+	shared, ok := d.Components.Integrations[name]
+	if !ok {
+		return nil, fmt.Errorf("integration $ref %q not found", integ.Ref)
+	}
+	return &shared, nil
+}
+
+// integrationFunction extracts the Lambda function an integration URI
+// invokes. It understands function ARNs, API Gateway invocation URIs that
+// wrap them, and CloudFormation !Sub / Fn::Sub expressions, where a
+// ${Logical.Arn} reference yields the logical ID.
+func integrationFunction(uri yaml.Node, stageVariables map[string]string) (string, error) {
+	value, err := uriString(uri)
+	if err != nil {
+		return "", err
+	}
+	value = stageVariableRef.ReplaceAllStringFunc(value, func(ref string) string {
+		name := stageVariableRef.FindStringSubmatch(ref)[1]
+		if v, ok := stageVariables[name]; ok {
+			return v
+		}
+		return ref
+	})
+
+	target := value
+	if i := strings.LastIndex(value, "/functions/"); i >= 0 {
+		target = strings.TrimSuffix(value[i+len("/functions/"):], "/invocations")
+	}
+
+	switch {
+	case strings.HasPrefix(target, "${") && strings.HasSuffix(target, "}"):
+		ref := strings.TrimSuffix(strings.TrimPrefix(target, "${"), "}")
+		if strings.HasPrefix(ref, "stageVariables.") {
+			return "", fmt.Errorf("integration uri %q references undefined stage variable %s", value, ref)
+		}
+		logicalID, _, _ := strings.Cut(ref, ".")
+		return logicalID, nil
+	case strings.Contains(target, ":function:"):
+		_, name, _ := strings.Cut(target, ":function:")
+		name, _, _ = strings.Cut(name, ":")
+		return name, nil
+	}
+	return "", fmt.Errorf("integration uri %q does not reference a Lambda function", value)
+}
+
+// uriString returns the template string of a plain, !Sub or Fn::Sub URI.
+func uriString(uri yaml.Node) (string, error) {
+	switch uri.Kind {
+	case yaml.ScalarNode:
+		return uri.Value, nil
+	case yaml.SequenceNode:
+		// !Sub [template, {variables}]
+		if uri.Tag == "!Sub" && len(uri.Content) > 0 && uri.Content[0].Kind == yaml.ScalarNode {
+			return uri.Content[0].Value, nil
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(uri.Content); i += 2 {
+			if uri.Content[i].Value == "Fn::Sub" {
+				return uriString(*subTemplate(uri.Content[i+1]))
+			}
+		}
+	case 0:
+		return "", fmt.Errorf("aws_proxy integration has no uri")
+	}
+	return "", fmt.Errorf("unsupported integration uri at line %d: use an ARN, !Sub or Fn::Sub", uri.Line)
+}
+
+// subTemplate returns the template of an Fn::Sub value, which is either the
+// template itself or a [template, variables] pair.
+func subTemplate(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.SequenceNode && len(node.Content) > 0 {
+		return node.Content[0]
+	}
+	return node
+}
+
+// resolveService maps an integration's function to a service, first through
+// the configured mapping and then by name.
+func resolveService(function string, mapping map[string]string, services map[string]config.Service) (string, error) {
+	name := function
+	if key, ok := config.Key(mapping, function); ok {
+		name = mapping[key]
+	}
+	if service, ok := config.Key(services, name); ok {
+		return service, nil
+	}
+	if name != function {
+		return "", fmt.Errorf("integration %q is mapped to unknown service %q", function, name)
+	}
+	return "", fmt.Errorf("integration %q does not match a service; map it under apiGateway.openapi.services", function)
+}
+
+// operationAuthorizer returns the first security requirement of an
+// operation that names a configured authorizer, with its scopes. Schemes
+// simla does not know about are ignored.
+func operationAuthorizer(security []map[string][]string, gw *config.APIGateway) (string, []string) {
+	for _, requirement := range security {
+		for name, scopes := range requirement {
+			if _, ok := gw.GetAuthorizer(context.Background(), name); ok {
+				return name, scopes
+			}
+		}
+	}
+	return "", nil
+}
+
+// routePath converts an OpenAPI path to a route path. HTTP API exports write
+// the catch-all route as /$default.
+func routePath(path string) string {
+	if strings.Trim(path, "/") == "$default" {
+		return "$default"
+	}
+	return path
+}
+
+// mergeRoutes returns local followed by the imported routes local does not
+// redefine.
+func mergeRoutes(imported, local []config.Route) []config.Route {
+	defined := make(map[string]bool, len(local))
+	for _, route := range local {
+		defined[routeKey(route)] = true
+	}
+	merged := append([]config.Route{}, local...)
+	for _, route := range imported {
+		if !defined[routeKey(route)] {
+			merged = append(merged, route)
+		}
+	}
+	return merged
+}
+
+// routeKey identifies a route by method and path, ignoring the leading and
+// trailing slashes .simla.yaml paths may or may not have.
+func routeKey(route config.Route) string {
+	if route.Path == "$default" {
+		return "$default"
+	}
+	method := strings.ToUpper(route.Method)
+	if method == "" {
+		method = "ANY"
+	}
+	return method + " /" + strings.Trim(route.Path, "/")
+}
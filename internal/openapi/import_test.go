@@ -0,0 +1,164 @@
+package openapi
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nyambati/simla/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleDocument = `
+openapi: "3.0.1"
+paths:
+  /orders:
+    get:
+      security:
+        - jwt: ["orders:read"]
+      x-amazon-apigateway-integration:
+        type: aws_proxy
+        uri: arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/arn:aws:lambda:us-east-1:123456789012:function:orders-fn:live/invocations
+        payloadFormatVersion: "2.0"
+    post:
+      x-amazon-apigateway-integration:
+        $ref: "#/components/x-amazon-apigateway-integrations/orders"
+  /orders/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+    x-amazon-apigateway-any-method:
+      x-amazon-apigateway-integration:
+        type: AWS_PROXY
+        uri: !Sub arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${OrderFunction.Arn}/invocations
+  /files/{proxy+}:
+    get:
+      x-amazon-apigateway-integration:
+        type: aws_proxy
+        uri:
+          Fn::Sub:
+            - arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${Fn}/invocations
+            - Fn: !GetAtt FilesFunction.Arn
+  /health:
+    get:
+      x-amazon-apigateway-integration:
+        type: mock
+  /$default:
+    x-amazon-apigateway-any-method:
+      x-amazon-apigateway-integration:
+        type: aws_proxy
+        uri: arn:aws:lambda:us-east-1:123456789012:function:${stageVariables.fallback}
+components:
+  x-amazon-apigateway-integrations:
+    orders:
+      type: aws_proxy
+      uri: arn:aws:lambda:us-east-1:123456789012:function:orders-fn
+`
+
+func testLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logrus.NewEntry(logger)
+}
+
+func testGateway(file string) *config.APIGateway {
+	return &config.APIGateway{
+		StageVariables: map[string]string{"fallback": "fallback"},
+		Authorizers:    map[string]config.Authorizer{"jwt": {Type: config.AuthorizerTypeJWT}},
+		OpenAPI: &config.OpenAPIImport{
+			File: file,
+			// Viper lowercases map keys.
+			Services: map[string]string{"orders-fn": "orders", "orderfunction": "orders", "fn": "files"},
+		},
+	}
+}
+
+func testServices() map[string]config.Service {
+	return map[string]config.Service{"orders": {}, "files": {}, "fallback": {}, "Legacy": {}}
+}
+
+func TestImportRoutes(t *testing.T) {
+	routes, err := importRoutes([]byte(sampleDocument), testGateway(""), testServices(), testLogger())
+	require.NoError(t, err)
+
+	assert.Equal(t, []config.Route{
+		{Path: "$default", Method: "ANY", Service: "fallback"},
+		{Path: "/files/{proxy+}", Method: "GET", Service: "files"},
+		{Path: "/orders", Method: "GET", Service: "orders", PayloadFormatVersion: "2.0", Authorizer: "jwt", AuthorizationScopes: []string{"orders:read"}},
+		{Path: "/orders", Method: "POST", Service: "orders"},
+		{Path: "/orders/{id}", Method: "ANY", Service: "orders"},
+	}, routes)
+}
+
+func TestImportRoutes_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"swagger 2", `swagger: "2.0"`, "unsupported openapi version"},
+		{
+			"unknown service",
+			"openapi: 3.1.0\npaths:\n  /x:\n    get:\n      x-amazon-apigateway-integration:\n        type: aws_proxy\n        uri: arn:aws:lambda:us-east-1:1:function:nope\n",
+			`integration "nope" does not match a service`,
+		},
+		{
+			"not a lambda",
+			"openapi: 3.1.0\npaths:\n  /x:\n    get:\n      x-amazon-apigateway-integration:\n        type: aws_proxy\n        uri: https://example.com\n",
+			"does not reference a Lambda function",
+		},
+		{
+			"missing ref",
+			"openapi: 3.1.0\npaths:\n  /x:\n    get:\n      x-amazon-apigateway-integration:\n        $ref: '#/components/x-amazon-apigateway-integrations/gone'\n",
+			"not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := importRoutes([]byte(tt.doc), testGateway(""), testServices(), testLogger())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestResolveService_CaseInsensitive(t *testing.T) {
+	service, err := resolveService("legacy", nil, testServices())
+	require.NoError(t, err)
+	assert.Equal(t, "Legacy", service)
+
+	_, err = resolveService("orders-fn", map[string]string{"orders-fn": "missing"}, testServices())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `mapped to unknown service "missing"`)
+}
+
+func TestImport_LocalRoutesTakePrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "openapi.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(sampleDocument), 0o644))
+
+	gw := testGateway(file)
+	gw.Routes = []config.Route{
+		{Path: "orders/", Method: "get", Service: "legacy-orders"},
+		{Path: "/status", Method: "GET", Service: "orders"},
+	}
+	require.NoError(t, Import(gw, testServices(), testLogger()))
+
+	byKey := map[string]string{}
+	for _, route := range gw.Routes {
+		byKey[routeKey(route)] = route.Service
+	}
+	assert.Len(t, gw.Routes, 6)
+	assert.Equal(t, "legacy-orders", byKey["GET /orders"])
+	assert.Equal(t, "orders", byKey["POST /orders"])
+	assert.Equal(t, "orders", byKey["GET /status"])
+}
+
+func TestImport_NoDocument(t *testing.T) {
+	gw := &config.APIGateway{Routes: []config.Route{{Path: "/a", Service: "a"}}}
+	require.NoError(t, Import(gw, nil, testLogger()))
+	assert.Len(t, gw.Routes, 1)
+}
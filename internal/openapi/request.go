@@ -0,0 +1,64 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Locations of required request parameters, in API Gateway notation:
+// method.request.<location>.<name>.
+const (
+	ParameterQueryString = "querystring"
+	ParameterHeader      = "header"
+	ParameterPath        = "path"
+
+	parameterPrefix = "method.request."
+)
+
+// parameterIn maps parameter locations to OpenAPI's "in" values.
+var parameterIn = map[string]string{
+	ParameterQueryString: "query",
+	ParameterHeader:      "header",
+	ParameterPath:        "path",
+}
+
+// LoadSchema reads a JSON Schema from a JSON or YAML (.yaml, .yml) file.
+func LoadSchema(path string) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &schema)
+	default:
+		err = json.Unmarshal(data, &schema)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("schema %s: %w", path, err)
+	}
+	return schema, nil
+}
+
+// ParseRequestParameter splits a required parameter such as
+// method.request.querystring.page into its location and name.
+func ParseRequestParameter(expr string) (location, name string, err error) {
+	rest, ok := strings.CutPrefix(expr, parameterPrefix)
+	if ok {
+		location, name, ok = strings.Cut(rest, ".")
+	}
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("invalid required parameter %q: expected %s<querystring|header|path>.<name>", expr, parameterPrefix)
+	}
+	if _, known := parameterIn[location]; !known {
+		return "", "", fmt.Errorf("invalid required parameter %q: unknown location %q", expr, location)
+	}
+	return location, name, nil
+}
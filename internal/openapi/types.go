@@ -0,0 +1,167 @@
+package openapi
+
+import "gopkg.in/yaml.v3"
+
+const (
+	// integrationExtension holds the API Gateway integration of an operation.
+	integrationExtension = "x-amazon-apigateway-integration"
+	// anyMethodExtension is the operation key API Gateway uses for ANY routes.
+	anyMethodExtension = "x-amazon-apigateway-any-method"
+	// integrationsRefPrefix is where HTTP API exports keep shared
+	// integrations referenced with $ref.
+	integrationsRefPrefix = "#/components/x-amazon-apigateway-integrations/"
+
+	integrationTypeAWSProxy = "aws_proxy"
+)
+
+// operationKeys are the path item keys that hold operations, mapped to the
+// route method they produce.
+var operationKeys = map[string]string{
+	"get":              "GET",
+	"put":              "PUT",
+	"post":             "POST",
+	"delete":           "DELETE",
+	"options":          "OPTIONS",
+	"head":             "HEAD",
+	"patch":            "PATCH",
+	"trace":            "TRACE",
+	anyMethodExtension: "ANY",
+}
+
+// sourceDocument is the subset of an imported OpenAPI 3 document needed to
+// derive routes.
+type sourceDocument struct {
+	OpenAPI    string                          `yaml:"openapi"`
+	Paths      map[string]map[string]yaml.Node `yaml:"paths"`
+	Components struct {
+		Integrations map[string]sourceIntegration `yaml:"x-amazon-apigateway-integrations"`
+	} `yaml:"components"`
+}
+
+// sourceOperation is the subset of an imported operation needed to derive a
+// route.
+type sourceOperation struct {
+	OperationID string                `yaml:"operationId"`
+	Security    []map[string][]string `yaml:"security"`
+	Integration *sourceIntegration    `yaml:"x-amazon-apigateway-integration"`
+}
+
+// sourceIntegration is an imported x-amazon-apigateway-integration object. URI is kept as
+// a node because CloudFormation templates often write it as a !Sub or
+// Fn::Sub expression rather than a plain string.
+type sourceIntegration struct {
+	Ref                  string    `yaml:"$ref"`
+	Type                 string    `yaml:"type"`
+	URI                  yaml.Node `yaml:"uri"`
+	PayloadFormatVersion string    `yaml:"payloadFormatVersion"`
+}
+
+// Document is an OpenAPI 3.1 document produced by Export.
+type Document struct {
+	OpenAPI    string              `yaml:"openapi" json:"openapi"`
+	Info       Info                `yaml:"info" json:"info"`
+	Servers    []Server            `yaml:"servers,omitempty" json:"servers,omitempty"`
+	Paths      map[string]PathItem `yaml:"paths" json:"paths"`
+	Components *Components         `yaml:"components,omitempty" json:"components,omitempty"`
+	CORS       *CORS               `yaml:"x-amazon-apigateway-cors,omitempty" json:"x-amazon-apigateway-cors,omitempty"`
+}
+
+type Info struct {
+	Title   string `yaml:"title" json:"title"`
+	Version string `yaml:"version" json:"version"`
+}
+
+type Server struct {
+	URL string `yaml:"url" json:"url"`
+}
+
+// PathItem maps operation keys (get, post, x-amazon-apigateway-any-method,
+// ...) to operations.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `yaml:"operationId,omitempty" json:"operationId,omitempty"`
+	Parameters  []Parameter           `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+	RequestBody *RequestBody          `yaml:"requestBody,omitempty" json:"requestBody,omitempty"`
+	Responses   map[string]Response   `yaml:"responses" json:"responses"`
+	Security    []map[string][]string `yaml:"security,omitempty" json:"security,omitempty"`
+	Integration Integration           `yaml:"x-amazon-apigateway-integration" json:"x-amazon-apigateway-integration"`
+	// RequestValidator names the validator from RequestValidators that
+	// applies to the operation.
+	RequestValidator string `yaml:"x-amazon-apigateway-request-validator,omitempty" json:"x-amazon-apigateway-request-validator,omitempty"`
+}
+
+type Parameter struct {
+	Name     string         `yaml:"name" json:"name"`
+	In       string         `yaml:"in" json:"in"`
+	Required bool           `yaml:"required" json:"required"`
+	Schema   map[string]any `yaml:"schema" json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `yaml:"required" json:"required"`
+	Content  map[string]MediaType `yaml:"content" json:"content"`
+}
+
+type MediaType struct {
+	Schema any `yaml:"schema" json:"schema"`
+}
+
+type Response struct {
+	Description string `yaml:"description" json:"description"`
+}
+
+// Integration is an x-amazon-apigateway-integration object.
+type Integration struct {
+	Type                 string `yaml:"type" json:"type"`
+	HTTPMethod           string `yaml:"httpMethod" json:"httpMethod"`
+	URI                  string `yaml:"uri" json:"uri"`
+	PayloadFormatVersion string `yaml:"payloadFormatVersion" json:"payloadFormatVersion"`
+}
+
+type Components struct {
+	SecuritySchemes   map[string]SecurityScheme   `yaml:"securitySchemes,omitempty" json:"securitySchemes,omitempty"`
+	RequestValidators map[string]RequestValidator `yaml:"x-amazon-apigateway-request-validators,omitempty" json:"x-amazon-apigateway-request-validators,omitempty"`
+}
+
+// SecurityScheme describes an authorizer. Lambda authorizers are apiKey
+// schemes and JWT authorizers oauth2 schemes, each carrying an
+// x-amazon-apigateway-authorizer extension.
+type SecurityScheme struct {
+	Type       string              `yaml:"type" json:"type"`
+	Name       string              `yaml:"name,omitempty" json:"name,omitempty"`
+	In         string              `yaml:"in,omitempty" json:"in,omitempty"`
+	Flows      map[string]any      `yaml:"flows,omitempty" json:"flows,omitempty"`
+	AuthType   string              `yaml:"x-amazon-apigateway-authtype,omitempty" json:"x-amazon-apigateway-authtype,omitempty"`
+	Authorizer AuthorizerExtension `yaml:"x-amazon-apigateway-authorizer" json:"x-amazon-apigateway-authorizer"`
+}
+
+type AuthorizerExtension struct {
+	Type                           string            `yaml:"type" json:"type"`
+	AuthorizerURI                  string            `yaml:"authorizerUri,omitempty" json:"authorizerUri,omitempty"`
+	IdentitySource                 string            `yaml:"identitySource,omitempty" json:"identitySource,omitempty"`
+	IdentityValidationExpression   string            `yaml:"identityValidationExpression,omitempty" json:"identityValidationExpression,omitempty"`
+	AuthorizerResultTTLInSeconds   int               `yaml:"authorizerResultTtlInSeconds,omitempty" json:"authorizerResultTtlInSeconds,omitempty"`
+	AuthorizerPayloadFormatVersion string            `yaml:"authorizerPayloadFormatVersion,omitempty" json:"authorizerPayloadFormatVersion,omitempty"`
+	EnableSimpleResponses          bool              `yaml:"enableSimpleResponses,omitempty" json:"enableSimpleResponses,omitempty"`
+	JWTConfiguration               *JWTConfiguration `yaml:"jwtConfiguration,omitempty" json:"jwtConfiguration,omitempty"`
+}
+
+type JWTConfiguration struct {
+	Issuer   string   `yaml:"issuer" json:"issuer"`
+	Audience []string `yaml:"audience" json:"audience"`
+}
+
+type RequestValidator struct {
+	ValidateRequestBody       bool `yaml:"validateRequestBody" json:"validateRequestBody"`
+	ValidateRequestParameters bool `yaml:"validateRequestParameters" json:"validateRequestParameters"`
+}
+
+// CORS is the x-amazon-apigateway-cors extension.
+type CORS struct {
+	AllowOrigins     []string `yaml:"allowOrigins" json:"allowOrigins"`
+	AllowMethods     []string `yaml:"allowMethods" json:"allowMethods"`
+	AllowHeaders     []string `yaml:"allowHeaders" json:"allowHeaders"`
+	AllowCredentials bool     `yaml:"allowCredentials" json:"allowCredentials"`
+	MaxAge           int      `yaml:"maxAge" json:"maxAge"`
+}
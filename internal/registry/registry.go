@@ -196,3 +196,50 @@ func (r *ServiceRegistry) UpdateContainerID(ctx context.Context, serviceName, co
 	}
 	return simlaerrors.NewServiceNotFoundError(serviceName)
 }
+
+func (r *ServiceRegistry) AddInstance(ctx context.Context, serviceName string, index int) (*Instance, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	service, exists := r.getService(serviceName)
+	if !exists {
+		return nil, simlaerrors.NewServiceNotFoundError(serviceName)
+	}
+	if index < len(service.Instances) {
+		return service.Instances[index], nil
+	}
+
+	for len(service.Instances) <= index {
+		r.LastAllocatedPort++
+		r.logger.Infof("adding instance on port %d to service %s", r.LastAllocatedPort, serviceName)
+		service.Instances = append(service.Instances, &Instance{Port: r.LastAllocatedPort, Status: StatusPending})
+	}
+
+	if err := r.Save(ctx); err != nil {
+		return nil, err
+	}
+	return service.Instances[index], nil
+}
+
+func (r *ServiceRegistry) UpdateInstance(ctx context.Context, serviceName string, port int, containerID string, status Status) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	service, exists := r.getService(serviceName)
+	if !exists {
+		return simlaerrors.NewServiceNotFoundError(serviceName)
+	}
+	for _, instance := range service.Instances {
+		if instance.Port != port {
+			continue
+		}
+		instance.Status = status
+		instance.Healthy = status == StatusRunning
+		if instance.ID == containerID {
+			return nil
+		}
+		instance.ID = containerID
+		return r.Save(ctx)
+	}
+	return fmt.Errorf("service %s has no instance on port %d", serviceName, port)
+}
@@ -201,6 +201,64 @@ func TestUpdateContainerID_PersistsToFile(t *testing.T) {
 	assert.Contains(t, string(data), "containerXYZ")
 }
 
+// ── Instances ─────────────────────────────────────────────────────────────────
+
+func TestAddInstance_AllocatesPortsInOrder(t *testing.T) {
+	r := newTestRegistry(t)
+	_, _ = r.AddService(ctx, "payments")
+
+	// Asking for the second instance registers the first one as well.
+	second, err := r.AddInstance(ctx, "payments", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 9002, second.Port)
+
+	first, err := r.AddInstance(ctx, "payments", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 9001, first.Port)
+	assert.Equal(t, StatusPending, first.Status)
+
+	orders, err := r.AddService(ctx, "orders")
+	require.NoError(t, err)
+	assert.Equal(t, 9003, orders.Port)
+}
+
+func TestAddInstance_UnknownService_ReturnsError(t *testing.T) {
+	r := newTestRegistry(t)
+	_, err := r.AddInstance(ctx, "ghost", 0)
+	require.Error(t, err)
+}
+
+func TestUpdateInstance(t *testing.T) {
+	r := newTestRegistry(t)
+	_, _ = r.AddService(ctx, "payments")
+	instance, _ := r.AddInstance(ctx, "payments", 0)
+
+	require.NoError(t, r.UpdateInstance(ctx, "payments", instance.Port, "ctr-2", StatusRunning))
+	assert.Equal(t, "ctr-2", instance.ID)
+	assert.Equal(t, StatusRunning, instance.Status)
+	assert.True(t, instance.Healthy)
+
+	assert.Error(t, r.UpdateInstance(ctx, "payments", 1234, "ctr-3", StatusRunning))
+}
+
+func TestInstances_PersistAcrossLoad(t *testing.T) {
+	r := newTestRegistry(t)
+	_, _ = r.AddService(ctx, "payments")
+	instance, _ := r.AddInstance(ctx, "payments", 0)
+	require.NoError(t, r.UpdateInstance(ctx, "payments", instance.Port, "ctr-2", StatusRunning))
+
+	r2 := newTestRegistry(t)
+	r2.FilePath = r.FilePath
+	require.NoError(t, r2.Load(ctx))
+
+	svc, ok := r2.GetService(ctx, "payments")
+	require.True(t, ok)
+	require.Len(t, svc.Instances, 1)
+	assert.Equal(t, "ctr-2", svc.Instances[0].ID)
+	assert.Equal(t, 9001, svc.Instances[0].Port)
+	assert.Equal(t, 9001, r2.LastAllocatedPort)
+}
+
 // ── Save / Load ───────────────────────────────────────────────────────────────
 
 func TestSaveAndLoad_RoundTrip(t *testing.T) {
@@ -16,6 +16,9 @@ const (
 	StatusPending Status = "pending"
 	StatusStopped Status = "stopped"
 	StatusFailed  Status = "failed"
+	// StatusReclaimed marks a container stopped after its idle timeout. It is
+	// started again on the next invocation.
+	StatusReclaimed Status = "reclaimed"
 )
 
 type Service struct {
@@ -26,6 +29,17 @@ type Service struct {
 	Healthy      bool      `yaml:"-"`
 	LastChecked  time.Time `yaml:"-"`
 	FailureCount int       `yaml:"-"`
+	// Instances are the containers started next to the first one when
+	// invocations of the service overlap. Each keeps its port across runs.
+	Instances []*Instance `yaml:"instances,omitempty"`
+}
+
+// Instance is one additional container of a service.
+type Instance struct {
+	ID      string `yaml:"id"`
+	Port    int    `yaml:"port"`
+	Status  Status `yaml:"-"`
+	Healthy bool   `yaml:"-"`
 }
 
 type ServiceRegistry struct {
@@ -46,4 +60,11 @@ type ServiceRegistryInterface interface {
 	UpdateStatus(ctx context.Context, name string, status Status)
 	UpdateHealth(ctx context.Context, name string, healthy bool)
 	UpdateContainerID(ctx context.Context, name, containerId string) error
+	// AddInstance returns the index-th additional container of a service,
+	// allocating ports for it and any instance before it that is not yet
+	// registered.
+	AddInstance(ctx context.Context, serviceName string, index int) (*Instance, error)
+	// UpdateInstance records the container and status of the instance of a
+	// service listening on port.
+	UpdateInstance(ctx context.Context, serviceName string, port int, containerID string, status Status) error
 }
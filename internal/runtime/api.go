@@ -0,0 +1,115 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nyambati/simla/internal/runtimeapi"
+	"github.com/sirupsen/logrus"
+)
+
+// shimPath is where the entrypoint shim is mounted in containers.
+const shimPath = "/var/simla/entrypoint.sh"
+
+// shim is the entrypoint of containers: it starts the extensions in
+// /opt/extensions and then the function's own entrypoint.
+//
+//go:embed entrypoint.sh
+var shim []byte
+
+// apis holds the Runtime API of every running container by ID. Every
+// Runtime in the process shares it, as schedulers create a runtime per
+// operation.
+var apis sync.Map
+
+// functionAPI is the Runtime API serving one container or process.
+type functionAPI struct {
+	api    *runtimeapi.Server
+	server *http.Server
+	addr   string
+	// reports receives the START, END and REPORT lines of its invocations.
+	reports *processLogs
+}
+
+// startAPI listens on addr and serves the Runtime API of the function of
+// config. The START, END and REPORT lines of invocations go to reports. The
+// runtime's init waits for the given number of extensions to register.
+func startAPI(config *RuntimeConfig, addr string, extensions int, reports *processLogs, logger *logrus.Entry) (*functionAPI, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on port %s: %w", config.Port, err)
+	}
+	var handler string
+	if len(config.Cmd) > 0 {
+		handler = config.Cmd[0]
+	}
+	api := runtimeapi.NewServer(runtimeapi.Config{
+		FunctionName: config.Name,
+		Handler:      handler,
+		FunctionARN:  fmt.Sprintf("arn:aws:lambda:%s:%s:function:%s", config.Region, config.AccountID, config.Name),
+		Timeout:      time.Duration(config.Timeout) * time.Second,
+		MemorySize:   config.MemorySize,
+		Extensions:   extensions,
+		InitTimeout:  initTimeout,
+		Log:          reports,
+	}, logger.WithField("service", config.Name))
+
+	f := &functionAPI{
+		api:     api,
+		server:  &http.Server{Handler: api.Handler()},
+		addr:    listener.Addr().String(),
+		reports: reports,
+	}
+	go func() {
+		if err := f.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.WithError(err).WithField("service", config.Name).Error("runtime api stopped")
+		}
+	}()
+	return f, nil
+}
+
+// shutdown gives the extensions of the environment stopGracePeriod to
+// handle a SHUTDOWN event.
+func (f *functionAPI) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), stopGracePeriod)
+	defer cancel()
+	f.api.Shutdown(ctx, runtimeapi.ShutdownSpindown)
+}
+
+// close stops serving the Runtime API.
+func (f *functionAPI) close() error {
+	if err := f.server.Close(); err != nil {
+		return fmt.Errorf("failed to stop runtime api: %w", err)
+	}
+	return nil
+}
+
+// writeShim writes the entrypoint shim to ~/.simla/runtime/entrypoint.sh,
+// to be mounted into containers, and returns its path.
+func writeShim() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	dir := filepath.Join(home, ".simla", "runtime")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create runtime directory: %w", err)
+	}
+	path := filepath.Join(dir, "entrypoint.sh")
+	// Containers may be starting with the current shim mounted.
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, shim) {
+		return path, nil
+	}
+	if err := os.WriteFile(path, shim, 0o755); err != nil {
+		return "", fmt.Errorf("failed to write entrypoint shim: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,12 @@
+#!/bin/sh
+# Entrypoint of the containers simla runs. It starts the external extensions
+# in /opt/extensions, as Lambda does, then runs the function's own entrypoint.
+# AWS_LAMBDA_RUNTIME_API points both at simla's Runtime API, so the image's
+# runtime interface emulator is never started. simla counts the same
+# extensions and holds the runtime's first event until they have registered.
+for extension in /opt/extensions/*; do
+	if [ -f "$extension" ] && [ -x "$extension" ]; then
+		"$extension" &
+	fi
+done
+exec "$@"
@@ -0,0 +1,65 @@
+package runtime
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Locations of the function code, the runtime and the layers in Lambda
+// base images.
+const (
+	taskRoot   = "/var/task"
+	runtimeDir = "/var/runtime"
+	optDir     = "/opt"
+)
+
+// lambdaEnvironment returns the environment of the container: the service's
+// own variables and the reserved ones Lambda sets to describe the function
+// and its execution environment. Reserved variables take precedence; TZ
+// defaults to UTC unless the service sets it.
+func lambdaEnvironment(config *RuntimeConfig) map[string]string {
+	env := make(map[string]string, len(config.Environment)+16)
+	for k, v := range config.Environment {
+		env[k] = v
+	}
+
+	env["AWS_LAMBDA_FUNCTION_NAME"] = config.Name
+	env["AWS_LAMBDA_FUNCTION_VERSION"] = "$LATEST"
+	env["AWS_LAMBDA_INITIALIZATION_TYPE"] = "on-demand"
+	env["AWS_LAMBDA_LOG_GROUP_NAME"] = "/aws/lambda/" + config.Name
+	env["AWS_LAMBDA_LOG_STREAM_NAME"] = logStreamName(time.Now())
+	env["LAMBDA_TASK_ROOT"] = taskRoot
+	env["LAMBDA_RUNTIME_DIR"] = runtimeDir
+	if _, ok := env["TZ"]; !ok {
+		env["TZ"] = ":UTC"
+	}
+
+	if config.Region != "" {
+		env["AWS_REGION"] = config.Region
+		env["AWS_DEFAULT_REGION"] = config.Region
+	}
+	if config.Runtime != "" {
+		env["AWS_EXECUTION_ENV"] = "AWS_Lambda_" + config.Runtime
+	}
+	if len(config.Cmd) > 0 {
+		env["_HANDLER"] = config.Cmd[0]
+	}
+	if config.MemorySize > 0 {
+		env["AWS_LAMBDA_FUNCTION_MEMORY_SIZE"] = strconv.Itoa(config.MemorySize)
+	}
+	if config.Timeout > 0 {
+		env["AWS_LAMBDA_FUNCTION_TIMEOUT"] = strconv.Itoa(config.Timeout)
+	}
+	return env
+}
+
+// logStreamName returns a log stream name in Lambda's format, e.g.
+// "2024/01/31/[$LATEST]3f1c...". Each container gets its own.
+func logStreamName(now time.Time) string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return fmt.Sprintf("%s/[$LATEST]%s", now.UTC().Format("2006/01/02"), hex.EncodeToString(id))
+}
@@ -0,0 +1,46 @@
+package runtime
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLambdaEnvironment(t *testing.T) {
+	env := lambdaEnvironment(&RuntimeConfig{
+		Name:        "orders",
+		Runtime:     "python3.12",
+		Cmd:         []string{"main.handler"},
+		Environment: map[string]string{"TABLE": "orders", "AWS_LAMBDA_FUNCTION_NAME": "other"},
+		MemorySize:  256,
+		Timeout:     30,
+		Region:      "eu-west-1",
+	})
+
+	assert.Equal(t, "orders", env["TABLE"])
+	assert.Equal(t, "orders", env["AWS_LAMBDA_FUNCTION_NAME"], "reserved variables take precedence")
+	assert.Equal(t, "$LATEST", env["AWS_LAMBDA_FUNCTION_VERSION"])
+	assert.Equal(t, "256", env["AWS_LAMBDA_FUNCTION_MEMORY_SIZE"])
+	assert.Equal(t, "30", env["AWS_LAMBDA_FUNCTION_TIMEOUT"])
+	assert.Equal(t, "/aws/lambda/orders", env["AWS_LAMBDA_LOG_GROUP_NAME"])
+	assert.Regexp(t, regexp.MustCompile(`^\d{4}/\d{2}/\d{2}/\[\$LATEST\][0-9a-f]{32}$`), env["AWS_LAMBDA_LOG_STREAM_NAME"])
+	assert.Equal(t, "eu-west-1", env["AWS_REGION"])
+	assert.Equal(t, "eu-west-1", env["AWS_DEFAULT_REGION"])
+	assert.Equal(t, "AWS_Lambda_python3.12", env["AWS_EXECUTION_ENV"])
+	assert.Equal(t, "main.handler", env["_HANDLER"])
+	assert.Equal(t, "/var/task", env["LAMBDA_TASK_ROOT"])
+	assert.Equal(t, "/var/runtime", env["LAMBDA_RUNTIME_DIR"])
+	assert.Equal(t, ":UTC", env["TZ"])
+}
+
+func TestLambdaEnvironment_TZ(t *testing.T) {
+	env := lambdaEnvironment(&RuntimeConfig{Name: "orders", Environment: map[string]string{"TZ": "Europe/Berlin"}})
+	assert.Equal(t, "Europe/Berlin", env["TZ"], "TZ is not reserved")
+}
+
+func TestLambdaEnvironment_ImageWithoutRuntime(t *testing.T) {
+	env := lambdaEnvironment(&RuntimeConfig{Name: "orders", Image: "orders:latest"})
+	assert.NotContains(t, env, "AWS_EXECUTION_ENV")
+	assert.NotContains(t, env, "_HANDLER")
+}
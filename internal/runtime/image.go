@@ -0,0 +1,277 @@
+package runtime
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
+)
+
+// defaultDockerfile is the Dockerfile used when a service sets only a build
+// context.
+const defaultDockerfile = "Dockerfile"
+
+// buildImage builds the image of a service defined by a Dockerfile and
+// returns its tag. Images are tagged with a hash of the build context,
+// Dockerfile, build arguments and architecture, so an unchanged service
+// reuses its image and any change builds a new one.
+func (r *Runtime) buildImage(ctx context.Context, config *RuntimeConfig) (string, error) {
+	buildContext, dockerfile := imageSource(config)
+	logger := r.logger.WithField("service", config.Name)
+
+	ignore, err := readDockerignore(buildContext)
+	if err != nil {
+		return "", err
+	}
+	hash, err := contextHash(buildContext, dockerfile, config.BuildArgs, config.Architecture, ignore)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash build context: %w", err)
+	}
+	tag := fmt.Sprintf("simla/%s:%s", strings.ToLower(config.Name), hash[:12])
+
+	if _, err := r.client.ImageInspect(ctx, tag); err == nil {
+		logger.WithField("image", tag).Debug("image is up to date")
+		return tag, nil
+	} else if !errdefs.IsNotFound(err) {
+		return "", fmt.Errorf("failed to inspect image %s: %w", tag, err)
+	}
+
+	logger.WithField("image", tag).Info("building image")
+	var archive bytes.Buffer
+	if err := tarContext(&archive, buildContext, dockerfile, ignore); err != nil {
+		return "", fmt.Errorf("failed to archive build context: %w", err)
+	}
+
+	args := make(map[string]*string, len(config.BuildArgs))
+	for k, v := range config.BuildArgs {
+		args[k] = &v
+	}
+	resp, err := r.client.ImageBuild(ctx, &archive, types.ImageBuildOptions{
+		Tags:       []string{tag},
+		Dockerfile: filepath.ToSlash(dockerfile),
+		BuildArgs:  args,
+		Platform:   fmt.Sprintf("%s/%s", OS, config.Architecture),
+		Labels:     map[string]string{"simla": "true", labelName: config.Name},
+		Remove:     true,
+	})
+	if err != nil {
+		return "", simlaerrors.NewBuildError(config.Name, "", err)
+	}
+	defer resp.Body.Close()
+
+	if output, err := readBuildOutput(resp.Body); err != nil {
+		return "", simlaerrors.NewBuildError(config.Name, output, err)
+	}
+
+	logger.WithField("image", tag).Info("image built successfully")
+	return tag, nil
+}
+
+// imageBuild reports whether config runs from an image built from a
+// Dockerfile.
+func (config *RuntimeConfig) imageBuild() bool {
+	return config.Dockerfile != "" || config.BuildContext != ""
+}
+
+// imageSource returns the build context of config and the path of its
+// Dockerfile relative to it.
+func imageSource(config *RuntimeConfig) (string, string) {
+	buildContext := config.BuildContext
+	if buildContext == "" {
+		buildContext = "."
+	}
+	dockerfile := config.Dockerfile
+	if dockerfile == "" {
+		dockerfile = defaultDockerfile
+	}
+	return buildContext, dockerfile
+}
+
+// buildMessage is one line of the progress stream of an image build.
+type buildMessage struct {
+	Stream      string `json:"stream"`
+	Error       string `json:"error"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// readBuildOutput drains the progress stream of an image build and returns
+// its output, with an error if the build failed.
+func readBuildOutput(r io.Reader) (string, error) {
+	var output strings.Builder
+	dec := json.NewDecoder(r)
+	for {
+		var msg buildMessage
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return strings.TrimSpace(output.String()), nil
+			}
+			return strings.TrimSpace(output.String()), fmt.Errorf("failed to read build output: %w", err)
+		}
+		output.WriteString(msg.Stream)
+		if msg.ErrorDetail != nil && msg.ErrorDetail.Message != "" {
+			return strings.TrimSpace(output.String()), errors.New(msg.ErrorDetail.Message)
+		}
+		if msg.Error != "" {
+			return strings.TrimSpace(output.String()), errors.New(msg.Error)
+		}
+	}
+}
+
+// readDockerignore returns a matcher for the patterns of the .dockerignore
+// file in dir, or nil when there is none. Patterns follow Docker's rules,
+// including "**" and re-inclusion with "!".
+func readDockerignore(dir string) (*patternmatcher.PatternMatcher, error) {
+	f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns, err := ignorefile.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	matcher, err := patternmatcher.New(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid .dockerignore: %w", err)
+	}
+	return matcher, nil
+}
+
+// ignored reports whether the context path rel is excluded by ignore. A
+// pattern matches a path or any of its parent directories; the last
+// matching pattern wins and patterns starting with ! re-include paths.
+func ignored(rel string, ignore *patternmatcher.PatternMatcher) bool {
+	if ignore == nil {
+		return false
+	}
+	excluded, _ := ignore.MatchesOrParentMatches(filepath.ToSlash(rel))
+	return excluded
+}
+
+// walkContext calls fn for every file of the build context in dir that is
+// not ignored, in lexical order.
+func walkContext(dir, dockerfile string, ignore *patternmatcher.PatternMatcher, fn func(path, rel string, info fs.FileInfo) error) error {
+	dockerfile = filepath.Clean(dockerfile)
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if rel == "." {
+			return nil
+		}
+		// The Dockerfile and .dockerignore are always sent, as Docker does.
+		if ignored(rel, ignore) && rel != ".dockerignore" && rel != dockerfile {
+			if d.IsDir() && !ignore.Exclusions() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(path, rel, info)
+	})
+}
+
+// contextHash returns a hash of everything that determines the image built
+// from dir: the content of its files, the Dockerfile path, the build
+// arguments and the architecture.
+func contextHash(dir, dockerfile string, buildArgs map[string]string, arch string, ignore *patternmatcher.PatternMatcher) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "dockerfile %s\narch %s\n", dockerfile, arch)
+
+	keys := make([]string, 0, len(buildArgs))
+	for k := range buildArgs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "arg %s=%s\n", k, buildArgs[k])
+	}
+
+	err := walkContext(dir, dockerfile, ignore, func(path, rel string, info fs.FileInfo) error {
+		fmt.Fprintf(h, "file %s %s\n", filepath.ToSlash(rel), info.Mode())
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(h, link)
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// tarContext writes the build context in dir to w as a tar archive.
+func tarContext(w io.Writer, dir, dockerfile string, ignore *patternmatcher.PatternMatcher) error {
+	tw := tar.NewWriter(w)
+	err := walkContext(dir, dockerfile, ignore, func(path, rel string, info fs.FileInfo) error {
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			var err error
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
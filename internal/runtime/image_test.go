@@ -0,0 +1,129 @@
+package runtime
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moby/patternmatcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeContext(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestIgnored(t *testing.T) {
+	patterns, err := patternmatcher.New([]string{"node_modules", "*.log", "build", "!build/keep.txt"})
+	require.NoError(t, err)
+	assert.True(t, ignored("node_modules/a/index.js", patterns))
+	assert.True(t, ignored("debug.log", patterns))
+	assert.True(t, ignored("build/out.bin", patterns))
+	assert.False(t, ignored("build/keep.txt", patterns))
+	assert.False(t, ignored("src/main.go", patterns))
+	assert.False(t, ignored("src/main.go", nil))
+}
+
+func TestIgnored_DoubleStar(t *testing.T) {
+	dir := writeContext(t, map[string]string{
+		".dockerignore": "**/node_modules\n**/*.pyc\n!**/keep.pyc\n",
+	})
+	patterns, err := readDockerignore(dir)
+	require.NoError(t, err)
+	assert.True(t, ignored("node_modules/a/index.js", patterns))
+	assert.True(t, ignored("web/app/node_modules/a/index.js", patterns))
+	assert.True(t, ignored("main.pyc", patterns))
+	assert.True(t, ignored("pkg/sub/mod.pyc", patterns))
+	assert.False(t, ignored("pkg/sub/keep.pyc", patterns))
+	assert.False(t, ignored("pkg/sub/mod.py", patterns))
+}
+
+func TestContextHash(t *testing.T) {
+	dir := writeContext(t, map[string]string{
+		"Dockerfile":    "FROM scratch",
+		"main.go":       "package main",
+		".dockerignore": "*.log\nDockerfile\n",
+	})
+	ignore, err := readDockerignore(dir)
+	require.NoError(t, err)
+
+	hash, err := contextHash(dir, "Dockerfile", nil, "amd64", ignore)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "debug.log"), []byte("x"), 0o644))
+	same, err := contextHash(dir, "Dockerfile", nil, "amd64", ignore)
+	require.NoError(t, err)
+	assert.Equal(t, hash, same, "ignored files do not change the hash")
+
+	arm, err := contextHash(dir, "Dockerfile", nil, "arm64", ignore)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, arm)
+
+	withArgs, err := contextHash(dir, "Dockerfile", map[string]string{"VERSION": "1"}, "amd64", ignore)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, withArgs)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM alpine"), 0o644))
+	changed, err := contextHash(dir, "Dockerfile", nil, "amd64", ignore)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, changed, "the Dockerfile is hashed even when ignored")
+}
+
+func TestTarContext(t *testing.T) {
+	dir := writeContext(t, map[string]string{
+		"Dockerfile":          "FROM scratch",
+		"src/main.go":         "package main",
+		"node_modules/x.js":   "x",
+		".dockerignore":       "node_modules\n",
+		"docker/Dockerfile.x": "FROM scratch",
+	})
+	ignore, err := readDockerignore(dir)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, tarContext(&buf, dir, "Dockerfile", ignore))
+
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	assert.ElementsMatch(t, []string{".dockerignore", "Dockerfile", "docker", "docker/Dockerfile.x", "src", "src/main.go"}, names)
+}
+
+func TestReadBuildOutput(t *testing.T) {
+	output, err := readBuildOutput(strings.NewReader(`{"stream":"Step 1/2 : FROM scratch\n"}{"stream":"Successfully built\n"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "Step 1/2 : FROM scratch\nSuccessfully built", output)
+
+	output, err = readBuildOutput(strings.NewReader(`{"stream":"Step 1/2 : RUN make\n"}{"errorDetail":{"message":"make: not found"},"error":"make: not found"}`))
+	assert.EqualError(t, err, "make: not found")
+	assert.Equal(t, "Step 1/2 : RUN make", output)
+}
+
+func TestImageSource(t *testing.T) {
+	buildContext, dockerfile := imageSource(&RuntimeConfig{BuildContext: "./web"})
+	assert.Equal(t, "./web", buildContext)
+	assert.Equal(t, "Dockerfile", dockerfile)
+
+	buildContext, dockerfile = imageSource(&RuntimeConfig{Dockerfile: "docker/Dockerfile.lambda"})
+	assert.Equal(t, ".", buildContext)
+	assert.Equal(t, "docker/Dockerfile.lambda", dockerfile)
+}
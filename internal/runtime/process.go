@@ -0,0 +1,584 @@
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nyambati/simla/internal/config"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
+	"github.com/nyambati/simla/internal/registry"
+	"github.com/sirupsen/logrus"
+)
+
+// processPrefix starts the IDs of processes started by ProcessRuntime, so
+// they can be told apart from container IDs.
+const processPrefix = "process-"
+
+// Limits of a process.
+const (
+	// maxProcessLogs is how much output is kept in memory for LogsSince.
+	maxProcessLogs = 1 << 20
+	// stopGracePeriod is how long a process may take to exit after SIGTERM
+	// before it is killed.
+	stopGracePeriod = 2 * time.Second
+	// initTimeout is how long extensions may take to register, as Lambda
+	// bounds the init phase.
+	initTimeout = 10 * time.Second
+)
+
+// processes holds the running processes by ID. Every ProcessRuntime in the
+// process shares it, as schedulers create a runtime per operation.
+var processes sync.Map
+
+// ProcessRuntime runs functions as processes on the host, without Docker.
+// Each process gets its own Runtime API server listening on the service's
+// port, which also accepts invocations the way a container does.
+type ProcessRuntime struct {
+	logger *logrus.Entry
+}
+
+// process is a function process, the extensions started with it and the
+// Runtime API serving them.
+type process struct {
+	cmd        *exec.Cmd
+	extensions []*exec.Cmd
+	api        *functionAPI
+	logs       *processLogs
+	done       chan struct{}
+}
+
+var _ RuntimeInterface = (*ProcessRuntime)(nil)
+
+// NewProcessRuntime returns a runtime running functions as host processes.
+func NewProcessRuntime(logger *logrus.Entry) RuntimeInterface {
+	return &ProcessRuntime{logger: logger.WithField("component", "runtime")}
+}
+
+// New returns the runtime for backend: a ProcessRuntime for
+// config.BackendProcess and the Docker runtime otherwise.
+func New(backend string, registry registry.ServiceRegistryInterface, logger *logrus.Entry) (RuntimeInterface, error) {
+	if backend == config.BackendProcess {
+		return NewProcessRuntime(logger), nil
+	}
+	return NewRuntime(registry, logger)
+}
+
+// IsProcess reports whether id is the ID of a process started by
+// ProcessRuntime rather than a container.
+func IsProcess(id string) bool {
+	return strings.HasPrefix(id, processPrefix)
+}
+
+// LogDir returns the directory holding the output of processes,
+// ~/.simla/logs.
+func LogDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".simla", "logs"), nil
+}
+
+// pidFile returns the file recording the process groups of the process
+// with id, ~/.simla/run/<id>.pid, so that another simla command can stop
+// them once the command that started them has exited.
+func pidFile(id string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".simla", "run", id+".pid"), nil
+}
+
+// StartContainer starts the function of config as a host process and the
+// Runtime API it polls, and returns the process's ID.
+func (p *ProcessRuntime) StartContainer(ctx context.Context, config *RuntimeConfig) (string, error) {
+	if errProcessUnsupported != nil {
+		return "", simlaerrors.NewRuntimeConfigError(errProcessUnsupported.Error())
+	}
+	codePath, err := filepath.Abs(config.CodePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve code path: %w", err)
+	}
+	name, args, err := processCommand(config, codePath)
+	if err != nil {
+		return "", err
+	}
+
+	id := processPrefix + uuid.NewString()
+	logs, err := newProcessLogs(id)
+	if err != nil {
+		return "", err
+	}
+	// The runtime starts once the extensions have registered, so the API
+	// need not wait for them.
+	api, err := startAPI(config, "127.0.0.1:"+config.Port, 0, logs, p.logger)
+	if err != nil {
+		logs.Close()
+		return "", err
+	}
+	env := processEnvironment(config, codePath, api.addr)
+
+	// Extensions start first and register while the runtime initialises,
+	// as in Lambda.
+	extensions, err := startExtensions(config, codePath, env, logs)
+	if err != nil {
+		stopProcesses(extensions)
+		_ = api.close()
+		logs.Close()
+		return "", err
+	}
+
+	if len(extensions) > 0 {
+		registerCtx, cancel := context.WithTimeout(ctx, initTimeout)
+		if !api.api.AwaitExtensions(registerCtx, len(extensions)) {
+			p.logger.WithField("service", config.Name).Warn("extensions did not register in time; starting the runtime anyway")
+		}
+		cancel()
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Dir = codePath
+	cmd.Env = env
+	cmd.Stdout = logs
+	cmd.Stderr = logs
+	if err := startGroup(cmd); err != nil {
+		stopProcesses(extensions)
+		_ = api.close()
+		logs.Close()
+		return "", simlaerrors.NewRuntimeConfigError(fmt.Sprintf("failed to start %s: %v", name, err))
+	}
+	if err := writePIDs(id, append([]*exec.Cmd{cmd}, extensions...)); err != nil {
+		p.logger.WithField("process", id).WithError(err).Warn("failed to record process IDs; other simla commands cannot stop the process")
+	}
+
+	proc := &process{cmd: cmd, extensions: extensions, api: api, logs: logs, done: make(chan struct{})}
+	processes.Store(id, proc)
+
+	go func() {
+		err := cmd.Wait()
+		api.api.Exited(err)
+		close(proc.done)
+		p.logger.WithFields(logrus.Fields{"service": config.Name, "process": id}).Debugf("process exited: %v", err)
+	}()
+
+	p.logger.WithFields(logrus.Fields{"service": config.Name, "process": id, "command": name}).Info("process started")
+	return id, nil
+}
+
+// processCommand returns the executable and arguments that run the
+// function of config: its entrypoint followed by cmd, as in a container,
+// or else a bootstrap in the code or layers, or else the executable named
+// by the first element of cmd, as for the go1.x runtime.
+func processCommand(config *RuntimeConfig, codePath string) (string, []string, error) {
+	if len(config.Entrypoint) > 0 {
+		return config.Entrypoint[0], slices.Concat(config.Entrypoint[1:], config.Cmd), nil
+	}
+	candidates := []string{filepath.Join(codePath, "bootstrap")}
+	if config.LayersPath != "" {
+		candidates = append(candidates, filepath.Join(config.LayersPath, "bootstrap"))
+	}
+	if len(config.Cmd) > 0 {
+		candidates = append(candidates, filepath.Join(codePath, config.Cmd[0]))
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			return candidate, nil, nil
+		}
+	}
+	return "", nil, simlaerrors.NewRuntimeConfigError(fmt.Sprintf(
+		"service %s has no executable bootstrap in %s; set entrypoint or build it for the host", config.Name, codePath))
+}
+
+// startExtensions starts the executables in the extensions directory of
+// the service's layers with env, writing their output to logs.
+func startExtensions(config *RuntimeConfig, codePath string, env []string, logs io.Writer) ([]*exec.Cmd, error) {
+	if config.LayersPath == "" {
+		return nil, nil
+	}
+	paths, err := extensionPaths(filepath.Join(config.LayersPath, "extensions"))
+	if err != nil {
+		return nil, err
+	}
+	var extensions []*exec.Cmd
+	for _, path := range paths {
+		cmd := exec.Command(path)
+		cmd.Dir = codePath
+		cmd.Env = env
+		cmd.Stdout = logs
+		cmd.Stderr = logs
+		if err := startGroup(cmd); err != nil {
+			return extensions, simlaerrors.NewRuntimeConfigError(fmt.Sprintf("failed to start extension %s: %v", filepath.Base(path), err))
+		}
+		go func() { _ = cmd.Wait() }()
+		extensions = append(extensions, cmd)
+	}
+	return extensions, nil
+}
+
+// extensionPaths returns the executables in the extensions directory dir,
+// as the entrypoint shim picks them.
+func extensionPaths(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read extensions: %w", err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if info, err := os.Stat(path); err != nil || info.IsDir() || info.Mode()&0o111 == 0 {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// stopProcesses kills cmds and the processes they started.
+func stopProcesses(cmds []*exec.Cmd) {
+	for _, cmd := range cmds {
+		_ = signalGroup(cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// recordedProcess is a process group leader recorded in a PID file, with
+// the time it started, which tells it apart from a later process given the
+// same PID.
+type recordedProcess struct {
+	pid   int
+	start string
+}
+
+// writePIDs records the process groups of cmds, each of which leads its
+// own, in the PID file of id, one "<pid> <start time>" line each.
+func writePIDs(id string, cmds []*exec.Cmd) error {
+	path, err := pidFile(id)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create run directory: %w", err)
+	}
+	var buf bytes.Buffer
+	for _, cmd := range cmds {
+		start, err := processStart(cmd.Process.Pid)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(&buf, cmd.Process.Pid, start)
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// readPIDs returns the process groups recorded in the PID file of id, or
+// none when there is no such file.
+func readPIDs(id string) ([]recordedProcess, error) {
+	path, err := pidFile(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read process IDs: %w", err)
+	}
+	var procs []recordedProcess
+	for _, line := range strings.Split(string(data), "\n") {
+		field, start, _ := strings.Cut(strings.TrimSpace(line), " ")
+		// 0 and 1 would signal our own group or every process.
+		if pid, err := strconv.Atoi(field); err == nil && pid > 1 {
+			procs = append(procs, recordedProcess{pid: pid, start: start})
+		}
+	}
+	return procs, nil
+}
+
+// removePIDs removes the PID file of id.
+func removePIDs(id string) error {
+	path, err := pidFile(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// stopGroups sends SIGTERM to the process groups pids, and SIGKILL to
+// those still running after stopGracePeriod.
+func stopGroups(pids []int) {
+	alive := func() bool {
+		for _, pid := range pids {
+			if signalGroup(pid, 0) == nil {
+				return true
+			}
+		}
+		return false
+	}
+	for _, pid := range pids {
+		_ = signalGroup(pid, syscall.SIGTERM)
+	}
+	for deadline := time.Now().Add(stopGracePeriod); alive() && time.Now().Before(deadline); {
+		time.Sleep(50 * time.Millisecond)
+	}
+	for _, pid := range pids {
+		_ = signalGroup(pid, syscall.SIGKILL)
+	}
+}
+
+// processEnvironment returns the environment of the process: the Lambda
+// environment, pointed at codePath and the Runtime API at apiAddr, and
+// the host's PATH and HOME.
+func processEnvironment(config *RuntimeConfig, codePath, apiAddr string) []string {
+	env := lambdaEnvironment(config)
+	env["LAMBDA_TASK_ROOT"] = codePath
+	delete(env, "LAMBDA_RUNTIME_DIR")
+	env["AWS_LAMBDA_RUNTIME_API"] = apiAddr
+
+	path := os.Getenv("PATH")
+	if config.LayersPath != "" {
+		path = filepath.Join(config.LayersPath, "bin") + string(os.PathListSeparator) + path
+	}
+	if _, ok := env["PATH"]; !ok {
+		env["PATH"] = path
+	}
+	if _, ok := env["HOME"]; !ok {
+		env["HOME"] = os.Getenv("HOME")
+	}
+	return formatEnvVars(env)
+}
+
+// StopContainer stops the process with id, its extensions and its Runtime
+// API. Extensions subscribed to SHUTDOWN handle it first. Processes get
+// stopGracePeriod to exit after SIGTERM before they are killed. Processes
+// started by another simla command that has exited are stopped through
+// their PID file; IDs without one are ignored.
+func (p *ProcessRuntime) StopContainer(ctx context.Context, id string) error {
+	value, ok := processes.LoadAndDelete(id)
+	if !ok {
+		return p.stopOrphan(id)
+	}
+	proc := value.(*process)
+
+	proc.api.shutdown()
+	_ = signalGroup(proc.cmd.Process.Pid, syscall.SIGTERM)
+	select {
+	case <-proc.done:
+	case <-time.After(stopGracePeriod):
+		_ = signalGroup(proc.cmd.Process.Pid, syscall.SIGKILL)
+		<-proc.done
+	}
+	stopProcesses(proc.extensions)
+	proc.logs.Close()
+	if err := removePIDs(id); err != nil {
+		return err
+	}
+	if err := proc.api.close(); err != nil {
+		return err
+	}
+	p.logger.WithField("process", id).Info("process stopped")
+	return nil
+}
+
+// stopOrphan stops the processes recorded in the PID file of id, which
+// outlived the simla command that started them and their Runtime API.
+// Groups whose leader has exited, or whose PID now belongs to another
+// process, as after a reboot, are left alone.
+func (p *ProcessRuntime) stopOrphan(id string) error {
+	procs, err := readPIDs(id)
+	if err != nil {
+		return err
+	}
+	logger := p.logger.WithField("process", id)
+	var pids []int
+	for _, proc := range procs {
+		if start, err := processStart(proc.pid); err != nil || start != proc.start {
+			logger.WithField("pid", proc.pid).Debug("recorded process is gone; not stopping its PID")
+			continue
+		}
+		pids = append(pids, proc.pid)
+	}
+	if len(pids) > 0 {
+		stopGroups(pids)
+		logger.Info("stopped process left by another simla command")
+	}
+	return removePIDs(id)
+}
+
+// DeleteContainer stops the process with id and removes its output.
+func (p *ProcessRuntime) DeleteContainer(ctx context.Context, id string) error {
+	if err := p.StopContainer(ctx, id); err != nil {
+		return err
+	}
+	dir, err := LogDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, id+".log")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// GetLogs returns the output of the process with id. It reads the log file
+// so it also works from another simla command; with follow it keeps
+// reading new output until ctx is cancelled.
+func (p *ProcessRuntime) GetLogs(ctx context.Context, id string, follow bool) (io.ReadCloser, error) {
+	dir, err := LogDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, id+".log"))
+	if err != nil {
+		return nil, err
+	}
+	if !follow {
+		return f, nil
+	}
+	return &followReader{ctx: ctx, file: f}, nil
+}
+
+// LogsSince returns the output the process with id has written since a
+// point in time.
+func (p *ProcessRuntime) LogsSince(ctx context.Context, id string, since time.Time) ([]byte, error) {
+	value, ok := processes.Load(id)
+	if !ok {
+		return nil, fmt.Errorf("process %s is not running", id)
+	}
+	return value.(*process).logs.since(since), nil
+}
+
+// StreamStartupLogs logs the output the process with id has written so far.
+func (p *ProcessRuntime) StreamStartupLogs(ctx context.Context, id string, _ time.Duration) {
+	value, ok := processes.Load(id)
+	if !ok {
+		return
+	}
+	logger := p.logger.WithField("process", id)
+	scanner := bufio.NewScanner(bytes.NewReader(value.(*process).logs.since(time.Time{})))
+	for scanner.Scan() {
+		if trimmed := strings.TrimSpace(scanner.Text()); trimmed != "" {
+			logger.Info("[process] " + trimmed)
+		}
+	}
+}
+
+// processLogs records the output of a process in its log file and keeps
+// the most recent output in memory, with the time it was written.
+type processLogs struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries []logEntry
+	size    int
+}
+
+type logEntry struct {
+	at   time.Time
+	data []byte
+}
+
+func newProcessLogs(id string) (*processLogs, error) {
+	dir, err := LogDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.Create(filepath.Join(dir, id+".log"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log file: %w", err)
+	}
+	return &processLogs{file: f}, nil
+}
+
+func (l *processLogs) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{at: time.Now(), data: bytes.Clone(p)})
+	l.size += len(p)
+	for l.size > maxProcessLogs && len(l.entries) > 1 {
+		l.size -= len(l.entries[0].data)
+		l.entries = l.entries[1:]
+	}
+	if l.file != nil {
+		_, _ = l.file.Write(p)
+	}
+	return len(p), nil
+}
+
+func (l *processLogs) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// since returns the output written at or after t.
+func (l *processLogs) since(t time.Time) []byte {
+	var buf bytes.Buffer
+	for _, e := range l.entriesSince(t) {
+		buf.Write(e.data)
+	}
+	return buf.Bytes()
+}
+
+// entriesSince returns the writes made at or after t.
+func (l *processLogs) entriesSince(t time.Time) []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var entries []logEntry
+	for _, e := range l.entries {
+		if !e.at.Before(t) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// followReader reads a growing file until its context is cancelled.
+type followReader struct {
+	ctx  context.Context
+	file *os.File
+}
+
+func (f *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.file.Read(p)
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+		select {
+		case <-f.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+func (f *followReader) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,169 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHelperBootstrap is the function process of TestProcessRuntime: a
+// minimal runtime that echoes every event back.
+func TestHelperBootstrap(t *testing.T) {
+	if os.Getenv("SIMLA_HELPER_BOOTSTRAP") != "1" {
+		t.Skip("only runs as the bootstrap of TestProcessRuntime")
+	}
+	api := "http://" + os.Getenv("AWS_LAMBDA_RUNTIME_API") + "/2018-06-01/runtime/invocation/"
+	fmt.Println("bootstrap ready")
+	for {
+		resp, err := http.Get(api + "next")
+		if err != nil {
+			os.Exit(1)
+		}
+		event, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		id := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+		if string(event) == `"exit"` {
+			os.Exit(3)
+		}
+		body := fmt.Sprintf(`{"event":%s,"root":%q}`, event, os.Getenv("LAMBDA_TASK_ROOT"))
+		resp, err = http.Post(api+id+"/response", "application/json", strings.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+// TestHelperExtension is the extension of TestProcessRuntime: it registers
+// for INVOKE events and prints each one it gets.
+func TestHelperExtension(t *testing.T) {
+	if os.Getenv("SIMLA_HELPER_EXTENSION") != "1" {
+		t.Skip("only runs as the extension of TestProcessRuntime")
+	}
+	api := "http://" + os.Getenv("AWS_LAMBDA_RUNTIME_API") + "/2020-01-01/extension/"
+	req, _ := http.NewRequest(http.MethodPost, api+"register", strings.NewReader(`{"events":["INVOKE"]}`))
+	req.Header.Set("Lambda-Extension-Name", "telemetry")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		os.Exit(1)
+	}
+	resp.Body.Close()
+	id := resp.Header.Get("Lambda-Extension-Identifier")
+	for {
+		req, _ := http.NewRequest(http.MethodGet, api+"event/next", nil)
+		req.Header.Set("Lambda-Extension-Identifier", id)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			os.Exit(0)
+		}
+		event, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if strings.Contains(string(event), `"eventType":"INVOKE"`) {
+			fmt.Println("extension got INVOKE")
+		}
+	}
+}
+
+func freePort(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestProcessRuntime(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	rt := NewProcessRuntime(logrus.NewEntry(logger))
+
+	code := t.TempDir()
+	layers := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(layers, "extensions"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(layers, "extensions", "telemetry"),
+		fmt.Appendf(nil, "#!/bin/sh\nSIMLA_HELPER_BOOTSTRAP= SIMLA_HELPER_EXTENSION=1 exec %q -test.run='^TestHelperExtension$'\n", os.Args[0]), 0o755))
+	port := freePort(t)
+	id, err := rt.StartContainer(context.Background(), &RuntimeConfig{
+		Name:        "orders",
+		CodePath:    code,
+		LayersPath:  layers,
+		MemorySize:  128,
+		Entrypoint:  []string{os.Args[0], "-test.run=^TestHelperBootstrap$"},
+		Environment: map[string]string{"SIMLA_HELPER_BOOTSTRAP": "1"},
+		Port:        port,
+		Timeout:     3,
+		Region:      "us-east-1",
+		AccountID:   "000000000000",
+	})
+	require.NoError(t, err)
+	assert.True(t, IsProcess(id))
+	t.Cleanup(func() { _ = rt.DeleteContainer(context.Background(), id) })
+
+	url := "http://127.0.0.1:" + port + "/2015-03-31/functions/function/invocations"
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 10*time.Second, 50*time.Millisecond)
+
+	start := time.Now()
+	resp, err := http.Post(url, "application/json", strings.NewReader(`{"id":1}`))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.JSONEq(t, fmt.Sprintf(`{"event":{"id":1},"root":%q}`, code), string(body))
+
+	resp, err = http.Post(url, "application/json", strings.NewReader(`"exit"`))
+	require.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(body), "Runtime.ExitError")
+
+	logs, err := rt.LogsSince(context.Background(), id, start.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Contains(t, string(logs), "bootstrap ready")
+	assert.Contains(t, string(logs), "extension got INVOKE")
+	assert.Regexp(t, `REPORT RequestId: \S+\tDuration: [\d.]+ ms\tBilled Duration: \d+ ms\tMemory Size: 128 MB\tInit Duration`, string(logs))
+
+	require.NoError(t, rt.StopContainer(context.Background(), id))
+	dir, err := LogDir()
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, id+".log"))
+}
+
+func TestProcessCommand(t *testing.T) {
+	code := t.TempDir()
+	_, _, err := processCommand(&RuntimeConfig{Name: "orders", Cmd: []string{"main"}}, code)
+	assert.ErrorContains(t, err, "no executable bootstrap")
+
+	require.NoError(t, os.WriteFile(filepath.Join(code, "main"), []byte("#!/bin/sh"), 0o755))
+	name, args, err := processCommand(&RuntimeConfig{Cmd: []string{"main"}}, code)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(code, "main"), name)
+	assert.Empty(t, args)
+
+	require.NoError(t, os.WriteFile(filepath.Join(code, "bootstrap"), []byte("#!/bin/sh"), 0o755))
+	name, _, err = processCommand(&RuntimeConfig{Cmd: []string{"main"}}, code)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(code, "bootstrap"), name, "bootstrap takes precedence, as in Lambda")
+
+	name, args, err = processCommand(&RuntimeConfig{Entrypoint: []string{"node", "--enable-source-maps"}, Cmd: []string{"index.js"}}, code)
+	require.NoError(t, err)
+	assert.Equal(t, "node", name)
+	assert.Equal(t, []string{"--enable-source-maps", "index.js"}, args)
+}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package runtime
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// errProcessUnsupported is nil where ProcessRuntime can run functions.
+var errProcessUnsupported error
+
+// startGroup starts cmd as the leader of a new process group, so that
+// stopping the group also stops the processes cmd starts.
+func startGroup(cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd.Start()
+}
+
+// signalGroup sends sig to the process group led by pid. Signal 0 only
+// checks that the group exists.
+func signalGroup(pid int, sig syscall.Signal) error {
+	return syscall.Kill(-pid, sig)
+}
+
+// processStart returns the time the process pid started, as ps reports it.
+func processStart(pid int) (string, error) {
+	out, err := exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the start time of process %d: %w", pid, err)
+	}
+	start := strings.Join(strings.Fields(string(out)), " ")
+	if start == "" {
+		return "", fmt.Errorf("process %d is not running", pid)
+	}
+	return start, nil
+}
@@ -0,0 +1,84 @@
+//go:build unix
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessRuntime_StopsOrphans(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	rt := NewProcessRuntime(logrus.NewEntry(logger))
+
+	code := t.TempDir()
+	id, err := rt.StartContainer(context.Background(), &RuntimeConfig{
+		Name:       "orders",
+		CodePath:   code,
+		Entrypoint: []string{"/bin/sh", "-c", "sleep 60 & echo $! > child; wait"},
+		Port:       freePort(t),
+	})
+	require.NoError(t, err)
+
+	var child int
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(filepath.Join(code, "child"))
+		child, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		return err == nil && child > 0
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// The command that started the process exits, taking its Runtime API
+	// with it; the process and its children keep running.
+	value, ok := processes.LoadAndDelete(id)
+	require.True(t, ok)
+	require.NoError(t, value.(*process).api.close())
+	require.NoError(t, syscall.Kill(child, 0))
+
+	require.NoError(t, rt.StopContainer(context.Background(), id))
+	assert.Eventually(t, func() bool { return syscall.Kill(child, 0) != nil }, 5*time.Second, 20*time.Millisecond,
+		"processes started by the function are stopped too")
+	path, err := pidFile(id)
+	require.NoError(t, err)
+	assert.NoFileExists(t, path)
+
+	assert.NoError(t, rt.StopContainer(context.Background(), "process-unknown"), "IDs without a PID file are ignored")
+}
+
+func TestProcessRuntime_SkipsReusedPIDs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	rt := NewProcessRuntime(logrus.NewEntry(logger))
+
+	// An unrelated process now has the PID a stale PID file recorded.
+	other := exec.Command("sleep", "60")
+	require.NoError(t, startGroup(other))
+	t.Cleanup(func() {
+		_ = other.Process.Kill()
+		_ = other.Wait()
+	})
+	id := processPrefix + "stale"
+	path, err := pidFile(id)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, fmt.Appendf(nil, "%d Thu Jan 1 00:00:00 1970\n", other.Process.Pid), 0o644))
+
+	require.NoError(t, rt.StopContainer(context.Background(), id))
+	assert.NoError(t, signalGroup(other.Process.Pid, 0), "the unrelated process keeps running")
+	assert.NoFileExists(t, path)
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package runtime
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// errProcessUnsupported fails every start of ProcessRuntime, which relies
+// on Unix process groups.
+var errProcessUnsupported = errors.New("process backend is not supported on windows")
+
+func startGroup(cmd *exec.Cmd) error {
+	return errProcessUnsupported
+}
+
+func signalGroup(pid int, sig syscall.Signal) error {
+	return errProcessUnsupported
+}
+
+func processStart(pid int) (string, error) {
+	return "", errProcessUnsupported
+}
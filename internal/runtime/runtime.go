@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"archive/tar"
 	"bufio"
 	"bytes"
 	"context"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,7 +21,6 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/errdefs"
 	"github.com/docker/docker/pkg/stdcopy"
-	"github.com/docker/go-connections/nat"
 	"github.com/google/uuid"
 	simlaerrors "github.com/nyambati/simla/internal/errors"
 	"github.com/nyambati/simla/internal/registry"
@@ -40,6 +41,60 @@ func (r *Runtime) GetLogs(ctx context.Context, containerID string, follow bool)
 	})
 }
 
+// LogsSince returns the demultiplexed stdout+stderr output the container
+// has written since the given time, interleaved with the START, END and
+// REPORT lines of the invocations its Runtime API served.
+func (r *Runtime) LogsSince(ctx context.Context, containerID string, since time.Time) ([]byte, error) {
+	reader, err := r.client.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Since:      strconv.FormatFloat(float64(since.UnixNano())/float64(time.Second), 'f', 9, 64),
+		Timestamps: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	if _, err := stdcopy.StdCopy(&buf, &buf, reader); err != nil {
+		return nil, err
+	}
+	entries := timestampedEntries(buf.Bytes())
+	if api, ok := apis.Load(containerID); ok {
+		entries = append(entries, api.(*functionAPI).reports.entriesSince(since)...)
+		slices.SortStableFunc(entries, func(a, b logEntry) int { return a.at.Compare(b.at) })
+	}
+
+	var out bytes.Buffer
+	for _, e := range entries {
+		out.Write(e.data)
+	}
+	return out.Bytes(), nil
+}
+
+// timestampedEntries splits container output read with timestamps into
+// lines, each with the time it was written.
+func timestampedEntries(output []byte) []logEntry {
+	var entries []logEntry
+	for len(output) > 0 {
+		line := output
+		if i := bytes.IndexByte(output, '\n'); i >= 0 {
+			line, output = output[:i+1], output[i+1:]
+		} else {
+			output = nil
+		}
+		var at time.Time
+		if stamp, rest, ok := bytes.Cut(line, []byte(" ")); ok {
+			if t, err := time.Parse(time.RFC3339Nano, string(stamp)); err == nil {
+				at, line = t, rest
+			}
+		}
+		entries = append(entries, logEntry{at: at, data: line})
+	}
+	return entries
+}
+
 // StreamStartupLogs reads already-buffered container logs and emits each line
 // as a structured log entry. It does not follow the stream (Follow: false) so
 // it returns quickly after draining whatever the container has written so far.
@@ -82,9 +137,20 @@ func (r *Runtime) StreamStartupLogs(ctx context.Context, containerID string, _ t
 }
 
 const (
-	InternalPort = "8080"
-	OS           = "linux"
-	NetworkName  = "simla-network"
+	OS          = "linux"
+	NetworkName = "simla-network"
+	// HostGateway is the hostname containers use to reach simla's own
+	// listeners on the host, such as the Lambda API.
+	HostGateway = "host.docker.internal"
+
+	// labelName and labelPort record the service and host port of a
+	// container.
+	labelName = "simla.service"
+	labelPort = "simla.port"
+
+	// memoryPerVCPU is the memory size in MB at which Lambda allocates one
+	// full vCPU to a function.
+	memoryPerVCPU = 1769
 )
 
 // HostArch returns the architecture of the current host machine in the Docker
@@ -124,15 +190,16 @@ func NewRuntime(registry registry.ServiceRegistryInterface, logger *logrus.Entry
 }
 
 // StartContainer creates a new container for the runtime configuration and starts it.
-// It pulls the required Docker image, creates a new container, and starts it.
+// It pulls the required Docker image, or builds it when the service defines a
+// Dockerfile, creates a new container, and starts it.
 // It returns the container ID or an error if any part of the process fails.
 func (r *Runtime) StartContainer(ctx context.Context, config *RuntimeConfig) (containerID string, err error) {
 
-	if config.Image == "" && config.Runtime == "" {
+	if config.Image == "" && config.Runtime == "" && !config.imageBuild() {
 		return "", fmt.Errorf("image or runtime must be specified")
 	}
 
-	if config.Image == "" && config.Runtime != "" {
+	if config.Image == "" && config.Runtime != "" && !config.imageBuild() {
 		config.Image = inferImageFromRuntime(config.Runtime)
 	}
 
@@ -156,7 +223,11 @@ func (r *Runtime) StartContainer(ctx context.Context, config *RuntimeConfig) (co
 		)
 	}
 
-	if err = r.pullImage(ctx, config.Image, config.Architecture); err != nil {
+	if config.imageBuild() {
+		if config.Image, err = r.buildImage(ctx, config); err != nil {
+			return "", err
+		}
+	} else if err = r.pullImage(ctx, config.Image, config.Architecture); err != nil {
 		return "", fmt.Errorf("pulling image failed: %w", err)
 	}
 
@@ -165,9 +236,21 @@ func (r *Runtime) StartContainer(ctx context.Context, config *RuntimeConfig) (co
 		return "", fmt.Errorf("creating container failed: %w", err)
 	}
 
+	// The container reaches the Runtime API through the host gateway, so it
+	// listens on every interface, as the published port of the emulator did.
+	extensions := r.countExtensions(ctx, containerID, config)
+	api, err := startAPI(config, ":"+config.Port, extensions, &processLogs{}, r.logger)
+	if err != nil {
+		_ = r.DeleteContainer(context.WithoutCancel(ctx), containerID)
+		return "", err
+	}
+	apis.Store(containerID, api)
+
 	if err = r.startContainer(ctx, containerID); err != nil {
+		_ = r.DeleteContainer(context.WithoutCancel(ctx), containerID)
 		return "", fmt.Errorf("starting container failed: %w", err)
 	}
+	go r.wait(containerID, api)
 
 	return containerID, nil
 }
@@ -231,42 +314,71 @@ func (r *Runtime) pullImage(ctx context.Context, imageName, arch string) error {
 func (r *Runtime) createContainer(ctx context.Context, config *RuntimeConfig) (string, error) {
 	r.logger.Info("checking for dangling containers")
 
-	if err := r.CleanContainerEnvironment(ctx, config.Name); err != nil {
+	if err := r.CleanContainerEnvironment(ctx, config.Name, config.Port); err != nil {
 		return "", fmt.Errorf("failed to clean container environment: %w", err)
 	}
 
-	absCodePath, err := filepath.Abs(config.CodePath)
+	containerName := fmt.Sprintf("%s-%s", config.Name, uuid.NewString())
+
+	shimFile, err := writeShim()
 	if err != nil {
-		return "", fmt.Errorf("failed to resolve code path: %w", err)
+		return "", err
 	}
+	command, err := r.command(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	env := lambdaEnvironment(config)
+	env["AWS_LAMBDA_RUNTIME_API"] = HostGateway + ":" + config.Port
 
-	containerName := fmt.Sprintf("%s-%s", config.Name, uuid.NewString())
-
+	// The shim starts the extensions and then the image's entrypoint, which
+	// runs the runtime against simla's Runtime API instead of the emulator.
 	containerConfig := &container.Config{
-		Image:        config.Image,
-		Cmd:          config.Cmd,
-		Entrypoint:   config.Entrypoint,
-		Env:          formatEnvVars(config.Environment),
-		ExposedPorts: nat.PortSet{nat.Port("8080/tcp"): struct{}{}},
-		Labels:       map[string]string{"simla": "true"},
+		Image:      config.Image,
+		Entrypoint: []string{"/bin/sh", shimPath},
+		Cmd:        command,
+		Env:        formatEnvVars(env),
+		Labels: map[string]string{
+			"simla":   "true",
+			labelName: config.Name,
+			labelPort: config.Port,
+		},
+	}
+
+	// Built images carry their own code; others mount CodePath.
+	mounts := []mount.Mount{{
+		Type:     mount.TypeBind,
+		Source:   shimFile,
+		Target:   shimPath,
+		ReadOnly: true,
+	}}
+	if !config.imageBuild() {
+		absCodePath, err := filepath.Abs(config.CodePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve code path: %w", err)
+		}
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeBind,
+			Source: absCodePath,
+			Target: taskRoot,
+		})
+	}
+	if config.LayersPath != "" {
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   config.LayersPath,
+			Target:   optDir,
+			ReadOnly: true,
+		})
 	}
 
 	hostConfig := &container.HostConfig{
-		Mounts: []mount.Mount{
-			{
-				Type:   mount.TypeBind,
-				Source: absCodePath,
-				Target: "/var/task",
-			},
-		},
-		PortBindings: nat.PortMap{
-			nat.Port(InternalPort + "/tcp"): []nat.PortBinding{
-				{
-					HostIP:   "0.0.0.0",
-					HostPort: config.Port,
-				},
-			},
-		},
+		Mounts:    mounts,
+		Resources: resources(config.MemorySize),
+		Tmpfs:     tmpfs(config.EphemeralStorage),
+		// Docker Desktop resolves host.docker.internal on its own; Linux
+		// engines need it mapped to the bridge gateway.
+		ExtraHosts: []string{HostGateway + ":host-gateway"},
 	}
 
 	if err := r.createNetwork(ctx); err != nil {
@@ -296,6 +408,104 @@ func (r *Runtime) createContainer(ctx context.Context, config *RuntimeConfig) (s
 	return resp.ID, nil
 }
 
+// countExtensions returns the number of extensions the shim will start in
+// the container: the executables in /opt/extensions, which hold the merged
+// layers when the service has any and the image's own files otherwise.
+func (r *Runtime) countExtensions(ctx context.Context, containerID string, config *RuntimeConfig) int {
+	if config.LayersPath != "" {
+		paths, err := extensionPaths(filepath.Join(config.LayersPath, "extensions"))
+		if err != nil {
+			r.logger.WithError(err).WithField("service", config.Name).Warn("failed to count extensions")
+		}
+		return len(paths)
+	}
+	// Most images have no /opt/extensions at all.
+	content, _, err := r.client.CopyFromContainer(ctx, containerID, optDir+"/extensions")
+	if err != nil {
+		return 0
+	}
+	defer content.Close()
+	return archivedExtensions(content)
+}
+
+// archivedExtensions returns the number of executables directly inside the
+// directory archived in the tar stream content.
+func archivedExtensions(content io.Reader) int {
+	n := 0
+	tr := tar.NewReader(content)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			return n
+		}
+		// Entries are named after the directory, as in extensions/telemetry.
+		name := strings.TrimSuffix(header.Name, "/")
+		if header.Typeflag == tar.TypeReg && strings.Count(name, "/") == 1 && header.Mode&0o111 != 0 {
+			n++
+		}
+	}
+}
+
+// command returns the command the shim runs in the container of config:
+// the service's entrypoint and cmd, each defaulting to the image's.
+func (r *Runtime) command(ctx context.Context, config *RuntimeConfig) ([]string, error) {
+	entrypoint, cmd := config.Entrypoint, config.Cmd
+	if len(entrypoint) == 0 || len(cmd) == 0 {
+		inspect, err := r.client.ImageInspect(ctx, config.Image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect image %s: %w", config.Image, err)
+		}
+		if inspect.Config != nil {
+			if len(entrypoint) == 0 {
+				entrypoint = inspect.Config.Entrypoint
+			}
+			if len(cmd) == 0 {
+				cmd = inspect.Config.Cmd
+			}
+		}
+	}
+	if len(entrypoint) == 0 && len(cmd) == 0 {
+		return nil, simlaerrors.NewRuntimeConfigError(fmt.Sprintf("service %s has no entrypoint or cmd to run", config.Name))
+	}
+	return slices.Concat(entrypoint, cmd), nil
+}
+
+// wait records the exit of the container with containerID in api, so
+// waiting and later invocations fail with Runtime.ExitError.
+func (r *Runtime) wait(containerID string, api *functionAPI) {
+	statusCh, errCh := r.client.ContainerWait(context.Background(), containerID, container.WaitConditionNotRunning)
+	select {
+	case status := <-statusCh:
+		api.api.Exited(fmt.Errorf("exit status %d", status.StatusCode))
+	case err := <-errCh:
+		r.logger.WithError(err).WithField("container_id", containerID).Debug("stopped waiting for container")
+	}
+}
+
+// resources limits a container to memoryMB of memory, without swap, and a
+// share of CPU proportional to it, as Lambda does. Zero leaves the container
+// unlimited.
+func resources(memoryMB int) container.Resources {
+	if memoryMB <= 0 {
+		return container.Resources{}
+	}
+	memory := int64(memoryMB) * 1024 * 1024
+	return container.Resources{
+		Memory:     memory,
+		MemorySwap: memory,
+		NanoCPUs:   int64(memoryMB) * 1e9 / memoryPerVCPU,
+	}
+}
+
+// tmpfs mounts a /tmp of sizeMB in the container. Zero keeps the image's
+// own /tmp.
+func tmpfs(sizeMB int) map[string]string {
+	if sizeMB <= 0 {
+		return nil
+	}
+	return map[string]string{"/tmp": fmt.Sprintf("rw,exec,size=%dm", sizeMB)}
+}
+
 // toV1Platform converts an architecture string to a v1.Platform pointer.
 // It assigns a predefined OS constant and the provided architecture.
 
@@ -371,6 +581,12 @@ func (r *Runtime) StopContainer(ctx context.Context, containerID string) error {
 	logger := r.logger.WithField("container_id", containerID)
 	logger.Info("stopping container")
 
+	api, ok := apis.LoadAndDelete(containerID)
+	if ok {
+		api.(*functionAPI).shutdown()
+		defer api.(*functionAPI).close()
+	}
+
 	if err := r.client.ContainerStop(ctx, containerID, container.StopOptions{
 		Timeout: &stopTimeout,
 	}); err != nil {
@@ -398,6 +614,10 @@ func (r *Runtime) DeleteContainer(ctx context.Context, containerID string) error
 	logger := r.logger.WithField("container_id", containerID)
 	logger.Info("deleting container")
 
+	if api, ok := apis.LoadAndDelete(containerID); ok {
+		defer api.(*functionAPI).close()
+	}
+
 	err := r.client.ContainerRemove(ctx, containerID, container.RemoveOptions{
 		Force: true,
 	})
@@ -419,14 +639,17 @@ func inferImageFromRuntime(runtime string) string {
 	return fmt.Sprintf(base, runtime)
 }
 
-func (r *Runtime) CleanContainerEnvironment(ctx context.Context, serviceName string) error {
+// CleanContainerEnvironment removes containers left over from an earlier run
+// that hold the host port of a service container about to be created. Other
+// containers of the service, serving its concurrent invocations, are kept.
+func (r *Runtime) CleanContainerEnvironment(ctx context.Context, serviceName, port string) error {
 	summary, err := r.client.ContainerList(ctx, container.ListOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to list containers: %w", err)
 	}
 
 	for _, container := range summary {
-		if strings.Contains(container.Names[0], serviceName) {
+		if isDangling(container.Labels, container.Names, serviceName, port) {
 			if err := r.DeleteContainer(ctx, container.ID); err != nil {
 				return fmt.Errorf("failed to delete container %s: %w", container.ID, err)
 			}
@@ -435,3 +658,12 @@ func (r *Runtime) CleanContainerEnvironment(ctx context.Context, serviceName str
 
 	return nil
 }
+
+// isDangling reports whether a container belongs to the service and holds
+// port. Containers created before simla labelled them are matched by name.
+func isDangling(labels map[string]string, names []string, serviceName, port string) bool {
+	if containerPort, ok := labels[labelPort]; ok {
+		return labels[labelName] == serviceName && containerPort == port
+	}
+	return len(names) > 0 && strings.HasPrefix(strings.TrimPrefix(names[0], "/"), serviceName+"-")
+}
@@ -0,0 +1,68 @@
+package runtime
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestampedEntries(t *testing.T) {
+	entries := timestampedEntries([]byte("2025-01-02T03:04:05.000000001Z processing order\n2025-01-02T03:04:06Z done"))
+	require.Len(t, entries, 2)
+	assert.Equal(t, time.Date(2025, 1, 2, 3, 4, 5, 1, time.UTC), entries[0].at)
+	assert.Equal(t, "processing order\n", string(entries[0].data))
+	assert.Equal(t, "done", string(entries[1].data))
+}
+
+func TestWriteShim(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path, err := writeShim()
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+
+	again, err := writeShim()
+	require.NoError(t, err)
+	assert.Equal(t, path, again)
+}
+
+func TestCountExtensions(t *testing.T) {
+	layers := t.TempDir()
+	dir := filepath.Join(layers, "extensions")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "telemetry"), []byte("#!/bin/sh"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("docs"), 0o644))
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	r := &Runtime{logger: logrus.NewEntry(logger)}
+	// The merged layers replace the image's /opt, so they are counted on
+	// the host.
+	assert.Equal(t, 1, r.countExtensions(context.Background(), "", &RuntimeConfig{LayersPath: layers}))
+}
+
+func TestArchivedExtensions(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, header := range []*tar.Header{
+		{Name: "extensions/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "extensions/telemetry", Typeflag: tar.TypeReg, Mode: 0o755},
+		{Name: "extensions/secrets", Typeflag: tar.TypeReg, Mode: 0o700},
+		{Name: "extensions/README", Typeflag: tar.TypeReg, Mode: 0o644},
+		{Name: "extensions/lib/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "extensions/lib/helper", Typeflag: tar.TypeReg, Mode: 0o755},
+	} {
+		require.NoError(t, tw.WriteHeader(header))
+	}
+	require.NoError(t, tw.Close())
+
+	assert.Equal(t, 2, archivedExtensions(&buf))
+}
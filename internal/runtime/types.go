@@ -27,6 +27,27 @@ type RuntimeConfig struct {
 	Entrypoint   []string
 	Environment  map[string]string
 	Port         string
+	// MemorySize is the container's memory limit in MB. CPU is allocated in
+	// proportion to it.
+	MemorySize int
+	// Timeout is the invocation timeout in seconds, reported to the function.
+	Timeout int
+	// EphemeralStorage is the size of the container's /tmp in MB.
+	EphemeralStorage int
+	// Region is the AWS region reported to the function.
+	Region string
+	// AccountID is the AWS account of the function's ARN.
+	AccountID string
+	// LayersPath is the directory of the service's merged layers, mounted
+	// read-only at /opt. Empty when the service has no layers.
+	LayersPath string
+	// Dockerfile and BuildContext define an image to build for the service
+	// in place of Image. Its code is part of the image, so CodePath is not
+	// mounted.
+	Dockerfile   string
+	BuildContext string
+	// BuildArgs are the build arguments of the image.
+	BuildArgs map[string]string
 }
 
 type RuntimeInterface interface {
@@ -34,6 +55,9 @@ type RuntimeInterface interface {
 	StopContainer(ctx context.Context, containerID string) error
 	DeleteContainer(ctx context.Context, containerID string) error
 	GetLogs(ctx context.Context, containerID string, follow bool) (io.ReadCloser, error)
+	// LogsSince returns the output the container has written since a point
+	// in time.
+	LogsSince(ctx context.Context, containerID string, since time.Time) ([]byte, error)
 	// StreamStartupLogs tails container logs for the given window duration and
 	// emits each line as a structured log entry. It is a best-effort helper
 	// called after StartContainer to surface early crash messages.
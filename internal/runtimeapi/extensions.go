@@ -0,0 +1,271 @@
+package runtimeapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Routes of the Extensions API.
+const (
+	registerPath           = "/2020-01-01/extension/register"
+	extensionNextPath      = "/2020-01-01/extension/event/next"
+	extensionInitErrorPath = "/2020-01-01/extension/init/error"
+	extensionExitErrorPath = "/2020-01-01/extension/exit/error"
+)
+
+// Headers of the Extensions API.
+const (
+	headerExtensionName       = "Lambda-Extension-Name"
+	headerExtensionIdentifier = "Lambda-Extension-Identifier"
+	headerExtensionEventID    = "Lambda-Extension-Event-Identifier"
+	headerExtensionErrorType  = "Lambda-Extension-Function-Error-Type"
+)
+
+// Events an extension may register for.
+const (
+	EventInvoke   = "INVOKE"
+	EventShutdown = "SHUTDOWN"
+)
+
+// Reasons given in SHUTDOWN events.
+const (
+	ShutdownSpindown = "spindown"
+	ShutdownTimeout  = "timeout"
+	ShutdownFailure  = "failure"
+)
+
+// extensionQueueSize bounds the events waiting for an extension that has
+// not asked for them yet.
+const extensionQueueSize = 16
+
+// extension is an extension registered with the server.
+type extension struct {
+	id     string
+	name   string
+	events []string
+	queue  chan []byte
+	// initialized is set once the extension has asked for its first event,
+	// ending its init phase.
+	initialized bool
+	// busy is set while the extension handles an event it was sent.
+	busy bool
+}
+
+// invokeEvent is the INVOKE event of the Extensions API.
+type invokeEvent struct {
+	EventType          string  `json:"eventType"`
+	DeadlineMs         int64   `json:"deadlineMs"`
+	RequestID          string  `json:"requestId"`
+	InvokedFunctionArn string  `json:"invokedFunctionArn"`
+	Tracing            tracing `json:"tracing"`
+}
+
+type tracing struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// shutdownEvent is the SHUTDOWN event of the Extensions API.
+type shutdownEvent struct {
+	EventType      string `json:"eventType"`
+	ShutdownReason string `json:"shutdownReason"`
+	DeadlineMs     int64  `json:"deadlineMs"`
+}
+
+func (s *Server) registerExtensionRoutes() {
+	s.router.HandleFunc(registerPath, s.handleRegister()).Methods(http.MethodPost)
+	s.router.HandleFunc(extensionNextPath, s.handleExtensionNext()).Methods(http.MethodGet)
+	s.router.HandleFunc(extensionInitErrorPath, s.handleExtensionError(true)).Methods(http.MethodPost)
+	s.router.HandleFunc(extensionExitErrorPath, s.handleExtensionError(false)).Methods(http.MethodPost)
+}
+
+// handleRegister registers an extension for the events in the request
+// body. Extensions can only register during the init phase.
+func (s *Server) handleRegister() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.Header.Get(headerExtensionName)
+		if name == "" {
+			writeStatus(w, http.StatusBadRequest, "Extension.InvalidRequest", "Missing "+headerExtensionName+" header")
+			return
+		}
+		var body struct {
+			Events []string `json:"events"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxErrorSize)).Decode(&body); err != nil {
+			writeStatus(w, http.StatusBadRequest, "Extension.InvalidRequest", "Invalid request body")
+			return
+		}
+		for _, event := range body.Events {
+			if event != EventInvoke && event != EventShutdown {
+				writeStatus(w, http.StatusBadRequest, "Extension.InvalidEventType", "Unknown event type "+event)
+				return
+			}
+		}
+
+		s.mu.Lock()
+		select {
+		case <-s.ready:
+			s.mu.Unlock()
+			writeStatus(w, http.StatusForbidden, "Extension.InvalidState", "Extensions can only register during init")
+			return
+		default:
+		}
+		ext := &extension{
+			id:     uuid.NewString(),
+			name:   name,
+			events: body.Events,
+			queue:  make(chan []byte, extensionQueueSize),
+		}
+		s.extensions[ext.id] = ext
+		s.notify()
+		s.mu.Unlock()
+
+		s.logger.WithFields(logrus.Fields{"extension": name, "events": body.Events}).Info("extension registered")
+		w.Header().Set(headerExtensionIdentifier, ext.id)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"functionName":    s.config.FunctionName,
+			"functionVersion": "$LATEST",
+			"handler":         s.config.Handler,
+		})
+	}
+}
+
+// handleExtensionNext blocks until the next event for the calling
+// extension. Asking for an event also reports the previous one handled.
+func (s *Server) handleExtensionNext() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		ext, ok := s.extensions[r.Header.Get(headerExtensionIdentifier)]
+		if !ok {
+			s.mu.Unlock()
+			writeStatus(w, http.StatusForbidden, "Extension.UnknownExtensionIdentifier", "Unknown extension identifier")
+			return
+		}
+		ext.busy = false
+		if !ext.initialized {
+			ext.initialized = true
+			s.checkReady()
+		}
+		s.notify()
+		s.mu.Unlock()
+
+		select {
+		case event := <-ext.queue:
+			w.Header().Set(headerExtensionEventID, uuid.NewString())
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(event)
+		case <-r.Context().Done():
+		}
+	}
+}
+
+// handleExtensionError records an error reported by an extension. An init
+// error fails the environment's init like one from the runtime.
+func (s *Server) handleExtensionError(init bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		ext, ok := s.extensions[r.Header.Get(headerExtensionIdentifier)]
+		s.mu.Unlock()
+		if !ok {
+			writeStatus(w, http.StatusForbidden, "Extension.UnknownExtensionIdentifier", "Unknown extension identifier")
+			return
+		}
+		errorType := r.Header.Get(headerExtensionErrorType)
+		if errorType == "" {
+			errorType = "Extension.Unknown"
+		}
+		if init {
+			r.Header.Set(headerErrorType, errorType)
+			s.initFailed(readErrorDocument(r, errorType))
+		} else {
+			body, _ := io.ReadAll(io.LimitReader(r.Body, maxErrorSize))
+			s.logger.WithFields(logrus.Fields{"extension": ext.name, "error_type": errorType}).Error(string(body))
+		}
+		writeStatus(w, http.StatusAccepted, "", "")
+	}
+}
+
+// dispatchInvoke sends the INVOKE event of inv to the extensions that
+// registered for it. s.mu must be held.
+func (s *Server) dispatchInvoke(inv *invocation) {
+	event, _ := json.Marshal(invokeEvent{
+		EventType:          EventInvoke,
+		DeadlineMs:         inv.deadline.UnixMilli(),
+		RequestID:          inv.id,
+		InvokedFunctionArn: s.config.FunctionARN,
+		Tracing:            tracing{Type: "X-Amzn-Trace-Id", Value: inv.traceID},
+	})
+	s.send(EventInvoke, event)
+}
+
+// send queues event for every extension registered for eventType and marks
+// them busy until they ask for their next event. s.mu must be held.
+func (s *Server) send(eventType string, event []byte) int {
+	sent := 0
+	for _, ext := range s.extensions {
+		if !ext.initialized || !slices.Contains(ext.events, eventType) {
+			continue
+		}
+		select {
+		case ext.queue <- event:
+			ext.busy = true
+			sent++
+		default:
+			s.logger.WithField("extension", ext.name).Warnf("extension is not reading events; dropping %s", eventType)
+		}
+	}
+	return sent
+}
+
+// extensionsIdle reports whether no extension is handling an event. s.mu
+// must be held.
+func (s *Server) extensionsIdle() bool {
+	for _, ext := range s.extensions {
+		if ext.busy {
+			return false
+		}
+	}
+	return true
+}
+
+// AwaitExtensions blocks until n extensions have registered, ctx is done or
+// the runtime exits, and reports whether they all registered. Starting the
+// runtime only then runs the extension init phase before the runtime's, as
+// in Lambda.
+func (s *Server) AwaitExtensions(ctx context.Context, n int) bool {
+	return s.waitFor(ctx, func() bool { return len(s.extensions) >= n })
+}
+
+// Shutdown sends a SHUTDOWN event with reason to the extensions registered
+// for it and waits until they have handled it, the runtime exits or ctx is
+// done. The deadline of ctx is reported to the extensions.
+func (s *Server) Shutdown(ctx context.Context, reason string) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(2 * time.Second)
+	}
+	event, _ := json.Marshal(shutdownEvent{
+		EventType:      EventShutdown,
+		ShutdownReason: reason,
+		DeadlineMs:     deadline.UnixMilli(),
+	})
+
+	s.mu.Lock()
+	sent := s.send(EventShutdown, event)
+	s.mu.Unlock()
+	if sent == 0 {
+		return
+	}
+	s.logger.WithField("reason", reason).Debugf("sent shutdown to %d extension(s)", sent)
+	s.waitFor(ctx, s.extensionsIdle)
+}
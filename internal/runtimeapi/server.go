@@ -0,0 +1,558 @@
+// Package runtimeapi serves the Lambda Runtime API and Extensions API for a
+// function that simla runs itself, in place of a runtime interface emulator.
+//
+// The function's runtime polls /2018-06-01/runtime/invocation/next for
+// events and posts results to .../response or .../error, as it does in
+// Lambda. The same server accepts invocations on the emulator's endpoint,
+// /2015-03-31/functions/function/invocations, so the scheduler and the
+// health checker talk to it exactly as they talk to a container.
+//
+// A Server models the lifecycle of an execution environment: the init
+// phase ends once the runtime and every registered extension have asked for
+// their first event, each invoke is reported with its duration, billed
+// duration and, for the first one, the init duration, and Shutdown gives
+// extensions a SHUTDOWN event before the environment is stopped.
+package runtimeapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// Routes of the server.
+const (
+	InvokePath    = "/2015-03-31/functions/function/invocations"
+	nextPath      = "/2018-06-01/runtime/invocation/next"
+	responsePath  = "/2018-06-01/runtime/invocation/{id}/response"
+	errorPath     = "/2018-06-01/runtime/invocation/{id}/error"
+	initErrorPath = "/2018-06-01/runtime/init/error"
+)
+
+// Headers of the Runtime API.
+const (
+	headerRequestID     = "Lambda-Runtime-Aws-Request-Id"
+	headerDeadline      = "Lambda-Runtime-Deadline-Ms"
+	headerFunctionARN   = "Lambda-Runtime-Invoked-Function-Arn"
+	headerTraceID       = "Lambda-Runtime-Trace-Id"
+	headerClientContext = "Lambda-Runtime-Client-Context"
+	headerErrorType     = "Lambda-Runtime-Function-Error-Type"
+)
+
+// Headers of invocations. An invoker may choose the request ID with
+// HeaderInvokeRequestID; the response carries it along with
+// HeaderFunctionError when the function failed.
+const (
+	HeaderInvokeRequestID = "X-Amzn-RequestId"
+	HeaderFunctionError   = "X-Amz-Function-Error"
+)
+
+// maxErrorSize bounds the error documents a runtime may post.
+const maxErrorSize = 1 << 20
+
+// Config describes the function served by a Server.
+type Config struct {
+	// FunctionName, Handler and FunctionARN describe the function to the
+	// runtime and its extensions.
+	FunctionName string
+	Handler      string
+	FunctionARN  string
+	// Timeout sets the deadline reported with every event.
+	Timeout time.Duration
+	// MemorySize is reported in REPORT lines, in MB.
+	MemorySize int
+	// Extensions is the number of extensions started along with the
+	// runtime. The runtime's first request for an event waits until they
+	// have registered, for at most InitTimeout, as Lambda starts the
+	// runtime only after them.
+	Extensions  int
+	InitTimeout time.Duration
+	// Log receives the START, END and REPORT lines of every invocation, as
+	// Lambda writes them to the function's log stream. Nil discards them.
+	Log io.Writer
+}
+
+// Server is the Runtime API of one execution environment. It hands one
+// invocation at a time to the runtime.
+type Server struct {
+	config  Config
+	logger  *logrus.Entry
+	router  *mux.Router
+	started time.Time
+
+	invocations chan *invocation
+
+	mu           sync.Mutex
+	pending      map[string]*invocation
+	extensions   map[string]*extension
+	runtimeReady bool
+	ready        chan struct{}
+	exited       chan struct{}
+	changed      chan struct{}
+	initDuration time.Duration
+	initReported bool
+	initErr      []byte
+	exitErr      error
+}
+
+// invocation is an event waiting for, or being handled by, the runtime.
+type invocation struct {
+	id         string
+	payload    []byte
+	header     http.Header
+	ctx        context.Context
+	results    chan result
+	deadline   time.Time
+	traceID    string
+	dispatched time.Time
+}
+
+// result is the outcome of an invocation: a response body the invoker
+// streams until it closes done, or an error document.
+type result struct {
+	body io.Reader
+	done chan struct{}
+	doc  []byte
+}
+
+// NewServer returns a Server for the function described by config. Its init
+// phase starts now.
+func NewServer(config Config, logger *logrus.Entry) *Server {
+	if config.Log == nil {
+		config.Log = io.Discard
+	}
+	s := &Server{
+		config:      config,
+		logger:      logger.WithField("component", "runtime-api"),
+		router:      mux.NewRouter(),
+		started:     time.Now(),
+		invocations: make(chan *invocation),
+		pending:     make(map[string]*invocation),
+		extensions:  make(map[string]*extension),
+		ready:       make(chan struct{}),
+		exited:      make(chan struct{}),
+		changed:     make(chan struct{}),
+	}
+	s.router.HandleFunc(InvokePath, s.handleHealth()).Methods(http.MethodGet)
+	s.router.HandleFunc(InvokePath, s.handleInvoke()).Methods(http.MethodPost)
+	s.router.HandleFunc(nextPath, s.handleNext()).Methods(http.MethodGet)
+	s.router.HandleFunc(responsePath, s.handleResponse()).Methods(http.MethodPost)
+	s.router.HandleFunc(errorPath, s.handleError()).Methods(http.MethodPost)
+	s.router.HandleFunc(initErrorPath, s.handleInitError()).Methods(http.MethodPost)
+	s.registerExtensionRoutes()
+	return s
+}
+
+// Handler returns the HTTP handler of the server.
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// Exited records that the runtime has exited with err. Invocations waiting
+// on it, and all later ones, fail with Runtime.ExitError.
+func (s *Server) Exited(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.exited:
+		return
+	default:
+	}
+	if err == nil {
+		err = errors.New("exit status 0")
+	}
+	s.exitErr = err
+	close(s.exited)
+	s.notify()
+}
+
+// handleHealth reports the environment healthy once its init phase is over,
+// whether it succeeded or not; invocations are answered from then on.
+func (s *Server) handleHealth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-s.ready:
+		case <-s.exited:
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// handleInvoke hands the request body to the runtime as an event and
+// writes back its response or error document.
+func (s *Server) handleInvoke() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "could not read request body", http.StatusBadRequest)
+			return
+		}
+		id := r.Header.Get(HeaderInvokeRequestID)
+		if id == "" {
+			id = uuid.NewString()
+		}
+		inv := &invocation{
+			id:      id,
+			payload: payload,
+			header:  r.Header,
+			ctx:     r.Context(),
+			results: make(chan result, 1),
+		}
+		w.Header().Set(HeaderInvokeRequestID, inv.id)
+		logger := s.logger.WithField("request_id", inv.id)
+
+		if doc := s.failure(inv.id); doc != nil {
+			writeDocument(w, doc)
+			return
+		}
+		// The environment is reused only once extensions are done with the
+		// previous event.
+		if !s.waitFor(r.Context(), s.extensionsIdle) {
+			if doc := s.failure(inv.id); doc != nil {
+				writeDocument(w, doc)
+			}
+			return
+		}
+
+		select {
+		case s.invocations <- inv:
+		case <-s.exited:
+			writeDocument(w, s.failure(inv.id))
+			return
+		case <-r.Context().Done():
+			return
+		}
+
+		select {
+		case res := <-inv.results:
+			if res.doc != nil {
+				writeDocument(w, res.doc)
+				s.report(inv)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			if _, err := io.Copy(flushWriter{w}, res.body); err != nil {
+				logger.WithError(err).Warn("failed to relay function response")
+			}
+			close(res.done)
+			s.report(inv)
+		case <-s.exited:
+			writeDocument(w, s.failure(inv.id))
+			s.report(inv)
+		case <-r.Context().Done():
+		}
+	}
+}
+
+// handleNext blocks until an invocation arrives and returns it to the
+// runtime as the next event. The first call ends the runtime's init.
+func (s *Server) handleNext() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.awaitExtensions(r.Context())
+		s.mu.Lock()
+		s.runtimeReady = true
+		s.checkReady()
+		s.mu.Unlock()
+
+		for {
+			var inv *invocation
+			select {
+			case inv = <-s.invocations:
+			case <-r.Context().Done():
+				return
+			}
+			// The invoker may have given up while the event was queued.
+			if inv.ctx.Err() != nil {
+				continue
+			}
+
+			now := time.Now()
+			s.mu.Lock()
+			inv.dispatched = now
+			inv.deadline = now.Add(s.config.Timeout)
+			inv.traceID = traceID(now)
+			s.pending[inv.id] = inv
+			s.dispatchInvoke(inv)
+			s.mu.Unlock()
+			fmt.Fprintf(s.config.Log, "START RequestId: %s Version: $LATEST\n", inv.id)
+
+			w.Header().Set(headerRequestID, inv.id)
+			w.Header().Set(headerDeadline, strconv.FormatInt(inv.deadline.UnixMilli(), 10))
+			w.Header().Set(headerFunctionARN, s.config.FunctionARN)
+			w.Header().Set(headerTraceID, inv.traceID)
+			if clientContext := inv.header.Get("X-Amz-Client-Context"); clientContext != "" {
+				w.Header().Set(headerClientContext, clientContext)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(inv.payload)
+			return
+		}
+	}
+}
+
+// awaitExtensions holds the runtime during init until the extensions
+// started with it have registered, or InitTimeout has passed, since
+// extensions cannot register once the runtime has asked for an event.
+func (s *Server) awaitExtensions(ctx context.Context) {
+	if s.config.Extensions == 0 {
+		return
+	}
+	select {
+	case <-s.ready:
+		return
+	default:
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.config.InitTimeout)
+	defer cancel()
+	if !s.AwaitExtensions(ctx, s.config.Extensions) && ctx.Err() == context.DeadlineExceeded {
+		s.logger.Warn("extensions did not register in time; starting the runtime anyway")
+	}
+}
+
+// handleResponse relays the runtime's response to the waiting invoker as it
+// is written.
+func (s *Server) handleResponse() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inv, ok := s.take(mux.Vars(r)["id"])
+		if !ok {
+			writeStatus(w, http.StatusBadRequest, "InvalidRequestID", "Invalid request ID")
+			return
+		}
+		done := make(chan struct{})
+		inv.results <- result{body: r.Body, done: done}
+		select {
+		case <-done:
+		case <-inv.ctx.Done():
+		}
+		writeStatus(w, http.StatusAccepted, "", "")
+	}
+}
+
+// handleError returns the runtime's error document to the waiting invoker.
+func (s *Server) handleError() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inv, ok := s.take(mux.Vars(r)["id"])
+		if !ok {
+			writeStatus(w, http.StatusBadRequest, "InvalidRequestID", "Invalid request ID")
+			return
+		}
+		inv.results <- result{doc: readErrorDocument(r, "Unhandled")}
+		writeStatus(w, http.StatusAccepted, "", "")
+	}
+}
+
+// handleInitError records the error the runtime failed to initialise with.
+// Every invocation returns it from then on.
+func (s *Server) handleInitError() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.initFailed(readErrorDocument(r, "Runtime.InitError"))
+		writeStatus(w, http.StatusAccepted, "", "")
+	}
+}
+
+// initFailed ends the init phase with the error document doc.
+func (s *Server) initFailed(doc []byte) {
+	s.logger.WithField("error", string(doc)).Error("function failed to initialise")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.initErr == nil {
+		s.initErr = doc
+	}
+	s.endInit()
+}
+
+// checkReady ends the init phase once the runtime and every extension have
+// asked for their first event. s.mu must be held.
+func (s *Server) checkReady() {
+	if !s.runtimeReady {
+		return
+	}
+	for _, ext := range s.extensions {
+		if !ext.initialized {
+			return
+		}
+	}
+	s.endInit()
+}
+
+// endInit closes the init phase and records its duration. s.mu must be
+// held.
+func (s *Server) endInit() {
+	select {
+	case <-s.ready:
+		return
+	default:
+	}
+	s.initDuration = time.Since(s.started)
+	close(s.ready)
+	s.notify()
+	s.logger.WithField("init_duration", s.initDuration.Round(time.Millisecond)).Debug("init phase complete")
+}
+
+// report writes the END and REPORT lines of inv. The first invocation of
+// the environment also reports its init duration.
+func (s *Server) report(inv *invocation) {
+	s.mu.Lock()
+	if inv.dispatched.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	duration := time.Since(inv.dispatched)
+	billed := (duration + time.Millisecond - 1) / time.Millisecond
+	var initDuration time.Duration
+	if !s.initReported {
+		initDuration, s.initReported = s.initDuration, true
+	}
+	s.mu.Unlock()
+
+	line := fmt.Sprintf("REPORT RequestId: %s\tDuration: %.2f ms\tBilled Duration: %d ms\tMemory Size: %d MB\t",
+		inv.id, milliseconds(duration), billed, s.config.MemorySize)
+	if initDuration > 0 {
+		line += fmt.Sprintf("Init Duration: %.2f ms\t", milliseconds(initDuration))
+	}
+	fmt.Fprintf(s.config.Log, "END RequestId: %s\n%s\n", inv.id, line)
+
+	fields := logrus.Fields{"request_id": inv.id, "duration": duration.Round(time.Microsecond), "billed_duration_ms": int64(billed)}
+	if initDuration > 0 {
+		fields["init_duration"] = initDuration.Round(time.Microsecond)
+	}
+	s.logger.WithFields(fields).Info("invocation report")
+}
+
+// failure returns the error document of an invocation that cannot run
+// because the environment failed to initialise or its runtime has exited,
+// or nil.
+func (s *Server) failure(requestID string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.initErr != nil {
+		return s.initErr
+	}
+	if s.exitErr != nil {
+		doc, _ := json.Marshal(map[string]string{
+			"errorType":    "Runtime.ExitError",
+			"errorMessage": fmt.Sprintf("RequestId: %s Error: Runtime exited with error: %v", requestID, s.exitErr),
+		})
+		return doc
+	}
+	return nil
+}
+
+// take removes and returns the pending invocation with id.
+func (s *Server) take(id string) (*invocation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv, ok := s.pending[id]
+	delete(s.pending, id)
+	return inv, ok
+}
+
+// notify wakes up everyone in waitFor. s.mu must be held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// waitFor blocks until cond, called with s.mu held, is true. It returns
+// false if ctx is done or the runtime exits first.
+func (s *Server) waitFor(ctx context.Context, cond func() bool) bool {
+	for {
+		s.mu.Lock()
+		ok := cond()
+		changed := s.changed
+		s.mu.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-s.exited:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// readErrorDocument returns the error document posted by the runtime. A
+// body that is not one is wrapped in a document of errorType, or of the
+// type in the Lambda-Runtime-Function-Error-Type header.
+func readErrorDocument(r *http.Request, errorType string) []byte {
+	body, _ := io.ReadAll(io.LimitReader(r.Body, maxErrorSize))
+	var doc struct {
+		ErrorMessage *string `json:"errorMessage"`
+		ErrorType    *string `json:"errorType"`
+	}
+	if err := json.Unmarshal(body, &doc); err == nil && doc.ErrorMessage != nil && doc.ErrorType != nil {
+		return body
+	}
+	if header := r.Header.Get(headerErrorType); header != "" {
+		errorType = header
+	}
+	wrapped, _ := json.Marshal(map[string]string{
+		"errorType":    errorType,
+		"errorMessage": string(bytes.TrimSpace(body)),
+	})
+	return wrapped
+}
+
+// writeDocument writes an error document as the response of a failed
+// invocation.
+func writeDocument(w http.ResponseWriter, doc []byte) {
+	w.Header().Set(HeaderFunctionError, "Unhandled")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(doc)
+}
+
+// writeStatus writes the status document the Runtime API answers runtime
+// requests with.
+func writeStatus(w http.ResponseWriter, status int, errorType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if errorType == "" {
+		_, _ = w.Write([]byte(`{"status":"OK"}`))
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{"errorType": errorType, "errorMessage": message})
+}
+
+// traceID returns an X-Ray trace header for a new invocation.
+func traceID(now time.Time) string {
+	id := uuid.New()
+	return fmt.Sprintf("Root=1-%08x-%x;Parent=%x;Sampled=0", now.Unix(), id[:12], id[8:])
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// flushWriter flushes every write so streamed responses reach the invoker
+// as the function writes them.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,343 @@
+package runtimeapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	return newTestServerWithLog(t, nil)
+}
+
+func newTestServerWithLog(t *testing.T, log io.Writer) (*Server, *httptest.Server) {
+	t.Helper()
+	return newTestServerWithConfig(t, func(c *Config) { c.Log = log })
+}
+
+func newTestServerWithConfig(t *testing.T, configure func(*Config)) (*Server, *httptest.Server) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	config := Config{
+		FunctionName: "orders",
+		Handler:      "main",
+		FunctionARN:  "arn:aws:lambda:us-east-1:000000000000:function:orders",
+		Timeout:      3 * time.Second,
+		MemorySize:   256,
+	}
+	configure(&config)
+	s := NewServer(config, logrus.NewEntry(logger))
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	// Release runtimes and extensions still long-polling for events, which
+	// Close would otherwise wait for.
+	t.Cleanup(ts.CloseClientConnections)
+	return s, ts
+}
+
+// next fetches the next event as a runtime would and returns its request
+// ID and payload.
+func next(t *testing.T, ts *httptest.Server) (string, string) {
+	t.Helper()
+	resp, err := http.Get(ts.URL + nextPath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "arn:aws:lambda:us-east-1:000000000000:function:orders", resp.Header.Get(headerFunctionARN))
+	assert.NotEmpty(t, resp.Header.Get(headerDeadline))
+	assert.True(t, strings.HasPrefix(resp.Header.Get(headerTraceID), "Root=1-"))
+	return resp.Header.Get(headerRequestID), string(body)
+}
+
+func invoke(t *testing.T, ts *httptest.Server, payload string) string {
+	t.Helper()
+	resp, err := http.Post(ts.URL+InvokePath, "application/json", strings.NewReader(payload))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	return string(body)
+}
+
+func health(t *testing.T, ts *httptest.Server) int {
+	t.Helper()
+	resp, err := http.Get(ts.URL + InvokePath)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestServer_Response(t *testing.T) {
+	_, ts := newTestServer(t)
+	assert.Equal(t, http.StatusServiceUnavailable, health(t, ts), "not ready before the runtime polls")
+
+	go func() {
+		id, payload := next(t, ts)
+		resp, err := http.Post(ts.URL+"/2018-06-01/runtime/invocation/"+id+"/response", "application/json",
+			strings.NewReader(`{"echo":`+payload+`}`))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	assert.Equal(t, `{"echo":{"id":1}}`, invoke(t, ts, `{"id":1}`))
+	assert.Equal(t, http.StatusOK, health(t, ts))
+}
+
+func TestServer_Report(t *testing.T) {
+	var log syncBuffer
+	_, ts := newTestServerWithLog(t, &log)
+	respond := func() {
+		id, _ := next(t, ts)
+		resp, err := http.Post(ts.URL+"/2018-06-01/runtime/invocation/"+id+"/response", "application/json", strings.NewReader(`{}`))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	go respond()
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+InvokePath, strings.NewReader(`{}`))
+	req.Header.Set(HeaderInvokeRequestID, "req-1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "req-1", resp.Header.Get(HeaderInvokeRequestID))
+
+	go respond()
+	invoke(t, ts, `{}`)
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	require.Len(t, lines, 6)
+	assert.Equal(t, "START RequestId: req-1 Version: $LATEST", lines[0])
+	assert.Equal(t, "END RequestId: req-1", lines[1])
+	assert.Regexp(t, `^REPORT RequestId: req-1\tDuration: [\d.]+ ms\tBilled Duration: \d+ ms\tMemory Size: 256 MB\tInit Duration: [\d.]+ ms`, lines[2])
+	assert.NotContains(t, lines[5], "Init Duration", "only the first invocation reports init")
+}
+
+func TestServer_Extensions(t *testing.T) {
+	s, ts := newTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+registerPath, strings.NewReader(`{"events":["INVOKE","SHUTDOWN"]}`))
+	req.Header.Set(headerExtensionName, "telemetry")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"functionName":"orders","functionVersion":"$LATEST","handler":"main"}`, string(body))
+	extID := resp.Header.Get(headerExtensionIdentifier)
+
+	events := make(chan string, 4)
+	extensionNext := func() {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+extensionNextPath, nil)
+		req.Header.Set(headerExtensionIdentifier, extID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		events <- string(body)
+	}
+
+	go func() {
+		id, _ := next(t, ts)
+		resp, err := http.Post(ts.URL+"/2018-06-01/runtime/invocation/"+id+"/response", "application/json", strings.NewReader(`{}`))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, health(t, ts), "init waits for the extension")
+
+	go extensionNext()
+	require.Eventually(t, func() bool { return health(t, ts) == http.StatusOK }, 2*time.Second, 10*time.Millisecond)
+	invoke(t, ts, `{}`)
+	assert.Contains(t, <-events, `"eventType":"INVOKE"`)
+
+	go extensionNext()
+	done := make(chan struct{})
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		s.Shutdown(ctx, ShutdownSpindown)
+		close(done)
+	}()
+	assert.Contains(t, <-events, `"shutdownReason":"spindown"`)
+	go extensionNext()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not return once the extension handled SHUTDOWN")
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, ts.URL+registerPath, strings.NewReader(`{"events":["INVOKE"]}`))
+	req.Header.Set(headerExtensionName, "late")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "extensions register during init only")
+}
+
+// register registers an extension for events and returns its identifier,
+// or the status the server refused it with.
+func register(t *testing.T, ts *httptest.Server, name, events string) (string, int) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+registerPath, strings.NewReader(events))
+	req.Header.Set(headerExtensionName, name)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.Header.Get(headerExtensionIdentifier), resp.StatusCode
+}
+
+func TestServer_AwaitsStartedExtensions(t *testing.T) {
+	// In a container the shim starts the extensions and runs the runtime at
+	// once, so the runtime usually asks for its first event before they
+	// register.
+	_, ts := newTestServerWithConfig(t, func(c *Config) {
+		c.Extensions = 1
+		c.InitTimeout = 5 * time.Second
+	})
+
+	responses := make(chan string, 1)
+	go func() {
+		id, payload := next(t, ts)
+		resp, err := http.Post(ts.URL+"/2018-06-01/runtime/invocation/"+id+"/response", "application/json", strings.NewReader(payload))
+		if err == nil {
+			resp.Body.Close()
+		}
+		responses <- payload
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	id, status := register(t, ts, "telemetry", `{"events":["INVOKE"]}`)
+	require.Equal(t, http.StatusOK, status, "init stays open for the started extension")
+	assert.Equal(t, http.StatusServiceUnavailable, health(t, ts))
+
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+extensionNextPath, nil)
+		req.Header.Set(headerExtensionIdentifier, id)
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	require.Eventually(t, func() bool { return health(t, ts) == http.StatusOK }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, `{"id":1}`, invoke(t, ts, `{"id":1}`))
+	assert.Equal(t, `{"id":1}`, <-responses)
+}
+
+func TestServer_AwaitsStartedExtensionsTimeout(t *testing.T) {
+	_, ts := newTestServerWithConfig(t, func(c *Config) {
+		c.Extensions = 1
+		c.InitTimeout = 100 * time.Millisecond
+	})
+
+	go func() {
+		id, _ := next(t, ts)
+		resp, err := http.Post(ts.URL+"/2018-06-01/runtime/invocation/"+id+"/response", "application/json", strings.NewReader(`"ok"`))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	// The extension never registers, so the runtime starts without it.
+	require.Eventually(t, func() bool { return health(t, ts) == http.StatusOK }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, `"ok"`, invoke(t, ts, `{}`))
+
+	_, status := register(t, ts, "late", `{"events":["INVOKE"]}`)
+	assert.Equal(t, http.StatusForbidden, status)
+}
+
+func TestServer_ExtensionInitError(t *testing.T) {
+	_, ts := newTestServer(t)
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+registerPath, strings.NewReader(`{"events":[]}`))
+	req.Header.Set(headerExtensionName, "broken")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	req, _ = http.NewRequest(http.MethodPost, ts.URL+extensionInitErrorPath, strings.NewReader("missing config"))
+	req.Header.Set(headerExtensionIdentifier, resp.Header.Get(headerExtensionIdentifier))
+	req.Header.Set(headerExtensionErrorType, "Extension.ConfigInvalid")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.JSONEq(t, `{"errorType":"Extension.ConfigInvalid","errorMessage":"missing config"}`, invoke(t, ts, `{}`))
+}
+
+func TestServer_Error(t *testing.T) {
+	_, ts := newTestServer(t)
+	go func() {
+		id, _ := next(t, ts)
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/2018-06-01/runtime/invocation/"+id+"/error", strings.NewReader("boom"))
+		req.Header.Set(headerErrorType, "ValueError")
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	assert.JSONEq(t, `{"errorType":"ValueError","errorMessage":"boom"}`, invoke(t, ts, `{}`))
+}
+
+func TestServer_InitError(t *testing.T) {
+	_, ts := newTestServer(t)
+	resp, err := http.Post(ts.URL+initErrorPath, "application/json",
+		bytes.NewReader([]byte(`{"errorType":"Runtime.ImportModuleError","errorMessage":"no module named app"}`)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	assert.Equal(t, http.StatusOK, health(t, ts))
+	assert.JSONEq(t, `{"errorType":"Runtime.ImportModuleError","errorMessage":"no module named app"}`, invoke(t, ts, `{}`))
+}
+
+func TestServer_Exited(t *testing.T) {
+	s, ts := newTestServer(t)
+	s.Exited(errors.New("exit status 2"))
+
+	assert.Equal(t, http.StatusOK, health(t, ts))
+	body := invoke(t, ts, `{}`)
+	assert.Contains(t, body, `"errorType":"Runtime.ExitError"`)
+	assert.Contains(t, body, "Runtime exited with error: exit status 2")
+}
+
+func TestServer_UnknownRequestID(t *testing.T) {
+	_, ts := newTestServer(t)
+	resp, err := http.Post(ts.URL+"/2018-06-01/runtime/invocation/nope/response", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
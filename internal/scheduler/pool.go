@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+// pools tracks which containers of each service are serving an invocation.
+// The gateway, the Lambda API, the queue and the triggers each hold their own
+// Scheduler, so the state is shared by all of them.
+var pools = newPoolSet()
+
+// poolSet holds the busy state of every service's containers. Slot 0 is the
+// service's first container; slot n is its (n-1)th registry instance.
+type poolSet struct {
+	mu    sync.Mutex
+	slots map[string][]slot
+}
+
+type slot struct {
+	busy bool
+	// lastUsed is when the container last finished an invocation or was
+	// started. It is zero for containers that were never started.
+	lastUsed time.Time
+}
+
+func newPoolSet() *poolSet {
+	return &poolSet{slots: make(map[string][]slot)}
+}
+
+// acquire marks the first idle slot of service below limit as busy and
+// returns it. It returns false when all limit slots are busy.
+func (p *poolSet) acquire(service string, limit int) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slots := p.slots[service]
+	for i := 0; i < limit; i++ {
+		if i == len(slots) {
+			slots = append(slots, slot{})
+		}
+		if !slots[i].busy {
+			slots[i].busy = true
+			p.slots[service] = slots
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// release marks slot i of service as idle again.
+func (p *poolSet) release(service string, i int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if slots := p.slots[service]; i < len(slots) {
+		slots[i].busy = false
+		slots[i].lastUsed = time.Now()
+	}
+}
+
+// touch records that the container in slot i of service was just started.
+func (p *poolSet) touch(service string, i int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	slots := p.slots[service]
+	for len(slots) <= i {
+		slots = append(slots, slot{})
+	}
+	slots[i].lastUsed = time.Now()
+	p.slots[service] = slots
+}
+
+// reserveIdle marks the slots of service that have not been used since
+// cutoff as busy, so no invocation picks them while they are stopped, and
+// returns them. The caller releases them when done.
+func (p *poolSet) reserveIdle(service string, cutoff time.Time) []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var idle []int
+	slots := p.slots[service]
+	for i := range slots {
+		if slots[i].busy || slots[i].lastUsed.IsZero() || slots[i].lastUsed.After(cutoff) {
+			continue
+		}
+		slots[i].busy = true
+		idle = append(idle, i)
+	}
+	return idle
+}
+
+// forget releases slot i of service after its container was stopped, so it
+// is not reaped again before it next serves an invocation.
+func (p *poolSet) forget(service string, i int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if slots := p.slots[service]; i < len(slots) {
+		slots[i] = slot{}
+	}
+}
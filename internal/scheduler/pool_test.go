@@ -0,0 +1,281 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/nyambati/simla/internal/config"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
+	"github.com/nyambati/simla/internal/mocks"
+	"github.com/nyambati/simla/internal/registry"
+	"github.com/nyambati/simla/internal/runtime"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestPoolSet_AcquireAndRelease(t *testing.T) {
+	p := newPoolSet()
+
+	first, ok := p.acquire("orders", 2)
+	require.True(t, ok)
+	second, ok := p.acquire("orders", 2)
+	require.True(t, ok)
+	assert.Equal(t, 0, first)
+	assert.Equal(t, 1, second)
+
+	_, ok = p.acquire("orders", 2)
+	assert.False(t, ok, "pool is saturated")
+
+	// Other services have their own pool.
+	_, ok = p.acquire("payments", 2)
+	assert.True(t, ok)
+
+	p.release("orders", first)
+	slot, ok := p.acquire("orders", 2)
+	require.True(t, ok)
+	assert.Equal(t, first, slot, "the idle slot is reused")
+
+	_, ok = p.acquire("audit", 0)
+	assert.False(t, ok, "a reserved concurrency of 0 throttles every invocation")
+}
+
+type poolTest struct {
+	scheduler *Scheduler
+	registry  *mocks.MockServiceRegistryInterface
+	health    *mocks.MockHealthCheckerInterface
+	router    *mocks.MockRouterInterface
+}
+
+func newPoolTest(t *testing.T, svc config.Service) *poolTest {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	pt := &poolTest{
+		registry: mocks.NewMockServiceRegistryInterface(ctrl),
+		health:   mocks.NewMockHealthCheckerInterface(ctrl),
+		router:   mocks.NewMockRouterInterface(ctrl),
+	}
+	pt.scheduler = &Scheduler{
+		registry: pt.registry,
+		health:   pt.health,
+		router:   pt.router,
+		logger:   logrus.NewEntry(logger),
+		config:   &config.Config{Services: map[string]config.Service{"orders": svc}},
+		pools:    newPoolSet(),
+	}
+	return pt
+}
+
+func TestScheduler_InvokeScalesOut(t *testing.T) {
+	pt := newPoolTest(t, config.Service{ReservedConcurrency: intPtr(2)})
+	ctx := context.WithValue(context.Background(), "service", "orders")
+
+	// The service's own container is busy with another invocation.
+	busy, ok := pt.scheduler.pools.acquire("orders", 2)
+	require.True(t, ok)
+
+	pt.registry.EXPECT().AddService(gomock.Any(), "orders").
+		Return(&registry.Service{Name: "orders", Port: 9000, Status: registry.StatusRunning, Healthy: true}, nil)
+	pt.registry.EXPECT().AddInstance(gomock.Any(), "orders", 0).
+		Return(&registry.Instance{ID: "c2", Port: 9005, Status: registry.StatusRunning, Healthy: true}, nil)
+	pt.health.EXPECT().IsHealthy(gomock.Any(), &registry.Service{Name: "orders", Port: 9005}).Return(true, nil)
+	pt.router.EXPECT().
+		SendRequest(gomock.Any(), "http://localhost:9005/2015-03-31/functions/function/invocations", gomock.Any(), []byte(`{}`)).
+		Return([]byte(`"ok"`), 200, nil)
+
+	response, err := pt.scheduler.Invoke(ctx, "orders", []byte(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, `"ok"`, string(response))
+
+	// The instance is released once the invocation returns.
+	pt.scheduler.pools.release("orders", busy)
+	slot, ok := pt.scheduler.pools.acquire("orders", 2)
+	require.True(t, ok)
+	assert.Equal(t, 0, slot)
+	slot, ok = pt.scheduler.pools.acquire("orders", 2)
+	require.True(t, ok)
+	assert.Equal(t, 1, slot)
+}
+
+func TestScheduler_InvokeThrottles(t *testing.T) {
+	pt := newPoolTest(t, config.Service{ReservedConcurrency: intPtr(1)})
+	ctx := context.WithValue(context.Background(), "service", "orders")
+
+	_, ok := pt.scheduler.pools.acquire("orders", 1)
+	require.True(t, ok)
+
+	_, err := pt.scheduler.Invoke(ctx, "orders", []byte(`{}`))
+	var throttled *simlaerrors.TooManyRequestsError
+	require.True(t, errors.As(err, &throttled))
+	assert.Equal(t, 1, throttled.Limit)
+
+	_, err = pt.scheduler.InvokeStream(ctx, "orders", []byte(`{}`))
+	assert.True(t, errors.As(err, &throttled))
+
+	m, ok := GlobalMetrics.Get("orders")
+	require.True(t, ok)
+	assert.GreaterOrEqual(t, m.Throttles, int64(2))
+}
+
+func TestScheduler_InvokeReleasesSlotOnFailure(t *testing.T) {
+	pt := newPoolTest(t, config.Service{ReservedConcurrency: intPtr(1)})
+	ctx := context.WithValue(context.Background(), "service", "orders")
+
+	pt.registry.EXPECT().AddService(gomock.Any(), "orders").Return(nil, errors.New("registry unavailable")).Times(2)
+
+	_, err := pt.scheduler.Invoke(ctx, "orders", []byte(`{}`))
+	require.Error(t, err)
+	_, err = pt.scheduler.InvokeStream(ctx, "orders", []byte(`{}`))
+	require.Error(t, err)
+
+	_, ok := pt.scheduler.pools.acquire("orders", 1)
+	assert.True(t, ok, "failed invocations must not keep their slot")
+}
+
+func TestScheduler_InvokeTimesOut(t *testing.T) {
+	pt := newPoolTest(t, config.Service{Timeout: 1})
+	ctx := context.WithValue(context.Background(), "service", "orders")
+
+	pt.registry.EXPECT().AddService(gomock.Any(), "orders").
+		Return(&registry.Service{Name: "orders", Port: 9000, Status: registry.StatusRunning, Healthy: true}, nil)
+	pt.health.EXPECT().IsHealthy(gomock.Any(), gomock.Any()).Return(true, nil)
+	pt.router.EXPECT().SendRequest(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ string, _ map[string]string, _ []byte) ([]byte, int, error) {
+			<-ctx.Done()
+			return nil, 408, simlaerrors.NewTimeoutError("orders")
+		})
+	// The container already exited, so there is nothing left to stop.
+	pt.registry.EXPECT().GetService(gomock.Any(), "orders").
+		Return(&registry.Service{Name: "orders", Status: registry.StatusStopped}, true)
+
+	response, err := pt.scheduler.Invoke(ctx, "orders", []byte(`{}`))
+	require.NoError(t, err)
+	assert.True(t, IsFunctionError(response))
+	assert.JSONEq(t, `{"errorType":"Sandbox.Timedout","errorMessage":"Task timed out after 1.00 seconds"}`, string(response))
+}
+
+// blockingStream is a response stream that has written first and blocks
+// until ctx is done, as a function running past its timeout does.
+type blockingStream struct {
+	ctx   context.Context
+	first []byte
+}
+
+func (b *blockingStream) Read(p []byte) (int, error) {
+	if len(b.first) > 0 {
+		n := copy(p, b.first)
+		b.first = b.first[n:]
+		return n, nil
+	}
+	<-b.ctx.Done()
+	return 0, b.ctx.Err()
+}
+
+func (b *blockingStream) Close() error { return nil }
+
+// expectTimedOutStop expects the running container of orders to be stopped.
+func (pt *poolTest) expectTimedOutStop() {
+	gomock.InOrder(
+		pt.registry.EXPECT().GetService(gomock.Any(), "orders").
+			Return(&registry.Service{Name: "orders", ID: "process-runaway", Status: registry.StatusRunning}, true),
+		pt.registry.EXPECT().UpdateStatus(gomock.Any(), "orders", registry.StatusStopped),
+		pt.registry.EXPECT().UpdateHealth(gomock.Any(), "orders", false),
+	)
+}
+
+func TestScheduler_InvokeStreamTimesOut(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	pt := newPoolTest(t, config.Service{Timeout: 1, Backend: config.BackendProcess, ReservedConcurrency: intPtr(1)})
+	ctx := context.WithValue(context.Background(), "service", "orders")
+
+	pt.registry.EXPECT().AddService(gomock.Any(), "orders").
+		Return(&registry.Service{Name: "orders", Port: 9000, Status: registry.StatusRunning, Healthy: true}, nil)
+	pt.health.EXPECT().IsHealthy(gomock.Any(), gomock.Any()).Return(true, nil)
+	pt.router.EXPECT().SendStreamingRequest(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ string, _ map[string]string, _ []byte) (io.ReadCloser, int, error) {
+			return &blockingStream{ctx: ctx, first: []byte("partial")}, 200, nil
+		})
+	pt.expectTimedOutStop()
+
+	stream, err := pt.scheduler.InvokeStream(ctx, "orders", []byte(`{}`))
+	require.NoError(t, err)
+	body, err := io.ReadAll(stream)
+	assert.Equal(t, "partial", string(body))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NoError(t, stream.Close())
+
+	_, ok := pt.scheduler.pools.acquire("orders", 1)
+	assert.True(t, ok, "the slot is released once its function is stopped")
+}
+
+func TestScheduler_InvokeStreamTimesOutBeforeFirstByte(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	pt := newPoolTest(t, config.Service{Timeout: 1, Backend: config.BackendProcess})
+	ctx := context.WithValue(context.Background(), "service", "orders")
+
+	pt.registry.EXPECT().AddService(gomock.Any(), "orders").
+		Return(&registry.Service{Name: "orders", Port: 9000, Status: registry.StatusRunning, Healthy: true}, nil)
+	pt.health.EXPECT().IsHealthy(gomock.Any(), gomock.Any()).Return(true, nil)
+	pt.router.EXPECT().SendStreamingRequest(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ string, _ map[string]string, _ []byte) (io.ReadCloser, int, error) {
+			<-ctx.Done()
+			return nil, 408, simlaerrors.NewTimeoutError("orders")
+		})
+	pt.expectTimedOutStop()
+
+	_, err := pt.scheduler.InvokeStream(ctx, "orders", []byte(`{}`))
+	var invocationErr *simlaerrors.ServiceInvocationError
+	require.True(t, errors.As(err, &invocationErr))
+	assert.JSONEq(t, `{"errorType":"Sandbox.Timedout","errorMessage":"Task timed out after 1.00 seconds"}`, invocationErr.Body)
+}
+
+func TestScheduler_InvokeRestartsUnresponsiveService(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	pt := newPoolTest(t, config.Service{
+		Backend:    config.BackendProcess,
+		CodePath:   t.TempDir(),
+		Entrypoint: []string{"sleep", "30"},
+	})
+	ctx := context.WithValue(context.Background(), "service", "orders")
+
+	// The registry still says running, but the command that started the
+	// process has exited and taken its Runtime API with it.
+	pt.registry.EXPECT().AddService(gomock.Any(), "orders").
+		Return(&registry.Service{Name: "orders", Status: registry.StatusRunning, Healthy: true}, nil)
+	var started string
+	gomock.InOrder(
+		pt.health.EXPECT().IsHealthy(gomock.Any(), gomock.Any()).Return(false, errors.New("connection refused")),
+		pt.registry.EXPECT().GetService(gomock.Any(), "orders").
+			Return(&registry.Service{Name: "orders", ID: "process-gone", Status: registry.StatusRunning}, true),
+		pt.registry.EXPECT().UpdateStatus(gomock.Any(), "orders", registry.StatusStopped),
+		pt.registry.EXPECT().UpdateHealth(gomock.Any(), "orders", false),
+		pt.registry.EXPECT().GetService(gomock.Any(), "orders").
+			Return(&registry.Service{Name: "orders", Status: registry.StatusStopped}, true),
+		pt.health.EXPECT().WaitForHealthy(gomock.Any(), gomock.Any()).Return(nil),
+		pt.registry.EXPECT().UpdateStatus(gomock.Any(), "orders", registry.StatusRunning),
+		pt.registry.EXPECT().UpdateHealth(gomock.Any(), "orders", true),
+		pt.registry.EXPECT().UpdateContainerID(gomock.Any(), "orders", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, id string) error {
+				started = id
+				return nil
+			}),
+		pt.health.EXPECT().IsHealthy(gomock.Any(), gomock.Any()).Return(true, nil),
+		pt.router.EXPECT().SendRequest(gomock.Any(), gomock.Any(), gomock.Any(), []byte(`{}`)).
+			Return([]byte(`"ok"`), 200, nil),
+	)
+
+	response, err := pt.scheduler.Invoke(ctx, "orders", []byte(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, `"ok"`, string(response))
+
+	require.True(t, runtime.IsProcess(started), "the service was started again")
+	assert.NoError(t, runtime.NewProcessRuntime(pt.scheduler.logger).StopContainer(ctx, started))
+}
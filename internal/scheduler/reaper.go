@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/health"
+	"github.com/nyambati/simla/internal/registry"
+	"github.com/sirupsen/logrus"
+)
+
+// reapInterval is how often the reaper looks for idle containers.
+const reapInterval = 15 * time.Second
+
+// Reaper stops the containers of services that have gone without
+// invocations for longer than their idle timeout, so the next invocation
+// cold starts them as Lambda would.
+type Reaper struct {
+	scheduler *Scheduler
+	timeouts  map[string]time.Duration
+	logger    *logrus.Entry
+}
+
+// NewReaper validates the idle timeout of every service and returns a
+// reaper for their containers.
+func NewReaper(cfg *config.Config, reg registry.ServiceRegistryInterface, logger *logrus.Entry) (*Reaper, error) {
+	timeouts := make(map[string]time.Duration, len(cfg.Services))
+	for name, svc := range cfg.Services {
+		timeout, err := idleTimeout(svc)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		timeouts[name] = timeout
+	}
+
+	return &Reaper{
+		scheduler: &Scheduler{
+			registry: reg,
+			health:   health.NewHealthChecker(logger),
+			router:   NewRouter(logger),
+			logger:   logger,
+			config:   cfg,
+			pools:    pools,
+		},
+		timeouts: timeouts,
+		logger:   logger,
+	}, nil
+}
+
+// idleTimeout returns the idle timeout of svc, applying DefaultIdleTimeout.
+// Zero means its containers are never reaped.
+func idleTimeout(svc config.Service) (time.Duration, error) {
+	value := svc.IdleTimeout
+	if value == "" {
+		value = config.DefaultIdleTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid idleTimeout %q: %w", value, err)
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("idleTimeout must not be negative, got %s", value)
+	}
+	return timeout, nil
+}
+
+// Start reaps idle containers every reapInterval until ctx is done.
+func (r *Reaper) Start(ctx context.Context) error {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			r.reap(ctx, now)
+		}
+	}
+}
+
+// reap stops every container whose last invocation ended before its
+// service's idle timeout elapsed at now.
+func (r *Reaper) reap(ctx context.Context, now time.Time) {
+	for name, timeout := range r.timeouts {
+		if timeout == 0 {
+			continue
+		}
+		for _, slot := range r.scheduler.pools.reserveIdle(name, now.Add(-timeout)) {
+			if err := r.scheduler.stopSlot(ctx, name, slot, registry.StatusReclaimed); err != nil {
+				r.logger.WithError(err).WithField("service", name).Warn("failed to stop idle container")
+				r.scheduler.pools.release(name, slot)
+				continue
+			}
+			r.scheduler.pools.forget(name, slot)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/registry"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	timeout, err := idleTimeout(config.Service{})
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, timeout)
+
+	timeout, err = idleTimeout(config.Service{IdleTimeout: "0"})
+	require.NoError(t, err)
+	assert.Zero(t, timeout, "0 keeps containers until simla down")
+
+	_, err = idleTimeout(config.Service{IdleTimeout: "soon"})
+	assert.Error(t, err)
+	_, err = idleTimeout(config.Service{IdleTimeout: "-1m"})
+	assert.Error(t, err)
+}
+
+func TestPoolSet_ReserveIdle(t *testing.T) {
+	p := newPoolSet()
+
+	first, _ := p.acquire("orders", 3)
+	second, _ := p.acquire("orders", 3)
+	third, _ := p.acquire("orders", 3)
+	p.release("orders", first)
+	p.release("orders", second)
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	p.release("orders", third)
+
+	idle := p.reserveIdle("orders", cutoff)
+	assert.Equal(t, []int{first, second}, idle, "recently used containers are kept")
+
+	slot, ok := p.acquire("orders", 3)
+	require.True(t, ok)
+	assert.Equal(t, third, slot, "reaped slots are not handed to invocations")
+	p.release("orders", slot)
+
+	p.forget("orders", first)
+	p.release("orders", second)
+	assert.NotContains(t, p.reserveIdle("orders", time.Now()), first,
+		"a stopped container is not reaped again until it serves an invocation")
+}
+
+func TestReaper_SkipsStoppedContainers(t *testing.T) {
+	pt := newPoolTest(t, config.Service{})
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	r := &Reaper{
+		scheduler: pt.scheduler,
+		timeouts:  map[string]time.Duration{"orders": time.Minute},
+		logger:    logrus.NewEntry(logger),
+	}
+
+	slot, _ := pt.scheduler.pools.acquire("orders", 1)
+	pt.scheduler.pools.release("orders", slot)
+
+	// simla down already stopped the container.
+	pt.registry.EXPECT().GetService(gomock.Any(), "orders").
+		Return(&registry.Service{Name: "orders", Status: registry.StatusStopped}, true)
+
+	r.reap(context.Background(), time.Now().Add(2*time.Minute))
+
+	assert.Empty(t, pt.scheduler.pools.reserveIdle("orders", time.Now()))
+}
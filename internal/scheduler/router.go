@@ -14,8 +14,13 @@ import (
 
 func NewRouter(logger *logrus.Entry) RouterInterface {
 	return &Router{
-		client: &http.Client{Timeout: 10 * time.Second},
-		logger: logger.WithField("component", "router"),
+		// Invocations are bounded by their service's timeout, which the
+		// scheduler sets on the request context.
+		client: &http.Client{},
+		// http.Client.Timeout also bounds reading the body, so streamed
+		// responses rely on the request context for their deadline instead.
+		streamClient: &http.Client{},
+		logger:       logger.WithField("component", "router"),
 	}
 }
 
@@ -30,6 +35,71 @@ func (r *Router) SendRequest(
 	logger := r.logger.WithFields(logrus.Fields{"service": serviceName, "url": url})
 	logger.Info("sending request to service")
 
+	resp, statusCode, err := r.post(ctx, r.client, url, headers, payload, logger)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	defer resp.Body.Close()
+
+	logger.WithField("status_code", resp.StatusCode).Info("received response from service")
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.WithError(err).Error("failed to read response body")
+		return nil, resp.StatusCode, fmt.Errorf("router: failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		logger.Warn("service returned non-2xx response")
+		return nil, resp.StatusCode, simlaerrors.NewServiceInvocationError(serviceName, resp.StatusCode, string(body))
+	}
+	duration := time.Since(startTime)
+	logger.WithField("duration", duration).Info("request completed successfully")
+	return body, resp.StatusCode, nil
+}
+
+// SendStreamingRequest is SendRequest for responses that are relayed as they
+// arrive. On success the body is returned unread and the caller must close
+// it; non-2xx responses are read in full and reported as errors.
+func (r *Router) SendStreamingRequest(
+	ctx context.Context,
+	url string,
+	headers map[string]string,
+	payload []byte,
+) (io.ReadCloser, int, error) {
+	serviceName := ctx.Value("service").(string)
+	logger := r.logger.WithFields(logrus.Fields{"service": serviceName, "url": url})
+	logger.Info("sending streaming request to service")
+
+	resp, statusCode, err := r.post(ctx, r.streamClient, url, headers, payload, logger)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	logger.WithField("status_code", resp.StatusCode).Info("streaming response from service")
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		logger.Warn("service returned non-2xx response")
+		return nil, resp.StatusCode, simlaerrors.NewServiceInvocationError(serviceName, resp.StatusCode, string(body))
+	}
+	return resp.Body, resp.StatusCode, nil
+}
+
+// post sends payload to url with client. On failure it returns the status
+// code to report alongside the error.
+func (r *Router) post(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	headers map[string]string,
+	payload []byte,
+	logger *logrus.Entry,
+) (*http.Response, int, error) {
+	serviceName := ctx.Value("service").(string)
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
 	if err != nil {
 		r.logger.WithError(err).Error("failed to create http request")
@@ -42,7 +112,7 @@ func (r *Router) SendRequest(
 		req.Header.Set(k, v)
 	}
 
-	resp, err := r.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		logger.WithError(err).Error("failed to send http request")
 		switch ctx.Err() {
@@ -54,22 +124,5 @@ func (r *Router) SendRequest(
 			return nil, http.StatusInternalServerError, simlaerrors.NewConnectionError(serviceName)
 		}
 	}
-
-	defer resp.Body.Close()
-
-	logger.WithField("status_code", resp.StatusCode).Info("received response from service")
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.WithError(err).Error("failed to read response body")
-		return nil, resp.StatusCode, fmt.Errorf("router: failed to read response body: %w", err)
-	}
-
-	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		logger.Warn("service returned non-2xx response")
-		return nil, resp.StatusCode, simlaerrors.NewServiceInvocationError(serviceName, resp.StatusCode, string(body))
-	}
-	duration := time.Since(startTime)
-	logger.WithField("duration", duration).Info("request completed successfully")
-	return body, resp.StatusCode, nil
+	return resp, resp.StatusCode, nil
 }
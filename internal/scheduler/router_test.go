@@ -71,3 +71,25 @@ func TestRouter(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_SendStreamingRequest(t *testing.T) {
+	defer gock.Off()
+	logger := logrus.NewEntry(&logrus.Logger{Out: io.Discard})
+	router := scheduler.NewRouter(logger)
+	ctx := context.WithValue(context.Background(), "service", "test")
+
+	gock.New(baseUrl).Post(invocationPath).Reply(200).BodyString("streamed body")
+	body, statusCode, err := router.SendStreamingRequest(ctx, baseUrl+invocationPath, map[string]string{}, payload)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, statusCode)
+	data, err := io.ReadAll(body)
+	assert.NoError(t, err)
+	assert.NoError(t, body.Close())
+	assert.Equal(t, "streamed body", string(data))
+
+	gock.New(baseUrl).Post(invocationPath).Reply(500).BodyString("boom")
+	body, statusCode, err = router.SendStreamingRequest(ctx, baseUrl+invocationPath, map[string]string{}, payload)
+	assert.Error(t, err)
+	assert.Nil(t, body)
+	assert.Equal(t, 500, statusCode)
+}
@@ -2,16 +2,24 @@ package scheduler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"sync"
 	"time"
 
+	"github.com/nyambati/simla/internal/build"
 	"github.com/nyambati/simla/internal/config"
 	"github.com/nyambati/simla/internal/env"
 	simlaerrors "github.com/nyambati/simla/internal/errors"
 	"github.com/nyambati/simla/internal/health"
+	"github.com/nyambati/simla/internal/layer"
 	"github.com/nyambati/simla/internal/metrics"
 	"github.com/nyambati/simla/internal/registry"
 	"github.com/nyambati/simla/internal/runtime"
+	"github.com/nyambati/simla/internal/runtimeapi"
 	"github.com/sirupsen/logrus"
 )
 
@@ -24,6 +32,10 @@ var _ SchedulerInterface = (*Scheduler)(nil)
 var InvokeHost = "http://localhost:%d/%s"
 var InvokeEndpoint = "2015-03-31/functions/function/invocations"
 
+// lambdaEndpointVar is the AWS SDK setting that overrides the Lambda
+// service endpoint.
+const lambdaEndpointVar = "AWS_ENDPOINT_URL_LAMBDA"
+
 func NewScheduler(config *config.Config, registry registry.ServiceRegistryInterface, logger *logrus.Entry) SchedulerInterface {
 	router := NewRouter(logger)
 	health := health.NewHealthChecker(logger)
@@ -33,56 +45,271 @@ func NewScheduler(config *config.Config, registry registry.ServiceRegistryInterf
 		health:   health,
 		router:   router,
 		config:   config,
+		pools:    pools,
 	}
 }
 
 func (s *Scheduler) Invoke(ctx context.Context, serviceName string, payload []byte) ([]byte, error) {
 	logger := s.logger.WithField("service", serviceName)
 
-	service, err := s.registry.AddService(ctx, serviceName)
+	slot, err := s.acquire(ctx, serviceName)
 	if err != nil {
 		return nil, err
 	}
+	defer s.pools.release(serviceName, slot)
 
-	if service.Status != registry.StatusRunning || !service.Healthy {
-		logger.Warn("service not running or unhealthy, starting service")
-		if err := s.StartService(ctx, serviceName); err != nil {
-			return nil, err
-		}
-	}
-
-	isHealthy, err := s.health.IsHealthy(ctx, service)
+	url, err := s.invocationURL(ctx, serviceName, slot)
 	if err != nil {
 		return nil, err
 	}
 
-	if !isHealthy {
-		return nil, simlaerrors.NewServiceInvocationError(serviceName, 500, "Service is not healthy")
-	}
-
-	url := fmt.Sprintf(InvokeHost, service.Port, InvokeEndpoint)
-
 	// Set service name into context for Router
 	ctx = context.WithValue(ctx, "service", serviceName)
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	timeout := s.timeout(ctx, serviceName)
+	invokeCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	headers := map[string]string{}
+	headers := invocationHeaders(ctx)
 
 	start := time.Now()
-	response, statusCode, err := s.router.SendRequest(ctx, url, headers, payload)
+	response, statusCode, err := s.router.SendRequest(invokeCtx, url, headers, payload)
 	elapsed := time.Since(start)
 
 	if err != nil {
 		GlobalMetrics.Record(serviceName, elapsed, true)
+		if ctx.Err() == nil && errors.Is(invokeCtx.Err(), context.DeadlineExceeded) {
+			return s.timedOut(ctx, serviceName, slot, timeout), nil
+		}
 		return nil, simlaerrors.NewServiceInvocationError(serviceName, statusCode, err.Error())
 	}
 
+	if isExitError(response) {
+		// The runtime is gone; the next invocation starts a new one.
+		if err := s.stopSlot(context.WithoutCancel(ctx), serviceName, slot, registry.StatusStopped); err != nil {
+			logger.WithError(err).Warn("failed to stop exited runtime")
+		}
+	}
+
 	GlobalMetrics.Record(serviceName, elapsed, false)
 	logger.WithField("latency", elapsed).Info("service invoked successfully")
 	return response, nil
 }
 
+// InvokeStream invokes serviceName and returns its response body as the
+// function writes it, for RESPONSE_STREAM integrations. The invocation is
+// recorded in GlobalMetrics when the caller closes the stream. A function
+// still running at its timeout is stopped, as by Invoke, whether or not it
+// has started streaming.
+func (s *Scheduler) InvokeStream(ctx context.Context, serviceName string, payload []byte) (io.ReadCloser, error) {
+	slot, err := s.acquire(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := s.invocationURL(ctx, serviceName, slot)
+	if err != nil {
+		s.pools.release(serviceName, slot)
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, "service", serviceName)
+	timeout := s.timeout(ctx, serviceName)
+	invokeCtx, cancel := context.WithTimeout(ctx, timeout)
+
+	start := time.Now()
+	body, statusCode, err := s.router.SendStreamingRequest(invokeCtx, url, invocationHeaders(ctx), payload)
+	if err != nil {
+		cancel()
+		GlobalMetrics.Record(serviceName, time.Since(start), true)
+		if ctx.Err() == nil && errors.Is(invokeCtx.Err(), context.DeadlineExceeded) {
+			response := s.timedOut(ctx, serviceName, slot, timeout)
+			s.pools.release(serviceName, slot)
+			return nil, simlaerrors.NewServiceInvocationError(serviceName, http.StatusGatewayTimeout, string(response))
+		}
+		s.pools.release(serviceName, slot)
+		return nil, simlaerrors.NewServiceInvocationError(serviceName, statusCode, err.Error())
+	}
+
+	return &invocationStream{
+		ReadCloser: body,
+		ctx:        invokeCtx,
+		cancel:     cancel,
+		done: func(failed, timedOut bool) {
+			// Stop the runaway function before its slot takes another
+			// invocation.
+			if timedOut && ctx.Err() == nil {
+				s.timedOut(ctx, serviceName, slot, timeout)
+			}
+			s.pools.release(serviceName, slot)
+			elapsed := time.Since(start)
+			GlobalMetrics.Record(serviceName, elapsed, failed)
+			s.logger.WithFields(logrus.Fields{"service": serviceName, "latency": elapsed}).Info("service stream completed")
+		},
+	}, nil
+}
+
+// requestIDKey is the context key of the request ID set by WithRequestID.
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx whose invocations run with request ID
+// id, so the function's logs and REPORT line carry the ID the caller was
+// given.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID set on ctx by WithRequestID, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// invocationHeaders returns the headers of an invocation made with ctx.
+func invocationHeaders(ctx context.Context) map[string]string {
+	headers := map[string]string{}
+	if id := RequestID(ctx); id != "" {
+		headers[runtimeapi.HeaderInvokeRequestID] = id
+	}
+	return headers
+}
+
+// timeout returns how long an invocation of serviceName may run.
+func (s *Scheduler) timeout(ctx context.Context, serviceName string) time.Duration {
+	if svcCfg, ok := s.config.GetService(ctx, serviceName); ok {
+		return svcCfg.TimeoutOrDefault()
+	}
+	return config.DefaultTimeout * time.Second
+}
+
+// timedOut stops the container in slot of serviceName, whose handler is
+// still running past its timeout, and returns the error document Lambda
+// returns for the invocation. The next invocation cold starts the container.
+func (s *Scheduler) timedOut(ctx context.Context, serviceName string, slot int, timeout time.Duration) []byte {
+	message := fmt.Sprintf("Task timed out after %.2f seconds", timeout.Seconds())
+	s.logger.WithField("service", serviceName).Warn(message)
+
+	if err := s.stopSlot(context.WithoutCancel(ctx), serviceName, slot, registry.StatusStopped); err != nil {
+		s.logger.WithError(err).WithField("service", serviceName).Warn("failed to stop timed out container")
+	}
+
+	response, _ := json.Marshal(map[string]string{
+		"errorType":    "Sandbox.Timedout",
+		"errorMessage": message,
+	})
+	return response
+}
+
+// acquire reserves a container of serviceName for one invocation and returns
+// its pool slot. It returns a TooManyRequestsError when as many invocations
+// as the service's reserved concurrency are already in flight.
+func (s *Scheduler) acquire(ctx context.Context, serviceName string) (int, error) {
+	limit := config.DefaultConcurrency
+	if svcCfg, ok := s.config.GetService(ctx, serviceName); ok && svcCfg.ReservedConcurrency != nil {
+		limit = *svcCfg.ReservedConcurrency
+	}
+
+	slot, ok := s.pools.acquire(serviceName, limit)
+	if !ok {
+		GlobalMetrics.RecordThrottle(serviceName)
+		s.logger.WithFields(logrus.Fields{"service": serviceName, "limit": limit}).Warn("invocation throttled")
+		return 0, simlaerrors.NewTooManyRequestsError(serviceName, limit)
+	}
+	return slot, nil
+}
+
+// invocationURL makes sure the container in slot of serviceName is
+// registered, running and healthy and returns the URL of its invocation
+// endpoint. Slot 0 is the service's own container; the others are instances
+// started as invocations overlap.
+func (s *Scheduler) invocationURL(ctx context.Context, serviceName string, slot int) (string, error) {
+	logger := s.logger.WithField("service", serviceName)
+
+	service, err := s.registry.AddService(ctx, serviceName)
+	if err != nil {
+		return "", err
+	}
+
+	if slot > 0 {
+		return s.instanceURL(ctx, serviceName, slot-1)
+	}
+
+	url := fmt.Sprintf(InvokeHost, service.Port, InvokeEndpoint)
+	if service.Status == registry.StatusRunning && service.Healthy {
+		if healthy, _ := s.health.IsHealthy(ctx, service); healthy {
+			return url, nil
+		}
+		// The Runtime API lives in the simla process that started the
+		// container, so a container left by a command that has exited no
+		// longer answers.
+		logger.Warn("running service does not respond, restarting it")
+		if err := s.stopSlot(ctx, serviceName, 0, registry.StatusStopped); err != nil {
+			return "", err
+		}
+	}
+
+	logger.Warn("service not running or unhealthy, starting service")
+	start := time.Now()
+	if err := s.StartService(ctx, serviceName); err != nil {
+		return "", err
+	}
+	s.recordColdStart(serviceName, time.Since(start))
+
+	isHealthy, err := s.health.IsHealthy(ctx, service)
+	if err != nil {
+		return "", err
+	}
+
+	if !isHealthy {
+		return "", simlaerrors.NewServiceInvocationError(serviceName, 500, "Service is not healthy")
+	}
+
+	return url, nil
+}
+
+// instanceURL starts the index-th instance of serviceName if it is not
+// running and returns the URL of its invocation endpoint.
+func (s *Scheduler) instanceURL(ctx context.Context, serviceName string, index int) (string, error) {
+	instance, err := s.registry.AddInstance(ctx, serviceName, index)
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf(InvokeHost, instance.Port, InvokeEndpoint)
+	logger := s.logger.WithFields(logrus.Fields{"service": serviceName, "port": instance.Port})
+	target := &registry.Service{Name: serviceName, Port: instance.Port}
+	if instance.Status == registry.StatusRunning && instance.Healthy {
+		if healthy, _ := s.health.IsHealthy(ctx, target); healthy {
+			return url, nil
+		}
+		logger.Warn("running instance does not respond, restarting it")
+		if err := s.stopSlot(ctx, serviceName, index+1, registry.StatusStopped); err != nil {
+			return "", err
+		}
+	}
+
+	logger.Info("scaling out service")
+	start := time.Now()
+	containerID, err := s.startContainer(ctx, serviceName, target)
+	if err != nil {
+		_ = s.registry.UpdateInstance(ctx, serviceName, instance.Port, instance.ID, registry.StatusFailed)
+		return "", err
+	}
+	if err := s.registry.UpdateInstance(ctx, serviceName, instance.Port, containerID, registry.StatusRunning); err != nil {
+		return "", err
+	}
+	s.recordColdStart(serviceName, time.Since(start))
+
+	isHealthy, err := s.health.IsHealthy(ctx, target)
+	if err != nil {
+		return "", err
+	}
+	if !isHealthy {
+		return "", simlaerrors.NewServiceInvocationError(serviceName, 500, "Service is not healthy")
+	}
+
+	return url, nil
+}
+
 func (s *Scheduler) StartService(ctx context.Context, serviceName string) error {
 	logger := s.logger.WithField("service", serviceName)
 
@@ -100,9 +327,42 @@ func (s *Scheduler) StartService(ctx context.Context, serviceName string) error
 
 	logger.Info("starting service container")
 
+	containerID, err := s.startContainer(ctx, serviceName, service)
+	if err != nil {
+		s.registry.UpdateStatus(ctx, serviceName, registry.StatusFailed)
+		return err
+	}
+
+	// Mark service as running and healthy
+	s.registry.UpdateStatus(ctx, serviceName, registry.StatusRunning)
+	s.registry.UpdateHealth(ctx, serviceName, true)
+	s.pools.touch(serviceName, 0)
+
+	// Update container ID
+	if err := s.registry.UpdateContainerID(ctx, serviceName, containerID); err != nil {
+		return err
+	}
+
+	logger.Info("service started successfully")
+
+	return nil
+}
+
+// recordColdStart records an invocation of serviceName that had to start a
+// container first. init is how long the container took to become healthy.
+func (s *Scheduler) recordColdStart(serviceName string, init time.Duration) {
+	GlobalMetrics.RecordColdStart(serviceName, init)
+	s.logger.WithFields(logrus.Fields{"service": serviceName, "init_duration": init}).Info("cold start")
+}
+
+// startContainer starts a container of serviceName listening on the port of
+// target and waits for it to become healthy.
+func (s *Scheduler) startContainer(ctx context.Context, serviceName string, target *registry.Service) (string, error) {
+	logger := s.logger.WithField("service", serviceName)
+
 	svcCfg, exists := s.config.GetService(ctx, serviceName)
 	if !exists {
-		return simlaerrors.NewServiceNotFoundError(serviceName)
+		return "", simlaerrors.NewServiceNotFoundError(serviceName)
 	}
 
 	// Resolve environment variables: merge inline map with optional .env file
@@ -115,59 +375,130 @@ func (s *Scheduler) StartService(ctx context.Context, serviceName string) error
 		logger.WithField("env", env.Mask(resolvedEnv)).Debug("resolved service environment")
 	}
 
+	resolvedEnv = s.lambdaEndpointEnv(resolvedEnv, svcCfg.Backend)
+
+	layersPath, err := s.mergeLayers(svcCfg)
+	if err != nil {
+		return "", err
+	}
+
+	codePath, err := s.codePath(ctx, serviceName, svcCfg)
+	if err != nil {
+		return "", err
+	}
+
 	runtimeConfig := &runtime.RuntimeConfig{
 		Name:         serviceName,
 		Runtime:      svcCfg.Runtime,
 		Image:        svcCfg.Image,
 		Architecture: svcCfg.Architecture,
-		CodePath:     svcCfg.CodePath,
+		CodePath:     codePath,
 		Cmd:          svcCfg.Cmd,
 		Entrypoint:   svcCfg.Entrypoint,
 		Environment:  resolvedEnv,
-		Port:         fmt.Sprintf("%d", service.Port),
+		Port:         fmt.Sprintf("%d", target.Port),
+
+		MemorySize:       svcCfg.MemorySizeOrDefault(),
+		Timeout:          int(svcCfg.TimeoutOrDefault().Seconds()),
+		EphemeralStorage: svcCfg.EphemeralStorageOrDefault(),
+		Region:           s.config.AWS.RegionOrDefault(),
+		AccountID:        s.config.AWS.AccountIDOrDefault(),
+		LayersPath:       layersPath,
+		Dockerfile:       svcCfg.Dockerfile,
+		BuildContext:     svcCfg.BuildContext,
+		BuildArgs:        svcCfg.BuildArgs,
 	}
 
-	runtime, err := runtime.NewRuntime(s.registry, s.logger)
+	runtime, err := runtime.New(svcCfg.Backend, s.registry, s.logger)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	containerID, err := runtime.StartContainer(ctx, runtimeConfig)
 	if err != nil {
-		s.registry.UpdateStatus(ctx, serviceName, registry.StatusFailed)
-		return err
+		return "", err
 	}
 
-	if err := s.health.WaitForHealthy(ctx, service); err != nil {
+	if err := s.health.WaitForHealthy(ctx, target); err != nil {
 		// Surface any startup logs before returning the error so the developer
 		// can see why the container failed to become healthy.
 		runtime.StreamStartupLogs(ctx, containerID, 3*time.Second)
-		s.registry.UpdateStatus(ctx, serviceName, registry.StatusFailed)
-		return err
+		return "", err
 	}
 
 	// Stream the first few seconds of startup logs so startup messages are
 	// visible in the terminal without requiring `simla logs`.
 	go runtime.StreamStartupLogs(ctx, containerID, 3*time.Second)
 
-	// Mark service as running and healthy
-	s.registry.UpdateStatus(ctx, serviceName, registry.StatusRunning)
-	s.registry.UpdateHealth(ctx, serviceName, true)
+	return containerID, nil
+}
 
-	// Update container ID
-	if err := s.registry.UpdateContainerID(ctx, serviceName, containerID); err != nil {
-		return err
+// codePath returns the directory to mount at /var/task for svc: its build
+// output, built now if the service was never built, or CodePath itself.
+func (s *Scheduler) codePath(ctx context.Context, serviceName string, svc *config.Service) (string, error) {
+	if svc.Build == nil || svc.ImageBuild() {
+		return svc.CodePath, nil
 	}
+	builder, err := build.NewDefaultBuilder(s.logger.WithField("component", "build"))
+	if err != nil {
+		return "", err
+	}
+	return builder.Ensure(ctx, serviceName, svc)
+}
 
-	logger.Info("service started successfully")
+// mergeLayers merges the layers of svc and returns the directory to mount
+// at /opt, or "" when it has none.
+func (s *Scheduler) mergeLayers(svc *config.Service) (string, error) {
+	if len(svc.Layers) == 0 {
+		return "", nil
+	}
+	paths, err := s.config.LayerPaths(svc)
+	if err != nil {
+		return "", err
+	}
+	cacheDir, err := layer.DefaultDir()
+	if err != nil {
+		return "", err
+	}
+	return layer.Merge(cacheDir, paths)
+}
 
-	return nil
+// runtime returns the runtime of serviceName's backend.
+func (s *Scheduler) runtime(ctx context.Context, serviceName string) (runtime.RuntimeInterface, error) {
+	backend := s.config.BackendOrDefault()
+	if svcCfg, ok := s.config.GetService(ctx, serviceName); ok {
+		backend = svcCfg.Backend
+	}
+	return runtime.New(backend, s.registry, s.logger)
+}
+
+// lambdaEndpointEnv points the AWS SDKs inside a service at simla's Lambda
+// API, so functions can invoke each other, unless the service already sets
+// AWS_ENDPOINT_URL_LAMBDA itself. Containers reach the host through
+// HostGateway; processes run on it.
+func (s *Scheduler) lambdaEndpointEnv(vars map[string]string, backend string) map[string]string {
+	if s.config.LambdaAPI.Port == "" {
+		return vars
+	}
+	if _, ok := vars[lambdaEndpointVar]; ok {
+		return vars
+	}
+	merged := make(map[string]string, len(vars)+1)
+	for k, v := range vars {
+		merged[k] = v
+	}
+	host := runtime.HostGateway
+	if backend == config.BackendProcess {
+		host = "127.0.0.1"
+	}
+	merged[lambdaEndpointVar] = fmt.Sprintf("http://%s:%s", host, s.config.LambdaAPI.Port)
+	return merged
 }
 
 func (s *Scheduler) StopAll(ctx context.Context) error {
 	services := s.registry.ListServices(ctx)
 	for _, svc := range services {
-		if svc.Status != registry.StatusRunning {
+		if !isRunning(svc) {
 			continue
 		}
 		if err := s.StopService(ctx, svc.Name); err != nil {
@@ -177,6 +508,8 @@ func (s *Scheduler) StopAll(ctx context.Context) error {
 	return nil
 }
 
+// StopService stops every running container of serviceName: its own and
+// the instances started for concurrent invocations.
 func (s *Scheduler) StopService(ctx context.Context, serviceName string) error {
 	logger := s.logger.WithField("service", serviceName)
 
@@ -186,21 +519,34 @@ func (s *Scheduler) StopService(ctx context.Context, serviceName string) error {
 		return nil
 	}
 
-	if service.Status != registry.StatusRunning {
+	if !isRunning(service) {
 		logger.Info("service not running")
 		return nil
 	}
 
 	logger.Info("stopping service container")
 
-	// TODO: Here you would normally trigger the container runtime (Docker API)
-	// For now, let's simulate stopping successfully.
-
-	runtime, err := runtime.NewRuntime(s.registry, s.logger)
+	runtime, err := s.runtime(ctx, serviceName)
 	if err != nil {
 		return err
 	}
 
+	for _, instance := range service.Instances {
+		if instance.Status != registry.StatusRunning {
+			continue
+		}
+		if err := runtime.StopContainer(ctx, instance.ID); err != nil {
+			return err
+		}
+		if err := s.registry.UpdateInstance(ctx, serviceName, instance.Port, instance.ID, registry.StatusStopped); err != nil {
+			return err
+		}
+	}
+
+	if service.Status != registry.StatusRunning {
+		return nil
+	}
+
 	s.registry.UpdateStatus(ctx, serviceName, registry.StatusPending)
 
 	if err = runtime.StopContainer(ctx, service.ID); err != nil {
@@ -213,3 +559,122 @@ func (s *Scheduler) StopService(ctx context.Context, serviceName string) error {
 
 	return nil
 }
+
+// stopSlot stops the container in slot of serviceName, if it is running,
+// and records status for it in the registry, so the next invocation using
+// the slot starts it again.
+func (s *Scheduler) stopSlot(ctx context.Context, serviceName string, slot int, status registry.Status) error {
+	service, exists := s.registry.GetService(ctx, serviceName)
+	if !exists {
+		return nil
+	}
+
+	containerID, current := service.ID, service.Status
+	if slot > 0 {
+		if slot > len(service.Instances) {
+			return nil
+		}
+		instance := service.Instances[slot-1]
+		containerID, current = instance.ID, instance.Status
+	}
+	if current != registry.StatusRunning {
+		return nil
+	}
+
+	runtime, err := s.runtime(ctx, serviceName)
+	if err != nil {
+		return err
+	}
+	if err := runtime.StopContainer(ctx, containerID); err != nil {
+		return err
+	}
+
+	if slot > 0 {
+		port := service.Instances[slot-1].Port
+		if err := s.registry.UpdateInstance(ctx, serviceName, port, containerID, status); err != nil {
+			return err
+		}
+	} else {
+		s.registry.UpdateStatus(ctx, serviceName, status)
+		s.registry.UpdateHealth(ctx, serviceName, false)
+	}
+
+	s.logger.WithFields(logrus.Fields{"service": serviceName, "container": containerID, "status": status}).Info("stopped container")
+	return nil
+}
+
+// isRunning reports whether any container of svc is running.
+func isRunning(svc *registry.Service) bool {
+	if svc.Status == registry.StatusRunning {
+		return true
+	}
+	for _, instance := range svc.Instances {
+		if instance.Status == registry.StatusRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// functionError is the error document a runtime returns when a handler
+// fails.
+type functionError struct {
+	ErrorMessage *string `json:"errorMessage"`
+	ErrorType    *string `json:"errorType"`
+}
+
+// IsFunctionError reports whether an invocation response is the error
+// document a runtime returns when the handler fails. Invoke returns such
+// responses without an error, as the Lambda Invoke API does.
+func IsFunctionError(response []byte) bool {
+	var doc functionError
+	if err := json.Unmarshal(response, &doc); err != nil {
+		return false
+	}
+	return doc.ErrorMessage != nil && doc.ErrorType != nil
+}
+
+// isExitError reports whether an invocation response reports that the
+// function's runtime exited.
+func isExitError(response []byte) bool {
+	var doc functionError
+	if err := json.Unmarshal(response, &doc); err != nil {
+		return false
+	}
+	return doc.ErrorType != nil && *doc.ErrorType == "Runtime.ExitError"
+}
+
+// invocationStream is the body of a streamed invocation. Closing it releases
+// the invocation's context and records its outcome once.
+type invocationStream struct {
+	io.ReadCloser
+	// ctx bounds the invocation by the function's timeout.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     func(failed, timedOut bool)
+	failed   bool
+	complete bool
+	once     sync.Once
+}
+
+func (s *invocationStream) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) {
+		s.complete = true
+	} else if err != nil {
+		s.failed = true
+	}
+	return n, err
+}
+
+// Close ends the invocation. One whose stream did not complete before the
+// function's timeout has timed out.
+func (s *invocationStream) Close() error {
+	err := s.ReadCloser.Close()
+	s.once.Do(func() {
+		timedOut := !s.complete && errors.Is(s.ctx.Err(), context.DeadlineExceeded)
+		s.cancel()
+		s.done(s.failed || timedOut, timedOut)
+	})
+	return err
+}
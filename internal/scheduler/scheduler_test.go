@@ -178,3 +178,43 @@ func deleteContainer(name string) {
 		}
 	}
 }
+
+func TestScheduler_LambdaEndpointEnv(t *testing.T) {
+	s := &Scheduler{config: &config.Config{LambdaAPI: config.LambdaAPI{Port: "3001"}}}
+
+	vars := map[string]string{"TEST_ENV": "test"}
+	got := s.lambdaEndpointEnv(vars, config.BackendDocker)
+	assert.Equal(t, "http://host.docker.internal:3001", got[lambdaEndpointVar])
+	assert.Equal(t, "test", got["TEST_ENV"])
+	assert.NotContains(t, vars, lambdaEndpointVar, "the service config must not be modified")
+
+	got = s.lambdaEndpointEnv(vars, config.BackendProcess)
+	assert.Equal(t, "http://127.0.0.1:3001", got[lambdaEndpointVar])
+
+	custom := map[string]string{lambdaEndpointVar: "http://example.com"}
+	assert.Equal(t, custom, s.lambdaEndpointEnv(custom, config.BackendDocker))
+
+	s.config.LambdaAPI.Port = ""
+	assert.Equal(t, vars, s.lambdaEndpointEnv(vars, config.BackendDocker))
+}
+
+func TestIsFunctionError(t *testing.T) {
+	assert.True(t, IsFunctionError([]byte(`{"errorMessage":"boom","errorType":"Error","stackTrace":[]}`)))
+	assert.False(t, IsFunctionError([]byte(`{"errorMessage":"only a message"}`)))
+	assert.False(t, IsFunctionError([]byte(`{"ok":true}`)))
+	assert.False(t, IsFunctionError([]byte(`"errorMessage"`)))
+	assert.False(t, IsFunctionError(nil))
+}
+
+func TestIsExitError(t *testing.T) {
+	assert.True(t, isExitError([]byte(`{"errorType":"Runtime.ExitError","errorMessage":"RequestId: 1 Error: Runtime exited with error: exit status 2"}`)))
+	assert.False(t, isExitError([]byte(`{"errorType":"Error","errorMessage":"boom"}`)))
+	assert.False(t, isExitError([]byte(`{"ok":true}`)))
+}
+
+func TestInvocationHeaders(t *testing.T) {
+	assert.Empty(t, invocationHeaders(context.Background()))
+	ctx := WithRequestID(context.Background(), "req-1")
+	assert.Equal(t, "req-1", RequestID(ctx))
+	assert.Equal(t, map[string]string{"X-Amzn-RequestId": "req-1"}, invocationHeaders(ctx))
+}
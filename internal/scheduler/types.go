@@ -4,6 +4,7 @@ package scheduler
 
 import (
 	"context"
+	"io"
 	"net/http"
 
 	"github.com/nyambati/simla/internal/config"
@@ -19,18 +20,26 @@ type RouterInterface interface {
 		headers map[string]string,
 		payload []byte,
 	) (response []byte, statusCode int, err error)
+	SendStreamingRequest(
+		ctx context.Context,
+		url string,
+		headers map[string]string,
+		payload []byte,
+	) (body io.ReadCloser, statusCode int, err error)
 }
 
 type SchedulerInterface interface {
 	Invoke(ctx context.Context, serviceName string, payload []byte) ([]byte, error)
+	InvokeStream(ctx context.Context, serviceName string, payload []byte) (io.ReadCloser, error)
 	StartService(ctx context.Context, serviceName string) error
 	StopService(ctx context.Context, serviceName string) error
 	StopAll(ctx context.Context) error
 }
 
 type Router struct {
-	client *http.Client
-	logger *logrus.Entry
+	client       *http.Client
+	streamClient *http.Client
+	logger       *logrus.Entry
 }
 
 type Scheduler struct {
@@ -39,4 +48,5 @@ type Scheduler struct {
 	router   RouterInterface
 	logger   *logrus.Entry
 	config   *config.Config
+	pools    *poolSet
 }
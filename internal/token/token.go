@@ -0,0 +1,241 @@
+// Package token manages the local signing key simla uses to issue test JWTs
+// and reads JSON Web Key Sets for the gateway's JWT authorizer.
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	keyFile  = "signing-key.pem"
+	jwksFile = "jwks.json"
+	keyBits  = 2048
+)
+
+// DefaultIssuer is the issuer `simla token` uses when none is configured.
+const DefaultIssuer = "https://simla.local"
+
+// DefaultDir returns the directory holding the local signing key and its
+// JWKS, ~/.simla/jwt.
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".simla", "jwt"), nil
+}
+
+// DefaultJWKSPath returns the path of the JWKS published for the local
+// signing key.
+func DefaultJWKSPath() (string, error) {
+	dir, err := DefaultDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, jwksFile), nil
+}
+
+// Issuer signs tokens with an RSA key stored on disk.
+type Issuer struct {
+	key *rsa.PrivateKey
+	kid string
+}
+
+// LoadOrCreateIssuer loads the signing key from dir, generating it and
+// writing the matching jwks.json on first use.
+func LoadOrCreateIssuer(dir string) (*Issuer, error) {
+	path := filepath.Join(dir, keyFile)
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s: no PEM data found", path)
+		}
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return newIssuer(key), nil
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(path, pemData, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+
+	issuer := newIssuer(key)
+	jwks, err := issuer.JWKS()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, jwksFile), jwks, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write jwks: %w", err)
+	}
+	return issuer, nil
+}
+
+func newIssuer(key *rsa.PrivateKey) *Issuer {
+	// The key ID is derived from the public modulus so it stays stable for
+	// the lifetime of the key.
+	sum := sha256.Sum256(key.N.Bytes())
+	return &Issuer{key: key, kid: base64.RawURLEncoding.EncodeToString(sum[:8])}
+}
+
+// KeyID returns the "kid" header of tokens signed by the issuer.
+func (i *Issuer) KeyID() string {
+	return i.kid
+}
+
+// Sign returns an RS256-signed JWT carrying claims. iat is always set; exp is
+// set from ttl when the claims do not already contain one.
+func (i *Issuer) Sign(claims map[string]any, ttl time.Duration) (string, error) {
+	now := time.Now()
+	mapClaims := jwt.MapClaims{"iat": now.Unix()}
+	if ttl > 0 {
+		mapClaims["exp"] = now.Add(ttl).Unix()
+	}
+	for k, v := range claims {
+		mapClaims[k] = v
+	}
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, mapClaims)
+	tok.Header["kid"] = i.kid
+	return tok.SignedString(i.key)
+}
+
+// JWKS returns the issuer's public key as a JSON Web Key Set.
+func (i *Issuer) JWKS() ([]byte, error) {
+	return json.MarshalIndent(keySet{Keys: []jsonWebKey{{
+		Kty: "RSA",
+		Kid: i.kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+	}}}, "", "  ")
+}
+
+type keySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// ParseJWKS decodes the RSA and EC signing keys of a JSON Web Key Set, keyed
+// by kid. Keys of other types, and keys marked for encryption, are skipped.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set keySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var (
+			key crypto.PublicKey
+			err error
+		)
+		switch k.Kty {
+		case "RSA":
+			key, err = rsaKey(k)
+		case "EC":
+			key, err = ecKey(k)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwks key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks contains no signing keys")
+	}
+	return keys, nil
+}
+
+func rsaKey(k jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func ecKey(k jsonWebKey) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := decodeBigInt(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeBigInt(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing key parameter")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,79 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrCreateIssuer_PersistsKey(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := LoadOrCreateIssuer(dir)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, keyFile))
+	assert.FileExists(t, filepath.Join(dir, jwksFile))
+
+	second, err := LoadOrCreateIssuer(dir)
+	require.NoError(t, err)
+	assert.Equal(t, first.KeyID(), second.KeyID())
+}
+
+func TestSign_VerifiesAgainstJWKS(t *testing.T) {
+	dir := t.TempDir()
+	issuer, err := LoadOrCreateIssuer(dir)
+	require.NoError(t, err)
+
+	signed, err := issuer.Sign(map[string]any{"sub": "alice"}, time.Hour)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, jwksFile))
+	require.NoError(t, err)
+	keys, err := ParseJWKS(data)
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	tok, err := jwt.ParseWithClaims(signed, claims, func(tok *jwt.Token) (any, error) {
+		return keys[tok.Header["kid"].(string)], nil
+	})
+	require.NoError(t, err)
+	assert.True(t, tok.Valid)
+	assert.Equal(t, "alice", claims["sub"])
+	assert.Contains(t, claims, "exp")
+}
+
+func TestParseJWKS_ECAndSkippedKeys(t *testing.T) {
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	enc := base64.RawURLEncoding.EncodeToString
+	data, err := json.Marshal(keySet{Keys: []jsonWebKey{
+		{Kty: "EC", Kid: "ec", Crv: "P-256", X: enc(ec.X.Bytes()), Y: enc(ec.Y.Bytes())},
+		{Kty: "RSA", Kid: "enc", Use: "enc", N: enc(rsaKey.N.Bytes()), E: "AQAB"},
+		{Kty: "oct", Kid: "hmac"},
+	}})
+	require.NoError(t, err)
+
+	keys, err := ParseJWKS(data)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.True(t, keys["ec"].(*ecdsa.PublicKey).Equal(&ec.PublicKey))
+}
+
+func TestParseJWKS_NoSigningKeys(t *testing.T) {
+	_, err := ParseJWKS([]byte(`{"keys":[]}`))
+	assert.Error(t, err)
+}
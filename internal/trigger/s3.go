@@ -88,7 +88,7 @@ func (s *s3Trigger) Start(ctx context.Context) error {
 				s.logger.WithError(err).Warn("s3: failed to build event payload")
 				continue
 			}
-			s.invoke(ctx, payload)
+			s.invokeAsync(ctx, payload)
 
 		case err, ok := <-fsw.Errors:
 			if !ok {
@@ -54,7 +54,7 @@ func (s *scheduleTrigger) Start(ctx context.Context) error {
 				s.logger.WithError(err).Warn("failed to build schedule event payload")
 				continue
 			}
-			s.invoke(ctx, payload)
+			s.invokeAsync(ctx, payload)
 		}
 	}
 }
@@ -114,8 +114,14 @@ func (s *snsTrigger) handlePublish(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Invoke asynchronously so the HTTP response is returned immediately.
-	go s.invoke(r.Context(), payload)
+	// SNS invokes subscribed functions asynchronously. Without a queue the
+	// invocation runs in the background so the response is returned
+	// immediately; it must not use the request context, which ends with it.
+	if s.queue != nil {
+		s.invokeAsync(r.Context(), payload)
+	} else {
+		go s.invoke(context.Background(), payload)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
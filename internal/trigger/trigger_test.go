@@ -43,7 +43,7 @@ func TestNew_UnknownType_ReturnsError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	sched := mocks.NewMockSchedulerInterface(ctrl)
 
-	_, err := New(config.Trigger{Type: "unknown"}, "svc", sched, logrus.NewEntry(logrus.New()))
+	_, err := New(config.Trigger{Type: "unknown"}, "svc", sched, nil, logrus.NewEntry(logrus.New()))
 	require.Error(t, err)
 	var unknown *UnknownTriggerTypeError
 	assert.True(t, errors.As(err, &unknown))
@@ -53,7 +53,7 @@ func TestNew_UnknownType_ReturnsError(t *testing.T) {
 func TestNew_Schedule_MissingExpression_ReturnsError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	sched := mocks.NewMockSchedulerInterface(ctrl)
-	_, err := New(config.Trigger{Type: config.TriggerTypeSchedule}, "svc", sched, logrus.NewEntry(logrus.New()))
+	_, err := New(config.Trigger{Type: config.TriggerTypeSchedule}, "svc", sched, nil, logrus.NewEntry(logrus.New()))
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "expression is required")
 }
@@ -61,7 +61,7 @@ func TestNew_Schedule_MissingExpression_ReturnsError(t *testing.T) {
 func TestNew_SQS_MissingQueueURL_ReturnsError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	sched := mocks.NewMockSchedulerInterface(ctrl)
-	_, err := New(config.Trigger{Type: config.TriggerTypeSQS}, "svc", sched, logrus.NewEntry(logrus.New()))
+	_, err := New(config.Trigger{Type: config.TriggerTypeSQS}, "svc", sched, nil, logrus.NewEntry(logrus.New()))
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "queueUrl is required")
 }
@@ -69,7 +69,7 @@ func TestNew_SQS_MissingQueueURL_ReturnsError(t *testing.T) {
 func TestNew_S3_MissingLocalPath_ReturnsError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	sched := mocks.NewMockSchedulerInterface(ctrl)
-	_, err := New(config.Trigger{Type: config.TriggerTypeS3, Bucket: "b"}, "svc", sched, logrus.NewEntry(logrus.New()))
+	_, err := New(config.Trigger{Type: config.TriggerTypeS3, Bucket: "b"}, "svc", sched, nil, logrus.NewEntry(logrus.New()))
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "localPath is required")
 }
@@ -77,7 +77,7 @@ func TestNew_S3_MissingLocalPath_ReturnsError(t *testing.T) {
 func TestNew_S3_MissingBucket_ReturnsError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	sched := mocks.NewMockSchedulerInterface(ctrl)
-	_, err := New(config.Trigger{Type: config.TriggerTypeS3, LocalPath: t.TempDir()}, "svc", sched, logrus.NewEntry(logrus.New()))
+	_, err := New(config.Trigger{Type: config.TriggerTypeS3, LocalPath: t.TempDir()}, "svc", sched, nil, logrus.NewEntry(logrus.New()))
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "bucket is required")
 }
@@ -85,7 +85,7 @@ func TestNew_S3_MissingBucket_ReturnsError(t *testing.T) {
 func TestNew_SNS_MissingTopicARN_ReturnsError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	sched := mocks.NewMockSchedulerInterface(ctrl)
-	_, err := New(config.Trigger{Type: config.TriggerTypeSNS}, "svc", sched, logrus.NewEntry(logrus.New()))
+	_, err := New(config.Trigger{Type: config.TriggerTypeSNS}, "svc", sched, nil, logrus.NewEntry(logrus.New()))
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "topicArn is required")
 }
@@ -93,7 +93,7 @@ func TestNew_SNS_MissingTopicARN_ReturnsError(t *testing.T) {
 func TestNew_DynamoDB_MissingStreamARN_ReturnsError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	sched := mocks.NewMockSchedulerInterface(ctrl)
-	_, err := New(config.Trigger{Type: config.TriggerTypeDynamoDBStreams, DynamoDBEndpoint: "http://localhost:8000"}, "svc", sched, logrus.NewEntry(logrus.New()))
+	_, err := New(config.Trigger{Type: config.TriggerTypeDynamoDBStreams, DynamoDBEndpoint: "http://localhost:8000"}, "svc", sched, nil, logrus.NewEntry(logrus.New()))
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "streamArn is required")
 }
@@ -101,7 +101,7 @@ func TestNew_DynamoDB_MissingStreamARN_ReturnsError(t *testing.T) {
 func TestNew_DynamoDB_MissingEndpoint_ReturnsError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	sched := mocks.NewMockSchedulerInterface(ctrl)
-	_, err := New(config.Trigger{Type: config.TriggerTypeDynamoDBStreams, StreamARN: "arn:..."}, "svc", sched, logrus.NewEntry(logrus.New()))
+	_, err := New(config.Trigger{Type: config.TriggerTypeDynamoDBStreams, StreamARN: "arn:..."}, "svc", sched, nil, logrus.NewEntry(logrus.New()))
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "dynamodbEndpoint is required")
 }
@@ -646,6 +646,29 @@ func TestSNSTrigger_HandlePublish_ValidRequest(t *testing.T) {
 	}
 }
 
+func TestSNSTrigger_HandlePublish_QueuesEvent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	queue := mocks.NewMockQueueInterface(ctrl)
+	queue.EXPECT().
+		Enqueue(gomock.Any(), "svc", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, p []byte) (string, error) {
+			var evt events.SNSEvent
+			require.NoError(t, json.Unmarshal(p, &evt))
+			assert.Equal(t, "hello", evt.Records[0].SNS.Message)
+			return "request-id", nil
+		})
+
+	b := newBase(t, "svc", mocks.NewMockSchedulerInterface(ctrl))
+	b.queue = queue
+	trigger := &snsTrigger{base: b, topicARN: "arn:aws:sns:local:000:topic"}
+
+	req := httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader(`{"Message":"hello"}`))
+	w := httptest.NewRecorder()
+	trigger.handlePublish(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
 func TestSNSTrigger_HandlePublish_NonPost_Returns405(t *testing.T) {
 	trigger := &snsTrigger{base: base{}}
 	req := httptest.NewRequest(http.MethodGet, "/publish", nil)
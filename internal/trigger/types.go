@@ -5,6 +5,7 @@ package trigger
 import (
 	"context"
 
+	"github.com/nyambati/simla/internal/async"
 	"github.com/nyambati/simla/internal/config"
 	"github.com/nyambati/simla/internal/scheduler"
 	"github.com/sirupsen/logrus"
@@ -18,15 +19,19 @@ type Source interface {
 
 // New constructs the correct Source for the given trigger configuration.
 // serviceName identifies which Lambda service to invoke when the trigger fires.
+// Sources that invoke asynchronously in AWS (schedule, S3 and SNS) queue
+// their events on queue; when queue is nil they invoke synchronously.
 func New(
 	trig config.Trigger,
 	serviceName string,
 	sched scheduler.SchedulerInterface,
+	queue async.QueueInterface,
 	logger *logrus.Entry,
 ) (Source, error) {
 	base := base{
 		serviceName: serviceName,
 		scheduler:   sched,
+		queue:       queue,
 		logger:      logger,
 	}
 
@@ -50,6 +55,7 @@ func New(
 type base struct {
 	serviceName string
 	scheduler   scheduler.SchedulerInterface
+	queue       async.QueueInterface
 	logger      *logrus.Entry
 }
 
@@ -65,6 +71,21 @@ func (b *base) invoke(ctx context.Context, payload []byte) {
 	b.logger.WithField("response", string(resp)).Debugf("trigger invocation succeeded for service %s", b.serviceName)
 }
 
+// invokeAsync queues payload as an asynchronous invocation, so failures are
+// retried and end up in the dead-letter store instead of being dropped.
+func (b *base) invokeAsync(ctx context.Context, payload []byte) {
+	if b.queue == nil {
+		b.invoke(ctx, payload)
+		return
+	}
+	id, err := b.queue.Enqueue(ctx, b.serviceName, payload)
+	if err != nil {
+		b.logger.WithError(err).Errorf("failed to queue trigger event for service %s", b.serviceName)
+		return
+	}
+	b.logger.WithField("request_id", id).Debugf("trigger event queued for service %s", b.serviceName)
+}
+
 // UnknownTriggerTypeError is returned when the config specifies a type that
 // has no registered implementation.
 type UnknownTriggerTypeError struct {
@@ -1,6 +1,7 @@
 // Package watcher provides file-system watch-based hot reload for Lambda
 // services. When a file inside a service's codePath changes, the watcher
-// debounces the event and triggers a container restart via the scheduler.
+// debounces the event, rebuilds the service if it has a build step, and
+// triggers a container restart via the scheduler.
 package watcher
 
 import (
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/nyambati/simla/internal/build"
 	"github.com/nyambati/simla/internal/config"
 	"github.com/nyambati/simla/internal/scheduler"
 	"github.com/sirupsen/logrus"
@@ -22,6 +24,7 @@ const defaultDebounce = 500 * time.Millisecond
 type Watcher struct {
 	config    *config.Config
 	scheduler scheduler.SchedulerInterface
+	builder   *build.Builder
 	logger    *logrus.Entry
 	debounce  time.Duration
 }
@@ -32,10 +35,16 @@ func New(cfg *config.Config, sched scheduler.SchedulerInterface, logger *logrus.
 	if debounce == 0 {
 		debounce = defaultDebounce
 	}
+	logger = logger.WithField("component", "watcher")
+	builder, err := build.NewDefaultBuilder(logger.WithField("component", "build"))
+	if err != nil {
+		logger.WithError(err).Warn("build directory unavailable; services will not be rebuilt on change")
+	}
 	return &Watcher{
 		config:    cfg,
 		scheduler: sched,
-		logger:    logger.WithField("component", "watcher"),
+		builder:   builder,
+		logger:    logger,
 		debounce:  debounce,
 	}
 }
@@ -55,9 +64,15 @@ func (w *Watcher) Start(ctx context.Context) error {
 	pathToService := make(map[string]string)
 
 	for name, svc := range w.config.Services {
-		abs, err := filepath.Abs(svc.CodePath)
+		// Services built from a Dockerfile restart with a rebuilt image when
+		// their build context changes.
+		path := svc.CodePath
+		if svc.ImageBuild() {
+			path = svc.BuildContext
+		}
+		abs, err := filepath.Abs(path)
 		if err != nil {
-			w.logger.WithError(err).Warnf("skipping watch for service %s: cannot resolve path %s", name, svc.CodePath)
+			w.logger.WithError(err).Warnf("skipping watch for service %s: cannot resolve path %s", name, path)
 			continue
 		}
 		if err := fsw.Add(abs); err != nil {
@@ -134,11 +149,20 @@ func (w *Watcher) Start(ctx context.Context) error {
 	}
 }
 
-// restart stops then starts the named service so the new code is picked up.
+// restart rebuilds the named service if it has a build step, then stops and
+// starts it so the new code is picked up. A failed build leaves the running
+// containers untouched.
 func (w *Watcher) restart(ctx context.Context, serviceName string) {
 	log := w.logger.WithField("service", serviceName)
 	log.Info("change detected — restarting service")
 
+	if svc, ok := w.config.GetService(ctx, serviceName); ok && svc.Build != nil && w.builder != nil {
+		if _, err := w.builder.Build(ctx, serviceName, svc); err != nil {
+			log.Error(err.Error())
+			return
+		}
+	}
+
 	if err := w.scheduler.StopService(ctx, serviceName); err != nil {
 		log.WithError(err).Error("failed to stop service for hot reload")
 		return
@@ -0,0 +1,132 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Context object
+// ---------------------------------------------------------------------------
+
+// contextTimeFormat is how times appear in the context object.
+const contextTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+type executionKey struct{}
+
+type stateContextKey struct{}
+
+// withExecution returns ctx carrying the execution whose states run in it.
+func withExecution(ctx context.Context, exec *Execution) context.Context {
+	return context.WithValue(ctx, executionKey{}, exec)
+}
+
+// executionFrom returns the execution carried by ctx, or nil.
+func executionFrom(ctx context.Context) *Execution {
+	exec, _ := ctx.Value(executionKey{}).(*Execution)
+	return exec
+}
+
+// stateContext is the context object, "$$", of a running state.
+type stateContext struct {
+	execution   *Execution
+	name        string
+	enteredTime time.Time
+	// taskToken is set for Task states.
+	taskToken  string
+	retryCount int
+	// object caches the encoded context object until retryCount changes.
+	object []byte
+}
+
+func withStateContext(ctx context.Context, sc *stateContext) context.Context {
+	return context.WithValue(ctx, stateContextKey{}, sc)
+}
+
+// stateContextFrom returns the context of the state running in ctx, or nil.
+func stateContextFrom(ctx context.Context) *stateContext {
+	sc, _ := ctx.Value(stateContextKey{}).(*stateContext)
+	return sc
+}
+
+// setRetryCount records the retry of the state that is about to run.
+func (sc *stateContext) setRetryCount(n int) {
+	if sc != nil && sc.retryCount != n {
+		sc.retryCount, sc.object = n, nil
+	}
+}
+
+// contextObject returns the context object of the state running in ctx, or
+// an empty object outside a state.
+func contextObject(ctx context.Context) []byte {
+	sc := stateContextFrom(ctx)
+	if sc == nil {
+		return []byte("{}")
+	}
+	if sc.object == nil {
+		sc.object = sc.encode(nil)
+	}
+	return sc.object
+}
+
+// encode returns the context object, with Map.Item set to item for the
+// ItemSelector of a Map state.
+func (sc *stateContext) encode(item map[string]any) []byte {
+	obj := map[string]any{}
+	if sc != nil {
+		if exec := sc.execution; exec != nil {
+			obj["Execution"] = map[string]any{
+				"Id":        exec.ARN,
+				"Name":      exec.ID,
+				"Input":     validOrNull(exec.Input),
+				"StartTime": exec.StartedAt.UTC().Format(contextTimeFormat),
+			}
+			obj["StateMachine"] = map[string]any{
+				"Id":   exec.StateMachineARN,
+				"Name": exec.WorkflowName,
+			}
+		}
+		obj["State"] = map[string]any{
+			"Name":        sc.name,
+			"EnteredTime": sc.enteredTime.UTC().Format(contextTimeFormat),
+			"RetryCount":  sc.retryCount,
+		}
+		if sc.taskToken != "" {
+			obj["Task"] = map[string]any{"Token": sc.taskToken}
+		}
+	}
+	if item != nil {
+		obj["Map"] = map[string]any{"Item": item}
+	}
+	data, _ := json.Marshal(obj)
+	return data
+}
+
+// selectPath returns the value at path of data or, for a path starting
+// "$$", of the context object of the state running in ctx.
+func selectPath(ctx context.Context, data []byte, path string) ([]byte, error) {
+	if strings.HasPrefix(path, "$$") {
+		return applyContextPath(data, contextObject(ctx), path)
+	}
+	return applyPath(data, path)
+}
+
+// applyContextPath returns the value at path of data or, for a path
+// starting "$$", of contextObject.
+func applyContextPath(data, contextObject []byte, path string) ([]byte, error) {
+	if rest, ok := strings.CutPrefix(path, "$$"); ok {
+		return applyPath(contextObject, "$"+rest)
+	}
+	return applyPath(data, path)
+}
+
+// validOrNull returns data as a raw JSON value, or null when it is not
+// valid JSON.
+func validOrNull(data []byte) json.RawMessage {
+	if !json.Valid(data) {
+		return json.RawMessage("null")
+	}
+	return data
+}
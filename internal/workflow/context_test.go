@@ -0,0 +1,240 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nyambati/simla/internal/config"
+	"github.com/nyambati/simla/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestExecute_ContextObject_Pass(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "context-test",
+		StartAt: "describe",
+		States: map[string]config.State{
+			"describe": {
+				Type: "Pass",
+				Parameters: map[string]any{
+					"id.$":      "$$.Execution.Id",
+					"name.$":    "$$.Execution.Name",
+					"input.$":   "$$.Execution.Input",
+					"started.$": "$$.Execution.StartTime",
+					"machine.$": "$$.StateMachine",
+					"state.$":   "$$.State.Name",
+					"entered.$": "$$.State.EnteredTime",
+					"retries.$": "$$.State.RetryCount",
+				},
+				End: true,
+			},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	out, err := ex.Execute(context.Background(), "context-test", []byte(`{"order":7}`))
+	require.NoError(t, err)
+
+	var got struct {
+		ID      string            `json:"id"`
+		Name    string            `json:"name"`
+		Input   json.RawMessage   `json:"input"`
+		Started string            `json:"started"`
+		Machine map[string]string `json:"machine"`
+		State   string            `json:"state"`
+		Entered string            `json:"entered"`
+		Retries int               `json:"retries"`
+	}
+	require.NoError(t, json.Unmarshal(out, &got))
+
+	assert.Equal(t, "arn:aws:states:us-east-1:012345678901:execution:context-test:"+got.Name, got.ID)
+	_, err = uuid.Parse(got.Name)
+	assert.NoError(t, err, "the execution name is its ID")
+	assert.JSONEq(t, `{"order":7}`, string(got.Input))
+	assert.Equal(t, map[string]string{
+		"Id":   "arn:aws:states:us-east-1:012345678901:stateMachine:context-test",
+		"Name": "context-test",
+	}, got.Machine)
+	assert.Equal(t, "describe", got.State)
+	assert.Zero(t, got.Retries)
+
+	started, err := time.Parse(contextTimeFormat, got.Started)
+	require.NoError(t, err)
+	entered, err := time.Parse(contextTimeFormat, got.Entered)
+	require.NoError(t, err)
+	assert.False(t, entered.Before(started))
+}
+
+func TestExecute_ContextObject_Paths(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "context-paths",
+		StartAt: "route",
+		States: map[string]config.State{
+			// Choice variables and paths can read the original execution input.
+			"route": {
+				Type: "Choice",
+				Choices: []config.ChoiceRule{
+					{Variable: "$$.Execution.Input.kind", StringEquals: "order", Next: "restore"},
+				},
+				DefaultChoice: "fail",
+			},
+			"restore": {Type: "Pass", InputPath: "$$.Execution.Input", End: true},
+			"fail":    {Type: "Fail", Error: "Unexpected"},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+
+	_, err := ex.Execute(context.Background(), "context-paths", []byte(`{"kind":"refund"}`))
+	require.Error(t, err)
+
+	out, err := ex.Execute(context.Background(), "context-paths", []byte(`{"kind":"order"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"kind":"order"}`, string(out))
+}
+
+func TestExecute_ContextObject_TaskRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+
+	var payloads []map[string]any
+	record := func(_ context.Context, _ string, payload []byte) {
+		var p map[string]any
+		require.NoError(t, json.Unmarshal(payload, &p))
+		payloads = append(payloads, p)
+	}
+	gomock.InOrder(
+		sched.EXPECT().Invoke(gomock.Any(), "svc-a", gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn string, payload []byte) ([]byte, error) {
+				record(ctx, fn, payload)
+				return nil, errors.New("transient")
+			}),
+		sched.EXPECT().Invoke(gomock.Any(), "svc-a", gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn string, payload []byte) ([]byte, error) {
+				record(ctx, fn, payload)
+				return []byte(`{"ok":true}`), nil
+			}),
+	)
+
+	sm := config.StateMachine{
+		Name:    "context-retry",
+		StartAt: "charge",
+		States: map[string]config.State{
+			"charge": {
+				Type:     "Task",
+				Resource: "svc-a",
+				Parameters: map[string]any{
+					"idempotencyKey.$": "$$.Execution.Id",
+					"attempt.$":        "$$.State.RetryCount",
+					"token.$":          "$$.Task.Token",
+				},
+				Retry: []config.RetryConfig{{Errors: []string{"States.ALL"}, MaxAttempts: 1, BackoffRate: 1}},
+				End:   true,
+			},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), sched, newLogger())
+	_, err := ex.Execute(context.Background(), "context-retry", []byte(`{}`))
+	require.NoError(t, err)
+
+	require.Len(t, payloads, 2)
+	assert.Equal(t, float64(0), payloads[0]["attempt"])
+	assert.Equal(t, float64(1), payloads[1]["attempt"])
+	assert.Equal(t, payloads[0]["idempotencyKey"], payloads[1]["idempotencyKey"], "retries share the execution ID")
+	assert.True(t, strings.HasPrefix(payloads[0]["idempotencyKey"].(string), "arn:aws:states:"))
+	_, err = uuid.Parse(payloads[0]["token"].(string))
+	assert.NoError(t, err)
+}
+
+func TestExecute_ContextObject_MissingField(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "context-missing",
+		StartAt: "pass",
+		States: map[string]config.State{
+			// Only Task states have a task token.
+			"pass": {Type: "Pass", Parameters: map[string]any{"token.$": "$$.Task.Token"}, End: true},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	_, err := ex.Execute(context.Background(), "context-missing", []byte(`{}`))
+	require.Error(t, err)
+	assert.Equal(t, ErrRuntime, classifyError(err))
+}
+
+func TestExecute_StatesAllSkipsRuntime(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+
+	sm := config.StateMachine{
+		Name:    "runtime-test",
+		StartAt: "task",
+		States: map[string]config.State{
+			"task": {
+				Type:       "Task",
+				Resource:   "svc-a",
+				Parameters: map[string]any{"value.$": "$.missing"},
+				Retry:      []config.RetryConfig{{Errors: []string{"States.ALL"}, MaxAttempts: 3, BackoffRate: 1}},
+				Catch:      []config.CatchConfig{{Errors: []string{"States.ALL"}, Next: "recover"}},
+				End:        true,
+			},
+			"recover": {Type: "Pass", End: true},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), sched, newLogger())
+	_, err := ex.Execute(context.Background(), "runtime-test", []byte(`{}`))
+	require.Error(t, err)
+	assert.Equal(t, ErrRuntime, classifyError(err))
+}
+
+func TestExecute_ContextObject_DistributedMap(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "context-map",
+		StartAt: "each",
+		States: map[string]config.State{
+			"each": {
+				Type:         "Map",
+				ItemSelector: map[string]any{"index.$": "$$.Map.Item.Index"},
+				ItemProcessor: &config.ItemProcessor{
+					ProcessorConfig: config.ProcessorConfig{Mode: MapModeDistributed},
+					StateMachine: config.StateMachine{
+						StartAt: "describe",
+						States: map[string]config.State{
+							"describe": {
+								Type: "Pass",
+								Parameters: map[string]any{
+									"index.$":   "$.index",
+									"id.$":      "$$.Execution.Id",
+									"machine.$": "$$.StateMachine.Id",
+								},
+								End: true,
+							},
+						},
+					},
+				},
+				End: true,
+			},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	out, err := ex.Execute(context.Background(), "context-map", []byte(`[1,2]`))
+	require.NoError(t, err)
+
+	var got []struct {
+		Index   int    `json:"index"`
+		ID      string `json:"id"`
+		Machine string `json:"machine"`
+	}
+	require.NoError(t, json.Unmarshal(out, &got))
+	require.Len(t, got, 2)
+	assert.NotEqual(t, got[0].ID, got[1].ID, "every child execution has its own ID")
+	for i, child := range got {
+		assert.Equal(t, i, child.Index)
+		assert.True(t, strings.HasPrefix(child.ID, "arn:aws:states:us-east-1:012345678901:execution:context-map/"))
+		assert.True(t, strings.HasPrefix(child.Machine, "arn:aws:states:us-east-1:012345678901:stateMachine:context-map/"))
+	}
+}
@@ -0,0 +1,524 @@
+package workflow
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nyambati/simla/internal/config"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ---------------------------------------------------------------------------
+// Distributed Map state
+// ---------------------------------------------------------------------------
+
+// runMapRun runs every input as a child execution of a new map run and
+// returns the outputs of the children, in order, or the location of their
+// results when the state has a ResultWriter. Results are written even when
+// the map run fails.
+func (e *Executor) runMapRun(
+	ctx context.Context,
+	workflowName, stateName string,
+	state *config.State,
+	machine *config.StateMachine,
+	effective []byte,
+	inputs [][]byte,
+	logger *logrus.Entry,
+) ([]byte, error) {
+	region, account := e.config.AWS.RegionOrDefault(), e.config.AWS.AccountIDOrDefault()
+	run := &MapRun{ID: uuid.NewString(), Executions: make([]*Execution, len(inputs))}
+	run.ARN = fmt.Sprintf("arn:aws:states:%s:%s:mapRun:%s/%s:%s", region, account, workflowName, stateName, run.ID)
+	stateMachineARN := fmt.Sprintf("arn:aws:states:%s:%s:stateMachine:%s/%s", region, account, workflowName, run.ID)
+	for i, input := range inputs {
+		id := uuid.NewString()
+		run.Executions[i] = &Execution{
+			ID:              id,
+			ARN:             fmt.Sprintf("arn:aws:states:%s:%s:execution:%s/%s:%s", region, account, workflowName, run.ID, id),
+			WorkflowName:    machine.Name,
+			StateMachineARN: stateMachineARN,
+			Status:          ExecutionStatusPending,
+			Input:           input,
+		}
+	}
+
+	logger = logger.WithField("map_run", run.ID)
+	logger.Infof("starting map run of %d child executions", len(inputs))
+
+	results, err := e.runItems(ctx, workflowName, stateName, state, inputs, logger,
+		func(ctx context.Context, i int, input []byte) ([]byte, error) {
+			child := run.Executions[i]
+			child.Status = ExecutionStatusRunning
+			child.StartedAt = time.Now()
+			// The states of a child execution see it as $$.Execution.
+			output, err := e.runMachine(withExecution(ctx, child), machine, input, logger.WithField("child_execution", child.ID))
+			child.StoppedAt = time.Now()
+			switch {
+			case err == nil:
+				child.Status = ExecutionStatusSucceeded
+				child.Output = output
+			case ctx.Err() != nil:
+				child.Status = ExecutionStatusAborted
+			default:
+				child.Status = ExecutionStatusFailed
+			}
+			if err != nil {
+				child.Error = classifyError(err)
+				child.Cause = err.Error()
+			}
+			return output, err
+		})
+	logMapRun(run, logger)
+
+	if state.ResultWriter != nil {
+		output, werr := writeResults(state.ResultWriter, run, effective, contextObject(ctx))
+		if werr != nil {
+			return nil, simlaerrors.NewWorkflowExecutionError(workflowName, ErrRuntime, werr.Error())
+		}
+		if err != nil {
+			return nil, err
+		}
+		return output, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(results)
+}
+
+// logMapRun logs how many child executions of run ended in every status.
+func logMapRun(run *MapRun, logger *logrus.Entry) {
+	counts := make(map[ExecutionStatus]int)
+	for _, child := range run.Executions {
+		counts[child.Status]++
+		logger.WithFields(logrus.Fields{
+			"child_execution": child.ID,
+			"status":          child.Status,
+		}).Debug("child execution finished")
+	}
+	logger.WithFields(logrus.Fields{
+		"succeeded": counts[ExecutionStatusSucceeded],
+		"failed":    counts[ExecutionStatusFailed],
+		"aborted":   counts[ExecutionStatusAborted],
+		"pending":   counts[ExecutionStatusPending],
+	}).Info("map run finished")
+}
+
+// ---------------------------------------------------------------------------
+// ItemReader
+// ---------------------------------------------------------------------------
+
+// readItems returns the items read by reader. Its Bucket parameter names a
+// local directory standing in for the bucket: getObject reads the file at
+// Key in it, or Bucket itself when it is a file, and listObjectsV2 lists
+// the files under Prefix in it.
+func readItems(reader *config.ItemReader, effective, contextObject []byte) ([]json.RawMessage, error) {
+	params, err := resolveParameters(reader.Parameters, effective, contextObject)
+	if err != nil {
+		return nil, fmt.Errorf("ItemReader error: %w", err)
+	}
+	bucket, err := stringParam(params, "Bucket")
+	if err != nil {
+		return nil, fmt.Errorf("ItemReader error: %w", err)
+	}
+	if bucket == "" {
+		return nil, errors.New("ItemReader error: Parameters.Bucket is required")
+	}
+
+	var items []json.RawMessage
+	switch reader.Resource {
+	case ResourceS3GetObject:
+		key, err := stringParam(params, "Key")
+		if err != nil {
+			return nil, fmt.Errorf("ItemReader error: %w", err)
+		}
+		file := bucket
+		if key != "" {
+			file = filepath.Join(bucket, filepath.FromSlash(key))
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("ItemReader error: %w", err)
+		}
+		items, err = parseItems(&reader.ReaderConfig, data)
+		if err != nil {
+			return nil, fmt.Errorf("ItemReader error: %s: %w", file, err)
+		}
+	case ResourceS3ListObjectsV2:
+		prefix, err := stringParam(params, "Prefix")
+		if err != nil {
+			return nil, fmt.Errorf("ItemReader error: %w", err)
+		}
+		items, err = listObjects(bucket, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("ItemReader error: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("ItemReader error: unsupported resource %q", reader.Resource)
+	}
+
+	if limit := reader.ReaderConfig.MaxItems; limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+// parseItems returns the items of an object of the given InputType: the
+// elements of a JSON array, the lines of JSONL, or the rows of a CSV file
+// as objects keyed by the headers.
+func parseItems(cfg *config.ReaderConfig, data []byte) ([]json.RawMessage, error) {
+	switch strings.ToUpper(cfg.InputType) {
+	case "", "JSON":
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("object is not a JSON array: %w", err)
+		}
+		return items, nil
+	case "JSONL":
+		return parseJSONLines(data)
+	case "CSV":
+		return parseCSV(cfg, data)
+	default:
+		return nil, fmt.Errorf("unsupported InputType %q", cfg.InputType)
+	}
+}
+
+func parseJSONLines(data []byte) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if !json.Valid(text) {
+			return nil, fmt.Errorf("line %d is not valid JSON", line)
+		}
+		items = append(items, json.RawMessage(bytes.Clone(text)))
+	}
+	return items, scanner.Err()
+}
+
+// parseCSV returns the rows of a CSV file as objects of strings, keyed by
+// its first row or, with CSVHeaderLocation GIVEN, by CSVHeaders.
+func parseCSV(cfg *config.ReaderConfig, data []byte) ([]json.RawMessage, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	var headers []string
+	switch strings.ToUpper(cfg.CSVHeaderLocation) {
+	case "", "FIRST_ROW":
+		first, err := r.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		headers = first
+	case "GIVEN":
+		if len(cfg.CSVHeaders) == 0 {
+			return nil, errors.New("CSVHeaderLocation GIVEN needs CSVHeaders")
+		}
+		headers = cfg.CSVHeaders
+		r.FieldsPerRecord = len(headers)
+	default:
+		return nil, fmt.Errorf("unsupported CSVHeaderLocation %q", cfg.CSVHeaderLocation)
+	}
+
+	var items []json.RawMessage
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(headers))
+		for i, header := range headers {
+			row[header] = record[i]
+		}
+		item, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// listObjects returns an object, shaped like the Contents of an S3
+// ListObjectsV2 response, for every file under dir whose key, its path
+// relative to dir, starts with prefix. Keys are in lexical order.
+func listObjects(dir, prefix string) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		sum := md5.Sum(data)
+		item, err := json.Marshal(map[string]any{
+			"Etag":         `"` + hex.EncodeToString(sum[:]) + `"`,
+			"Key":          key,
+			"LastModified": info.ModTime().UTC().Format(time.RFC3339),
+			"Size":         info.Size(),
+			"StorageClass": "STANDARD",
+		})
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
+
+// ---------------------------------------------------------------------------
+// ItemBatcher
+// ---------------------------------------------------------------------------
+
+// batch is the input of a child execution that processes a batch of items.
+type batch struct {
+	BatchInput any               `json:"BatchInput,omitempty"`
+	Items      []json.RawMessage `json:"Items"`
+}
+
+// batchItems groups items into batches of at most MaxItemsPerBatch items
+// and MaxInputBytesPerBatch bytes, and returns the input of every batch.
+func batchItems(batcher *config.ItemBatcher, items []json.RawMessage, effective, contextObject []byte) ([][]byte, error) {
+	if batcher.MaxItemsPerBatch <= 0 && batcher.MaxInputBytesPerBatch <= 0 {
+		return nil, errors.New("ItemBatcher needs MaxItemsPerBatch or MaxInputBytesPerBatch")
+	}
+	var batchInput any
+	if batcher.BatchInput != nil {
+		var err error
+		if batchInput, err = resolveTemplate(batcher.BatchInput, effective, contextObject); err != nil {
+			return nil, fmt.Errorf("BatchInput error: %w", err)
+		}
+	}
+	empty, err := json.Marshal(batch{BatchInput: batchInput, Items: []json.RawMessage{}})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		inputs  [][]byte
+		current []json.RawMessage
+		size    int
+	)
+	flush := func() error {
+		input, err := json.Marshal(batch{BatchInput: batchInput, Items: current})
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, input)
+		current, size = nil, len(empty)
+		return nil
+	}
+	size = len(empty)
+	for i, item := range items {
+		// Every item after the first adds a comma.
+		itemSize := len(item) + min(len(current), 1)
+		full := batcher.MaxItemsPerBatch > 0 && len(current) == batcher.MaxItemsPerBatch
+		tooBig := batcher.MaxInputBytesPerBatch > 0 && size+itemSize > batcher.MaxInputBytesPerBatch
+		if len(current) > 0 && (full || tooBig) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			itemSize = len(item)
+		}
+		if batcher.MaxInputBytesPerBatch > 0 && size+itemSize > batcher.MaxInputBytesPerBatch {
+			return nil, fmt.Errorf("item %d does not fit in MaxInputBytesPerBatch %d", i, batcher.MaxInputBytesPerBatch)
+		}
+		current = append(current, item)
+		size += itemSize
+	}
+	if len(current) > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	return inputs, nil
+}
+
+// ---------------------------------------------------------------------------
+// ResultWriter
+// ---------------------------------------------------------------------------
+
+// resultFile is a result file listed in the manifest of a map run.
+type resultFile struct {
+	Key  string `json:"Key"`
+	Size int    `json:"Size"`
+}
+
+// manifest describes the result files of a map run.
+type manifest struct {
+	DestinationBucket string                  `json:"DestinationBucket"`
+	MapRunArn         string                  `json:"MapRunArn"`
+	ResultFiles       map[string][]resultFile `json:"ResultFiles"`
+}
+
+// resultEntry is a child execution in a result file.
+type resultEntry struct {
+	ExecutionArn string `json:"ExecutionArn"`
+	Name         string `json:"Name"`
+	Input        string `json:"Input"`
+	Output       string `json:"Output,omitempty"`
+	Error        string `json:"Error,omitempty"`
+	Cause        string `json:"Cause,omitempty"`
+	Status       string `json:"Status"`
+	StartDate    string `json:"StartDate,omitempty"`
+	StopDate     string `json:"StopDate,omitempty"`
+}
+
+// writeResults writes the child executions of run into SUCCEEDED_0.json,
+// FAILED_0.json and PENDING_0.json, and a manifest.json listing them, under
+// Prefix/<map run ID>/ in the directory named by the Bucket parameter of
+// writer. It returns the output of the Map state: the map run's ARN and
+// where the manifest is.
+func writeResults(writer *config.ResultWriter, run *MapRun, effective, contextObject []byte) ([]byte, error) {
+	if writer.Resource != ResourceS3PutObject {
+		return nil, fmt.Errorf("ResultWriter error: unsupported resource %q", writer.Resource)
+	}
+	params, err := resolveParameters(writer.Parameters, effective, contextObject)
+	if err != nil {
+		return nil, fmt.Errorf("ResultWriter error: %w", err)
+	}
+	bucket, err := stringParam(params, "Bucket")
+	if err != nil {
+		return nil, fmt.Errorf("ResultWriter error: %w", err)
+	}
+	if bucket == "" {
+		return nil, errors.New("ResultWriter error: Parameters.Bucket is required")
+	}
+	prefix, err := stringParam(params, "Prefix")
+	if err != nil {
+		return nil, fmt.Errorf("ResultWriter error: %w", err)
+	}
+	prefix = path.Join(prefix, run.ID)
+
+	entries := map[string][]resultEntry{}
+	for _, child := range run.Executions {
+		status := string(child.Status)
+		if child.Status == ExecutionStatusAborted {
+			status = string(ExecutionStatusFailed)
+		}
+		entries[status] = append(entries[status], newResultEntry(child))
+	}
+
+	m := manifest{DestinationBucket: bucket, MapRunArn: run.ARN, ResultFiles: map[string][]resultFile{}}
+	for _, status := range []ExecutionStatus{ExecutionStatusFailed, ExecutionStatusPending, ExecutionStatusSucceeded} {
+		files := []resultFile{}
+		if list := entries[string(status)]; len(list) > 0 {
+			key := path.Join(prefix, string(status)+"_0.json")
+			size, err := writeObject(bucket, key, list)
+			if err != nil {
+				return nil, fmt.Errorf("ResultWriter error: %w", err)
+			}
+			files = append(files, resultFile{Key: key, Size: size})
+		}
+		m.ResultFiles[string(status)] = files
+	}
+	manifestKey := path.Join(prefix, "manifest.json")
+	if _, err := writeObject(bucket, manifestKey, m); err != nil {
+		return nil, fmt.Errorf("ResultWriter error: %w", err)
+	}
+
+	return json.Marshal(map[string]any{
+		"MapRunArn": run.ARN,
+		"ResultWriterDetails": map[string]string{
+			"Bucket": bucket,
+			"Key":    manifestKey,
+		},
+	})
+}
+
+func newResultEntry(child *Execution) resultEntry {
+	entry := resultEntry{
+		ExecutionArn: child.ARN,
+		Name:         child.ID,
+		Input:        string(child.Input),
+		Output:       string(child.Output),
+		Error:        child.Error,
+		Cause:        child.Cause,
+		Status:       string(child.Status),
+	}
+	if !child.StartedAt.IsZero() {
+		entry.StartDate = child.StartedAt.UTC().Format(time.RFC3339Nano)
+		entry.StopDate = child.StoppedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return entry
+}
+
+// writeObject writes v as JSON to key in the directory bucket and returns
+// its size.
+func writeObject(bucket, key string, v any) (int, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	file := filepath.Join(bucket, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", filepath.Dir(file), err)
+	}
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return len(data), nil
+}
+
+// resolveParameters resolves the Parameters of an ItemReader or
+// ResultWriter against the state's effective input.
+func resolveParameters(params map[string]any, effective, contextObject []byte) (map[string]any, error) {
+	resolved, err := resolveTemplate(params, effective, contextObject)
+	if err != nil {
+		return nil, err
+	}
+	m, _ := resolved.(map[string]any)
+	return m, nil
+}
+
+// stringParam returns the string parameter name of params, or "" when it
+// is not set. Names match case-insensitively.
+func stringParam(params map[string]any, name string) (string, error) {
+	for key, value := range params {
+		if strings.EqualFold(key, name) {
+			s, ok := value.(string)
+			if !ok {
+				return "", fmt.Errorf("Parameters.%s must be a string, got %s", name, jsonType(value))
+			}
+			return s, nil
+		}
+	}
+	return "", nil
+}
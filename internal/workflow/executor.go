@@ -3,6 +3,7 @@ package workflow
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand/v2"
@@ -42,15 +43,18 @@ func (e *Executor) Execute(ctx context.Context, workflowName string, input []byt
 	})
 	logger.Info("starting workflow execution")
 
+	region, account := e.config.AWS.RegionOrDefault(), e.config.AWS.AccountIDOrDefault()
 	exec := &Execution{
-		ID:           execID,
-		WorkflowName: workflowName,
-		Status:       ExecutionStatusRunning,
-		Input:        input,
-		StartedAt:    time.Now(),
+		ID:              execID,
+		ARN:             fmt.Sprintf("arn:aws:states:%s:%s:execution:%s:%s", region, account, workflowName, execID),
+		WorkflowName:    workflowName,
+		StateMachineARN: fmt.Sprintf("arn:aws:states:%s:%s:stateMachine:%s", region, account, workflowName),
+		Status:          ExecutionStatusRunning,
+		Input:           input,
+		StartedAt:       time.Now(),
 	}
 
-	output, err := e.runMachine(ctx, sm, input, logger)
+	output, err := e.runMachine(withExecution(ctx, exec), sm, input, logger)
 	exec.StoppedAt = time.Now()
 	if err != nil {
 		exec.Status = ExecutionStatusFailed
@@ -86,7 +90,13 @@ func (e *Executor) runMachine(ctx context.Context, sm *config.StateMachine, inpu
 		logger := logger.WithField("state", currentState)
 		logger.Infof("entering state (type=%s)", stateDef.Type)
 
-		result, err := e.executeState(ctx, sm.Name, currentState, &stateDef, data, logger)
+		// Every state sees its own context object as "$$".
+		sc := &stateContext{execution: executionFrom(ctx), name: currentState, enteredTime: time.Now()}
+		if config.StateType(stateDef.Type) == config.StateTypeTask {
+			sc.taskToken = uuid.NewString()
+		}
+
+		result, err := e.executeState(withStateContext(ctx, sc), sm.Name, currentState, &stateDef, data, logger)
 		if err != nil {
 			return nil, err
 		}
@@ -118,11 +128,13 @@ func (e *Executor) executeState(
 	case config.StateTypeTask:
 		return e.executeTask(ctx, workflowName, stateName, state, input, logger)
 	case config.StateTypePass:
-		return e.executePass(state, input)
+		return e.executePass(ctx, workflowName, stateName, state, input)
 	case config.StateTypeChoice:
-		return e.executeChoice(workflowName, stateName, state, input)
+		return e.executeChoice(ctx, workflowName, stateName, state, input)
 	case config.StateTypeParallel:
 		return e.executeParallel(ctx, workflowName, stateName, state, input, logger)
+	case config.StateTypeMap:
+		return e.executeMap(ctx, workflowName, stateName, state, input, logger)
 	case config.StateTypeWait:
 		return e.executeWait(ctx, workflowName, stateName, state, input)
 	case config.StateTypeSucceed:
@@ -130,7 +142,7 @@ func (e *Executor) executeState(
 	case config.StateTypeFail:
 		cause := state.Cause
 		if state.CausePath != "" {
-			if raw, err := applyPath(input, state.CausePath); err == nil {
+			if raw, err := selectPath(ctx, input, state.CausePath); err == nil {
 				cause = strings.Trim(string(raw), `"`)
 			}
 		}
@@ -157,7 +169,7 @@ func (e *Executor) executeTask(
 	}
 
 	// Apply InputPath to narrow the data sent to the service.
-	effective, err := applyPath(input, state.InputPath)
+	effective, err := selectPath(ctx, input, state.InputPath)
 	if err != nil {
 		return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName, fmt.Sprintf("InputPath error: %v", err))
 	}
@@ -190,10 +202,10 @@ func (e *Executor) executeTask(
 		return nil, taskErr
 	}
 
-	// Apply OutputPath to the raw task response.
-	filtered, err := applyPath(taskOutput, state.OutputPath)
+	// Shape the raw task response with ResultSelector.
+	result, err := applyTemplate(state.ResultSelector, taskOutput, contextObject(ctx))
 	if err != nil {
-		return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName, fmt.Sprintf("OutputPath error: %v", err))
+		return nil, dataError(workflowName, fmt.Errorf("ResultSelector error: %w", err))
 	}
 
 	// Merge task result back into the input document at ResultPath.
@@ -201,35 +213,63 @@ func (e *Executor) executeTask(
 	if resultPath == "" {
 		resultPath = "$" // AWS default: replace effective input with task output
 	}
-	merged, err := mergePath(input, filtered, resultPath)
+	merged, err := mergePath(input, result, resultPath)
 	if err != nil {
-		return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName, fmt.Sprintf("ResultPath error: %v", err))
+		return nil, resultPathError(workflowName, stateName, err)
 	}
 
-	return &stateResult{output: merged, nextState: state.Next, end: state.End}, nil
+	// Apply OutputPath to the merged document.
+	filtered, err := selectPath(ctx, merged, state.OutputPath)
+	if err != nil {
+		return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName, fmt.Sprintf("OutputPath error: %v", err))
+	}
+
+	return &stateResult{output: filtered, nextState: state.Next, end: state.End}, nil
 }
 
-// invokeWithRetry calls the scheduler, honouring the state's Retry config.
+// invokeWithRetry calls the scheduler with the state's Parameters applied to
+// effective, honouring the state's Retry config. Parameters are applied for
+// every attempt, as $$.State.RetryCount changes between them.
 func (e *Executor) invokeWithRetry(
 	ctx context.Context,
 	workflowName, stateName string,
 	state *config.State,
-	payload []byte,
+	effective []byte,
+	logger *logrus.Entry,
+) ([]byte, error) {
+	return e.withRetry(ctx, workflowName, stateName, state.Retry, logger, func() ([]byte, error) {
+		payload, err := applyTemplate(state.Parameters, effective, contextObject(ctx))
+		if err != nil {
+			return nil, dataError(workflowName, fmt.Errorf("Parameters error: %w", err))
+		}
+		return e.scheduler.Invoke(ctx, state.Resource, payload)
+	})
+}
+
+// withRetry calls run until it succeeds or fails with an error no
+// RetryConfig of retries allows another attempt for.
+func (e *Executor) withRetry(
+	ctx context.Context,
+	workflowName, stateName string,
+	retries []config.RetryConfig,
 	logger *logrus.Entry,
+	run func() ([]byte, error),
 ) ([]byte, error) {
 	attempt := 0
+	sc := stateContextFrom(ctx)
 
 	for {
-		output, err := e.scheduler.Invoke(ctx, state.Resource, payload)
+		sc.setRetryCount(attempt)
+		output, err := run()
 		if err == nil {
 			return output, nil
 		}
 
-		if len(state.Retry) == 0 {
+		if len(retries) == 0 {
 			return nil, err
 		}
 
-		rc := matchRetry(state.Retry, err)
+		rc := matchRetry(retries, err)
 		if rc == nil {
 			return nil, err
 		}
@@ -275,7 +315,7 @@ func matchRetry(retries []config.RetryConfig, err error) *config.RetryConfig {
 	errName := classifyError(err)
 	for i := range retries {
 		for _, e := range retries[i].Errors {
-			if e == ErrAll || e == errName {
+			if matchesError(e, errName) {
 				return &retries[i]
 			}
 		}
@@ -283,13 +323,22 @@ func matchRetry(retries []config.RetryConfig, err error) *config.RetryConfig {
 	return nil
 }
 
+// matchesError reports whether the error name of a Retry or Catch matches
+// errName. As in AWS, States.ALL does not match States.Runtime.
+func matchesError(name, errName string) bool {
+	if name == ErrAll {
+		return errName != ErrRuntime
+	}
+	return name == errName
+}
+
 // tryCatch attempts to match err against the catch configs and returns the
 // next state name, an updated output document, and whether a match was found.
 func (e *Executor) tryCatch(catches []config.CatchConfig, err error, input []byte) (string, []byte, bool) {
 	errName := classifyError(err)
 	for _, c := range catches {
 		for _, ce := range c.Errors {
-			if ce == ErrAll || ce == errName {
+			if matchesError(ce, errName) {
 				// Build an error object to put at ResultPath.
 				errObj, _ := json.Marshal(map[string]string{
 					"Error": errName,
@@ -310,11 +359,16 @@ func (e *Executor) tryCatch(catches []config.CatchConfig, err error, input []byt
 	return "", nil, false
 }
 
-// classifyError maps a Go error to an AWS-style error name.
+// classifyError maps a Go error to an AWS-style error name. Errors raised
+// with a name, such as those of Fail states, keep it.
 func classifyError(err error) string {
 	if err == nil {
 		return ""
 	}
+	var named *simlaerrors.WorkflowExecutionError
+	if errors.As(err, &named) && named.Error_ != "" {
+		return named.Error_
+	}
 	msg := err.Error()
 	switch {
 	case strings.Contains(msg, "timed out") || strings.Contains(msg, "deadline exceeded"):
@@ -326,11 +380,22 @@ func classifyError(err error) string {
 	}
 }
 
+// resultPathError returns the error of a state whose result cannot be
+// merged at ResultPath: States.ResultPathMatchFailure when the input has no
+// place for it, a state error for an invalid ResultPath.
+func resultPathError(workflowName, stateName string, err error) error {
+	msg := fmt.Sprintf("ResultPath error: %v", err)
+	if errors.Is(err, errResultPathMatch) {
+		return simlaerrors.NewWorkflowExecutionError(workflowName, ErrResultPathNull, msg)
+	}
+	return simlaerrors.NewWorkflowStateError(workflowName, stateName, msg)
+}
+
 // ---------------------------------------------------------------------------
 // Pass state
 // ---------------------------------------------------------------------------
 
-func (e *Executor) executePass(state *config.State, input []byte) (*stateResult, error) {
+func (e *Executor) executePass(ctx context.Context, workflowName, stateName string, state *config.State, input []byte) (*stateResult, error) {
 	var data []byte
 
 	if state.Result != nil {
@@ -342,11 +407,14 @@ func (e *Executor) executePass(state *config.State, input []byte) (*stateResult,
 		data = encoded
 	} else {
 		// Apply InputPath first.
-		effective, err := applyPath(input, state.InputPath)
+		effective, err := selectPath(ctx, input, state.InputPath)
 		if err != nil {
 			return nil, fmt.Errorf("Pass state InputPath error: %w", err)
 		}
-		data = effective
+		data, err = applyTemplate(state.Parameters, effective, contextObject(ctx))
+		if err != nil {
+			return nil, dataError(workflowName, fmt.Errorf("Parameters error: %w", err))
+		}
 	}
 
 	// Apply ResultPath to merge back.
@@ -356,11 +424,11 @@ func (e *Executor) executePass(state *config.State, input []byte) (*stateResult,
 	}
 	merged, err := mergePath(input, data, resultPath)
 	if err != nil {
-		return nil, fmt.Errorf("Pass state ResultPath error: %w", err)
+		return nil, resultPathError(workflowName, stateName, err)
 	}
 
 	// Apply OutputPath last.
-	output, err := applyPath(merged, state.OutputPath)
+	output, err := selectPath(ctx, merged, state.OutputPath)
 	if err != nil {
 		return nil, fmt.Errorf("Pass state OutputPath error: %w", err)
 	}
@@ -373,13 +441,15 @@ func (e *Executor) executePass(state *config.State, input []byte) (*stateResult,
 // ---------------------------------------------------------------------------
 
 func (e *Executor) executeChoice(
+	ctx context.Context,
 	workflowName, stateName string,
 	state *config.State,
 	input []byte,
 ) (*stateResult, error) {
+	contextObject := contextObject(ctx)
 	for _, rule := range state.Choices {
 		eval := toChoiceRuleEval(&rule)
-		matched, err := evaluateCondition(input, eval)
+		matched, err := evaluateCondition(input, contextObject, eval)
 		if err != nil {
 			return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName,
 				fmt.Sprintf("error evaluating choice rule: %v", err))
@@ -495,6 +565,11 @@ func (e *Executor) executeParallel(
 		return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName, "Parallel state has no branches")
 	}
 
+	branchInput, err := applyTemplate(state.Parameters, input, contextObject(ctx))
+	if err != nil {
+		return nil, dataError(workflowName, fmt.Errorf("Parameters error: %w", err))
+	}
+
 	type branchResult struct {
 		index  int
 		output []byte
@@ -512,7 +587,7 @@ func (e *Executor) executeParallel(
 		go func() {
 			defer wg.Done()
 			branchLogger := logger.WithField("branch", branch.Name)
-			out, err := e.runMachine(ctx, &branch, input, branchLogger)
+			out, err := e.runMachine(ctx, &branch, branchInput, branchLogger)
 			ch <- branchResult{index: i, output: out, err: err}
 		}()
 	}
@@ -556,6 +631,10 @@ func (e *Executor) executeParallel(
 		return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName,
 			fmt.Sprintf("cannot serialise parallel outputs: %v", err))
 	}
+	combined, err = applyTemplate(state.ResultSelector, combined, contextObject(ctx))
+	if err != nil {
+		return nil, dataError(workflowName, fmt.Errorf("ResultSelector error: %w", err))
+	}
 
 	// Merge combined array back into the input document at ResultPath.
 	resultPath := state.ResultPath
@@ -564,8 +643,7 @@ func (e *Executor) executeParallel(
 	}
 	merged, err := mergePath(input, combined, resultPath)
 	if err != nil {
-		return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName,
-			fmt.Sprintf("ResultPath error: %v", err))
+		return nil, resultPathError(workflowName, stateName, err)
 	}
 
 	return &stateResult{output: merged, nextState: state.Next, end: state.End}, nil
@@ -588,7 +666,7 @@ func (e *Executor) executeWait(
 		duration = time.Duration(state.Seconds) * time.Second
 
 	case state.SecondsPath != "":
-		raw, err := applyPath(input, state.SecondsPath)
+		raw, err := selectPath(ctx, input, state.SecondsPath)
 		if err != nil {
 			return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName,
 				fmt.Sprintf("SecondsPath error: %v", err))
@@ -609,7 +687,7 @@ func (e *Executor) executeWait(
 		duration = time.Until(t)
 
 	case state.TimestampPath != "":
-		raw, err := applyPath(input, state.TimestampPath)
+		raw, err := selectPath(ctx, input, state.TimestampPath)
 		if err != nil {
 			return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName,
 				fmt.Sprintf("TimestampPath error: %v", err))
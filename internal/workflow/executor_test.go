@@ -1,15 +1,20 @@
 package workflow
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/nyambati/simla/internal/config"
 	"github.com/nyambati/simla/internal/mocks"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
@@ -534,3 +539,601 @@ func TestExecute_LinearMultiStep(t *testing.T) {
 	require.NoError(t, err)
 	assert.JSONEq(t, `{"step":"two"}`, string(out))
 }
+
+// ---------------------------------------------------------------------------
+// Map state
+// ---------------------------------------------------------------------------
+
+// mapProcessor returns an item processor that fails items whose status is
+// "bad" and passes the others through.
+func mapProcessor() *config.ItemProcessor {
+	return &config.ItemProcessor{StateMachine: config.StateMachine{
+		StartAt: "check",
+		States: map[string]config.State{
+			"check": {
+				Type:          "Choice",
+				Choices:       []config.ChoiceRule{{Variable: "$.status", StringEquals: "bad", Next: "fail"}},
+				DefaultChoice: "done",
+			},
+			"fail": {Type: "Fail", Error: "ItemFailed", Cause: "bad item"},
+			"done": {Type: "Pass", End: true},
+		},
+	}}
+}
+
+func TestExecute_MapState_ItemSelector(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "map-test",
+		StartAt: "each",
+		States: map[string]config.State{
+			"each": {
+				Type:           "Map",
+				ItemsPath:      "$.orders",
+				MaxConcurrency: 1,
+				ItemSelector: map[string]any{
+					"customer.$": "$.customer",
+					"index.$":    "$$.Map.Item.Index",
+					"order.$":    "$$.Map.Item.Value",
+				},
+				ItemProcessor: &config.ItemProcessor{StateMachine: config.StateMachine{
+					StartAt: "pass",
+					States:  map[string]config.State{"pass": {Type: "Pass", End: true}},
+				}},
+				ResultPath: "$.results",
+				End:        true,
+			},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	out, err := ex.Execute(context.Background(), "map-test", []byte(`{"customer":"c1","orders":[{"id":1},{"id":2}]}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"customer":"c1","orders":[{"id":1},{"id":2}],"results":[
+		{"customer":"c1","index":0,"order":{"id":1}},
+		{"customer":"c1","index":1,"order":{"id":2}}]}`, string(out))
+}
+
+func TestExecute_MapState_Concurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+
+	var running, peak atomic.Int32
+	sched.EXPECT().Invoke(gomock.Any(), "svc-a", gomock.Any()).Times(6).
+		DoAndReturn(func(_ context.Context, _ string, payload []byte) ([]byte, error) {
+			peak.Store(max(peak.Load(), running.Add(1)))
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+			return payload, nil
+		})
+
+	sm := config.StateMachine{
+		Name:    "map-concurrency",
+		StartAt: "each",
+		States: map[string]config.State{
+			"each": {
+				Type:           "Map",
+				MaxConcurrency: 2,
+				Iterator: &config.ItemProcessor{StateMachine: config.StateMachine{
+					StartAt: "task",
+					States:  map[string]config.State{"task": {Type: "Task", Resource: "svc-a", End: true}},
+				}},
+				End: true,
+			},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), sched, newLogger())
+	out, err := ex.Execute(context.Background(), "map-concurrency", []byte(`[1,2,3,4,5,6]`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[1,2,3,4,5,6]`, string(out), "results keep the order of the items")
+	assert.LessOrEqual(t, peak.Load(), int32(2))
+}
+
+func TestExecute_MapState_ToleratedFailures(t *testing.T) {
+	state := config.State{
+		Type:                  "Map",
+		ItemProcessor:         mapProcessor(),
+		ToleratedFailureCount: 1,
+		End:                   true,
+	}
+	sm := config.StateMachine{Name: "map-tolerated", StartAt: "each", States: map[string]config.State{"each": state}}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+
+	out, err := ex.Execute(context.Background(), "map-tolerated", []byte(`[{"status":"ok"},{"status":"bad"}]`))
+	require.NoError(t, err)
+	var results []map[string]any
+	require.NoError(t, json.Unmarshal(out, &results))
+	require.Len(t, results, 2)
+	assert.Equal(t, map[string]any{"status": "ok"}, results[0])
+	assert.Equal(t, "ItemFailed", results[1]["Error"])
+
+	_, err = ex.Execute(context.Background(), "map-tolerated", []byte(`[{"status":"bad"},{"status":"bad"},{"status":"ok"}]`))
+	require.Error(t, err)
+	assert.Equal(t, ErrExceedToleratedFailureThreshold, classifyError(err))
+}
+
+func TestExecute_MapState_FailurePercentageCaught(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "map-catch",
+		StartAt: "each",
+		States: map[string]config.State{
+			"each": {
+				Type:                       "Map",
+				ItemProcessor:              mapProcessor(),
+				ItemsPath:                  "$.items",
+				ToleratedFailurePercentage: 25,
+				Catch:                      []config.CatchConfig{{Errors: []string{ErrExceedToleratedFailureThreshold}, Next: "recover", ResultPath: "$.error"}},
+				Next:                       "done",
+			},
+			"recover": {Type: "Pass", End: true},
+			"done":    {Type: "Succeed"},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	out, err := ex.Execute(context.Background(), "map-catch", []byte(`{"items":[{"status":"bad"},{"status":"bad"},{"status":"ok"},{"status":"ok"}]}`))
+	require.NoError(t, err)
+	assert.Contains(t, string(out), ErrExceedToleratedFailureThreshold)
+}
+
+func TestExecute_MapState_ItemErrorWithoutTolerance(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "map-fail",
+		StartAt: "each",
+		States:  map[string]config.State{"each": {Type: "Map", ItemProcessor: mapProcessor(), End: true}},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	_, err := ex.Execute(context.Background(), "map-fail", []byte(`[{"status":"ok"},{"status":"bad"}]`))
+	require.Error(t, err)
+	assert.Equal(t, "ItemFailed", classifyError(err), "the item's error fails the Map")
+
+	_, err = ex.Execute(context.Background(), "map-fail", []byte(`{"items":"nope"}`))
+	require.Error(t, err)
+	assert.Equal(t, ErrRuntime, classifyError(err))
+}
+
+// distributedProcessor returns mapProcessor in DISTRIBUTED mode.
+func distributedProcessor() *config.ItemProcessor {
+	p := mapProcessor()
+	p.ProcessorConfig.Mode = MapModeDistributed
+	return p
+}
+
+// distributedPassProcessor returns a DISTRIBUTED item processor that passes
+// items through.
+func distributedPassProcessor() *config.ItemProcessor {
+	return &config.ItemProcessor{
+		ProcessorConfig: config.ProcessorConfig{Mode: MapModeDistributed},
+		StateMachine: config.StateMachine{
+			StartAt: "done",
+			States:  map[string]config.State{"done": {Type: "Pass", End: true}},
+		},
+	}
+}
+
+func TestExecute_DistributedMap_ReadsItems(t *testing.T) {
+	bucket := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bucket, "items.json"), []byte(`[{"id":1},{"id":2},{"id":3}]`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(bucket, "items.jsonl"), []byte("{\"id\":1}\n\n{\"id\":2}\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(bucket, "items.csv"), []byte("id,name\n1,a\n2,b\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(bucket, "rows.csv"), []byte("1,a\n"), 0o644))
+
+	cases := []struct {
+		name   string
+		reader config.ItemReader
+		want   string
+	}{
+		{
+			name: "json with MaxItems",
+			reader: config.ItemReader{
+				Resource:     ResourceS3GetObject,
+				ReaderConfig: config.ReaderConfig{InputType: "JSON", MaxItems: 2},
+				Parameters:   map[string]any{"bucket.$": "$.bucket", "key": "items.json"},
+			},
+			want: `[{"id":1},{"id":2}]`,
+		},
+		{
+			name: "jsonl",
+			reader: config.ItemReader{
+				Resource:     ResourceS3GetObject,
+				ReaderConfig: config.ReaderConfig{InputType: "JSONL"},
+				Parameters:   map[string]any{"Bucket": filepath.Join(bucket, "items.jsonl")},
+			},
+			want: `[{"id":1},{"id":2}]`,
+		},
+		{
+			name: "csv with first row headers",
+			reader: config.ItemReader{
+				Resource:     ResourceS3GetObject,
+				ReaderConfig: config.ReaderConfig{InputType: "CSV"},
+				Parameters:   map[string]any{"Bucket": bucket, "Key": "items.csv"},
+			},
+			want: `[{"id":"1","name":"a"},{"id":"2","name":"b"}]`,
+		},
+		{
+			name: "csv with given headers",
+			reader: config.ItemReader{
+				Resource:     ResourceS3GetObject,
+				ReaderConfig: config.ReaderConfig{InputType: "CSV", CSVHeaderLocation: "GIVEN", CSVHeaders: []string{"id", "name"}},
+				Parameters:   map[string]any{"Bucket": bucket, "Key": "rows.csv"},
+			},
+			want: `[{"id":"1","name":"a"}]`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := tc.reader
+			sm := config.StateMachine{
+				Name:    "dmap",
+				StartAt: "each",
+				States: map[string]config.State{"each": {
+					Type:          "Map",
+					ItemProcessor: distributedPassProcessor(),
+					ItemReader:    &reader,
+					End:           true,
+				}},
+			}
+			ex := NewExecutor(buildCfg(sm), nil, newLogger())
+			out, err := ex.Execute(context.Background(), "dmap", mustJSON(map[string]string{"bucket": bucket}))
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.want, string(out))
+		})
+	}
+}
+
+func TestReadItems_NonStringParameter(t *testing.T) {
+	reader := &config.ItemReader{
+		Resource:   ResourceS3GetObject,
+		Parameters: map[string]any{"Bucket": t.TempDir(), "Key": map[string]any{"$": "$.key"}},
+	}
+	_, err := readItems(reader, []byte(`{}`), nil)
+	assert.ErrorContains(t, err, "ItemReader error: Parameters.Key must be a string, got object")
+}
+
+func TestExecute_DistributedMap_ListObjects(t *testing.T) {
+	bucket := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(bucket, "logs", "2024"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(bucket, "logs", "2024", "b.log"), []byte("bb"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(bucket, "logs", "a.log"), []byte("a"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(bucket, "other.txt"), []byte("x"), 0o644))
+
+	sm := config.StateMachine{
+		Name:    "dmap-list",
+		StartAt: "each",
+		States: map[string]config.State{"each": {
+			Type:          "Map",
+			ItemProcessor: distributedPassProcessor(),
+			ItemReader: &config.ItemReader{
+				Resource:   ResourceS3ListObjectsV2,
+				Parameters: map[string]any{"Bucket": bucket, "Prefix": "logs/"},
+			},
+			ItemSelector: map[string]any{"key.$": "$$.Map.Item.Value.Key", "size.$": "$$.Map.Item.Value.Size"},
+			End:          true,
+		}},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	out, err := ex.Execute(context.Background(), "dmap-list", []byte(`{}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"key":"logs/2024/b.log","size":2},{"key":"logs/a.log","size":1}]`, string(out))
+}
+
+func TestExecute_DistributedMap_ItemBatcher(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "dmap-batch",
+		StartAt: "each",
+		States: map[string]config.State{"each": {
+			Type:          "Map",
+			ItemProcessor: distributedPassProcessor(),
+			ItemBatcher: &config.ItemBatcher{
+				MaxItemsPerBatch: 2,
+				BatchInput:       map[string]any{"run.$": "$.run"},
+			},
+			ItemsPath: "$.items",
+			End:       true,
+		}},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	out, err := ex.Execute(context.Background(), "dmap-batch", []byte(`{"run":"r1","items":[1,2,3]}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"BatchInput":{"run":"r1"},"Items":[1,2]},
+		{"BatchInput":{"run":"r1"},"Items":[3]}
+	]`, string(out))
+
+	batches, err := batchItems(&config.ItemBatcher{MaxInputBytesPerBatch: 25}, []json.RawMessage{
+		json.RawMessage(`"aaaa"`), json.RawMessage(`"bbbb"`), json.RawMessage(`"cccc"`),
+	}, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+	assert.Equal(t, `{"Items":["aaaa","bbbb"]}`, string(batches[0]), "a batch fills up to exactly the limit")
+	assert.Equal(t, `{"Items":["cccc"]}`, string(batches[1]))
+
+	_, err = batchItems(&config.ItemBatcher{}, nil, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestExecute_DistributedMap_ResultWriter(t *testing.T) {
+	results := t.TempDir()
+	state := config.State{
+		Type:                  "Map",
+		ItemProcessor:         distributedProcessor(),
+		ToleratedFailureCount: 1,
+		ResultWriter: &config.ResultWriter{
+			Resource:   ResourceS3PutObject,
+			Parameters: map[string]any{"Bucket": results, "Prefix": "out"},
+		},
+		End: true,
+	}
+	sm := config.StateMachine{Name: "dmap-write", StartAt: "each", States: map[string]config.State{"each": state}}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+
+	out, err := ex.Execute(context.Background(), "dmap-write", []byte(`[{"status":"ok"},{"status":"bad"}]`))
+	require.NoError(t, err)
+
+	var output struct {
+		MapRunArn           string
+		ResultWriterDetails struct{ Bucket, Key string }
+	}
+	require.NoError(t, json.Unmarshal(out, &output))
+	assert.Contains(t, output.MapRunArn, ":mapRun:dmap-write/each:")
+	assert.Equal(t, results, output.ResultWriterDetails.Bucket)
+
+	data, err := os.ReadFile(filepath.Join(results, filepath.FromSlash(output.ResultWriterDetails.Key)))
+	require.NoError(t, err)
+	var m manifest
+	require.NoError(t, json.Unmarshal(data, &m))
+	assert.Equal(t, output.MapRunArn, m.MapRunArn)
+	assert.Empty(t, m.ResultFiles["PENDING"])
+	require.Len(t, m.ResultFiles["SUCCEEDED"], 1)
+	require.Len(t, m.ResultFiles["FAILED"], 1)
+
+	data, err = os.ReadFile(filepath.Join(results, filepath.FromSlash(m.ResultFiles["SUCCEEDED"][0].Key)))
+	require.NoError(t, err)
+	var succeeded []resultEntry
+	require.NoError(t, json.Unmarshal(data, &succeeded))
+	require.Len(t, succeeded, 1)
+	assert.Equal(t, `{"status":"ok"}`, succeeded[0].Input)
+	assert.Equal(t, `{"status":"ok"}`, succeeded[0].Output)
+
+	data, err = os.ReadFile(filepath.Join(results, filepath.FromSlash(m.ResultFiles["FAILED"][0].Key)))
+	require.NoError(t, err)
+	var failed []resultEntry
+	require.NoError(t, json.Unmarshal(data, &failed))
+	require.Len(t, failed, 1)
+	assert.Equal(t, "ItemFailed", failed[0].Error)
+	assert.Equal(t, "FAILED", failed[0].Status)
+}
+
+func TestExecute_InlineMap_RejectsItemReader(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "inline-reader",
+		StartAt: "each",
+		States: map[string]config.State{"each": {
+			Type:          "Map",
+			ItemProcessor: mapProcessor(),
+			ItemReader:    &config.ItemReader{Resource: ResourceS3GetObject},
+			End:           true,
+		}},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	_, err := ex.Execute(context.Background(), "inline-reader", []byte(`{}`))
+	assert.ErrorContains(t, err, "DISTRIBUTED")
+}
+
+// ---------------------------------------------------------------------------
+// Parameters and ResultSelector
+// ---------------------------------------------------------------------------
+
+func TestExecute_TaskState_ParametersAndResultSelector(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+
+	sched.EXPECT().
+		Invoke(gomock.Any(), "svc-a", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, payload []byte) ([]byte, error) {
+			assert.JSONEq(t, `{"greeting":"Hello, Alice!","static":1,"user":{"id":"42"}}`, string(payload))
+			return []byte(`{"status":200,"body":"{\"ok\":true}","headers":{}}`), nil
+		})
+
+	sm := config.StateMachine{
+		Name:    "params-test",
+		StartAt: "greet",
+		States: map[string]config.State{
+			"greet": {
+				Type:     "Task",
+				Resource: "svc-a",
+				Parameters: map[string]any{
+					"greeting.$": "States.Format('Hello, {}!', $.name)",
+					"static":     1,
+					"user":       map[string]any{"id.$": "$.id"},
+				},
+				ResultSelector: map[string]any{
+					"status.$": "$.status",
+					"body.$":   "States.StringToJson($.body)",
+				},
+				ResultPath: "$.response",
+				OutputPath: "$.response",
+				End:        true,
+			},
+		},
+	}
+
+	ex := NewExecutor(buildCfg(sm), sched, newLogger())
+	out, err := ex.Execute(context.Background(), "params-test", []byte(`{"name":"Alice","id":"42"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":200,"body":{"ok":true}}`, string(out))
+}
+
+func TestExecute_PassState_Parameters(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "pass-params",
+		StartAt: "shape",
+		States: map[string]config.State{
+			"shape": {
+				Type:      "Pass",
+				InputPath: "$.order",
+				Parameters: map[string]any{
+					"count.$": "States.ArrayLength($.items)",
+					"first.$": "States.ArrayGetItem($.items, 0)",
+				},
+				ResultPath: "$.summary",
+				End:        true,
+			},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	out, err := ex.Execute(context.Background(), "pass-params", []byte(`{"order":{"items":["a","b"]}}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"order":{"items":["a","b"]},"summary":{"count":2,"first":"a"}}`, string(out))
+}
+
+func TestExecute_ParallelState_ParametersAndResultSelector(t *testing.T) {
+	branch := func(name string) config.StateMachine {
+		return config.StateMachine{Name: name, StartAt: "done", States: map[string]config.State{"done": {Type: "Pass", End: true}}}
+	}
+	sm := config.StateMachine{
+		Name:    "parallel-params",
+		StartAt: "fan",
+		States: map[string]config.State{
+			"fan": {
+				Type:           "Parallel",
+				Branches:       []config.StateMachine{branch("a"), branch("b")},
+				Parameters:     map[string]any{"id.$": "$.id"},
+				ResultSelector: map[string]any{"branches.$": "States.ArrayLength($)", "outputs.$": "$"},
+				End:            true,
+			},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	out, err := ex.Execute(context.Background(), "parallel-params", []byte(`{"id":7,"other":true}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"branches":2,"outputs":[{"id":7},{"id":7}]}`, string(out))
+}
+
+func TestExecute_MapState_ParametersAndResultSelector(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "map-params",
+		StartAt: "each",
+		States: map[string]config.State{
+			"each": {
+				Type:          "Map",
+				ItemProcessor: mapProcessor(),
+				// Parameters is the older name of ItemSelector.
+				Parameters:     map[string]any{"status.$": "$$.Map.Item.Value"},
+				ResultSelector: map[string]any{"count.$": "States.ArrayLength($)"},
+				End:            true,
+			},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	out, err := ex.Execute(context.Background(), "map-params", []byte(`["ok","fine"]`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"count":2}`, string(out))
+}
+
+func TestExecute_IntrinsicFailure(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "intrinsic-fail",
+		StartAt: "shape",
+		States: map[string]config.State{
+			"shape": {
+				Type:       "Pass",
+				Parameters: map[string]any{"item.$": "States.ArrayGetItem($.items, 5)"},
+				End:        true,
+			},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	_, err := ex.Execute(context.Background(), "intrinsic-fail", []byte(`{"items":[1,2]}`))
+	require.Error(t, err)
+	assert.Equal(t, ErrIntrinsicFailure, classifyError(err))
+	assert.Contains(t, err.Error(), "index 5 is out of range")
+
+	sm.States["shape"] = config.State{Type: "Pass", Parameters: map[string]any{"item.$": "$.missing"}, End: true}
+	ex = NewExecutor(buildCfg(sm), nil, newLogger())
+	_, err = ex.Execute(context.Background(), "intrinsic-fail", []byte(`{}`))
+	require.Error(t, err)
+	assert.Equal(t, ErrRuntime, classifyError(err))
+}
+
+// ---------------------------------------------------------------------------
+// Definitions loaded from YAML
+// ---------------------------------------------------------------------------
+
+func TestExecute_LoadedFromYAML(t *testing.T) {
+	bucket := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bucket, "tags.json"), []byte(`["a","b"]`), 0o644))
+
+	data := []byte(`
+workflows:
+  greet:
+    StartAt: Greet
+    States:
+      Greet:
+        Type: Task
+        Resource: svc-a
+        Parameters:
+          greeting.$: "States.Format('Hello, {}!', $.user.name)"
+          userId.$: "$.user.id"
+          executionId.$: "$$.Execution.Id"
+          source: workflow
+        ResultSelector:
+          statusCode.$: "$.statusCode"
+        ResultPath: "$.greeting"
+        Next: Defaults
+      Defaults:
+        Type: Pass
+        Result:
+          retryLimit: 3
+          Region.Name: eu-west-1
+        ResultPath: "$.defaults"
+        Next: Tags
+      Tags:
+        Type: Map
+        ItemReader:
+          Resource: arn:aws:states:::s3:getObject
+          Parameters:
+            Bucket.$: "$.bucket"
+            Key.$: "$.key"
+        ItemSelector:
+          tagName.$: "$$.Map.Item.Value"
+        ItemProcessor:
+          ProcessorConfig:
+            Mode: DISTRIBUTED
+          StartAt: Done
+          States:
+            Done:
+              Type: Pass
+              End: true
+        ResultPath: "$.tags"
+        End: true
+`)
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(bytes.NewReader(data)))
+	cfg := &config.Config{}
+	require.NoError(t, v.Unmarshal(cfg))
+	require.NoError(t, cfg.DecodeWorkflowData(data))
+
+	ctrl := gomock.NewController(t)
+	sched := mocks.NewMockSchedulerInterface(ctrl)
+	var payload map[string]any
+	sched.EXPECT().Invoke(gomock.Any(), "svc-a", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, p []byte) ([]byte, error) {
+			require.NoError(t, json.Unmarshal(p, &payload))
+			return []byte(`{"statusCode":200,"body":"ignored"}`), nil
+		})
+
+	ex := NewExecutor(cfg, sched, newLogger())
+	input := mustJSON(map[string]any{"user": map[string]any{"name": "Alice", "id": 42}, "bucket": bucket, "key": "tags.json"})
+	out, err := ex.Execute(context.Background(), "greet", input)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Hello, Alice!", payload["greeting"])
+	assert.Equal(t, float64(42), payload["userId"])
+	assert.Contains(t, payload["executionId"], "arn:aws:states:")
+	assert.Equal(t, "workflow", payload["source"])
+
+	var got map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(out, &got))
+	assert.JSONEq(t, `{"statusCode":200}`, string(got["greeting"]))
+	assert.JSONEq(t, `{"retryLimit":3,"Region.Name":"eu-west-1"}`, string(got["defaults"]))
+	assert.JSONEq(t, `[{"tagName":"a"},{"tagName":"b"}]`, string(got["tags"]))
+}
@@ -0,0 +1,733 @@
+package workflow
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ---------------------------------------------------------------------------
+// Intrinsic functions
+// ---------------------------------------------------------------------------
+
+// Limits AWS places on the arguments and results of intrinsic functions.
+const (
+	maxIntrinsicStringLength = 10000
+	maxArrayRangeItems       = 1000
+)
+
+// intrinsicError reports an intrinsic function that cannot be parsed or
+// evaluated. It fails the state with States.IntrinsicFailure.
+type intrinsicError struct {
+	expr string
+	msg  string
+}
+
+func (e *intrinsicError) Error() string {
+	return fmt.Sprintf("intrinsic function %s: %s", e.expr, e.msg)
+}
+
+// isIntrinsic reports whether the value of a ".$" field calls an intrinsic
+// function rather than naming a path.
+func isIntrinsic(expr string) bool {
+	return strings.HasPrefix(strings.TrimSpace(expr), "States.")
+}
+
+// evaluateIntrinsic parses and evaluates the intrinsic function call expr.
+// Its paths refer to data or, starting "$$", to contextObject.
+func evaluateIntrinsic(expr string, data, contextObject []byte) (any, error) {
+	call, err := parseIntrinsic(expr)
+	if err != nil {
+		return nil, &intrinsicError{expr: expr, msg: err.Error()}
+	}
+	value, err := call.evaluate(data, contextObject)
+	if err != nil {
+		return nil, &intrinsicError{expr: expr, msg: err.Error()}
+	}
+	return value, nil
+}
+
+// intrinsicCall is a parsed call of an intrinsic function.
+type intrinsicCall struct {
+	name string
+	args []intrinsicArg
+}
+
+// intrinsicArg is an argument of an intrinsic function: a literal, a path
+// or a nested call.
+type intrinsicArg struct {
+	literal any
+	// raw is a string literal as written, escapes included.
+	raw  string
+	path string
+	call *intrinsicCall
+}
+
+// intrinsicFunc describes an intrinsic function. maxArgs is -1 for
+// functions taking any number of arguments.
+type intrinsicFunc struct {
+	minArgs, maxArgs int
+	call             func(call *intrinsicCall, args []any) (any, error)
+}
+
+var intrinsics = map[string]intrinsicFunc{
+	"States.Format":         {1, -1, intrinsicFormat},
+	"States.StringToJson":   {1, 1, intrinsicStringToJSON},
+	"States.JsonToString":   {1, 1, intrinsicJSONToString},
+	"States.Array":          {0, -1, intrinsicArray},
+	"States.ArrayPartition": {2, 2, intrinsicArrayPartition},
+	"States.ArrayContains":  {2, 2, intrinsicArrayContains},
+	"States.ArrayRange":     {3, 3, intrinsicArrayRange},
+	"States.ArrayGetItem":   {2, 2, intrinsicArrayGetItem},
+	"States.ArrayLength":    {1, 1, intrinsicArrayLength},
+	"States.ArrayUnique":    {1, 1, intrinsicArrayUnique},
+	"States.Base64Encode":   {1, 1, intrinsicBase64Encode},
+	"States.Base64Decode":   {1, 1, intrinsicBase64Decode},
+	"States.Hash":           {2, 2, intrinsicHash},
+	"States.JsonMerge":      {3, 3, intrinsicJSONMerge},
+	"States.MathRandom":     {2, 3, intrinsicMathRandom},
+	"States.MathAdd":        {2, 2, intrinsicMathAdd},
+	"States.StringSplit":    {2, 2, intrinsicStringSplit},
+	"States.UUID":           {0, 0, intrinsicUUID},
+}
+
+// evaluate returns the result of the call, after evaluating its arguments.
+func (c *intrinsicCall) evaluate(data, contextObject []byte) (any, error) {
+	fn := intrinsics[c.name]
+	if len(c.args) < fn.minArgs || (fn.maxArgs >= 0 && len(c.args) > fn.maxArgs) {
+		return nil, fmt.Errorf("%s takes %s, got %d", c.name, arity(fn), len(c.args))
+	}
+	args := make([]any, len(c.args))
+	for i, arg := range c.args {
+		value, err := arg.evaluate(data, contextObject)
+		if err != nil {
+			return nil, fmt.Errorf("%s argument %d: %w", c.name, i+1, err)
+		}
+		args[i] = value
+	}
+	result, err := fn.call(c, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.name, err)
+	}
+	return result, nil
+}
+
+func (a *intrinsicArg) evaluate(data, contextObject []byte) (any, error) {
+	switch {
+	case a.call != nil:
+		return a.call.evaluate(data, contextObject)
+	case a.path != "":
+		return resolvePath(a.path, data, contextObject)
+	default:
+		return a.literal, nil
+	}
+}
+
+func arity(fn intrinsicFunc) string {
+	switch {
+	case fn.maxArgs < 0:
+		return fmt.Sprintf("at least %d arguments", fn.minArgs)
+	case fn.minArgs == fn.maxArgs && fn.minArgs == 1:
+		return "1 argument"
+	case fn.minArgs == fn.maxArgs:
+		return fmt.Sprintf("%d arguments", fn.minArgs)
+	default:
+		return fmt.Sprintf("%d to %d arguments", fn.minArgs, fn.maxArgs)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Parser
+// ---------------------------------------------------------------------------
+
+// intrinsicParser parses intrinsic function calls:
+//
+//	call     = name "(" [ argument { "," argument } ] ")"
+//	argument = string | number | "true" | "false" | "null" | path | call
+//
+// Strings are quoted with ' and escape ', {, } and \ with a backslash.
+type intrinsicParser struct {
+	src string
+	pos int
+}
+
+func parseIntrinsic(expr string) (*intrinsicCall, error) {
+	p := &intrinsicParser{src: expr}
+	p.skipSpace()
+	call, err := p.parseCall()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.done() {
+		return nil, p.errorf("unexpected %q after the call", p.src[p.pos:])
+	}
+	return call, nil
+}
+
+// errorf returns an error located at the parser's current position.
+func (p *intrinsicParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), p.pos+1)
+}
+
+func (p *intrinsicParser) done() bool { return p.pos >= len(p.src) }
+
+func (p *intrinsicParser) skipSpace() {
+	for !p.done() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *intrinsicParser) consume(c byte) bool {
+	if !p.done() && p.src[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// found describes the character at the parser's position for errors.
+func (p *intrinsicParser) found() string {
+	if p.done() {
+		return "the end of the expression"
+	}
+	return strconv.Quote(p.src[p.pos : p.pos+1])
+}
+
+func (p *intrinsicParser) parseCall() (*intrinsicCall, error) {
+	start := p.pos
+	for !p.done() && (isWordChar(p.src[p.pos]) || p.src[p.pos] == '.') {
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	if _, ok := intrinsics[name]; !ok {
+		p.pos = start
+		if name == "" {
+			return nil, p.errorf("expected a function name, found %s", p.found())
+		}
+		return nil, p.errorf("unknown function %q", name)
+	}
+	p.skipSpace()
+	if !p.consume('(') {
+		return nil, p.errorf("expected \"(\" after %s, found %s", name, p.found())
+	}
+
+	call := &intrinsicCall{name: name}
+	p.skipSpace()
+	if p.consume(')') {
+		return call, nil
+	}
+	for {
+		p.skipSpace()
+		arg, err := p.parseArg()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		p.skipSpace()
+		if p.consume(')') {
+			return call, nil
+		}
+		if !p.consume(',') {
+			return nil, p.errorf("expected \",\" or \")\" in %s, found %s", name, p.found())
+		}
+	}
+}
+
+func (p *intrinsicParser) parseArg() (intrinsicArg, error) {
+	if p.done() {
+		return intrinsicArg{}, p.errorf("expected an argument, found %s", p.found())
+	}
+	switch c := p.src[p.pos]; {
+	case c == '\'':
+		return p.parseString()
+	case c == '$':
+		return p.parsePath()
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	case strings.HasPrefix(p.src[p.pos:], "States."):
+		call, err := p.parseCall()
+		return intrinsicArg{call: call}, err
+	}
+
+	start := p.pos
+	for !p.done() && isWordChar(p.src[p.pos]) {
+		p.pos++
+	}
+	switch word := p.src[start:p.pos]; word {
+	case "true":
+		return intrinsicArg{literal: true}, nil
+	case "false":
+		return intrinsicArg{literal: false}, nil
+	case "null":
+		return intrinsicArg{}, nil
+	default:
+		p.pos = start
+		return intrinsicArg{}, p.errorf("expected an argument, found %s", p.found())
+	}
+}
+
+func (p *intrinsicParser) parseString() (intrinsicArg, error) {
+	start := p.pos
+	p.pos++ // opening quote
+	var value strings.Builder
+	for !p.done() {
+		c := p.src[p.pos]
+		switch c {
+		case '\'':
+			p.pos++
+			return intrinsicArg{literal: value.String(), raw: p.src[start+1 : p.pos-1]}, nil
+		case '\\':
+			p.pos++
+			if p.done() {
+				return intrinsicArg{}, p.errorf("unterminated escape")
+			}
+			value.WriteByte(p.src[p.pos])
+		default:
+			value.WriteByte(c)
+		}
+		p.pos++
+	}
+	p.pos = start
+	return intrinsicArg{}, p.errorf("unterminated string")
+}
+
+// parsePath reads a path up to the "," or ")" that ends the argument,
+// skipping those inside brackets, parentheses and quotes of the path.
+func (p *intrinsicParser) parsePath() (intrinsicArg, error) {
+	start := p.pos
+	depth := 0
+	var quote byte
+	for ; !p.done(); p.pos++ {
+		c := p.src[p.pos]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				p.pos++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || (c == ')' && depth > 0):
+			depth--
+		case (c == ',' || c == ')') && depth == 0:
+			return intrinsicArg{path: strings.TrimSpace(p.src[start:p.pos])}, nil
+		}
+	}
+	return intrinsicArg{path: strings.TrimSpace(p.src[start:])}, nil
+}
+
+func (p *intrinsicParser) parseNumber() (intrinsicArg, error) {
+	start := p.pos
+	for !p.done() && strings.IndexByte("+-.eE0123456789", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return intrinsicArg{}, p.errorf("invalid number %q", p.src[start:p.pos])
+	}
+	return intrinsicArg{literal: n}, nil
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// ---------------------------------------------------------------------------
+// Functions
+// ---------------------------------------------------------------------------
+
+// intrinsicFormat replaces every {} of its template, the first argument,
+// with the next argument. Escaped braces, \{ and \}, are kept literally.
+func intrinsicFormat(call *intrinsicCall, args []any) (any, error) {
+	template, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if raw := call.args[0].raw; call.args[0].call == nil && call.args[0].path == "" {
+		template = raw
+	} else {
+		template = strings.ReplaceAll(template, `\`, `\\`)
+	}
+
+	values := make([]string, 0, len(args)-1)
+	for i, arg := range args[1:] {
+		switch v := arg.(type) {
+		case string:
+			values = append(values, v)
+		case nil, bool, float64, int:
+			encoded, _ := json.Marshal(v)
+			values = append(values, string(encoded))
+		default:
+			return nil, fmt.Errorf("argument %d must be a string, number, boolean or null, got %s", i+2, jsonType(arg))
+		}
+	}
+
+	var out strings.Builder
+	next := 0
+	for i := 0; i < len(template); i++ {
+		switch {
+		case template[i] == '\\' && i+1 < len(template):
+			i++
+			out.WriteByte(template[i])
+		case template[i] == '{' && i+1 < len(template) && template[i+1] == '}':
+			if next < len(values) {
+				out.WriteString(values[next])
+			}
+			next++
+			i++
+		default:
+			out.WriteByte(template[i])
+		}
+	}
+	if next != len(values) {
+		return nil, fmt.Errorf("template has %d placeholders but %d values were given", next, len(values))
+	}
+	return out.String(), nil
+}
+
+func intrinsicStringToJSON(_ *intrinsicCall, args []any) (any, error) {
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return nil, fmt.Errorf("argument 1 is not valid JSON: %w", err)
+	}
+	return value, nil
+}
+
+func intrinsicJSONToString(_ *intrinsicCall, args []any) (any, error) {
+	encoded, err := json.Marshal(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+func intrinsicArray(_ *intrinsicCall, args []any) (any, error) {
+	return append([]any{}, args...), nil
+}
+
+func intrinsicArrayPartition(_ *intrinsicCall, args []any) (any, error) {
+	array, err := arrayArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	size, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("argument 2 must be a positive chunk size, got %d", size)
+	}
+	chunks := []any{}
+	for start := 0; start < len(array); start += size {
+		chunks = append(chunks, array[start:min(start+size, len(array))])
+	}
+	return chunks, nil
+}
+
+func intrinsicArrayContains(_ *intrinsicCall, args []any) (any, error) {
+	array, err := arrayArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	want := canonicalJSON(args[1])
+	for _, item := range array {
+		if canonicalJSON(item) == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func intrinsicArrayRange(_ *intrinsicCall, args []any) (any, error) {
+	bounds := make([]int, 3)
+	for i := range bounds {
+		n, err := intArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		bounds[i] = n
+	}
+	start, end, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return nil, errors.New("argument 3, the step, must not be 0")
+	}
+	items := []any{}
+	for n := start; (step > 0 && n <= end) || (step < 0 && n >= end); n += step {
+		if len(items) == maxArrayRangeItems {
+			return nil, fmt.Errorf("range has more than %d items", maxArrayRangeItems)
+		}
+		items = append(items, n)
+	}
+	return items, nil
+}
+
+func intrinsicArrayGetItem(_ *intrinsicCall, args []any) (any, error) {
+	array, err := arrayArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	index, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(array) {
+		return nil, fmt.Errorf("index %d is out of range for an array of length %d", index, len(array))
+	}
+	return array[index], nil
+}
+
+func intrinsicArrayLength(_ *intrinsicCall, args []any) (any, error) {
+	array, err := arrayArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return len(array), nil
+}
+
+func intrinsicArrayUnique(_ *intrinsicCall, args []any) (any, error) {
+	array, err := arrayArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(array))
+	unique := []any{}
+	for _, item := range array {
+		key := canonicalJSON(item)
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, item)
+		}
+	}
+	return unique, nil
+}
+
+func intrinsicBase64Encode(_ *intrinsicCall, args []any) (any, error) {
+	s, err := limitedStringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(s)), nil
+}
+
+func intrinsicBase64Decode(_ *intrinsicCall, args []any) (any, error) {
+	s, err := limitedStringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("argument 1 is not valid base64: %w", err)
+	}
+	return string(decoded), nil
+}
+
+func intrinsicHash(_ *intrinsicCall, args []any) (any, error) {
+	s, err := limitedStringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var h hash.Hash
+	switch algorithm {
+	case "MD5":
+		h = md5.New()
+	case "SHA-1":
+		h = sha1.New()
+	case "SHA-256":
+		h = sha256.New()
+	case "SHA-384":
+		h = sha512.New384()
+	case "SHA-512":
+		h = sha512.New()
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, want MD5, SHA-1, SHA-256, SHA-384 or SHA-512", algorithm)
+	}
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// intrinsicJSONMerge merges the second object into the first. As in AWS,
+// only shallow merges are supported.
+func intrinsicJSONMerge(_ *intrinsicCall, args []any) (any, error) {
+	merged := map[string]any{}
+	for i := range 2 {
+		obj, ok := args[i].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("argument %d must be an object, got %s", i+1, jsonType(args[i]))
+		}
+		for key, value := range obj {
+			merged[key] = value
+		}
+	}
+	deep, ok := args[2].(bool)
+	if !ok {
+		return nil, fmt.Errorf("argument 3 must be a boolean, got %s", jsonType(args[2]))
+	}
+	if deep {
+		return nil, errors.New("only shallow merges are supported, argument 3 must be false")
+	}
+	return merged, nil
+}
+
+// intrinsicMathRandom returns a random integer from start, inclusive, to
+// end, exclusive, from the optional seed.
+func intrinsicMathRandom(_ *intrinsicCall, args []any) (any, error) {
+	start, err := intArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	end, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if end <= start {
+		return nil, fmt.Errorf("end %d must be greater than start %d", end, start)
+	}
+	intN := rand.IntN
+	if len(args) == 3 {
+		seed, err := intArg(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		intN = rand.New(rand.NewPCG(uint64(seed), 0)).IntN
+	}
+	return start + intN(end-start), nil
+}
+
+func intrinsicMathAdd(_ *intrinsicCall, args []any) (any, error) {
+	a, err := intArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	b, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return a + b, nil
+}
+
+// intrinsicStringSplit splits a string at every character of the second
+// argument, dropping empty parts.
+func intrinsicStringSplit(_ *intrinsicCall, args []any) (any, error) {
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	delimiters, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if delimiters == "" {
+		return nil, errors.New("argument 2 must not be empty")
+	}
+	parts := []any{}
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return strings.ContainsRune(delimiters, r) }) {
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+func intrinsicUUID(*intrinsicCall, []any) (any, error) {
+	return uuid.NewString(), nil
+}
+
+// ---------------------------------------------------------------------------
+// Arguments
+// ---------------------------------------------------------------------------
+
+func stringArg(args []any, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", fmt.Errorf("argument %d must be a string, got %s", i+1, jsonType(args[i]))
+	}
+	return s, nil
+}
+
+// limitedStringArg returns a string argument of at most
+// maxIntrinsicStringLength characters.
+func limitedStringArg(args []any, i int) (string, error) {
+	s, err := stringArg(args, i)
+	if err != nil {
+		return "", err
+	}
+	if len(s) > maxIntrinsicStringLength {
+		return "", fmt.Errorf("argument %d is longer than %d characters", i+1, maxIntrinsicStringLength)
+	}
+	return s, nil
+}
+
+func intArg(args []any, i int) (int, error) {
+	var n float64
+	switch v := args[i].(type) {
+	case int: // the result of a nested call
+		n = float64(v)
+	case float64:
+		n = v
+	default:
+		return 0, fmt.Errorf("argument %d must be an integer, got %s", i+1, jsonType(args[i]))
+	}
+	if n != math.Trunc(n) || math.Abs(n) > math.MaxInt32 {
+		return 0, fmt.Errorf("argument %d must be an integer, got %v", i+1, n)
+	}
+	return int(n), nil
+}
+
+func arrayArg(args []any, i int) ([]any, error) {
+	array, ok := args[i].([]any)
+	if !ok {
+		return nil, fmt.Errorf("argument %d must be an array, got %s", i+1, jsonType(args[i]))
+	}
+	return array, nil
+}
+
+// jsonType names the JSON type of a decoded value for errors.
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, int:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// canonicalJSON encodes v with sorted keys, so that equal values compare
+// equal.
+func canonicalJSON(v any) string {
+	encoded, _ := json.Marshal(v)
+	return string(encoded)
+}
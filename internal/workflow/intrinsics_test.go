@@ -0,0 +1,126 @@
+package workflow
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateIntrinsic(t *testing.T) {
+	data := []byte(`{
+		"name": "Alice",
+		"count": 3,
+		"items": [1, 2, 2, 3, {"a": 1}, {"a": 1}],
+		"json": "{\"x\":[1,2]}",
+		"obj": {"a": 1, "b": {"c": 2}},
+		"other": {"b": 3}
+	}`)
+	contextObject := []byte(`{"Map":{"Item":{"Index":4}}}`)
+
+	cases := []struct {
+		expr string
+		want string
+	}{
+		{`States.Format('Hello, {}! You have {} items', $.name, $.count)`, `"Hello, Alice! You have 3 items"`},
+		{`States.Format('{} {} {}', true, null, 1.5)`, `"true null 1.5"`},
+		{`States.Format('\{\} is literal, it\'s {}', 'here')`, `"{} is literal, it's here"`},
+		{`States.Format('item {}', $$.Map.Item.Index)`, `"item 4"`},
+		{`States.StringToJson($.json)`, `{"x":[1,2]}`},
+		{`States.JsonToString($.obj)`, `"{\"a\":1,\"b\":{\"c\":2}}"`},
+		{`States.Array(1, 'two', $.name, States.Array())`, `[1,"two","Alice",[]]`},
+		{`States.ArrayPartition(States.Array(1, 2, 3, 4, 5), 2)`, `[[1,2],[3,4],[5]]`},
+		{`States.ArrayContains($.items, $.obj.b.c)`, `true`},
+		{`States.ArrayContains($.items, 9)`, `false`},
+		{`States.ArrayRange(1, 9, 2)`, `[1,3,5,7,9]`},
+		{`States.ArrayRange(3, 1, -1)`, `[3,2,1]`},
+		{`States.ArrayGetItem($.items, 4)`, `{"a":1}`},
+		{`States.ArrayLength($.items)`, `6`},
+		{`States.ArrayUnique($.items)`, `[1,2,3,{"a":1}]`},
+		{`States.Base64Encode('Data to encode')`, `"RGF0YSB0byBlbmNvZGU="`},
+		{`States.Base64Decode('RGF0YSB0byBlbmNvZGU=')`, `"Data to encode"`},
+		{`States.Hash('input', 'SHA-256')`, `"c96c6d5be8d08a12e7b5cdc1b207fa6b2430974c86803d8891675e76fd992c20"`},
+		{`States.Hash('input', 'MD5')`, `"a43c1b0aa53a0c908810c06ab1ff3967"`},
+		{`States.JsonMerge($.obj, $.other, false)`, `{"a":1,"b":3}`},
+		{`States.MathAdd($.count, -1)`, `2`},
+		{`States.MathAdd(States.ArrayLength($.items), 1)`, `7`},
+		{`States.StringSplit('a,b+c,,d', ',+')`, `["a","b","c","d"]`},
+		{` States.ArrayLength( States.Array( 1 , 2 ) ) `, `2`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			got, err := evaluateIntrinsic(tc.expr, data, contextObject)
+			require.NoError(t, err)
+			encoded, err := json.Marshal(got)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.want, string(encoded))
+		})
+	}
+}
+
+func TestEvaluateIntrinsic_Random(t *testing.T) {
+	for range 20 {
+		n, err := evaluateIntrinsic(`States.MathRandom(1, 3)`, nil, nil)
+		require.NoError(t, err)
+		assert.Contains(t, []int{1, 2}, n)
+	}
+
+	a, err := evaluateIntrinsic(`States.MathRandom(0, 1000, 7)`, nil, nil)
+	require.NoError(t, err)
+	b, err := evaluateIntrinsic(`States.MathRandom(0, 1000, 7)`, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, a, b, "a seed makes the result repeatable")
+
+	id, err := evaluateIntrinsic(`States.UUID()`, nil, nil)
+	require.NoError(t, err)
+	_, err = uuid.Parse(id.(string))
+	assert.NoError(t, err)
+}
+
+func TestEvaluateIntrinsic_Errors(t *testing.T) {
+	data := []byte(`{"items":[1,2],"name":"Alice","obj":{}}`)
+	cases := []struct {
+		expr string
+		want string
+	}{
+		// Parse errors report where the expression went wrong.
+		{`States.Nope(1)`, `unknown function "States.Nope" at position 1`},
+		{`States.Format('a'`, `expected "," or ")" in States.Format, found the end of the expression at position 18`},
+		{`States.Format('a)`, `unterminated string at position 15`},
+		{`States.Array(1 2)`, `expected "," or ")" in States.Array, found "2" at position 16`},
+		{`States.Array(1,)`, `expected an argument, found ")" at position 16`},
+		{`States.Array(yes)`, `expected an argument, found "y" at position 14`},
+		{`States.Array(1) x`, `unexpected "x" after the call at position 17`},
+		{`States.UUID`, `expected "(" after States.UUID, found the end of the expression at position 12`},
+		// Evaluation errors name the function and the argument.
+		{`States.UUID(1)`, `States.UUID takes 0 arguments, got 1`},
+		{`States.ArrayLength()`, `States.ArrayLength takes 1 argument, got 0`},
+		{`States.Format('{} {}', 'a')`, `template has 2 placeholders but 1 values were given`},
+		{`States.Format('{}', $.obj)`, `argument 2 must be a string, number, boolean or null, got object`},
+		{`States.ArrayGetItem($.items, 2)`, `index 2 is out of range for an array of length 2`},
+		{`States.ArrayGetItem($.items, 0.5)`, `argument 2 must be an integer, got 0.5`},
+		{`States.ArrayLength($.name)`, `argument 1 must be an array, got string`},
+		{`States.ArrayLength($.missing)`, `States.ArrayLength argument 1:`},
+		{`States.ArrayRange(1, 2, 0)`, `the step, must not be 0`},
+		{`States.ArrayRange(0, 2000, 1)`, `more than 1000 items`},
+		{`States.ArrayPartition($.items, 0)`, `must be a positive chunk size`},
+		{`States.Base64Decode('!!')`, `not valid base64`},
+		{`States.Hash('a', 'SHA-3')`, `unsupported algorithm "SHA-3"`},
+		{`States.JsonMerge($.obj, $.obj, true)`, `only shallow merges are supported`},
+		{`States.MathRandom(5, 5)`, `end 5 must be greater than start 5`},
+		{`States.StringToJson('{')`, `argument 1 is not valid JSON`},
+		{`States.StringSplit('a', '')`, `argument 2 must not be empty`},
+		{`States.Array(States.ArrayLength(1))`, `States.Array argument 1: States.ArrayLength: argument 1 must be an array, got number`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := evaluateIntrinsic(tc.expr, data, nil)
+			require.Error(t, err)
+			var intrinsic *intrinsicError
+			assert.ErrorAs(t, err, &intrinsic)
+			assert.Contains(t, err.Error(), tc.want)
+		})
+	}
+}
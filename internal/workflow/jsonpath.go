@@ -1,28 +1,38 @@
 package workflow
 
 import (
+	"bytes"
+	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-// applyPath extracts or merges JSON data according to an AWS-style Reference
-// Path (a restricted subset of JSONPath).  The path must start with "$".
+// errResultPathMatch is wrapped by the errors of mergePath when the input
+// has no place for the result at ResultPath. States fail with
+// States.ResultPathMatchFailure.
+var errResultPathMatch = errors.New("ResultPath does not match the input")
+
+// applyPath returns the value at path of data. A definite path, of names and
+// single array indexes, selects one value and fails when it is missing; any
+// other path returns the array of the values it matches, possibly empty.
 //
 // Special values:
 //   - ""   → return data unchanged (AWS default when the field is omitted)
 //   - "$"  → return data unchanged (root reference)
-//   - "$$" → not supported locally; treated as "$"
 //
-// Only simple dot-notation paths are supported (e.g. "$.foo.bar").
-// Array subscripts ("$.items[0]") and filter expressions are not supported.
+// Paths of the context object, starting "$$", go through applyContextPath.
 func applyPath(data []byte, path string) ([]byte, error) {
-	if path == "" || path == "$" || path == "$$" {
+	if path == "" || path == "$" {
 		return data, nil
 	}
 
-	if !strings.HasPrefix(path, "$.") {
-		return nil, fmt.Errorf("invalid reference path %q: must start with \"$.\"", path)
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
 	}
 
 	var root any
@@ -30,22 +40,16 @@ func applyPath(data []byte, path string) ([]byte, error) {
 		return nil, fmt.Errorf("cannot unmarshal input for path %q: %w", path, err)
 	}
 
-	// Walk the path segments after the leading "$.".
-	segments := strings.Split(path[2:], ".")
-	current := root
-	for _, seg := range segments {
-		m, ok := current.(map[string]any)
-		if !ok {
-			return nil, fmt.Errorf("path %q: expected object at segment %q, got %T", path, seg, current)
-		}
-		val, exists := m[seg]
-		if !exists {
-			return nil, fmt.Errorf("path %q: key %q not found", path, seg)
+	var result any
+	if p.definite() {
+		if result, err = p.lookup(root); err != nil {
+			return nil, fmt.Errorf("path %q: %w", path, err)
 		}
-		current = val
+	} else {
+		result = p.evaluate(root)
 	}
 
-	out, err := json.Marshal(current)
+	out, err := json.Marshal(result)
 	if err != nil {
 		return nil, fmt.Errorf("path %q: cannot marshal result: %w", path, err)
 	}
@@ -58,8 +62,11 @@ func applyPath(data []byte, path string) ([]byte, error) {
 //   - ""   → discard the task result; return input unchanged
 //   - "$"  → replace the entire effective input with the task result
 //   - "$.foo.bar" → set/overwrite input["foo"]["bar"] = taskResult
+//   - "$.items[1].result" → set the field of an existing array element
 //
-// Intermediate objects are created automatically if missing.
+// ResultPath must be a reference path, of names and single array indexes.
+// Missing intermediate objects are created; an intermediate that is not an
+// object, or an index outside its array, fails with errResultPathMatch.
 func mergePath(input []byte, result []byte, resultPath string) ([]byte, error) {
 	// Discard task result — return input unchanged.
 	if resultPath == "" {
@@ -71,14 +78,19 @@ func mergePath(input []byte, result []byte, resultPath string) ([]byte, error) {
 		return result, nil
 	}
 
-	if !strings.HasPrefix(resultPath, "$.") {
-		return nil, fmt.Errorf("invalid ResultPath %q: must start with \"$.\"", resultPath)
+	p, err := parseJSONPath(resultPath)
+	if err != nil {
+		return nil, err
+	}
+	if !p.definite() {
+		return nil, fmt.Errorf("invalid ResultPath %q: must be a reference path of names and array indexes", resultPath)
 	}
 
-	var root map[string]any
-	if err := json.Unmarshal(input, &root); err != nil {
-		// If input is not an object, start with an empty object.
-		root = map[string]any{}
+	var root any
+	if len(bytes.TrimSpace(input)) > 0 {
+		if err := json.Unmarshal(input, &root); err != nil {
+			return nil, fmt.Errorf("ResultPath %q: cannot unmarshal input: %w", resultPath, err)
+		}
 	}
 
 	var value any
@@ -86,8 +98,10 @@ func mergePath(input []byte, result []byte, resultPath string) ([]byte, error) {
 		return nil, fmt.Errorf("ResultPath %q: cannot unmarshal task result: %w", resultPath, err)
 	}
 
-	segments := strings.Split(resultPath[2:], ".")
-	setNested(root, segments, value)
+	root, err = setPath(root, p.segments, value)
+	if err != nil {
+		return nil, fmt.Errorf("ResultPath %q: %w", resultPath, err)
+	}
 
 	out, err := json.Marshal(root)
 	if err != nil {
@@ -96,28 +110,767 @@ func mergePath(input []byte, result []byte, resultPath string) ([]byte, error) {
 	return out, nil
 }
 
-// setNested walks the map creating intermediate objects as needed and then
-// sets the leaf key to value.
-func setNested(m map[string]any, keys []string, value any) {
-	if len(keys) == 1 {
-		m[keys[0]] = value
-		return
+// setPath returns node with value set at the definite path segments,
+// creating missing objects along the way.
+func setPath(node any, segments []pathSegment, value any) (any, error) {
+	if len(segments) == 0 {
+		return value, nil
+	}
+	seg := segments[0]
+	switch seg.kind {
+	case segmentName:
+		name := seg.names[0]
+		var m map[string]any
+		switch n := node.(type) {
+		case map[string]any:
+			m = n
+		case nil:
+			m = map[string]any{}
+		default:
+			return nil, fmt.Errorf("%w: cannot set key %q in %s", errResultPathMatch, name, jsonType(node))
+		}
+		child, err := setPath(m[name], segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		m[name] = child
+		return m, nil
+	default: // segmentIndex
+		arr, ok := node.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot set index %d in %s", errResultPathMatch, seg.indexes[0], jsonType(node))
+		}
+		i, ok := arrayIndex(seg.indexes[0], len(arr))
+		if !ok {
+			return nil, fmt.Errorf("%w: index %d is out of range for an array of length %d", errResultPathMatch, seg.indexes[0], len(arr))
+		}
+		child, err := setPath(arr[i], segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = child
+		return arr, nil
+	}
+}
+
+// ---------------------------------------------------------------------------
+// JSONPath engine
+// ---------------------------------------------------------------------------
+
+// jsonPath is a parsed JSONPath expression.
+type jsonPath struct {
+	segments []pathSegment
+}
+
+type segmentKind int
+
+const (
+	segmentName     segmentKind = iota // .name, ['name'] or ['a','b']
+	segmentIndex                       // [0] or [0,2]
+	segmentWildcard                    // .* or [*]
+	segmentSlice                       // [start:end:step]
+	segmentFilter                      // [?(expression)]
+)
+
+// pathSegment selects children of the values matched so far or, when
+// recursive (after ".."), of those values and all their descendants.
+type pathSegment struct {
+	kind      segmentKind
+	recursive bool
+	names     []string
+	indexes   []int
+	// start and end of a slice are nil when omitted; step 0 means 1.
+	start, end *int
+	step       int
+	filter     filterExpr
+}
+
+// definite reports whether the path is a reference path, selecting at most
+// one value with names and single array indexes.
+func (p *jsonPath) definite() bool {
+	for _, seg := range p.segments {
+		switch {
+		case seg.recursive:
+			return false
+		case seg.kind == segmentName && len(seg.names) == 1:
+		case seg.kind == segmentIndex && len(seg.indexes) == 1:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns the value at a definite path of root, or an error naming
+// the first segment that does not match.
+func (p *jsonPath) lookup(root any) (any, error) {
+	current := root
+	for _, seg := range p.segments {
+		if seg.kind == segmentName {
+			name := seg.names[0]
+			m, ok := current.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("expected object at segment %q, got %s", name, jsonType(current))
+			}
+			if current, ok = m[name]; !ok {
+				return nil, fmt.Errorf("key %q not found", name)
+			}
+			continue
+		}
+		index := seg.indexes[0]
+		arr, ok := current.([]any)
+		if !ok {
+			return nil, fmt.Errorf("expected array at index %d, got %s", index, jsonType(current))
+		}
+		i, ok := arrayIndex(index, len(arr))
+		if !ok {
+			return nil, fmt.Errorf("index %d is out of range for an array of length %d", index, len(arr))
+		}
+		current = arr[i]
+	}
+	return current, nil
+}
+
+// evaluate returns every value the path matches in root, in document order.
+func (p *jsonPath) evaluate(root any) []any {
+	return selectSegments(p.segments, root, []any{root})
+}
+
+func selectSegments(segments []pathSegment, root any, nodes []any) []any {
+	for _, seg := range segments {
+		next := []any{}
+		for _, node := range nodes {
+			if !seg.recursive {
+				next = seg.selectFrom(node, root, next)
+				continue
+			}
+			for _, descendant := range descendants(node, nil) {
+				next = seg.selectFrom(descendant, root, next)
+			}
+		}
+		nodes = next
 	}
-	child, ok := m[keys[0]].(map[string]any)
+	return nodes
+}
+
+// selectFrom appends the children of node the segment selects to out.
+func (seg *pathSegment) selectFrom(node, root any, out []any) []any {
+	switch seg.kind {
+	case segmentName:
+		if m, ok := node.(map[string]any); ok {
+			for _, name := range seg.names {
+				if v, ok := m[name]; ok {
+					out = append(out, v)
+				}
+			}
+		}
+	case segmentIndex:
+		if arr, ok := node.([]any); ok {
+			for _, index := range seg.indexes {
+				if i, ok := arrayIndex(index, len(arr)); ok {
+					out = append(out, arr[i])
+				}
+			}
+		}
+	case segmentWildcard:
+		out = append(out, children(node)...)
+	case segmentSlice:
+		if arr, ok := node.([]any); ok {
+			out = append(out, seg.slice(arr)...)
+		}
+	case segmentFilter:
+		for _, child := range children(node) {
+			if seg.filter.match(root, child) {
+				out = append(out, child)
+			}
+		}
+	}
+	return out
+}
+
+// slice returns the elements of arr from start, inclusive, to end,
+// exclusive, by step, with Python's semantics for negative and omitted
+// bounds.
+func (seg *pathSegment) slice(arr []any) []any {
+	n, step := len(arr), seg.step
+	if step == 0 {
+		step = 1
+	}
+	bound := func(b *int, def, lo, hi int) int {
+		if b == nil {
+			return def
+		}
+		i := *b
+		if i < 0 {
+			i += n
+		}
+		return max(lo, min(i, hi))
+	}
+	out := []any{}
+	if step > 0 {
+		for i := bound(seg.start, 0, 0, n); i < bound(seg.end, n, 0, n); i += step {
+			out = append(out, arr[i])
+		}
+		return out
+	}
+	for i := bound(seg.start, n-1, -1, n-1); i > bound(seg.end, -1, -1, n-1); i += step {
+		out = append(out, arr[i])
+	}
+	return out
+}
+
+// children returns the elements of an array or the values of an object, in
+// key order.
+func children(node any) []any {
+	switch n := node.(type) {
+	case []any:
+		return n
+	case map[string]any:
+		keys := make([]string, 0, len(n))
+		for key := range n {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		values := make([]any, len(keys))
+		for i, key := range keys {
+			values[i] = n[key]
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// descendants appends node and every value nested in it to out.
+func descendants(node any, out []any) []any {
+	out = append(out, node)
+	for _, child := range children(node) {
+		out = descendants(child, out)
+	}
+	return out
+}
+
+// arrayIndex resolves index, counting from the end when negative, against
+// an array of length n.
+func arrayIndex(index, n int) (int, bool) {
+	if index < 0 {
+		index += n
+	}
+	return index, index >= 0 && index < n
+}
+
+// ---------------------------------------------------------------------------
+// Filter expressions
+// ---------------------------------------------------------------------------
+
+// filterExpr is the expression of a [?(...)] segment, matched against every
+// child of the values selected so far.
+type filterExpr interface {
+	match(root, current any) bool
+}
+
+type filterOr struct{ left, right filterExpr }
+
+func (f filterOr) match(root, current any) bool {
+	return f.left.match(root, current) || f.right.match(root, current)
+}
+
+type filterAnd struct{ left, right filterExpr }
+
+func (f filterAnd) match(root, current any) bool {
+	return f.left.match(root, current) && f.right.match(root, current)
+}
+
+type filterNot struct{ expr filterExpr }
+
+func (f filterNot) match(root, current any) bool { return !f.expr.match(root, current) }
+
+// filterExists matches when its operand, a path, exists.
+type filterExists struct{ operand filterOperand }
+
+func (f filterExists) match(root, current any) bool {
+	_, ok := f.operand.value(root, current)
+	return ok
+}
+
+type filterCompare struct {
+	op          string
+	left, right filterOperand
+}
+
+func (f filterCompare) match(root, current any) bool {
+	a, ok := f.left.value(root, current)
+	if !ok {
+		return false
+	}
+	b, ok := f.right.value(root, current)
 	if !ok {
-		child = map[string]any{}
+		return false
+	}
+	switch f.op {
+	case "==":
+		return canonicalJSON(a) == canonicalJSON(b)
+	case "!=":
+		return canonicalJSON(a) != canonicalJSON(b)
+	}
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			return ordered(f.op, cmp.Compare(x, y))
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return ordered(f.op, cmp.Compare(x, y))
+		}
+	}
+	return false
+}
+
+func ordered(op string, c int) bool {
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default: // ">="
+		return c >= 0
+	}
+}
+
+// filterOperand is a literal or a path, relative to the current value
+// ("@") or to the root ("$").
+type filterOperand struct {
+	path     *jsonPath
+	relative bool
+	literal  any
+}
+
+// value returns the operand's value, and false when its path is missing.
+func (o filterOperand) value(root, current any) (any, bool) {
+	if o.path == nil {
+		return o.literal, true
+	}
+	base := root
+	if o.relative {
+		base = current
+	}
+	if o.path.definite() {
+		v, err := o.path.lookup(base)
+		return v, err == nil
+	}
+	return o.path.evaluate(base), true
+}
+
+// ---------------------------------------------------------------------------
+// Parser
+// ---------------------------------------------------------------------------
+
+// pathParser parses JSONPath expressions:
+//
+//	path    = "$" { segment }
+//	segment = [ "." ] "." ( name | "*" ) | [ ".." ] "[" selector "]"
+//	selector = "*" | index { "," index } | [ start ] ":" [ end ] [ ":" step ]
+//	         | quoted { "," quoted } | "?(" filter ")"
+//	filter  = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) operand ]
+//	          combined with "&&", "||", "!" and parentheses
+//
+// Filter operands are paths from "@", the current value, or "$", the root,
+// strings quoted with ' or ", numbers, true, false and null.
+type pathParser struct {
+	src string
+	pos int
+}
+
+func parseJSONPath(path string) (*jsonPath, error) {
+	p := &pathParser{src: path}
+	if !p.consume('$') {
+		return nil, fmt.Errorf("invalid path %q: must start with \"$\"", path)
+	}
+	segments, err := p.parseSegments(false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %w", path, err)
+	}
+	return &jsonPath{segments: segments}, nil
+}
+
+// errorf returns an error located at the parser's current position.
+func (p *pathParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), p.pos+1)
+}
+
+func (p *pathParser) done() bool { return p.pos >= len(p.src) }
+
+func (p *pathParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *pathParser) consume(c byte) bool {
+	if p.peek() == c && !p.done() {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *pathParser) consumeString(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *pathParser) skipSpace() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+// found describes the character at the parser's position for errors.
+func (p *pathParser) found() string {
+	if p.done() {
+		return "the end of the path"
+	}
+	return strconv.Quote(p.src[p.pos : p.pos+1])
+}
+
+// parseSegments parses segments up to the end of the path or, in a filter,
+// up to the first character that cannot continue a path.
+func (p *pathParser) parseSegments(inFilter bool) ([]pathSegment, error) {
+	var segments []pathSegment
+	for !p.done() {
+		switch p.peek() {
+		case '.':
+			p.pos++
+			recursive := p.consume('.')
+			if p.peek() == '[' {
+				if !recursive {
+					return nil, p.errorf("unexpected \"[\" after \".\"")
+				}
+				seg, err := p.parseBracket()
+				if err != nil {
+					return nil, err
+				}
+				seg.recursive = true
+				segments = append(segments, seg)
+				continue
+			}
+			if p.consume('*') {
+				segments = append(segments, pathSegment{kind: segmentWildcard, recursive: recursive})
+				continue
+			}
+			name := p.readName(inFilter)
+			if name == "" {
+				return nil, p.errorf("expected a name after \".\", found %s", p.found())
+			}
+			segments = append(segments, pathSegment{kind: segmentName, recursive: recursive, names: []string{name}})
+		case '[':
+			seg, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, seg)
+		default:
+			if inFilter {
+				return segments, nil
+			}
+			return nil, p.errorf("unexpected %s", p.found())
+		}
+	}
+	return segments, nil
+}
+
+// readName reads a name of dot notation. In filters, names also end at
+// spaces, operators and parentheses.
+func (p *pathParser) readName(inFilter bool) string {
+	stop := ".["
+	if inFilter {
+		stop = ".[ \t()=!<>&|,"
+	}
+	start := p.pos
+	for !p.done() && strings.IndexByte(stop, p.src[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *pathParser) parseBracket() (pathSegment, error) {
+	p.pos++ // "["
+	p.skipSpace()
+	var seg pathSegment
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		seg.kind = segmentWildcard
+	case c == '?':
+		p.pos++
+		p.skipSpace()
+		if !p.consume('(') {
+			return seg, p.errorf("expected \"(\" after \"?\", found %s", p.found())
+		}
+		filter, err := p.parseOr()
+		if err != nil {
+			return seg, err
+		}
+		p.skipSpace()
+		if !p.consume(')') {
+			return seg, p.errorf("expected \")\" to close the filter, found %s", p.found())
+		}
+		seg.kind, seg.filter = segmentFilter, filter
+	case c == '\'' || c == '"':
+		seg.kind = segmentName
+		for {
+			name, err := p.readQuoted()
+			if err != nil {
+				return seg, err
+			}
+			seg.names = append(seg.names, name)
+			p.skipSpace()
+			if !p.consume(',') {
+				break
+			}
+			p.skipSpace()
+		}
+	case c == '-' || c == ':' || (c >= '0' && c <= '9'):
+		var err error
+		if seg, err = p.parseIndexes(); err != nil {
+			return seg, err
+		}
+	default:
+		return seg, p.errorf("expected an index, a slice, a quoted name, \"*\" or a filter, found %s", p.found())
+	}
+	p.skipSpace()
+	if !p.consume(']') {
+		return seg, p.errorf("expected \"]\", found %s", p.found())
+	}
+	return seg, nil
+}
+
+// parseIndexes parses the indexes or the slice of a bracket.
+func (p *pathParser) parseIndexes() (pathSegment, error) {
+	first, hasFirst, err := p.readInt()
+	if err != nil {
+		return pathSegment{}, err
+	}
+	p.skipSpace()
+	if p.consume(':') {
+		seg := pathSegment{kind: segmentSlice}
+		if hasFirst {
+			seg.start = &first
+		}
+		p.skipSpace()
+		end, hasEnd, err := p.readInt()
+		if err != nil {
+			return seg, err
+		}
+		if hasEnd {
+			seg.end = &end
+		}
+		p.skipSpace()
+		if p.consume(':') {
+			p.skipSpace()
+			step, hasStep, err := p.readInt()
+			if err != nil {
+				return seg, err
+			}
+			if hasStep && step == 0 {
+				return seg, p.errorf("slice step must not be 0")
+			}
+			seg.step = step
+		}
+		return seg, nil
+	}
+
+	seg := pathSegment{kind: segmentIndex, indexes: []int{first}}
+	for p.consume(',') {
+		p.skipSpace()
+		index, ok, err := p.readInt()
+		if err != nil {
+			return seg, err
+		}
+		if !ok {
+			return seg, p.errorf("expected an index, found %s", p.found())
+		}
+		seg.indexes = append(seg.indexes, index)
+		p.skipSpace()
+	}
+	return seg, nil
+}
+
+// readInt reads an optionally negative integer, reporting whether there was
+// one.
+func (p *pathParser) readInt() (int, bool, error) {
+	start := p.pos
+	p.consume('-')
+	for !p.done() && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, false, p.errorf("invalid index %q", p.src[start:p.pos])
+	}
+	return n, true, nil
+}
+
+// readQuoted reads a string quoted with ' or ", whose backslashes escape
+// the next character.
+func (p *pathParser) readQuoted() (string, error) {
+	start := p.pos
+	quote := p.src[p.pos]
+	if quote != '\'' && quote != '"' {
+		return "", p.errorf("expected a quoted name, found %s", p.found())
+	}
+	p.pos++
+	var value strings.Builder
+	for !p.done() {
+		c := p.src[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return value.String(), nil
+		case c == '\\' && !p.done():
+			value.WriteByte(p.src[p.pos])
+			p.pos++
+		default:
+			value.WriteByte(c)
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *pathParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consumeString("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+}
+
+func (p *pathParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consumeString("&&") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+}
+
+func (p *pathParser) parseUnary() (filterExpr, error) {
+	p.skipSpace()
+	if p.peek() == '!' && !strings.HasPrefix(p.src[p.pos:], "!=") {
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{expr}, nil
+	}
+	if p.consume('(') {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(')') {
+			return nil, p.errorf("expected \")\", found %s", p.found())
+		}
+		return expr, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consumeString(op) {
+			p.skipSpace()
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return filterCompare{op: op, left: left, right: right}, nil
+		}
+	}
+	if left.path == nil {
+		return nil, p.errorf("expected a comparison after a literal, found %s", p.found())
+	}
+	return filterExists{left}, nil
+}
+
+func (p *pathParser) parseOperand() (filterOperand, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		segments, err := p.parseSegments(true)
+		if err != nil {
+			return filterOperand{}, err
+		}
+		return filterOperand{path: &jsonPath{segments: segments}, relative: c == '@'}, nil
+	case c == '\'' || c == '"':
+		s, err := p.readQuoted()
+		return filterOperand{literal: s}, err
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for !p.done() && strings.IndexByte("+-.eE0123456789", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			p.pos = start
+			return filterOperand{}, p.errorf("invalid number %q", p.src[start:p.pos])
+		}
+		return filterOperand{literal: n}, nil
+	}
+	for _, word := range []string{"true", "false", "null"} {
+		if p.consumeString(word) {
+			var literal any
+			if word != "null" {
+				literal = word == "true"
+			}
+			return filterOperand{literal: literal}, nil
+		}
 	}
-	m[keys[0]] = child
-	setNested(child, keys[1:], value)
+	return filterOperand{}, p.errorf("expected a path, string, number, true, false or null, found %s", p.found())
 }
 
 // evaluateCondition evaluates a single ChoiceRule condition against the given
-// JSON document and returns true when the condition is satisfied.
-func evaluateCondition(data []byte, rule ChoiceRuleEval) (bool, error) {
+// JSON document and returns true when the condition is satisfied. Variables
+// starting "$$" refer to contextObject.
+func evaluateCondition(data, contextObject []byte, rule ChoiceRuleEval) (bool, error) {
 	// Logical combinators.
 	if len(rule.And) > 0 {
 		for _, sub := range rule.And {
-			ok, err := evaluateCondition(data, sub)
+			ok, err := evaluateCondition(data, contextObject, sub)
 			if err != nil {
 				return false, err
 			}
@@ -130,7 +883,7 @@ func evaluateCondition(data []byte, rule ChoiceRuleEval) (bool, error) {
 
 	if len(rule.Or) > 0 {
 		for _, sub := range rule.Or {
-			ok, err := evaluateCondition(data, sub)
+			ok, err := evaluateCondition(data, contextObject, sub)
 			if err != nil {
 				return false, err
 			}
@@ -142,7 +895,7 @@ func evaluateCondition(data []byte, rule ChoiceRuleEval) (bool, error) {
 	}
 
 	if rule.Not != nil {
-		ok, err := evaluateCondition(data, *rule.Not)
+		ok, err := evaluateCondition(data, contextObject, *rule.Not)
 		if err != nil {
 			return false, err
 		}
@@ -154,7 +907,7 @@ func evaluateCondition(data []byte, rule ChoiceRuleEval) (bool, error) {
 		return false, fmt.Errorf("choice rule has no Variable set")
 	}
 
-	rawVar, err := applyPath(data, rule.Variable)
+	rawVar, err := applyContextPath(data, contextObject, rule.Variable)
 	if err != nil {
 		return false, fmt.Errorf("choice rule variable %q: %w", rule.Variable, err)
 	}
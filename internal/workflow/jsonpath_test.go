@@ -0,0 +1,177 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nyambati/simla/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const storeJSON = `{
+	"store": {
+		"book": [
+			{"category": "reference", "author": "Nigel Rees", "title": "Sayings", "price": 8.95},
+			{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword", "price": 12.99},
+			{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553", "price": 8.99},
+			{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord", "isbn": "0-395", "price": 22.99}
+		],
+		"bicycle": {"color": "red", "price": 19.95}
+	},
+	"expensive": 10,
+	"odd key": {"a.b": 1},
+	"matrix": [[1, 2], [3, 4]]
+}`
+
+func TestApplyPath(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{`$`, storeJSON},
+		{`$.store.bicycle.color`, `"red"`},
+		{`$.store.book[0].author`, `"Nigel Rees"`},
+		{`$.store.book[-1].title`, `"The Lord"`},
+		{`$.matrix[1][0]`, `3`},
+		{`$['odd key']['a.b']`, `1`},
+		{`$["odd key"]`, `{"a.b":1}`},
+		{`$.store.book[*].author`, `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`},
+		{`$.store.book.*.price`, `[8.95,12.99,8.99,22.99]`},
+		{`$.store.book[0,2].title`, `["Sayings","Moby Dick"]`},
+		{`$.store.book[1:3].title`, `["Sword","Moby Dick"]`},
+		{`$.store.book[:2].title`, `["Sayings","Sword"]`},
+		{`$.store.book[-2:].title`, `["Moby Dick","The Lord"]`},
+		{`$.store.book[::2].title`, `["Sayings","Moby Dick"]`},
+		{`$.store.book[::-1].price`, `[22.99,8.99,12.99,8.95]`},
+		{`$.store.bicycle['color','price']`, `["red",19.95]`},
+		{`$.store.*`, `[{"color":"red","price":19.95},[
+			{"category":"reference","author":"Nigel Rees","title":"Sayings","price":8.95},
+			{"category":"fiction","author":"Evelyn Waugh","title":"Sword","price":12.99},
+			{"category":"fiction","author":"Herman Melville","title":"Moby Dick","isbn":"0-553","price":8.99},
+			{"category":"fiction","author":"J. R. R. Tolkien","title":"The Lord","isbn":"0-395","price":22.99}
+		]]`},
+		{`$..author`, `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`},
+		{`$.store..price`, `[19.95,8.95,12.99,8.99,22.99]`},
+		{`$..book[2].title`, `["Moby Dick"]`},
+		{`$.store.book[?(@.price > 10)].title`, `["Sword","The Lord"]`},
+		{`$.store.book[?(@.price<10)].title`, `["Sayings","Moby Dick"]`},
+		{`$.store.book[?(@.isbn)].title`, `["Moby Dick","The Lord"]`},
+		{`$.store.book[?(!@.isbn)].title`, `["Sayings","Sword"]`},
+		{`$.store.book[?(@.category == 'fiction' && @.price < 20)].title`, `["Sword","Moby Dick"]`},
+		{`$.store.book[?(@.author == "Nigel Rees" || @.price >= 22.99)].title`, `["Sayings","The Lord"]`},
+		{`$.store.book[?(@.price > $.expensive)].title`, `["Sword","The Lord"]`},
+		{`$.store.book[?(@['category'] != 'fiction')].title`, `["Sayings"]`},
+		{`$.store.book[?((@.price < 9 || @.price > 20) && @.isbn)].title`, `["Moby Dick","The Lord"]`},
+		{`$.store.book[?(@.missing > 1)]`, `[]`},
+		{`$.store.book[9:].title`, `[]`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			out, err := applyPath([]byte(storeJSON), tc.path)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.want, string(out))
+		})
+	}
+}
+
+func TestApplyPath_Errors(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{`$.store.missing`, `key "missing" not found`},
+		{`$.store.book[10]`, `index 10 is out of range for an array of length 4`},
+		{`$.store.bicycle[0]`, `expected array at index 0, got object`},
+		{`$.expensive.value`, `expected object at segment "value", got number`},
+		{`store.book`, `must start with "$"`},
+		{`$.store.`, `expected a name after "."`},
+		{`$.store[`, `expected an index, a slice, a quoted name, "*" or a filter, found the end of the path`},
+		{`$.store['book`, `unterminated string`},
+		{`$.store.book[0`, `expected "]", found the end of the path`},
+		{`$.store.book[::0]`, `slice step must not be 0`},
+		{`$.store.book[?(@.price > )]`, `expected a path, string, number, true, false or null`},
+		{`$.store.book[?(@.price > 10]`, `expected ")" to close the filter`},
+		{`$.store.book[?('a')]`, `expected a comparison after a literal`},
+		{`$x`, `unexpected "x" at position 2`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			_, err := applyPath([]byte(storeJSON), tc.path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.want)
+		})
+	}
+}
+
+func TestMergePath(t *testing.T) {
+	cases := []struct {
+		name       string
+		input      string
+		resultPath string
+		want       string
+	}{
+		{"root replaces input", `{"a":1}`, `$`, `{"r":true}`},
+		{"empty discards result", `{"a":1}`, ``, `{"a":1}`},
+		{"new key", `{"a":1}`, `$.b`, `{"a":1,"b":{"r":true}}`},
+		{"missing intermediates are created", `{}`, `$.a.b`, `{"a":{"b":{"r":true}}}`},
+		{"array element field", `{"items":[{"id":1},{"id":2}]}`, `$.items[1].result`, `{"items":[{"id":1},{"id":2,"result":{"r":true}}]}`},
+		{"negative index", `{"items":[1,2]}`, `$.items[-1]`, `{"items":[1,{"r":true}]}`},
+		{"bracket-quoted key", `{}`, `$['a b']`, `{"a b":{"r":true}}`},
+		{"null input", `null`, `$.a`, `{"a":{"r":true}}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := mergePath([]byte(tc.input), []byte(`{"r":true}`), tc.resultPath)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.want, string(out))
+		})
+	}
+}
+
+func TestMergePath_Errors(t *testing.T) {
+	cases := []struct {
+		name       string
+		input      string
+		resultPath string
+		match      bool
+		want       string
+	}{
+		{"array input", `[1,2]`, `$.a`, true, `cannot set key "a" in array`},
+		{"string intermediate", `{"a":"x"}`, `$.a.b`, true, `cannot set key "b" in string`},
+		{"index out of range", `{"items":[]}`, `$.items[0]`, true, `index 0 is out of range for an array of length 0`},
+		{"index into object", `{"items":{}}`, `$.items[0]`, true, `cannot set index 0 in object`},
+		{"wildcard", `{}`, `$.items[*]`, false, `must be a reference path`},
+		{"filter", `{}`, `$.items[?(@.a)]`, false, `must be a reference path`},
+		{"recursive descent", `{}`, `$..a`, false, `must be a reference path`},
+		{"slice", `{}`, `$.a[0:1]`, false, `must be a reference path`},
+		{"syntax", `{}`, `$.a[`, false, `invalid path`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := mergePath([]byte(tc.input), []byte(`1`), tc.resultPath)
+			require.Error(t, err)
+			assert.Equal(t, tc.match, errors.Is(err, errResultPathMatch))
+			assert.Contains(t, err.Error(), tc.want)
+		})
+	}
+}
+
+func TestExecute_ResultPathMatchFailure(t *testing.T) {
+	sm := config.StateMachine{
+		Name:    "result-path-match",
+		StartAt: "set",
+		States: map[string]config.State{
+			"set": {Type: "Pass", Result: map[string]any{"ok": true}, ResultPath: "$.status.code", End: true},
+		},
+	}
+	ex := NewExecutor(buildCfg(sm), nil, newLogger())
+	_, err := ex.Execute(context.Background(), "result-path-match", []byte(`{"status":"done"}`))
+	require.Error(t, err)
+	assert.Equal(t, ErrResultPathNull, classifyError(err))
+
+	out, err := ex.Execute(context.Background(), "result-path-match", []byte(`{"status":{}}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":{"code":{"ok":true}}}`, string(out))
+}
@@ -0,0 +1,276 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nyambati/simla/internal/config"
+	simlaerrors "github.com/nyambati/simla/internal/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ---------------------------------------------------------------------------
+// Map state
+// ---------------------------------------------------------------------------
+
+// executeMap runs the state's item processor for every item of the array at
+// ItemsPath, or read by its ItemReader, and collects their outputs, in item
+// order, into an array. In DISTRIBUTED mode every item, or batch of items,
+// runs as a child execution of a map run.
+func (e *Executor) executeMap(
+	ctx context.Context,
+	workflowName, stateName string,
+	state *config.State,
+	input []byte,
+	logger *logrus.Entry,
+) (*stateResult, error) {
+	processor := state.Processor()
+	if processor == nil {
+		return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName, "Map state has no ItemProcessor")
+	}
+	distributed := strings.EqualFold(processor.ProcessorConfig.Mode, MapModeDistributed)
+	if !distributed && (state.ItemReader != nil || state.ItemBatcher != nil || state.ResultWriter != nil) {
+		return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName,
+			"ItemReader, ItemBatcher and ResultWriter need ProcessorConfig.Mode DISTRIBUTED")
+	}
+	machine := processor.StateMachine
+	if machine.Name == "" {
+		machine.Name = stateName
+	}
+
+	effective, err := selectPath(ctx, input, state.InputPath)
+	if err != nil {
+		return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName, fmt.Sprintf("InputPath error: %v", err))
+	}
+	inputs, err := mapInputs(ctx, state, effective)
+	if err != nil {
+		return nil, dataError(workflowName, err)
+	}
+
+	output, err := e.withRetry(ctx, workflowName, stateName, state.Retry, logger, func() ([]byte, error) {
+		if distributed {
+			return e.runMapRun(ctx, workflowName, stateName, state, &machine, effective, inputs, logger)
+		}
+		results, err := e.runItems(ctx, workflowName, stateName, state, inputs, logger,
+			func(ctx context.Context, i int, input []byte) ([]byte, error) {
+				return e.runMachine(ctx, &machine, input, logger.WithField("item", i))
+			})
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(results)
+	})
+	if err != nil {
+		if len(state.Catch) > 0 {
+			next, catchOutput, matched := e.tryCatch(state.Catch, err, input)
+			if matched {
+				logger.WithError(err).Infof("error caught, transitioning to %s", next)
+				return &stateResult{output: catchOutput, nextState: next}, nil
+			}
+		}
+		return nil, err
+	}
+	output, err = applyTemplate(state.ResultSelector, output, contextObject(ctx))
+	if err != nil {
+		return nil, dataError(workflowName, fmt.Errorf("ResultSelector error: %w", err))
+	}
+
+	resultPath := state.ResultPath
+	if resultPath == "" {
+		resultPath = "$"
+	}
+	merged, err := mergePath(input, output, resultPath)
+	if err != nil {
+		return nil, resultPathError(workflowName, stateName, err)
+	}
+	filtered, err := selectPath(ctx, merged, state.OutputPath)
+	if err != nil {
+		return nil, simlaerrors.NewWorkflowStateError(workflowName, stateName, fmt.Sprintf("OutputPath error: %v", err))
+	}
+
+	return &stateResult{output: filtered, nextState: state.Next, end: state.End}, nil
+}
+
+// mapInputs returns the input of every iteration of a Map state: its items,
+// from ItemsPath or the ItemReader, through ItemSelector and, when the state
+// has one, grouped by its ItemBatcher.
+func mapInputs(ctx context.Context, state *config.State, effective []byte) ([][]byte, error) {
+	contextObject := contextObject(ctx)
+	var items []json.RawMessage
+	var err error
+	if state.ItemReader != nil {
+		items, err = readItems(state.ItemReader, effective, contextObject)
+	} else {
+		items, err = mapItems(effective, contextObject, state.ItemsPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Parameters is the name of ItemSelector in older definitions.
+	selector := state.ItemSelector
+	if selector == nil {
+		selector = state.Parameters
+	}
+	selected := make([]json.RawMessage, len(items))
+	for i, item := range items {
+		if selected[i], err = selectItem(stateContextFrom(ctx), selector, effective, i, item); err != nil {
+			return nil, err
+		}
+	}
+	if state.ItemBatcher != nil {
+		return batchItems(state.ItemBatcher, selected, effective, contextObject)
+	}
+	inputs := make([][]byte, len(selected))
+	for i, item := range selected {
+		inputs[i] = item
+	}
+	return inputs, nil
+}
+
+// runItems calls run for every input, at most MaxConcurrency at once, and
+// returns their outputs in order. Failed items tolerated by the state hold
+// an error object in their place. Once the failures exceed what the state
+// tolerates, items still running are cancelled and the Map fails.
+func (e *Executor) runItems(
+	ctx context.Context,
+	workflowName, stateName string,
+	state *config.State,
+	inputs [][]byte,
+	logger *logrus.Entry,
+	run func(ctx context.Context, i int, input []byte) ([]byte, error),
+) ([]json.RawMessage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	limit := state.MaxConcurrency
+	if limit <= 0 || limit > len(inputs) {
+		limit = len(inputs)
+	}
+	slots := make(chan struct{}, max(limit, 1))
+
+	results := make([]json.RawMessage, len(inputs))
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		failed  int
+		failure error
+	)
+
+	for i, input := range inputs {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			output, err := run(ctx, i, input)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if failure != nil {
+				return
+			}
+			if err == nil {
+				results[i] = rawOrNull(output)
+				return
+			}
+			failed++
+			results[i] = errorObject(err)
+			if !exceedsTolerance(state, failed, len(inputs)) {
+				logger.WithError(err).WithField("item", i).Warn("item failed within the tolerated failures")
+				return
+			}
+			failure = err
+			if state.ToleratedFailureCount > 0 || state.ToleratedFailurePercentage > 0 {
+				failure = simlaerrors.NewWorkflowExecutionError(workflowName, ErrExceedToleratedFailureThreshold,
+					fmt.Sprintf("%d of %d items failed: %v", failed, len(inputs), err))
+			}
+			cancel()
+		}()
+	}
+	wg.Wait()
+
+	if failure != nil {
+		return results, failure
+	}
+	if err := ctx.Err(); err != nil {
+		return results, simlaerrors.NewWorkflowTimeoutError(workflowName, stateName)
+	}
+	return results, nil
+}
+
+// mapItems returns the elements of the array at itemsPath of data or, for
+// a path starting "$$", of contextObject.
+func mapItems(data, contextObject []byte, itemsPath string) ([]json.RawMessage, error) {
+	raw, err := applyContextPath(data, contextObject, itemsPath)
+	if err != nil {
+		return nil, fmt.Errorf("ItemsPath error: %w", err)
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("ItemsPath %q does not reference an array", itemsPath)
+	}
+	return items, nil
+}
+
+// selectItem returns the input of the item at index: the item itself, or
+// the result of selector when the state has one. The selector's context
+// object is that of the Map state, sc, with Map.Item set to the item.
+func selectItem(sc *stateContext, selector map[string]any, effective []byte, index int, item json.RawMessage) (json.RawMessage, error) {
+	if selector == nil {
+		return item, nil
+	}
+	contextObject := sc.encode(map[string]any{"Index": index, "Value": item})
+	value, err := resolveTemplate(selector, effective, contextObject)
+	if err != nil {
+		return nil, fmt.Errorf("ItemSelector error: %w", err)
+	}
+	return json.Marshal(value)
+}
+
+// exceedsTolerance reports whether failed of total items is more than state
+// tolerates. Without ToleratedFailureCount or ToleratedFailurePercentage no
+// failure is tolerated.
+func exceedsTolerance(state *config.State, failed, total int) bool {
+	count, percentage := state.ToleratedFailureCount, state.ToleratedFailurePercentage
+	switch {
+	case failed == 0:
+		return false
+	case count <= 0 && percentage <= 0:
+		return true
+	case count > 0 && failed > count:
+		return true
+	case percentage > 0 && float64(failed)*100/float64(total) > percentage:
+		return true
+	default:
+		return false
+	}
+}
+
+// errorObject returns the Error and Cause object that stands for a failed
+// item in the output of a Map state.
+func errorObject(err error) json.RawMessage {
+	obj, _ := json.Marshal(map[string]string{
+		"Error": classifyError(err),
+		"Cause": err.Error(),
+	})
+	return obj
+}
+
+func rawOrNull(data []byte) json.RawMessage {
+	if data == nil {
+		return json.RawMessage("null")
+	}
+	return data
+}
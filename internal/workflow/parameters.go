@@ -0,0 +1,104 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	simlaerrors "github.com/nyambati/simla/internal/errors"
+)
+
+// ---------------------------------------------------------------------------
+// Parameters, ResultSelector and ItemSelector
+// ---------------------------------------------------------------------------
+
+// applyTemplate returns template resolved against data as JSON, or data
+// itself when there is no template.
+func applyTemplate(template map[string]any, data, contextObject []byte) ([]byte, error) {
+	if template == nil {
+		return data, nil
+	}
+	resolved, err := resolveTemplate(template, data, contextObject)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resolved)
+}
+
+// resolveTemplate returns template with every field whose key ends in ".$"
+// replaced by the value of its intrinsic function or path: of context for
+// paths starting "$$", of data otherwise. Nested objects and arrays are
+// resolved too.
+func resolveTemplate(template any, data, contextObject []byte) (any, error) {
+	switch t := template.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for key, value := range t {
+			if name, ok := strings.CutSuffix(key, ".$"); ok {
+				expr, ok := value.(string)
+				if !ok {
+					return nil, fmt.Errorf("field %q must be a path or an intrinsic function", key)
+				}
+				resolved, err := resolveField(expr, data, contextObject)
+				if err != nil {
+					return nil, fmt.Errorf("field %q: %w", key, err)
+				}
+				out[name] = resolved
+				continue
+			}
+			resolved, err := resolveTemplate(value, data, contextObject)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = resolved
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(t))
+		for i, value := range t {
+			resolved, err := resolveTemplate(value, data, contextObject)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	default:
+		return template, nil
+	}
+}
+
+// resolveField returns the value of the ".$" field expr.
+func resolveField(expr string, data, contextObject []byte) (any, error) {
+	if isIntrinsic(expr) {
+		return evaluateIntrinsic(expr, data, contextObject)
+	}
+	return resolvePath(expr, data, contextObject)
+}
+
+// resolvePath returns the value at path: of contextObject for paths
+// starting "$$", of data otherwise.
+func resolvePath(path string, data, contextObject []byte) (any, error) {
+	raw, err := applyContextPath(data, contextObject, path)
+	if err != nil {
+		return nil, err
+	}
+	var resolved any
+	if err := json.Unmarshal(raw, &resolved); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// dataError returns the error that fails a state whose data cannot be
+// shaped: States.IntrinsicFailure when an intrinsic function failed,
+// States.Runtime otherwise.
+func dataError(workflowName string, err error) error {
+	name := ErrRuntime
+	var intrinsic *intrinsicError
+	if errors.As(err, &intrinsic) {
+		name = ErrIntrinsicFailure
+	}
+	return simlaerrors.NewWorkflowExecutionError(workflowName, name, err.Error())
+}
@@ -20,6 +20,9 @@ const (
 	ExecutionStatusFailed    ExecutionStatus = "FAILED"
 	ExecutionStatusTimedOut  ExecutionStatus = "TIMED_OUT"
 	ExecutionStatusAborted   ExecutionStatus = "ABORTED"
+	// ExecutionStatusPending marks the child executions of a map run that
+	// never started, because the run failed first.
+	ExecutionStatusPending ExecutionStatus = "PENDING"
 )
 
 // Standard AWS Step Functions error names used in Retry/Catch matchers.
@@ -33,6 +36,20 @@ const (
 	ErrNoChoiceMatched                 = "States.NoChoiceMatched"
 	ErrIntrinsicFailure                = "States.IntrinsicFailure"
 	ErrExceedToleratedFailureThreshold = "States.ExceedToleratedFailureThreshold"
+	ErrRuntime                         = "States.Runtime"
+)
+
+// Processing modes of a Map state.
+const (
+	MapModeInline      = "INLINE"
+	MapModeDistributed = "DISTRIBUTED"
+)
+
+// Resources of the ItemReader and ResultWriter of a distributed Map state.
+const (
+	ResourceS3GetObject     = "arn:aws:states:::s3:getObject"
+	ResourceS3ListObjectsV2 = "arn:aws:states:::s3:listObjectsV2"
+	ResourceS3PutObject     = "arn:aws:states:::s3:putObject"
 )
 
 // ExecutorInterface is the primary interface for running workflows.
@@ -45,14 +62,26 @@ type ExecutorInterface interface {
 // Execution holds the runtime state of a single workflow run.
 type Execution struct {
 	ID           string
+	ARN          string
 	WorkflowName string
-	Status       ExecutionStatus
-	Input        []byte
-	Output       []byte
-	StartedAt    time.Time
-	StoppedAt    time.Time
-	Error        string
-	Cause        string
+	// StateMachineARN identifies the state machine the execution runs: the
+	// workflow or, for a child execution of a map run, its item processor.
+	StateMachineARN string
+	Status          ExecutionStatus
+	Input           []byte
+	Output          []byte
+	StartedAt       time.Time
+	StoppedAt       time.Time
+	Error           string
+	Cause           string
+}
+
+// MapRun holds the child executions of a distributed Map state, one per
+// item or batch of items, in item order.
+type MapRun struct {
+	ID         string
+	ARN        string
+	Executions []*Execution
 }
 
 // Executor is the concrete implementation of ExecutorInterface.